@@ -0,0 +1,94 @@
+package cassette
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
+)
+
+var (
+	ipv4Pattern  = regexp.MustCompile(`\b\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}\b`)
+	ipv6Pattern  = regexp.MustCompile(`(?i)(\b|::)([0-9a-f]{0,4}:){2,7}[0-9a-f]{0,4}\b`)
+	emailPattern = regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)
+)
+
+// Anonymized values are allocated from ranges reserved for documentation or
+// future use, so they never clash with real addresses and are not anonymized
+// again when a cassette is saved multiple times.
+var (
+	anonymizedIPv4Prefix = netip.MustParsePrefix("240.0.0.0/4")
+	anonymizedIPv6Prefix = netip.MustParsePrefix("2001:db8::/32")
+	anonymizedEmailHost  = "example.com"
+)
+
+// AnonymizeIPs returns a [Normalizer] which replaces IPv4 and IPv6 addresses
+// with deterministic fake addresses. The same address is always replaced with
+// the same fake one, which preserves relationships between interactions.
+// Loopback and unspecified addresses are left intact.
+//
+// Note, that this is pseudonymization, and not a cryptographic guarantee.
+func AnonymizeIPs() Normalizer {
+	return func(s string) string {
+		s = ipv4Pattern.ReplaceAllStringFunc(s, anonymizeIP)
+		return ipv6Pattern.ReplaceAllStringFunc(s, anonymizeIP)
+	}
+}
+
+// AnonymizeEmails returns a [Normalizer] which replaces email addresses with
+// deterministic fake addresses in the example.com domain.
+func AnonymizeEmails() Normalizer {
+	return func(s string) string {
+		return emailPattern.ReplaceAllStringFunc(s, func(email string) string {
+			_, host, _ := strings.Cut(email, "@")
+			if strings.EqualFold(host, anonymizedEmailHost) {
+				return email
+			}
+			sum := sha256.Sum256([]byte(strings.ToLower(email)))
+			return fmt.Sprintf("user-%x@%s", sum[:4], anonymizedEmailHost)
+		})
+	}
+}
+
+func anonymizeIP(s string) string {
+	addr, err := netip.ParseAddr(s)
+	if err != nil || addr.IsLoopback() || addr.IsUnspecified() {
+		return s
+	}
+
+	if anonymizedIPv4Prefix.Contains(addr) || anonymizedIPv6Prefix.Contains(addr) {
+		return s
+	}
+
+	sum := sha256.Sum256(addr.AsSlice())
+	if addr.Is4() {
+		return netip.AddrFrom4([4]byte{0xf0 | sum[0]&0x0f, sum[1], sum[2], sum[3]}).String()
+	}
+
+	var b [16]byte
+	copy(b[:], []byte{0x20, 0x01, 0x0d, 0xb8})
+	copy(b[4:], sum[:12])
+	return netip.AddrFrom16(b).String()
+}
+
+// AnonymizeInteraction replaces IP addresses and email addresses in the
+// remote address, headers, forms and bodies of the interaction with
+// deterministic fake values. The request URL and host are left intact.
+func AnonymizeInteraction(i *Interaction) {
+	n := ChainNormalizers(AnonymizeIPs(), AnonymizeEmails())
+
+	i.Request.RemoteAddr = n(i.Request.RemoteAddr)
+	normalizeHeader(i.Request.Headers, n)
+	normalizeHeader(i.Request.Trailer, n)
+	i.Request.Body, i.Request.ContentLength = normalizeBody(i.Request.Body, i.Request.ContentLength, i.Request.Headers, n)
+	for _, values := range i.Request.Form {
+		for idx, v := range values {
+			values[idx] = n(v)
+		}
+	}
+
+	normalizeHeader(i.Response.Headers, n)
+	normalizeHeader(i.Response.Trailer, n)
+	i.Response.Body, i.Response.ContentLength = normalizeBody(i.Response.Body, i.Response.ContentLength, i.Response.Headers, n)
+}
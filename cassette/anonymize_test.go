@@ -0,0 +1,52 @@
+package cassette
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAnonymizeInteraction(t *testing.T) {
+	newInteraction := func() *Interaction {
+		return &Interaction{
+			Request: Request{
+				URL:        "http://127.0.0.1:8080/users",
+				RemoteAddr: "203.0.113.7:51234",
+				Headers:    http.Header{"X-Forwarded-For": {"198.51.100.23, 2001:4860:4860::8888"}},
+			},
+			Response: Response{
+				Body: `{"email":"Jane.Doe@corp.io","ip":"198.51.100.23","at":"12:34:56"}`,
+			},
+		}
+	}
+
+	i := newInteraction()
+	AnonymizeInteraction(i)
+
+	for _, leaked := range []string{"203.0.113.7", "198.51.100.23", "2001:4860:4860::8888", "Jane.Doe@corp.io"} {
+		all := i.Request.RemoteAddr + i.Request.Headers.Get("X-Forwarded-For") + i.Response.Body
+		if strings.Contains(all, leaked) {
+			t.Fatalf("%q was not anonymized", leaked)
+		}
+	}
+
+	if !strings.HasSuffix(i.Request.RemoteAddr, ":51234") {
+		t.Fatalf("remote port should be preserved, got %q", i.Request.RemoteAddr)
+	}
+
+	if i.Request.URL != "http://127.0.0.1:8080/users" {
+		t.Fatalf("request URL should be left intact, got %q", i.Request.URL)
+	}
+
+	if !strings.Contains(i.Response.Body, `"at":"12:34:56"`) {
+		t.Fatalf("non-address values should be left intact, got %q", i.Response.Body)
+	}
+
+	// Anonymization is deterministic and idempotent.
+	other := newInteraction()
+	AnonymizeInteraction(other)
+	AnonymizeInteraction(other)
+	if other.Response.Body != i.Response.Body || other.Request.RemoteAddr != i.Request.RemoteAddr {
+		t.Fatalf("anonymization is not deterministic: %q != %q", other.Response.Body, i.Response.Body)
+	}
+}
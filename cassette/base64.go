@@ -0,0 +1,43 @@
+package cassette
+
+import (
+	"encoding/base64"
+	"fmt"
+)
+
+// BodyEncodingBase64 is the encoding of response bodies, which are stored
+// base64-encoded, see [Base64EncodeResponseBody].
+const BodyEncodingBase64 = "base64"
+
+// Base64EncodeResponseBody encodes the response body of the interaction
+// using base64, so that binary bodies, e.g. images, are stored as plain
+// text in the cassette. The encoding is kept in the BodyEncoding field of
+// the response, and the body is decoded again on replay.
+//
+// Responses without a body, which were already encoded, or whose body was
+// not recorded, are left as is.
+func Base64EncodeResponseBody(i *Interaction) error {
+	if i.Response.Body == "" || i.Response.BodyEncoding != "" || i.Response.BodyDigest != "" {
+		return nil
+	}
+
+	i.Response.Body = base64.StdEncoding.EncodeToString([]byte(i.Response.Body))
+	i.Response.BodyEncoding = BodyEncodingBase64
+
+	return nil
+}
+
+// decodeResponseBody decodes a response body, which was stored using the
+// given encoding, see [Base64EncodeResponseBody].
+func decodeResponseBody(body, encoding string) (string, error) {
+	if encoding != BodyEncodingBase64 {
+		return "", fmt.Errorf("unsupported body encoding %q", encoding)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode %s body: %w", encoding, err)
+	}
+
+	return string(data), nil
+}
@@ -0,0 +1,44 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestBase64EncodeResponseBody(t *testing.T) {
+	body := "\x89PNG\r\n\x1a\n\x00\x01"
+	i := &Interaction{
+		Request: Request{Method: http.MethodGet, URL: "https://example.com/logo.png"},
+		Response: Response{
+			Code:          http.StatusOK,
+			ContentLength: int64(len(body)),
+			Headers:       http.Header{"Content-Type": {"image/png"}},
+			Body:          body,
+		},
+	}
+
+	if err := Base64EncodeResponseBody(i); err != nil {
+		t.Fatal(err)
+	}
+	if want := "iVBORw0KGgoAAQ=="; i.Response.Body != want || i.Response.BodyEncoding != BodyEncodingBase64 {
+		t.Fatalf("got body %q encoded as %q, want %q", i.Response.Body, i.Response.BodyEncoding, want)
+	}
+
+	// Encoding the body again leaves it as is
+	if err := Base64EncodeResponseBody(i); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := i.GetHTTPResponse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != body || resp.ContentLength != int64(len(body)) {
+		t.Fatalf("got replayed body %q of length %d, want %q", got, resp.ContentLength, body)
+	}
+}
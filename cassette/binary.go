@@ -0,0 +1,50 @@
+package cassette
+
+import (
+	"io"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// binaryCassette is the representation of a cassette in the binary CBOR
+// format.
+type binaryCassette struct {
+	Version      int            `cbor:"version"`
+	Interactions []*Interaction `cbor:"interactions"`
+}
+
+// binaryEncMode encodes cassettes deterministically, with the map keys
+// sorted, so that unchanged cassettes are encoded the same way.
+var binaryEncMode = func() cbor.EncMode {
+	opts := cbor.CoreDetEncOptions()
+	opts.Time = cbor.TimeRFC3339Nano
+	em, err := opts.EncMode()
+	if err != nil {
+		panic(err)
+	}
+	return em
+}()
+
+// encodeBinary writes the cassette in the binary CBOR format to w.
+func encodeBinary(w io.Writer, c *Cassette) error {
+	return binaryEncMode.NewEncoder(w).Encode(binaryCassette{
+		Version:      c.Version,
+		Interactions: c.Interactions,
+	})
+}
+
+// decodeBinary reads the cassette in the binary CBOR format from r.
+func decodeBinary(r io.Reader, c *Cassette) error {
+	var bc binaryCassette
+	if err := cbor.NewDecoder(r).Decode(&bc); err != nil {
+		return err
+	}
+
+	c.Version = bc.Version
+	c.Interactions = bc.Interactions
+	if c.Interactions == nil {
+		c.Interactions = make([]*Interaction, 0)
+	}
+
+	return nil
+}
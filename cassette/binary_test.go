@@ -0,0 +1,102 @@
+package cassette
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBinaryFormat(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "binary")
+
+	c := New(name)
+	for n := range 10 {
+		parentID := n - 1
+		i := &Interaction{
+			Request: Request{
+				Proto: "HTTP/1.1", ProtoMajor: 1, ProtoMinor: 1,
+				Method:  http.MethodPost,
+				URL:     fmt.Sprintf("https://example.com/users/%d", n),
+				Headers: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+				Body:    "name=go-vcr",
+				Form:    url.Values{"name": {"go-vcr"}},
+			},
+			Response: Response{
+				Proto: "HTTP/1.1", ProtoMajor: 1, ProtoMinor: 1,
+				Code:     http.StatusOK,
+				Status:   "200 OK",
+				Headers:  http.Header{"Content-Type": {"application/json"}},
+				Body:     strings.Repeat(`{"id": 1, "name": "go-vcr"}`, 10),
+				Duration: time.Duration(n) * time.Millisecond,
+			},
+			RecordedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Metadata:   map[string]string{"test": "binary"},
+			Tags:       []string{"users"},
+		}
+		if n > 0 {
+			i.ParentID = &parentID
+		}
+		if err := c.AddInteraction(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	yamlInfo, err := os.Stat(name + ".yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Convert to the binary format, and load it transparently
+	if err := Convert(name+".yaml", name+".cbor", WithConvertRemoveSource()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(name + ".yaml"); !os.IsNotExist(err) {
+		t.Fatalf("expected YAML cassette to be removed, got %v", err)
+	}
+	binaryInfo, err := os.Stat(name + ".cbor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if binaryInfo.Size() >= yamlInfo.Size() {
+		t.Fatalf("got binary cassette of %d bytes, want less than YAML cassette of %d bytes", binaryInfo.Size(), yamlInfo.Size())
+	}
+
+	loaded, err := Load(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Binary {
+		t.Fatal("expected binary cassette")
+	}
+	if !reflect.DeepEqual(loaded.Interactions, c.Interactions) {
+		t.Fatalf("got interactions %+v, want %+v", loaded.Interactions, c.Interactions)
+	}
+
+	req, err := loaded.Interactions[3].GetHTTPRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i, err := loaded.GetInteraction(req); err != nil || i.ID != 3 {
+		t.Fatalf("got interaction %v and error %v, want interaction 3", i, err)
+	}
+
+	// Convert back to YAML
+	if err := Convert(name+".cbor", name+".yaml", WithConvertRemoveSource()); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err = Load(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Binary || !reflect.DeepEqual(loaded.Interactions, c.Interactions) {
+		t.Fatal("got different interactions after converting to YAML")
+	}
+}
@@ -0,0 +1,167 @@
+package cassette
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// replayableBody is a request body backed by a byte slice, which can be read
+// again without copying, e.g. for hashing and matching a request.
+type replayableBody struct {
+	*bytes.Reader
+	data []byte
+}
+
+// newReplayableBody returns a request body reading the given bytes.
+func newReplayableBody(data []byte) io.ReadCloser {
+	return &replayableBody{Reader: bytes.NewReader(data), data: data}
+}
+
+// Close implements io.Closer.
+func (b *replayableBody) Close() error {
+	return nil
+}
+
+// readBody returns the body of the request, and replaces it with a
+// replayable body, so that it can be read again. Bodies, which are
+// replayable already, are not copied.
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+
+	if b, ok := r.Body.(*replayableBody); ok {
+		r.Body = newReplayableBody(b.data)
+		return b.data, nil
+	}
+
+	var buf bytes.Buffer
+	if r.ContentLength > 0 {
+		buf.Grow(int(r.ContentLength))
+	}
+	if _, err := buf.ReadFrom(r.Body); err != nil {
+		return nil, err
+	}
+	r.Body.Close()
+	r.Body = newReplayableBody(buf.Bytes())
+
+	return buf.Bytes(), nil
+}
+
+// hashBody writes the body of the request to w, and replaces it with a
+// replayable body, so that it can be read again. The body is streamed into
+// w, while buffering it, unless it is replayable already.
+func hashBody(r *http.Request, w io.Writer) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	if b, ok := r.Body.(*replayableBody); ok {
+		r.Body = newReplayableBody(b.data)
+		_, err := w.Write(b.data)
+		return err
+	}
+
+	var buf bytes.Buffer
+	if r.ContentLength > 0 {
+		buf.Grow(int(r.ContentLength))
+	}
+	if _, err := io.Copy(w, io.TeeReader(r.Body, &buf)); err != nil {
+		return err
+	}
+	r.Body.Close()
+	r.Body = newReplayableBody(buf.Bytes())
+
+	return nil
+}
+
+// rewindBody replaces a replayable body of the request, which was read, e.g.
+// when parsing the form, with one reading it again from the start.
+func rewindBody(r *http.Request) {
+	if b, ok := r.Body.(*replayableBody); ok {
+		r.Body = newReplayableBody(b.data)
+	}
+}
+
+// zeroReader is an endless reader of zero bytes, which synthesizes bodies,
+// which were not recorded.
+type zeroReader struct{}
+
+// Read implements io.Reader.
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// DropResponseBody drops the response body of the interaction, and records
+// its SHA-256 digest and length in the BodyDigest and BodyLength fields of
+// the response instead, e.g. for videos, which are not worth storing in the
+// cassette. A body of the same length, which consists of zero bytes, is
+// replayed instead.
+//
+// Responses without a body, or whose body was dropped already, are left as
+// is.
+func DropResponseBody(i *Interaction) {
+	if i.Response.Body == "" || i.Response.BodyDigest != "" {
+		return
+	}
+
+	sum := sha256.Sum256([]byte(i.Response.Body))
+	i.Response.BodyDigest = "sha256:" + hex.EncodeToString(sum[:])
+	i.Response.BodyLength = int64(len(i.Response.Body))
+	i.Response.Body = ""
+	i.Response.BodyEncoding = ""
+}
+
+// RepairContentLength fixes up the content length of the response of the
+// interaction, so that it matches the length of the body, e.g. after hooks
+// modified the body. The Content-Length header is removed from chunked
+// responses. Bodies, which are stored encoded or pretty-printed, get their
+// length fixed up when converted, see [Interaction.GetHTTPResponse].
+//
+// Responses of unknown length, responses to HEAD requests, and responses,
+// which have no body as per their status code, are left as is.
+func RepairContentLength(i *Interaction) {
+	r := &i.Response
+	if slices.Contains(r.TransferEncoding, "chunked") {
+		r.ContentLength = -1
+		r.Headers.Del("Content-Length")
+		return
+	}
+
+	if i.Request.Method == http.MethodHead || !bodyAllowedForStatus(r.Code) {
+		return
+	}
+	if r.BodyEncoding != "" || r.PrettyPrinted != "" || r.DecodedContentEncoding != "" {
+		return
+	}
+	if r.ContentLength < 0 && r.Headers.Get("Content-Length") == "" {
+		return
+	}
+
+	n := int64(len(r.Body))
+	if r.BodyDigest != "" {
+		n = r.BodyLength
+	}
+	r.ContentLength = n
+	if r.Headers.Get("Content-Length") != "" {
+		r.Headers.Set("Content-Length", strconv.FormatInt(n, 10))
+	}
+}
+
+// bodyAllowedForStatus returns true, if responses with the status code may
+// have a body, as per RFC 9110, section 6.4.1.
+func bodyAllowedForStatus(code int) bool {
+	switch {
+	case code >= 100 && code < 200:
+		return false
+	case code == http.StatusNoContent, code == http.StatusNotModified:
+		return false
+	}
+	return true
+}
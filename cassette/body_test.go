@@ -0,0 +1,128 @@
+package cassette
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestHashBody(t *testing.T) {
+	body := "name=go-vcr&version=4"
+	r, err := http.NewRequest(http.MethodPost, "https://example.com/form", io.NopCloser(strings.NewReader(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// Hashing the request restores its body, including parsing its form,
+	// and hashes the buffered body the same way
+	first, err := DefaultMatcher.Hash(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.Body.(*replayableBody); !ok {
+		t.Fatalf("got body of type %T, want a replayable body", r.Body)
+	}
+	second, err := DefaultMatcher.Hash(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Fatalf("got hashes %q and %q, want identical hashes", first, second)
+	}
+	if got := r.PostForm.Get("name"); got != "go-vcr" {
+		t.Fatalf("got form value %q, want go-vcr", got)
+	}
+	if b, err := io.ReadAll(r.Body); err != nil || string(b) != body {
+		t.Fatalf("got body %q (%v), want %q", b, err, body)
+	}
+}
+
+func TestDropResponseBody(t *testing.T) {
+	i := &Interaction{
+		Request: Request{Method: http.MethodGet, URL: "https://example.com/video.mp4"},
+		Response: Response{
+			Code:          http.StatusOK,
+			ContentLength: 5,
+			Headers:       http.Header{"Content-Type": {"video/mp4"}},
+			Body:          "video",
+		},
+	}
+
+	DropResponseBody(i)
+	want := "sha256:0cab1c9617404faf2b24e221e189ca5945813e14d3f766345b09ca13bbe28ffc"
+	if i.Response.Body != "" || i.Response.BodyDigest != want || i.Response.BodyLength != 5 {
+		t.Fatalf("got body %q, digest %q and length %d, want the digest and length only", i.Response.Body, i.Response.BodyDigest, i.Response.BodyLength)
+	}
+
+	resp, err := i.GetHTTPResponse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "\x00\x00\x00\x00\x00" {
+		t.Fatalf("got replayed body %q, want 5 zero bytes", got)
+	}
+}
+
+func TestRepairContentLength(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		code       int
+		chunked    bool
+		length     int64
+		wantLength int64
+		wantHeader string
+	}{
+		{name: "modified body", method: http.MethodGet, code: http.StatusOK, length: 15, wantLength: 4, wantHeader: "4"},
+		{name: "chunked", method: http.MethodGet, code: http.StatusOK, chunked: true, length: 15, wantLength: -1},
+		{name: "HEAD request", method: http.MethodHead, code: http.StatusOK, length: 15, wantLength: 15, wantHeader: "15"},
+		{name: "no content", method: http.MethodGet, code: http.StatusNoContent, length: 15, wantLength: 15, wantHeader: "15"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			i := &Interaction{
+				Request: Request{Method: test.method},
+				Response: Response{
+					Code:          test.code,
+					Headers:       http.Header{"Content-Length": {"15"}},
+					Body:          "body",
+					ContentLength: test.length,
+				},
+			}
+			if test.chunked {
+				i.Response.TransferEncoding = []string{"chunked"}
+			}
+
+			RepairContentLength(i)
+			if i.Response.ContentLength != test.wantLength || i.Response.Headers.Get("Content-Length") != test.wantHeader {
+				t.Fatalf("got content length %d and header %q, want %d and %q", i.Response.ContentLength, i.Response.Headers.Get("Content-Length"), test.wantLength, test.wantHeader)
+			}
+		})
+	}
+}
+
+func BenchmarkHashLargeBody(b *testing.B) {
+	body := bytes.Repeat([]byte("go-vcr "), 1<<20)
+	r, err := http.NewRequest(http.MethodPut, "https://example.com/upload", bytes.NewReader(body))
+	if err != nil {
+		b.Fatal(err)
+	}
+	r.Body = newReplayableBody(body)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(body)))
+	b.ResetTimer()
+	for range b.N {
+		if _, err := DefaultMatcher.Hash(r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
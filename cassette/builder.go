@@ -0,0 +1,193 @@
+package cassette
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// InteractionBuilder builds interactions by hand, e.g. for fixtures crafted
+// in Go, populating the fields the same way as recording a request sent by
+// an [http.Client] does, so that the interactions are matched and replayed
+// like recorded ones. Create it using [NewInteraction].
+//
+//	i, err := cassette.NewInteraction().
+//		Request(http.MethodPost, "https://example.com/users").
+//		ReqBodyJSON(user).
+//		RespStatus(http.StatusCreated).
+//		RespBodyJSON(created).
+//		Build()
+//
+// Errors, e.g. of encoding a body, are returned by [InteractionBuilder.Build].
+type InteractionBuilder struct {
+	i   *Interaction
+	err error
+}
+
+// NewInteraction returns a new [InteractionBuilder] of an interaction,
+// which is a GET request over HTTP/1.1 answered with 200 OK, until
+// configured otherwise.
+func NewInteraction() *InteractionBuilder {
+	b := &InteractionBuilder{
+		i: &Interaction{
+			Request: Request{
+				Proto:      "HTTP/1.1",
+				ProtoMajor: 1,
+				ProtoMinor: 1,
+				Headers:    make(http.Header),
+				Method:     http.MethodGet,
+			},
+			Response: Response{
+				Proto:      "HTTP/1.1",
+				ProtoMajor: 1,
+				ProtoMinor: 1,
+				Headers:    make(http.Header),
+			},
+		},
+	}
+
+	return b.RespStatus(http.StatusOK).RespBody("")
+}
+
+// Request sets the method and URL of the request. The host of the request
+// is set to the host of the URL.
+func (b *InteractionBuilder) Request(method, rawURL string) *InteractionBuilder {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		b.setErr(fmt.Errorf("failed to parse request URL %s: %w", rawURL, err))
+		return b
+	}
+
+	b.i.Request.Method = method
+	b.i.Request.URL = u.String()
+	b.i.Request.Host = u.Host
+
+	return b
+}
+
+// ReqHeader adds the value to the request header.
+func (b *InteractionBuilder) ReqHeader(key, value string) *InteractionBuilder {
+	b.i.Request.Headers.Add(key, value)
+	return b
+}
+
+// ReqBody sets the body of the request, and its content length.
+func (b *InteractionBuilder) ReqBody(body string) *InteractionBuilder {
+	b.i.Request.Body = body
+	b.i.Request.ContentLength = int64(len(body))
+	return b
+}
+
+// ReqBodyJSON sets the body of the request to the JSON encoding of v, along
+// with its content length and an application/json Content-Type header.
+func (b *InteractionBuilder) ReqBodyJSON(v any) *InteractionBuilder {
+	data, err := json.Marshal(v)
+	if err != nil {
+		b.setErr(fmt.Errorf("failed to encode request body: %w", err))
+		return b
+	}
+
+	b.i.Request.Headers.Set("Content-Type", "application/json")
+	return b.ReqBody(string(data))
+}
+
+// ReqForm sets the body of the request to the URL encoded form values,
+// along with its content length, its form and an
+// application/x-www-form-urlencoded Content-Type header.
+func (b *InteractionBuilder) ReqForm(values url.Values) *InteractionBuilder {
+	b.i.Request.Headers.Set("Content-Type", "application/x-www-form-urlencoded")
+	b.i.Request.Form = values
+	return b.ReqBody(values.Encode())
+}
+
+// RespStatus sets the status code of the response, and the corresponding
+// status text, e.g. "404 Not Found".
+func (b *InteractionBuilder) RespStatus(code int) *InteractionBuilder {
+	b.i.Response.Code = code
+	b.i.Response.Status = fmt.Sprintf("%d %s", code, http.StatusText(code))
+	return b
+}
+
+// RespHeader adds the value to the response header.
+func (b *InteractionBuilder) RespHeader(key, value string) *InteractionBuilder {
+	b.i.Response.Headers.Add(key, value)
+	return b
+}
+
+// RespBody sets the body of the response, along with its content length and
+// Content-Length header.
+func (b *InteractionBuilder) RespBody(body string) *InteractionBuilder {
+	b.i.Response.Body = body
+	b.i.Response.ContentLength = int64(len(body))
+	b.i.Response.Headers.Set("Content-Length", strconv.Itoa(len(body)))
+	return b
+}
+
+// RespBodyJSON sets the body of the response to the JSON encoding of v,
+// along with its content length and an application/json Content-Type
+// header.
+func (b *InteractionBuilder) RespBodyJSON(v any) *InteractionBuilder {
+	data, err := json.Marshal(v)
+	if err != nil {
+		b.setErr(fmt.Errorf("failed to encode response body: %w", err))
+		return b
+	}
+
+	b.i.Response.Headers.Set("Content-Type", "application/json")
+	return b.RespBody(string(data))
+}
+
+// RespDuration sets the duration of the response, which is simulated on
+// replay, unless the request latency is skipped.
+func (b *InteractionBuilder) RespDuration(d time.Duration) *InteractionBuilder {
+	b.i.Response.Duration = d
+	return b
+}
+
+// Metadata sets the metadata value of the interaction.
+func (b *InteractionBuilder) Metadata(key, value string) *InteractionBuilder {
+	b.i.SetMetadata(key, value)
+	return b
+}
+
+// Build returns the interaction, or the errors, which occurred while
+// building it. The interaction is added to a cassette using
+// [Cassette.AddInteraction].
+func (b *InteractionBuilder) Build() (*Interaction, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if b.i.Request.URL == "" {
+		return nil, errors.New("interaction has no request URL")
+	}
+
+	return b.i.Clone(), nil
+}
+
+// setErr records the error, which is returned by [InteractionBuilder.Build].
+func (b *InteractionBuilder) setErr(err error) {
+	b.err = errors.Join(b.err, err)
+}
+
+// AddStub appends a synthetic interaction of a request with the given method
+// and URL, which is answered with the given status code and body, e.g. from
+// test setup code. The fields of the interaction are populated as described
+// in [InteractionBuilder]. The returned interaction may be customized
+// further, e.g. by adding response headers, while changes of its request
+// are not considered when matching, see [Cassette.ReplaceInteraction].
+func (c *Cassette) AddStub(method, url string, status int, body string) (*Interaction, error) {
+	i, err := NewInteraction().Request(method, url).RespStatus(status).RespBody(body).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.AddInteraction(i); err != nil {
+		return nil, err
+	}
+
+	return i, nil
+}
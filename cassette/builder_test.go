@@ -0,0 +1,129 @@
+package cassette
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInteractionBuilder(t *testing.T) {
+	type user struct {
+		ID   int    `json:"id,omitempty"`
+		Name string `json:"name"`
+	}
+
+	i, err := NewInteraction().
+		Request(http.MethodPost, "https://example.com/users").
+		ReqBodyJSON(user{Name: "go-vcr"}).
+		RespStatus(http.StatusCreated).
+		RespBodyJSON(user{ID: 1, Name: "go-vcr"}).
+		RespDuration(time.Millisecond).
+		Metadata("fixture", "users").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := New("builder")
+	if err := c.AddInteraction(i); err != nil {
+		t.Fatal(err)
+	}
+
+	// The interaction matches a request sent by a client
+	req, err := http.NewRequest(http.MethodPost, "https://example.com/users", strings.NewReader(`{"name":"go-vcr"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	got, err := c.GetInteraction(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := got.GetHTTPResponse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantBody := `{"id":1,"name":"go-vcr"}`
+	if string(body) != wantBody {
+		t.Fatalf("got body %q, want %q", body, wantBody)
+	}
+	if resp.StatusCode != http.StatusCreated || resp.Status != "201 Created" {
+		t.Fatalf("got status %q", resp.Status)
+	}
+	if resp.ContentLength != int64(len(wantBody)) || resp.Header.Get("Content-Length") != "24" {
+		t.Fatalf("got content length %d and header %q, want %d", resp.ContentLength, resp.Header.Get("Content-Length"), len(wantBody))
+	}
+	if resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("got content type %q", resp.Header.Get("Content-Type"))
+	}
+	if got.Response.Duration != time.Millisecond || got.Metadata["fixture"] != "users" {
+		t.Fatalf("got duration %s and metadata %v", got.Response.Duration, got.Metadata)
+	}
+
+	// Forms are matched as well
+	form := url.Values{"name": {"go-vcr"}}
+	i, err = NewInteraction().Request(http.MethodPost, "https://example.com/form").ReqForm(form).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddInteraction(i); err != nil {
+		t.Fatal(err)
+	}
+	req, err = http.NewRequest(http.MethodPost, "https://example.com/form", bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if _, err := c.GetInteraction(req); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInteractionBuilderErrors(t *testing.T) {
+	if _, err := NewInteraction().Build(); err == nil {
+		t.Fatal("expected interaction without URL to fail")
+	}
+	if _, err := NewInteraction().Request(http.MethodGet, "https://example.com/\x7f").Build(); err == nil {
+		t.Fatal("expected invalid URL to fail")
+	}
+	if _, err := NewInteraction().Request(http.MethodGet, "https://example.com/").RespBodyJSON(make(chan int)).Build(); err == nil {
+		t.Fatal("expected unsupported body to fail")
+	}
+}
+
+func TestAddStub(t *testing.T) {
+	c := New("stub")
+	i, err := c.AddStub(http.MethodGet, "https://example.com/missing", http.StatusNotFound, "not found")
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.Response.Headers.Set("Content-Type", "text/plain")
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/missing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.GetInteraction(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != 0 || got.Response.Code != http.StatusNotFound || got.Response.Body != "not found" {
+		t.Fatalf("got interaction %+v", got)
+	}
+	if got.Response.Headers.Get("Content-Type") != "text/plain" {
+		t.Fatalf("got content type %q, want the customized one", got.Response.Headers.Get("Content-Type"))
+	}
+
+	if _, err := c.AddStub(http.MethodGet, "https://example.com/\x7f", http.StatusOK, ""); err == nil {
+		t.Fatal("expected invalid URL to fail")
+	}
+}
@@ -0,0 +1,95 @@
+package cassette
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+type requestCacheContextKey struct{}
+
+// requestCache caches the body of a request, and its hashes computed by
+// the matchers, for a single round trip.
+type requestCache struct {
+	mu     sync.Mutex
+	body   []byte
+	read   bool
+	hashes map[RequestMatcher]string
+}
+
+// ContextWithRequestCache returns a copy of the parent context carrying a
+// cache for the body and the hashes of a request, see [RequestBody] and
+// [HashRequest]. This avoids reading and hashing the request repeatedly,
+// e.g. when matching it against the interactions of several cassettes. The
+// context must be used for a single request only, and the request must not
+// be modified after it was hashed.
+func ContextWithRequestCache(parent context.Context) context.Context {
+	return context.WithValue(parent, requestCacheContextKey{}, &requestCache{
+		hashes: make(map[RequestMatcher]string),
+	})
+}
+
+// requestCacheFromContext returns the request cache carried by the
+// context, or nil.
+func requestCacheFromContext(ctx context.Context) *requestCache {
+	cache, _ := ctx.Value(requestCacheContextKey{}).(*requestCache)
+	return cache
+}
+
+// RequestBody reads the body of the request, and replaces it with a reader
+// of the returned bytes, so that it can be read again without copying. The
+// body is read only once, if the context of the request carries a request
+// cache, see [ContextWithRequestCache].
+func RequestBody(r *http.Request) ([]byte, error) {
+	cache := requestCacheFromContext(r.Context())
+	if cache != nil {
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+
+		if cache.read {
+			r.Body = newReplayableBody(cache.body)
+			return cache.body, nil
+		}
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if cache != nil {
+		cache.body, cache.read = body, true
+	}
+
+	return body, nil
+}
+
+// HashRequest returns the hash of the request computed by the matcher. The
+// hash is computed only once per matcher, if the context of the request
+// carries a request cache, see [ContextWithRequestCache]. Only matchers of
+// pointer types are cached, since other types may not be comparable.
+func HashRequest(m RequestMatcher, r *http.Request) (string, error) {
+	cache := requestCacheFromContext(r.Context())
+	if cache == nil || reflect.ValueOf(m).Kind() != reflect.Pointer {
+		return m.Hash(r)
+	}
+
+	cache.mu.Lock()
+	hash, ok := cache.hashes[m]
+	cache.mu.Unlock()
+	if ok {
+		return hash, nil
+	}
+
+	hash, err := m.Hash(r)
+	if err != nil {
+		return "", err
+	}
+
+	cache.mu.Lock()
+	cache.hashes[m] = hash
+	cache.mu.Unlock()
+
+	return hash, nil
+}
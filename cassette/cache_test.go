@@ -0,0 +1,81 @@
+package cassette
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// countingMatcher counts the hashes computed by the wrapped matcher.
+type countingMatcher struct {
+	RequestMatcher
+	calls int
+}
+
+func (m *countingMatcher) Hash(r *http.Request) (string, error) {
+	m.calls++
+	return m.RequestMatcher.Hash(r)
+}
+
+func TestRequestCache(t *testing.T) {
+	dir := t.TempDir()
+	body := `{"name": "go-vcr"}`
+
+	m := &countingMatcher{RequestMatcher: DefaultMatcher}
+	var cassettes []*Cassette
+	for _, name := range []string{"first", "second", "third"} {
+		c := New(filepath.Join(dir, name))
+		c.Matcher = m
+		cassettes = append(cassettes, c)
+	}
+	i, err := NewInteraction().Request(http.MethodPost, "https://example.com/users").ReqBody(body).RespStatus(http.StatusCreated).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cassettes[2].AddInteraction(i); err != nil {
+		t.Fatal(err)
+	}
+	set := NewSet(cassettes...)
+
+	// The request is hashed once for all cassettes of the set
+	m.calls = 0
+	r, err := http.NewRequest(http.MethodPost, "https://example.com/users", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := set.GetInteraction(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Response.Code != http.StatusCreated {
+		t.Fatalf("got status %d, want %d", got.Response.Code, http.StatusCreated)
+	}
+	if m.calls != 1 {
+		t.Fatalf("got %d hashes, want 1", m.calls)
+	}
+	if b, err := io.ReadAll(r.Body); err != nil || string(b) != body {
+		t.Fatalf("got body %q (%v), want it to be readable again", b, err)
+	}
+
+	// The body is read once per request
+	ctx := ContextWithRequestCache(context.Background())
+	r, err = http.NewRequestWithContext(ctx, http.MethodPost, "https://example.com/users", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		b, err := RequestBody(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != body {
+			t.Fatalf("got body %q, want %q", b, body)
+		}
+	}
+	if b, err := io.ReadAll(r.Body); err != nil || string(b) != body {
+		t.Fatalf("got body %q (%v), want it to be readable again", b, err)
+	}
+}
@@ -25,18 +25,21 @@
 package cassette
 
 import (
-	"bytes"
 	"compress/gzip"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -55,100 +58,288 @@ var (
 	// ErrCassetteNotFound indicates that a requested casette doesn't exist.
 	ErrCassetteNotFound = errors.New("requested cassette not found")
 
+	// ErrEmptyCassette indicates that a cassette exists, but contains no
+	// interactions.
+	ErrEmptyCassette = errors.New("cassette contains no interactions")
+
 	// ErrUnsupportedCassetteFormat is returned when attempting to use an
 	// older and potentially unsupported format of a cassette.
 	ErrUnsupportedCassetteFormat = fmt.Errorf("unsupported cassette version format")
+
+	// ErrReadOnlyCassette is returned when attempting to save a read-only
+	// cassette.
+	ErrReadOnlyCassette = errors.New("cassette is read-only")
 )
 
 // Request represents a client request as recorded in the cassette file.
 type Request struct {
-	Proto            string      `yaml:"proto"`
-	ProtoMajor       int         `yaml:"proto_major"`
-	ProtoMinor       int         `yaml:"proto_minor"`
-	ContentLength    int64       `yaml:"content_length"`
-	TransferEncoding []string    `yaml:"transfer_encoding,omitempty"`
-	Trailer          http.Header `yaml:"trailer,omitempty"`
-	Host             string      `yaml:"host"`
-	RemoteAddr       string      `yaml:"remote_addr,omitempty"`
-	RequestURI       string      `yaml:"request_uri,omitempty"`
+	Proto            string      `yaml:"proto" cbor:"proto"`
+	ProtoMajor       int         `yaml:"proto_major" cbor:"proto_major"`
+	ProtoMinor       int         `yaml:"proto_minor" cbor:"proto_minor"`
+	ContentLength    int64       `yaml:"content_length" cbor:"content_length"`
+	TransferEncoding []string    `yaml:"transfer_encoding,omitempty" cbor:"transfer_encoding,omitempty"`
+	Trailer          http.Header `yaml:"trailer,omitempty" cbor:"trailer,omitempty"`
+	Host             string      `yaml:"host" cbor:"host"`
+	RemoteAddr       string      `yaml:"remote_addr,omitempty" cbor:"remote_addr,omitempty"`
+	RequestURI       string      `yaml:"request_uri,omitempty" cbor:"request_uri,omitempty"`
 
 	// Body of request
-	Body string `yaml:"body,omitempty"`
+	Body string `yaml:"body,omitempty" cbor:"body,omitempty"`
+
+	// BodyDigest is the digest of a large request body, which was not
+	// recorded in full, in the form "sha256:<hex>". If set, the request is
+	// matched on the digest instead of the body, which holds a sample of
+	// the body only, if any.
+	BodyDigest string `yaml:"body_digest,omitempty" cbor:"body_digest,omitempty"`
+
+	// BodyFile is the path of the file holding a large request body, which
+	// was not recorded in full, relative to the directory of the cassette.
+	BodyFile string `yaml:"body_file,omitempty" cbor:"body_file,omitempty"`
+
+	// Parts are the parts of a multipart/form-data body, which were
+	// recorded separately instead of the body. If set, the request is
+	// matched on its parts, see [PartsBody].
+	Parts []Part `yaml:"parts,omitempty" cbor:"parts,omitempty"`
 
 	// Form values
-	Form url.Values `yaml:"form,omitempty"`
+	Form url.Values `yaml:"form,omitempty" cbor:"form,omitempty"`
 
 	// Request headers
-	Headers http.Header `yaml:"headers,omitempty"`
+	Headers http.Header `yaml:"headers,omitempty" cbor:"headers,omitempty"`
 
 	// Request URL
-	URL string `yaml:"url"`
+	URL string `yaml:"url" cbor:"url"`
 
 	// Request method
-	Method string `yaml:"method"`
+	Method string `yaml:"method" cbor:"method"`
 }
 
 // Response represents a server response as recorded in the cassette file.
 type Response struct {
-	Proto            string      `yaml:"proto"`
-	ProtoMajor       int         `yaml:"proto_major"`
-	ProtoMinor       int         `yaml:"proto_minor"`
-	TransferEncoding []string    `yaml:"transfer_encoding,omitempty"`
-	Trailer          http.Header `yaml:"trailer,omitempty"`
-	ContentLength    int64       `yaml:"content_length"`
-	Uncompressed     bool        `yaml:"uncompressed,omitempty"`
+	Proto            string      `yaml:"proto" cbor:"proto"`
+	ProtoMajor       int         `yaml:"proto_major" cbor:"proto_major"`
+	ProtoMinor       int         `yaml:"proto_minor" cbor:"proto_minor"`
+	TransferEncoding []string    `yaml:"transfer_encoding,omitempty" cbor:"transfer_encoding,omitempty"`
+	Trailer          http.Header `yaml:"trailer,omitempty" cbor:"trailer,omitempty"`
+	ContentLength    int64       `yaml:"content_length" cbor:"content_length"`
+	Uncompressed     bool        `yaml:"uncompressed,omitempty" cbor:"uncompressed,omitempty"`
+
+	// DecodedContentEncoding contains the content codings, which were
+	// removed from the body when recording, so that it is stored as
+	// readable text. The body is encoded again on replay.
+	DecodedContentEncoding string `yaml:"decoded_content_encoding,omitempty" cbor:"decoded_content_encoding,omitempty"`
+
+	// PrettyPrinted contains the format, e.g. "json", the body was
+	// pretty-printed as when recording, so that it is easy to review. The
+	// body is compacted again on replay.
+	PrettyPrinted string `yaml:"pretty_printed,omitempty" cbor:"pretty_printed,omitempty"`
+
+	// Continue is true, if the server responded with 100 Continue to a
+	// request with an "Expect: 100-continue" header, before receiving the
+	// request body.
+	Continue bool `yaml:"continue,omitempty" cbor:"continue,omitempty"`
 
 	// Body of response
-	Body string `yaml:"body"`
+	Body string `yaml:"body" cbor:"body"`
+
+	// BodyEncoding is the encoding, e.g. "base64", the body is stored in,
+	// see [Base64EncodeResponseBody]. The body is decoded on replay.
+	BodyEncoding string `yaml:"body_encoding,omitempty" cbor:"body_encoding,omitempty"`
+
+	// BodyDigest is the digest of a response body, which was not recorded,
+	// in the form "sha256:<hex>". If set, a body of BodyLength zero bytes
+	// is replayed instead.
+	BodyDigest string `yaml:"body_digest,omitempty" cbor:"body_digest,omitempty"`
+
+	// BodyLength is the length of a response body, which was not recorded,
+	// see BodyDigest.
+	BodyLength int64 `yaml:"body_length,omitempty" cbor:"body_length,omitempty"`
 
 	// Response headers
-	Headers http.Header `yaml:"headers"`
+	Headers http.Header `yaml:"headers" cbor:"headers"`
 
 	// Response status message
-	Status string `yaml:"status"`
+	Status string `yaml:"status" cbor:"status"`
 
 	// Response status code
-	Code int `yaml:"code"`
+	Code int `yaml:"code" cbor:"code"`
 
 	// Response duration
-	Duration time.Duration `yaml:"duration"`
+	Duration time.Duration `yaml:"duration" cbor:"duration"`
 }
 
 // Interaction type contains a pair of request/response for a single HTTP
 // interaction between a client and a server.
 type Interaction struct {
 	// ID is the id of the interaction
-	ID int `yaml:"id"`
+	ID int `yaml:"id" cbor:"id"`
 
 	// Hash is the pre-computed hash of the request for fast matching.
 	// If empty, the hash will be computed on load.
-	Hash string `yaml:"hash,omitempty"`
+	Hash string `yaml:"hash,omitempty" cbor:"hash,omitempty"`
 
 	// Request is the recorded request
-	Request Request `yaml:"request"`
+	Request Request `yaml:"request" cbor:"request"`
 
 	// Response is the recorded response
-	Response Response `yaml:"response"`
+	Response Response `yaml:"response" cbor:"response"`
+
+	// RecordedAt is the time when the interaction was recorded. It is zero
+	// for interactions recorded by older versions, or crafted by hand.
+	RecordedAt time.Time `yaml:"recorded_at,omitempty" cbor:"recorded_at,omitempty"`
+
+	// ParentID is the id of the interaction, whose redirect response
+	// caused the request of this interaction, when following a redirect
+	// chain. It is nil for requests, which are not part of a redirect
+	// chain.
+	ParentID *int `yaml:"parent_id,omitempty" cbor:"parent_id,omitempty"`
+
+	// Metadata contains user-defined annotations of the interaction, e.g.
+	// the name of the test or the feature flags which produced it.
+	Metadata map[string]string `yaml:"metadata,omitempty" cbor:"metadata,omitempty"`
+
+	// Tags are user-defined labels of the interaction, e.g. the test group
+	// using it. Replay may be restricted to interactions with certain tags,
+	// see [Cassette.IncludeTags] and [Cassette.ExcludeTags].
+	Tags []string `yaml:"tags,omitempty" cbor:"tags,omitempty"`
 
 	// DiscardOnSave if set to true will discard the interaction as a whole
 	// and it will not be part of the final interactions when saving the
 	// cassette on disk.
-	DiscardOnSave bool `yaml:"-"`
+	DiscardOnSave bool `yaml:"-" cbor:"-"`
 
-	// replayed is true when this interaction has been played already.
-	replayed bool `yaml:"-"`
+	// replayed is true when this interaction has been played already. It is
+	// set while holding the lock of the cassette, but read concurrently, e.g.
+	// by hooks of parallel tests.
+	replayed atomic.Bool
 }
 
 // WasReplayed returns a boolean indicating whether the given interaction was
 // already replayed.
 func (i *Interaction) WasReplayed() bool {
-	return i.replayed
+	return i.replayed.Load()
+}
+
+// Clone returns a deep copy of the interaction, whose headers, trailers,
+// form, metadata and tags may be modified without affecting the original
+// interaction.
+func (i *Interaction) Clone() *Interaction {
+	clone := &Interaction{
+		ID:            i.ID,
+		Hash:          i.Hash,
+		Request:       i.Request,
+		Response:      i.Response,
+		RecordedAt:    i.RecordedAt,
+		Metadata:      maps.Clone(i.Metadata),
+		Tags:          slices.Clone(i.Tags),
+		DiscardOnSave: i.DiscardOnSave,
+	}
+	if i.ParentID != nil {
+		parentID := *i.ParentID
+		clone.ParentID = &parentID
+	}
+
+	clone.Request.TransferEncoding = slices.Clone(i.Request.TransferEncoding)
+	clone.Request.Trailer = i.Request.Trailer.Clone()
+	clone.Request.Form = cloneValues(i.Request.Form)
+	clone.Request.Headers = i.Request.Headers.Clone()
+	clone.Request.Parts = slices.Clone(i.Request.Parts)
+	clone.Response.TransferEncoding = slices.Clone(i.Response.TransferEncoding)
+	clone.Response.Trailer = i.Response.Trailer.Clone()
+	clone.Response.Headers = i.Response.Headers.Clone()
+	clone.replayed.Store(i.replayed.Load())
+
+	return clone
+}
+
+// cloneValues returns a deep copy of the url.Values, or nil if v is nil.
+func cloneValues(v url.Values) url.Values {
+	if v == nil {
+		return nil
+	}
+	return url.Values(http.Header(v).Clone())
+}
+
+// Age returns the time elapsed since the interaction was recorded. It
+// returns zero if the recording time of the interaction is unknown.
+func (i *Interaction) Age() time.Duration {
+	if i.RecordedAt.IsZero() {
+		return 0
+	}
+	return time.Since(i.RecordedAt)
+}
+
+// SetMetadata sets the metadata key to the given value.
+func (i *Interaction) SetMetadata(key, value string) {
+	if i.Metadata == nil {
+		i.Metadata = make(map[string]string)
+	}
+	i.Metadata[key] = value
+}
+
+// AddTag adds the given tags to the interaction, unless it has them
+// already.
+func (i *Interaction) AddTag(tags ...string) {
+	for _, tag := range tags {
+		if !i.HasTag(tag) {
+			i.Tags = append(i.Tags, tag)
+		}
+	}
+}
+
+// HasTag returns true, if the interaction has the given tag.
+func (i *Interaction) HasTag(tag string) bool {
+	return slices.Contains(i.Tags, tag)
+}
+
+// metadataContextKey is the context key for interaction metadata.
+type metadataContextKey struct{}
+
+// ContextWithMetadata returns a copy of the parent context carrying the given
+// interaction metadata. When a request using the returned context is
+// recorded, the metadata is stored in the recorded interaction.
+func ContextWithMetadata(parent context.Context, metadata map[string]string) context.Context {
+	return context.WithValue(parent, metadataContextKey{}, metadata)
+}
+
+// MetadataFromContext returns the interaction metadata carried by the
+// context, if any. Requests returned by [Interaction.GetHTTPRequest] carry
+// the metadata of the interaction, which makes it available to matchers.
+func MetadataFromContext(ctx context.Context) map[string]string {
+	metadata, _ := ctx.Value(metadataContextKey{}).(map[string]string)
+	return metadata
+}
+
+// tagsContextKey is the context key for interaction tags.
+type tagsContextKey struct{}
+
+// ContextWithTags returns a copy of the parent context carrying the given
+// interaction tags. When a request using the returned context is recorded,
+// the tags are added to the recorded interaction.
+func ContextWithTags(parent context.Context, tags ...string) context.Context {
+	return context.WithValue(parent, tagsContextKey{}, tags)
+}
+
+// TagsFromContext returns the interaction tags carried by the context, if
+// any.
+func TagsFromContext(ctx context.Context) []string {
+	tags, _ := ctx.Value(tagsContextKey{}).([]string)
+	return tags
 }
 
 // GetHTTPRequest converts the recorded interaction request to http.Request
 // instance.
 func (i *Interaction) GetHTTPRequest() (*http.Request, error) {
-	return toHTTPRequest(i.Request)
+	req, err := toHTTPRequest(i.Request)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(i.Metadata) > 0 {
+		req = req.WithContext(ContextWithMetadata(context.Background(), i.Metadata))
+	}
+
+	return req, nil
 }
 
 func toHTTPRequest(req Request) (*http.Request, error) {
@@ -157,6 +348,16 @@ func toHTTPRequest(req Request) (*http.Request, error) {
 		return nil, fmt.Errorf("failed to parse request URL %s: %w", req.URL, err)
 	}
 
+	// Large and multipart bodies are matched on their digest and parts, see
+	// [Request.BodyDigest] and [Request.Parts].
+	body := req.Body
+	switch {
+	case req.BodyDigest != "":
+		body = req.BodyDigest
+	case len(req.Parts) > 0:
+		body = PartsBody(req.Parts)
+	}
+
 	return &http.Request{
 		Proto:            req.Proto,
 		ProtoMajor:       req.ProtoMajor,
@@ -167,7 +368,7 @@ func toHTTPRequest(req Request) (*http.Request, error) {
 		Host:             req.Host,
 		RemoteAddr:       req.RemoteAddr,
 		RequestURI:       req.RequestURI,
-		Body:             io.NopCloser(strings.NewReader(req.Body)),
+		Body:             io.NopCloser(strings.NewReader(body)),
 		Form:             req.Form,
 		Header:           req.Headers,
 		URL:              url,
@@ -190,7 +391,6 @@ func (i *Interaction) GetHTTPResponse() (*http.Response, error) {
 		ProtoMajor:       i.Response.ProtoMajor,
 		ProtoMinor:       i.Response.ProtoMinor,
 		TransferEncoding: i.Response.TransferEncoding,
-		Trailer:          i.Response.Trailer,
 		ContentLength:    i.Response.ContentLength,
 		Uncompressed:     i.Response.Uncompressed,
 		Body:             io.NopCloser(strings.NewReader(i.Response.Body)),
@@ -198,16 +398,156 @@ func (i *Interaction) GetHTTPResponse() (*http.Response, error) {
 		Close:            true,
 		Request:          req,
 	}
+	if i.Response.BodyDigest != "" {
+		// Bodies, which were not recorded, are synthesized
+		resp.Body = io.NopCloser(io.LimitReader(zeroReader{}, i.Response.BodyLength))
+	}
+
+	switch {
+	case resp.ProtoMajor == 0 && resp.Proto == "":
+		// Interactions crafted by hand may not specify the protocol
+		resp.Proto, resp.ProtoMajor, resp.ProtoMinor = "HTTP/1.1", 1, 1
+	case resp.ProtoMajor >= 2:
+		applyHTTP2Semantics(resp)
+	}
+
+	r := i.Response
+	if r.BodyEncoding != "" {
+		body, err := decodeResponseBody(r.Body, r.BodyEncoding)
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+		setResponseBody(resp, body)
+	}
+
+	if r.PrettyPrinted != "" {
+		body, err := CompactBody(r.Body, r.PrettyPrinted)
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+		setResponseBody(resp, body)
+	}
+
+	if r.DecodedContentEncoding != "" {
+		if err := encodeResponseBody(resp, r); err != nil {
+			return nil, err
+		}
+	}
+
+	if slices.Contains(resp.TransferEncoding, "chunked") {
+		// Chunked responses have no known length, even if the
+		// recorded headers were edited to include one.
+		resp.ContentLength = -1
+		if resp.Header.Get("Content-Length") != "" {
+			resp.Header = resp.Header.Clone()
+			resp.Header.Del("Content-Length")
+		}
+	}
+
+	if len(i.Response.Trailer) > 0 {
+		// Trailers are announced up front, and only populated once the
+		// body was consumed, the same way as by the [http.Client].
+		resp.Trailer = make(http.Header, len(i.Response.Trailer))
+		for k := range i.Response.Trailer {
+			resp.Trailer[http.CanonicalHeaderKey(k)] = nil
+		}
+		resp.Body = &trailerBody{ReadCloser: resp.Body, resp: resp, trailer: i.Response.Trailer}
+	}
 
 	return resp, nil
 }
 
+// trailerBody populates the trailer of the response, when the body was read
+// until EOF.
+type trailerBody struct {
+	io.ReadCloser
+	resp    *http.Response
+	trailer http.Header
+	done    bool
+}
+
+func (b *trailerBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF && !b.done {
+		b.done = true
+		for k, values := range b.trailer {
+			b.resp.Trailer[http.CanonicalHeaderKey(k)] = slices.Clone(values)
+		}
+	}
+	return n, err
+}
+
+// http2ForbiddenHeaders are the connection-specific headers, which must not
+// be used in HTTP/2 responses as per RFC 9113, section 8.2.2.
+var http2ForbiddenHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Connection",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// applyHTTP2Semantics adjusts a replayed response to look like one received
+// over HTTP/2. HTTP/2 has no transfer codings and connection-specific
+// headers, and header names are transmitted in lower case, which the Go
+// HTTP/2 client canonicalizes.
+func applyHTTP2Semantics(resp *http.Response) {
+	resp.TransferEncoding = nil
+	resp.Close = false
+	resp.Header = canonicalHeader(resp.Header)
+	for _, h := range http2ForbiddenHeaders {
+		resp.Header.Del(h)
+	}
+}
+
+// canonicalHeader returns a copy of the header with canonical keys.
+func canonicalHeader(h http.Header) http.Header {
+	if h == nil {
+		return nil
+	}
+
+	canonical := make(http.Header, len(h))
+	for k, values := range h {
+		key := http.CanonicalHeaderKey(k)
+		canonical[key] = append(canonical[key], values...)
+	}
+
+	return canonical
+}
+
+// DowngradeToHTTP1 rewrites the protocol of the response to HTTP/1.1. This
+// is useful for replaying interactions recorded over HTTP/2 to clients,
+// which expect HTTP/1.1.
+func DowngradeToHTTP1(resp *http.Response) {
+	if resp.ProtoMajor < 2 {
+		return
+	}
+	resp.Proto, resp.ProtoMajor, resp.ProtoMinor = "HTTP/1.1", 1, 1
+}
+
 // RequestMatcher generates a deterministic hash from an HTTP request for matching.
 // Two requests that should be considered equivalent must produce the same hash.
 type RequestMatcher interface {
 	Hash(r *http.Request) (string, error)
 }
 
+// HashWith returns the hash of a clone of the request computed by the
+// matcher, after modifying the clone using mutate, e.g. to remove headers,
+// which are ignored when matching. The body is shared with the clone, and
+// handed back to the request, as the matcher may replace it after reading
+// it.
+func HashWith(m RequestMatcher, r *http.Request, mutate func(*http.Request)) (string, error) {
+	clone := r.Clone(r.Context())
+	clone.Body = r.Body
+	mutate(clone)
+	hash, err := m.Hash(clone)
+	r.Body = clone.Body
+
+	return hash, err
+}
+
 // MatcherOption is a function which configures a matcher.
 type MatcherOption func(m *defaultMatcher)
 
@@ -235,22 +575,123 @@ func WithIgnoreHeaders(val ...string) MatcherOption {
 	}
 }
 
+// WithIgnoreRange is a [MatcherOption] that configures the matcher to
+// ignore the Range and If-Range HTTP headers when matching, so that requests
+// for different ranges of a resource match the same interaction.
+func WithIgnoreRange() MatcherOption {
+	return func(m *defaultMatcher) {
+		m.ignoreHeaders = append(m.ignoreHeaders, "Range", "If-Range")
+	}
+}
+
+// WithIgnoreConditionals is a [MatcherOption] that configures the matcher to
+// ignore the If-None-Match and If-Modified-Since HTTP headers when
+// matching, so that conditional requests match the unconditional ones.
+func WithIgnoreConditionals() MatcherOption {
+	return func(m *defaultMatcher) {
+		m.ignoreHeaders = append(m.ignoreHeaders, "If-None-Match", "If-Modified-Since")
+	}
+}
+
+// WithIgnoreCookies is a [MatcherOption] that configures the matcher to
+// ignore the Cookie HTTP header when matching. This is useful for session
+// cookies, whose values differ between recording and replaying.
+func WithIgnoreCookies() MatcherOption {
+	return func(m *defaultMatcher) {
+		m.ignoreHeaders = append(m.ignoreHeaders, "Cookie")
+	}
+}
+
+// WithIgnoreQueryParams is a [MatcherOption] that configures the matcher
+// to ignore the specified URL query parameters when matching.
+func WithIgnoreQueryParams(val ...string) MatcherOption {
+	return func(m *defaultMatcher) {
+		m.ignoreQueryParams = append(m.ignoreQueryParams, val...)
+	}
+}
+
+// WithIgnoreTrailers is a [MatcherOption] that configures the matcher to
+// ignore the trailer of the request and the Trailer header announcing it
+// when matching, e.g. for requests passing proxies, which add trailers
+// intermittently.
+func WithIgnoreTrailers() MatcherOption {
+	return func(m *defaultMatcher) {
+		m.fields &^= HashTrailer
+		m.ignoreHeaders = append(m.ignoreHeaders, "Trailer")
+	}
+}
+
+// WithIgnoreTransferEncoding is a [MatcherOption] that configures the
+// matcher to ignore the transfer encoding of the request when matching. The
+// content length is ignored as well, see [WithIgnoreContentLength], as it is
+// unknown for chunked requests.
+func WithIgnoreTransferEncoding() MatcherOption {
+	return func(m *defaultMatcher) {
+		m.fields &^= HashTransferEncoding | HashContentLength
+		m.ignoreHeaders = append(m.ignoreHeaders, "Transfer-Encoding", "Content-Length")
+	}
+}
+
+// WithIgnoreContentLength is a [MatcherOption] that configures the matcher
+// to ignore the content length of the request and its Content-Length header
+// when matching, which differ between chunked and non-chunked sends of the
+// same body. The body itself is still matched.
+func WithIgnoreContentLength() MatcherOption {
+	return func(m *defaultMatcher) {
+		m.fields &^= HashContentLength
+		m.ignoreHeaders = append(m.ignoreHeaders, "Content-Length")
+	}
+}
+
+// WithHashFields is a [MatcherOption] that configures the matcher to hash
+// only the given request components, instead of the [DefaultHashFields],
+// e.g.
+//
+//	cassette.NewMatcher(cassette.WithHashFields(cassette.HashMethod | cassette.HashPath | cassette.HashBody))
+//
+// The options ignoring request components, e.g. [WithIgnoreContentLength],
+// remove them from the fields configured before. Hashes persisted by a
+// matcher hashing other fields are recomputed, when loading a cassette.
+func WithHashFields(fields HashField) MatcherOption {
+	return func(m *defaultMatcher) {
+		m.fields = fields
+	}
+}
+
+// WithHashAlgorithm is a [MatcherOption] that configures the matcher to hash
+// requests using the given algorithm, e.g. [HashXXH64] for cassettes with
+// large bodies, where hashing dominates the replay time. Hashes persisted
+// using another algorithm are recomputed, when loading a cassette.
+func WithHashAlgorithm(alg HashAlgorithm) MatcherOption {
+	return func(m *defaultMatcher) {
+		m.algorithm = alg
+	}
+}
+
 // defaultMatcher is the default RequestMatcher implementation.
 type defaultMatcher struct {
-	ignoreHeaders []string
+	ignoreHeaders     []string
+	ignoreQueryParams []string
+	fields            HashField
+	algorithm         HashAlgorithm
+
+	// fingerprint is the fingerprint of the configuration of the matcher,
+	// see [matcherFingerprint].
+	fingerprint string
 }
 
 // Hash implements RequestMatcher.
 func (m *defaultMatcher) Hash(r *http.Request) (string, error) {
-	return defaultInteractionRequestHasher(r, m.ignoreHeaders)
+	return defaultInteractionRequestHasher(r, m)
 }
 
 // NewMatcher creates a new RequestMatcher with the given options.
 func NewMatcher(opts ...MatcherOption) RequestMatcher {
-	m := &defaultMatcher{}
+	m := &defaultMatcher{fields: DefaultHashFields}
 	for _, opt := range opts {
 		opt(m)
 	}
+	m.fingerprint = matcherFingerprint(m)
 	return m
 }
 
@@ -259,6 +700,11 @@ func NewMatcher(opts ...MatcherOption) RequestMatcher {
 var DefaultMatcher = NewMatcher()
 
 // Cassette represents a cassette containing recorded interactions.
+//
+// The methods of a cassette are safe for concurrent use. The interactions
+// returned by [Cassette.GetInteraction] are copies, which may be modified,
+// while the Interactions field must only be accessed while holding the lock
+// of the cassette, if it is used concurrently.
 type Cassette struct {
 	sync.Mutex `yaml:"-"`
 
@@ -278,16 +724,68 @@ type Cassette struct {
 	// CompressionEnabled defines whether to compress the cassette
 	CompressionEnabled bool `yaml:"compression_enabled,omitempty"`
 
+	// CompressionLevel is the gzip compression level of the cassette, from
+	// [gzip.BestSpeed] to [gzip.BestCompression]. Lower levels trade some
+	// size for much faster saves of large cassettes. The zero value uses
+	// [gzip.DefaultCompression].
+	CompressionLevel int `yaml:"-"`
+
+	// Binary specifies whether the cassette is stored in the compact
+	// binary CBOR format, instead of YAML, which is much faster to load and
+	// smaller for large cassettes. See [Convert] for converting cassettes
+	// between the formats.
+	Binary bool `yaml:"-"`
+
 	// Matcher generates hashes from requests for matching.
 	Matcher RequestMatcher `yaml:"-"`
 
+	// MatchVary specifies whether interactions, whose recorded response
+	// carries a Vary header, are matched on exactly the varied request
+	// headers, instead of the headers considered by the matcher. This
+	// mirrors the semantics of HTTP caches.
+	MatchVary bool `yaml:"-"`
+
+	// IncludeTags restricts replay to the interactions, which have at least
+	// one of the tags. All interactions are replayed, if empty.
+	IncludeTags []string `yaml:"-"`
+
+	// ExcludeTags excludes the interactions, which have any of the tags,
+	// from replay. It takes precedence over IncludeTags.
+	ExcludeTags []string `yaml:"-"`
+
+	// Strict specifies whether loading the YAML cassette fails on unknown
+	// fields, e.g. misspelled ones in hand-edited cassettes, which are
+	// ignored otherwise. The errors contain the line and the path of the
+	// unknown fields, e.g. interactions[1].request.hedaers.
+	Strict bool `yaml:"-"`
+
+	// OnDuplicates specifies how loading the cassette treats interactions,
+	// which match the same requests, e.g. accidentally duplicated ones. See
+	// [Cassette.Duplicates].
+	OnDuplicates DuplicatePolicy `yaml:"-"`
+
+	// ReadOnly specifies whether the cassette file must never be written,
+	// e.g. for hand-redacted fixtures. Saving the cassette fails with
+	// [ErrReadOnlyCassette], and loading it does not save the computed
+	// hashes of the interactions.
+	ReadOnly bool `yaml:"-"`
+
+	// SecretScanner, when set, scans the interactions for leaked
+	// credentials before saving, and fails the save if any are found.
+	SecretScanner *SecretScanner `yaml:"-"`
+
 	// IsNew specifies whether this is a newly created cassette.
 	// Returns false, when the cassette was loaded from an
 	// existing source, e.g. a file.
 	IsNew bool `yaml:"-"`
 
+	// modified specifies whether interactions were added or replaced, or
+	// hashes were computed, since the cassette was loaded or saved.
+	modified bool `yaml:"-"`
+
 	nextInteractionId int              `yaml:"-"`
 	hashIndex         map[string][]int `yaml:"-"`
+	varyIndex         map[string][]int `yaml:"-"`
 }
 
 // New creates a new empty cassette
@@ -312,6 +810,9 @@ func (c *Cassette) File() string {
 	}
 
 	file := fmt.Sprintf("%s.yaml", c.Name)
+	if c.Binary {
+		file = fmt.Sprintf("%s.cbor", c.Name)
+	}
 
 	if c.CompressionEnabled {
 		return file + ".gz"
@@ -320,14 +821,18 @@ func (c *Cassette) File() string {
 	return file
 }
 
+// Load loads the cassette from disk. The cassette file is located using
+// [Cassette.Locate], and its compression and format are detected from its
+// contents, regardless of the CompressionEnabled and Binary fields, which
+// are updated accordingly. This allows loading fixture directories with
+// mixed formats without configuring each cassette. Cassettes loaded from
+// .yml, .json or zstd compressed files are saved as .yaml files.
 func (c *Cassette) Load() error {
 	if c == nil {
 		return fmt.Errorf("cassette is nil")
 	}
 
-	file := c.File()
-
-	f, err := os.Open(file)
+	file, err := c.Locate()
 	if err != nil {
 		// Return the original os.ReadFile error format for consistency.
 		if os.IsNotExist(err) {
@@ -335,50 +840,102 @@ func (c *Cassette) Load() error {
 		}
 		return fmt.Errorf("failed to read cassette file %s: %w", file, err)
 	}
+
+	f, compressed, binary, err := openCassetteFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return err
+		}
+		return fmt.Errorf("failed to read cassette file %s: %w", file, err)
+	}
 	defer f.Close()
 
-	var reader io.Reader = f
-	if c.CompressionEnabled {
-		gz, err := gzip.NewReader(f)
-		if err != nil {
-			return fmt.Errorf("failed to create gzip reader for %s: %w", file, err)
+	upgraded, err := c.decode(f, compressed, binary)
+	if err != nil {
+		return fmt.Errorf("failed to load cassette file %s: %w", file, err)
+	}
+
+	// Auto-upgrade: save cassette with computed hashes for faster future
+	// loads, unless it would be saved to a different file.
+	if upgraded && file == c.File() && !c.ReadOnly {
+		if err := c.Save(); err != nil {
+			slog.Warn("failed to save upgraded cassette", "cassette", c.Name, "error", err)
 		}
-		defer gz.Close()
-		reader = gz
 	}
 
+	return nil
+}
+
+// LoadFrom reads the cassette from r, without touching the file system,
+// e.g. for in-memory round-trips, network transport or custom storage
+// layers. The compression and format of the cassette are detected from its
+// contents, and the CompressionEnabled and Binary fields are updated
+// accordingly, see [Cassette.Load].
+func (c *Cassette) LoadFrom(r io.Reader) error {
+	if c == nil {
+		return fmt.Errorf("cassette is nil")
+	}
+
+	cr, compressed, binary, err := newCassetteReader(r)
+	if err != nil {
+		return err
+	}
+	defer cr.Close()
+
+	_, err = c.decode(cr, compressed, binary)
+	return err
+}
+
+// decode reads the decompressed cassette of the detected format from r, and
+// builds its hash index. It returns true, if any hashes were computed.
+func (c *Cassette) decode(r io.Reader, compressed, binary bool) (upgraded bool, err error) {
 	c.IsNew = false
-	if err := yaml.NewDecoder(reader).Decode(c); err != nil {
-		return fmt.Errorf("failed to decode cassette file %s: %w", file, err)
+	c.Binary = binary
+	switch {
+	case binary:
+		err = decodeBinary(r, c)
+	case c.Strict:
+		err = decodeStrictYAML(r, c)
+	default:
+		err = yaml.NewDecoder(r).Decode(c)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to decode cassette: %w", err)
 	}
+	c.CompressionEnabled = compressed
 
 	if c.Version != CassetteFormatVersion {
-		return fmt.Errorf("%w: found version %d, but reader supports version %d", ErrUnsupportedCassetteFormat, c.Version, CassetteFormatVersion)
+		return false, fmt.Errorf("%w: found version %d, but reader supports version %d", ErrUnsupportedCassetteFormat, c.Version, CassetteFormatVersion)
 	}
 
 	c.nextInteractionId = len(c.Interactions)
 
-	upgraded, err := c.buildHashIndex()
+	upgraded, err = c.buildHashIndex()
 	if err != nil {
-		return fmt.Errorf("failed to build hash index for cassette %s: %w", c.Name, err)
+		return false, fmt.Errorf("failed to build hash index for cassette %s: %w", c.Name, err)
 	}
+	c.modified = upgraded
 
-	// Auto-upgrade: save cassette with computed hashes for faster future loads
-	if upgraded {
-		if err := c.Save(); err != nil {
-			slog.Warn("failed to save upgraded cassette", "cassette", c.Name, "error", err)
-		}
+	if err := c.checkDuplicates(); err != nil {
+		return false, err
 	}
 
-	return nil
+	return upgraded, nil
 }
 
 // Load is a convenience function which loads a cassette from disk and returns
-// it.
+// it. The format and compression of the cassette are detected, see
+// [Cassette.Load]. If the cassette does not exist, but was recorded in
+// shards, the shards are loaded and merged, see [LoadShards].
 func Load(name string) (*Cassette, error) {
 	c := New(name)
 
 	if err := c.Load(); err != nil {
+		if os.IsNotExist(err) {
+			if shards, shardErr := shardIndices(name); shardErr == nil && len(shards) > 0 {
+				return LoadShards(name)
+			}
+		}
 		return nil, fmt.Errorf("failed to load cassette %s: %w", name, err)
 	}
 
@@ -392,8 +949,17 @@ func (c *Cassette) buildHashIndex() (upgraded bool, err error) {
 		return false, nil
 	}
 
+	// Hashes persisted using another algorithm, or by a matcher hashing
+	// other request components than the default matcher, are recomputed.
+	m, isDefault := c.Matcher.(*defaultMatcher)
+
 	for i, interaction := range c.Interactions {
 		hash := interaction.Hash
+		if isDefault && hash != "" {
+			if fingerprint, _ := hashFingerprintOf(hash); fingerprint != m.fingerprint || hashAlgorithmOf(hash) != m.algorithm {
+				hash = ""
+			}
+		}
 
 		// Fall back to computing hash for old cassettes without pre-computed hashes
 		if hash == "" {
@@ -418,6 +984,43 @@ func (c *Cassette) buildHashIndex() (upgraded bool, err error) {
 	return upgraded, nil
 }
 
+// OlderThan returns the interactions, which were recorded more than d ago.
+// Interactions with an unknown recording time are not included.
+func (c *Cassette) OlderThan(d time.Duration) []*Interaction {
+	c.Lock()
+	defer c.Unlock()
+
+	interactions := make([]*Interaction, 0)
+	for _, i := range c.Interactions {
+		if !i.RecordedAt.IsZero() && i.Age() > d {
+			interactions = append(interactions, i)
+		}
+	}
+
+	return interactions
+}
+
+// SetMatcher replaces the matcher of the cassette, and rebuilds the hash
+// index of the interactions using the new matcher.
+func (c *Cassette) SetMatcher(m RequestMatcher) error {
+	c.Lock()
+	defer c.Unlock()
+
+	c.Matcher = m
+	c.hashIndex = make(map[string][]int)
+	c.varyIndex = nil
+	for _, i := range c.Interactions {
+		i.Hash = ""
+	}
+
+	if _, err := c.buildHashIndex(); err != nil {
+		return fmt.Errorf("failed to rebuild hash index for cassette %s: %w", c.Name, err)
+	}
+	c.modified = true
+
+	return nil
+}
+
 // AddInteraction appends a new interaction to the cassette
 func (c *Cassette) AddInteraction(i *Interaction) error {
 	c.Lock()
@@ -442,10 +1045,101 @@ func (c *Cassette) AddInteraction(i *Interaction) error {
 	}
 
 	c.Interactions = append(c.Interactions, i)
+	if c.varyIndex != nil {
+		if err := c.indexVary(len(c.Interactions)-1, i); err != nil {
+			// The index is built again on next use, which reports the error.
+			c.varyIndex = nil
+		}
+	}
+	c.modified = true
+	return nil
+}
+
+// ReplaceInteraction replaces the interaction with the given id in place,
+// preserving its id and position within the cassette. This is useful for
+// refreshing a single interaction without re-recording the whole cassette.
+func (c *Cassette) ReplaceInteraction(id int, i *Interaction) error {
+	c.Lock()
+	defer c.Unlock()
+
+	idx := slices.IndexFunc(c.Interactions, func(i *Interaction) bool { return i.ID == id })
+	if idx == -1 {
+		return fmt.Errorf("%w: no interaction with id %d", ErrInteractionNotFound, id)
+	}
+
+	old := c.Interactions[idx]
+	i.ID = old.ID
+	i.replayed.Store(old.replayed.Load())
+
+	if c.Matcher != nil {
+		req, err := i.GetHTTPRequest()
+		if err != nil {
+			return fmt.Errorf("failed to get HTTP request for interaction %d: %w", i.ID, err)
+		}
+
+		hash, err := c.Matcher.Hash(req)
+		if err != nil {
+			return fmt.Errorf("failed to hash request for interaction %d: %w", i.ID, err)
+		}
+
+		c.hashIndex[old.Hash] = slices.DeleteFunc(c.hashIndex[old.Hash], func(n int) bool { return n == idx })
+		if len(c.hashIndex[old.Hash]) == 0 {
+			delete(c.hashIndex, old.Hash)
+		}
+
+		// Keep the indices sorted, so that replay order is preserved.
+		i.Hash = hash
+		indices := append(c.hashIndex[hash], idx)
+		slices.Sort(indices)
+		c.hashIndex[hash] = indices
+	}
+
+	if c.varyIndex != nil {
+		if err := errors.Join(c.unindexVary(idx, old), c.indexVary(idx, i)); err != nil {
+			// The index is built again on next use, which reports the error.
+			c.varyIndex = nil
+		}
+	}
+	c.Interactions[idx] = i
+	c.modified = true
 	return nil
 }
 
-// GetInteraction retrieves a recorded request/response interaction
+// maxRedirects is the maximum length of a redirect chain followed by
+// [Cassette.FollowRedirects], which matches the limit of the [http.Client].
+const maxRedirects = 10
+
+// FollowRedirects follows the redirect chain starting at the interaction
+// with the given id, and returns the last interaction of the chain, which
+// is the interaction itself, if no redirect was recorded for it. All hops of
+// the chain are marked as replayed.
+func (c *Cassette) FollowRedirects(id int) (*Interaction, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	idx := slices.IndexFunc(c.Interactions, func(i *Interaction) bool { return i.ID == id })
+	if idx == -1 {
+		return nil, fmt.Errorf("%w: no interaction with id %d", ErrInteractionNotFound, id)
+	}
+
+	current := c.Interactions[idx]
+	for range maxRedirects {
+		next := slices.IndexFunc(c.Interactions, func(i *Interaction) bool {
+			return i.ParentID != nil && *i.ParentID == current.ID && (c.ReplayableInteractions || !i.WasReplayed())
+		})
+		if next == -1 {
+			return current, nil
+		}
+
+		current = c.Interactions[next]
+		current.replayed.Store(true)
+	}
+
+	return nil, fmt.Errorf("stopped after %d redirects from interaction %d", maxRedirects, id)
+}
+
+// GetInteraction retrieves a recorded request/response interaction. The
+// returned interaction is a copy, which is not shared with other callers.
 func (c *Cassette) GetInteraction(r *http.Request) (*Interaction, error) {
 	c.Lock()
 	defer c.Unlock()
@@ -459,19 +1153,39 @@ func (c *Cassette) GetInteraction(r *http.Request) (*Interaction, error) {
 	}
 
 	// Read and cache the request body.
-	bodyBytes, err := io.ReadAll(r.Body)
+	bodyBytes, err := RequestBody(r)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read request body for matching: %w", err)
 	}
-	r.Body.Close()
-	r.Body = io.NopCloser(bytes.NewReader(bodyBytes))
 
-	reqHash, err := c.Matcher.Hash(r)
+	reqHash, err := HashRequest(c.Matcher, r)
 	if err != nil {
 		return nil, fmt.Errorf("failed to hash request: %w", err)
 	}
 
 	interactionIndices, ok := c.hashIndex[reqHash]
+	if c.MatchVary {
+		// Interactions with a Vary header match on the varied headers
+		// only, regardless of their hash.
+		interactionIndices = slices.DeleteFunc(slices.Clone(interactionIndices), func(idx int) bool {
+			_, vary := varyHeaders(c.Interactions[idx].Response)
+			return vary
+		})
+
+		candidates, err := c.varyCandidates(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to match request on varied headers: %w", err)
+		}
+		interactionIndices = append(interactionIndices, candidates...)
+		slices.Sort(interactionIndices)
+		ok = len(interactionIndices) > 0
+	}
+	if ok && (len(c.IncludeTags) > 0 || len(c.ExcludeTags) > 0) {
+		interactionIndices = slices.DeleteFunc(slices.Clone(interactionIndices), func(idx int) bool {
+			return !c.tagsAllowed(c.Interactions[idx])
+		})
+		ok = len(interactionIndices) > 0
+	}
 	if !ok {
 		slog.Warn("no interactions found for request hash", "hash", reqHash)
 		return nil, ErrInteractionNotFound
@@ -480,12 +1194,12 @@ func (c *Cassette) GetInteraction(r *http.Request) (*Interaction, error) {
 	var lastReplayedIdx int = -1
 	for _, idx := range interactionIndices {
 		interaction := c.Interactions[idx]
-		if !c.ReplayableInteractions && interaction.replayed {
+		if !c.ReplayableInteractions && interaction.WasReplayed() {
 			lastReplayedIdx = idx
 			continue
 		}
 
-		interaction.replayed = true
+		interaction.replayed.Store(true)
 		return c.overrideRecordedRequestBody(r, interaction, bodyBytes)
 	}
 
@@ -498,34 +1212,58 @@ func (c *Cassette) GetInteraction(r *http.Request) (*Interaction, error) {
 	return nil, ErrInteractionNotFound
 }
 
+// tagsAllowed returns true, if the tags of the interaction allow replaying
+// it according to the IncludeTags and ExcludeTags of the cassette.
+func (c *Cassette) tagsAllowed(i *Interaction) bool {
+	if slices.ContainsFunc(c.ExcludeTags, i.HasTag) {
+		return false
+	}
+	return len(c.IncludeTags) == 0 || slices.ContainsFunc(c.IncludeTags, i.HasTag)
+}
+
 // overrideRecordedRequestBody reads the request body from the HTTP request and
 // overrides the recorded request body in the interaction with the actual
 // request.  This is useful when the request body contains dynamic data that
 // needs to be re-used in the response, such as JSON-RPC id fields.
 func (c *Cassette) overrideRecordedRequestBody(r *http.Request, originalInteraction *Interaction, bodyBytes []byte) (*Interaction, error) {
+	// Large and multipart bodies are matched on their digest and parts, and
+	// not overridden.
+	if originalInteraction.Request.BodyDigest != "" || len(originalInteraction.Request.Parts) > 0 {
+		return originalInteraction.Clone(), nil
+	}
+
 	// Restore body for form parsing.
-	r.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+	r.Body = newReplayableBody(bodyBytes)
 
 	_ = r.ParseForm()
 
 	// Restore body again for further use.
-	r.Body = io.NopCloser(bytes.NewReader(bodyBytes))
-
-	// Create a copy of the interaction to modify.
-	interaction := *originalInteraction
-	interaction.Request = originalInteraction.Request
+	r.Body = newReplayableBody(bodyBytes)
 
+	// Create a copy of the interaction to modify, which is not shared with
+	// concurrent replays of the same interaction.
+	interaction := originalInteraction.Clone()
 	interaction.Request.Body = string(bodyBytes)
 	interaction.Request.Form = r.PostForm
 
-	return &interaction, nil
+	return interaction, nil
 }
 
-// Save writes the cassette data on disk for future re-use
+// Save writes the cassette data on disk for future re-use.
+//
+// The output is deterministic, so that cassettes can be reviewed and diffed
+// in version control: interactions are written in their recorded order,
+// fields in a fixed order, and header, form and metadata keys sorted, so
+// that re-recording a single interaction only changes the lines of that
+// interaction.
 func (c *Cassette) Save() error {
 	c.Lock()
 	defer c.Unlock()
 
+	if c.ReadOnly {
+		return fmt.Errorf("%w: %s", ErrReadOnlyCassette, c.Name)
+	}
+
 	file := c.File()
 
 	// Create directory for cassette if missing
@@ -536,6 +1274,55 @@ func (c *Cassette) Save() error {
 		}
 	}
 
+	if err := c.prepare(); err != nil {
+		return err
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := c.encode(f); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	c.modified = false
+
+	return nil
+}
+
+// Modified returns true, if interactions were added or replaced using
+// [Cassette.AddInteraction] and [Cassette.ReplaceInteraction], or hashes
+// were computed, since the cassette was loaded or saved. Changes made to the
+// Interactions field directly are not tracked.
+func (c *Cassette) Modified() bool {
+	c.Lock()
+	defer c.Unlock()
+	return c.modified
+}
+
+// SaveTo writes the cassette to w, without touching the file system, e.g.
+// for in-memory round-trips, network transport or custom storage layers.
+// The cassette is encoded and compressed the same way as by [Cassette.Save],
+// according to the Binary, CompressionEnabled and CompressionLevel fields.
+func (c *Cassette) SaveTo(w io.Writer) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if err := c.prepare(); err != nil {
+		return err
+	}
+
+	return c.encode(w)
+}
+
+// prepare discards interactions and scans for secrets before encoding the
+// cassette. The caller must hold the lock of the cassette.
+func (c *Cassette) prepare() error {
 	// Filter out interactions which should be discarded. While discarding
 	// interactions we should also fix the interaction IDs, so that we don't
 	// introduce gaps in the final results.
@@ -550,35 +1337,45 @@ func (c *Cassette) Save() error {
 	}
 	c.Interactions = interactions
 
-	// Marshal to YAML and save interactions
-	data, err := yaml.Marshal(c)
-	if err != nil {
-		return err
+	// Refuse to write leaked credentials on disk
+	if c.SecretScanner != nil {
+		if findings := c.SecretScanner.Scan(c.Interactions); len(findings) > 0 {
+			return &SecretsError{Findings: findings}
+		}
 	}
 
-	// underlying file
-	f, err := os.Create(file)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
+	return nil
+}
 
-	var w io.WriteCloser = f
+// encode writes the cassette to w. The caller must hold the lock of the
+// cassette.
+func (c *Cassette) encode(w io.Writer) error {
+	var gz *gzip.Writer
 	if c.CompressionEnabled {
-		w = gzip.NewWriter(f)
-		defer w.Close()
+		level := c.CompressionLevel
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		var err error
+		if gz, err = gzip.NewWriterLevel(w, level); err != nil {
+			return err
+		}
+		w = gz
 	}
 
-	// Honor the YAML structure specification
-	// http://www.yaml.org/spec/1.2/spec.html#id2760395
-	_, err = w.Write([]byte("---\n"))
-	if err != nil {
-		return err
+	if c.Binary {
+		if err := encodeBinary(w, c); err != nil {
+			return err
+		}
+	} else {
+		if err := encodeYAML(w, c); err != nil {
+			return err
+		}
 	}
-
-	_, err = w.Write(data)
-	if err != nil {
-		return err
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return err
+		}
 	}
 
 	return nil
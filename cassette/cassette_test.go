@@ -25,12 +25,20 @@
 package cassette
 
 import (
+	"bytes"
+	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func getMatcherRequests(t *testing.T) (*http.Request, Request) {
@@ -317,6 +325,120 @@ func TestMatcher(t *testing.T) {
 			}
 		})
 	})
+
+	t.Run("IgnoreTrailers", func(t *testing.T) {
+		matcher := NewMatcher(WithIgnoreTrailers())
+
+		t.Run("match", func(t *testing.T) {
+			r, i := getMatcherRequests(t)
+			r.Header.Set("Trailer", "X-Checksum")
+			r.Trailer = http.Header{"X-Checksum": {"abc"}}
+			if !hashesMatch(t, matcher, r, i) {
+				t.Fatalf("request should have matched")
+			}
+		})
+	})
+
+	t.Run("HashFields", func(t *testing.T) {
+		matcher := NewMatcher(WithHashFields(HashMethod | HashPath | HashBody))
+
+		t.Run("match", func(t *testing.T) {
+			r, i := getMatcherRequests(t)
+			r.Host = "other.example.com"
+			r.URL.RawQuery = "page=2"
+			r.Header = http.Header{"X-Request-Id": {"1"}}
+			r.ContentLength = -1
+			if !hashesMatch(t, matcher, r, i) {
+				t.Fatalf("request should have matched")
+			}
+		})
+
+		t.Run("not match Path", func(t *testing.T) {
+			r, i := getMatcherRequests(t)
+			r.URL.Path = "/other"
+			if hashesMatch(t, matcher, r, i) {
+				t.Fatalf("request should not have matched")
+			}
+		})
+	})
+
+	t.Run("IgnoreContentLength", func(t *testing.T) {
+		matcher := NewMatcher(WithIgnoreContentLength())
+
+		t.Run("match", func(t *testing.T) {
+			r, i := getMatcherRequests(t)
+			r.ContentLength = -1
+			r.Header.Set("Content-Length", "1")
+			if !hashesMatch(t, matcher, r, i) {
+				t.Fatalf("request should have matched")
+			}
+		})
+
+		t.Run("not match TransferEncoding", func(t *testing.T) {
+			r, i := getMatcherRequests(t)
+			r.TransferEncoding = []string{"chunked"}
+			if hashesMatch(t, matcher, r, i) {
+				t.Fatalf("request should not have matched")
+			}
+		})
+	})
+
+	t.Run("IgnoreTransferEncoding", func(t *testing.T) {
+		matcher := NewMatcher(WithIgnoreTransferEncoding())
+
+		t.Run("match", func(t *testing.T) {
+			r, i := getMatcherRequests(t)
+			r.TransferEncoding = []string{"chunked"}
+			r.ContentLength = -1
+			if !hashesMatch(t, matcher, r, i) {
+				t.Fatalf("request should have matched")
+			}
+		})
+
+		t.Run("not match Trailer", func(t *testing.T) {
+			r, i := getMatcherRequests(t)
+			r.Trailer = http.Header{"X-Checksum": {"abc"}}
+			if hashesMatch(t, matcher, r, i) {
+				t.Fatalf("request should not have matched")
+			}
+		})
+	})
+}
+
+func TestHashWith(t *testing.T) {
+	r1, r2 := getHasherRequests(t)
+	r2.Header.Set("X-Request-Id", "2")
+
+	hash1, err := HashWith(DefaultMatcher, r1, func(r *http.Request) {
+		r.Header.Del("X-Request-Id")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash2, err := HashWith(DefaultMatcher, r2, func(r *http.Request) {
+		r.Header.Del("X-Request-Id")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash1 != hash2 {
+		t.Fatalf("expected hashes to be identical, but got %q and %q", hash1, hash2)
+	}
+
+	// The request is left as is, and its body can still be read
+	if r2.Header.Get("X-Request-Id") != "2" {
+		t.Fatal("expected the header of the request to be kept")
+	}
+	want, err := DefaultMatcher.Hash(r2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want == hash2 {
+		t.Fatal("expected the hash of the request to differ")
+	}
+	if hash, _ := HashWith(DefaultMatcher, r1, func(*http.Request) {}); hash != hash1 {
+		t.Fatalf("got hash %q, want %q", hash, hash1)
+	}
 }
 
 func TestMatcherHash(t *testing.T) {
@@ -433,3 +555,585 @@ func TestMatcherHash(t *testing.T) {
 		})
 	})
 }
+
+func TestHashAlgorithm(t *testing.T) {
+	r1, r2 := getHasherRequests(t)
+	sha, err := NewMatcher().Hash(r1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matcher := NewMatcher(WithHashAlgorithm(HashXXH64))
+	hash1, err := matcher.Hash(r1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash2, err := matcher.Hash(r2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash1 != hash2 {
+		t.Fatalf("expected hashes to be identical for equivalent requests, but got %q and %q", hash1, hash2)
+	}
+	if !strings.HasPrefix(hash1, "xxh64:") || len(hash1) != len("xxh64:")+16 || hash1 == sha {
+		t.Fatalf("got hash %q, want an xxh64 hash", hash1)
+	}
+
+	// Hashes persisted using SHA-256 are recomputed
+	name := filepath.Join(t.TempDir(), "hash_algorithm")
+	c := New(name)
+	if _, err := c.AddStub(http.MethodGet, "https://example.com/", http.StatusOK, "ok"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	c = New(name)
+	c.Matcher = matcher
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if hash := c.Interactions[0].Hash; !strings.HasPrefix(hash, "xxh64:") {
+		t.Fatalf("got hash %q, want an xxh64 hash", hash)
+	}
+	r, err := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetInteraction(r); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHashFieldsRehash(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "hash_fields")
+	c := New(name)
+	if _, err := c.AddStub(http.MethodGet, "https://example.com/items?page=1", http.StatusOK, "ok"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	load := func(matcher RequestMatcher) *Cassette {
+		t.Helper()
+		c := New(name)
+		c.Matcher = matcher
+		if err := c.Load(); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	get := func(c *Cassette, url string) error {
+		t.Helper()
+		r, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = c.GetInteraction(r)
+		return err
+	}
+
+	// Hashes persisted by the default matcher are recomputed by a matcher
+	// hashing other fields
+	c = load(NewMatcher(WithHashFields(HashMethod | HashPath)))
+	if hash := c.Interactions[0].Hash; !strings.HasPrefix(hash, "m-") {
+		t.Fatalf("got hash %q, want a hash with a fingerprint", hash)
+	}
+	if err := get(c, "https://example.com/items?page=2"); err != nil {
+		t.Fatal(err)
+	}
+
+	// ... and again by the default matcher
+	c = load(DefaultMatcher)
+	if hash := c.Interactions[0].Hash; strings.HasPrefix(hash, "m-") {
+		t.Fatalf("got hash %q, want a hash without a fingerprint", hash)
+	}
+	if err := get(c, "https://example.com/items?page=2"); !errors.Is(err, ErrInteractionNotFound) {
+		t.Fatalf("got error %v, want %v", err, ErrInteractionNotFound)
+	}
+	if err := get(c, "https://example.com/items?page=1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRequestHasherPool(t *testing.T) {
+	for _, alg := range []HashAlgorithm{HashSHA256, HashXXH64} {
+		matcher := NewMatcher(WithHashAlgorithm(alg))
+		r, _ := getHasherRequests(t)
+		r.Header["X-Multi"] = []string{"b", "a"}
+		want, err := matcher.Hash(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.Header["X-Multi"]; got[0] != "b" {
+			t.Fatalf("got header values %q, want them unmodified", got)
+		}
+
+		// The pooled hashers are reset, and compute the same hash, also
+		// when used concurrently.
+		requests := make([]*http.Request, 16)
+		for idx := range requests {
+			requests[idx], _ = getHasherRequests(t)
+			requests[idx].Header["X-Multi"] = []string{"a", "b"}
+		}
+
+		var wg sync.WaitGroup
+		for _, r := range requests {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if got, err := matcher.Hash(r); err != nil || got != want {
+					t.Errorf("got hash %q, %v, want %q", got, err, want)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+func TestStripQueryParams(t *testing.T) {
+	got := StripQueryParams("https://example.com/path?b=2&api_key=secret&a=1&signature=x%20y", "api_key", "signature")
+	want := "https://example.com/path?b=2&a=1"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	matcher := NewMatcher(WithIgnoreQueryParams("ts"))
+	r1, r2 := getHasherRequests(t)
+	r1.URL.RawQuery = "q=1&ts=100"
+	r2.URL.RawQuery = "q=1&ts=200"
+
+	hash1, _ := matcher.Hash(r1)
+	hash2, _ := matcher.Hash(r2)
+	if hash1 != hash2 {
+		t.Error("expected hashes to be identical when ignored query params differ")
+	}
+
+	r2.URL.RawQuery = "q=2&ts=200"
+	hash2, _ = matcher.Hash(r2)
+	if hash1 == hash2 {
+		t.Error("expected hashes to be different when other query params differ")
+	}
+}
+
+func TestSaveDeterministic(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "deterministic")
+	newCassette := func(headerKeys []string) *Cassette {
+		c := New(name)
+		for n := range 3 {
+			i := &Interaction{
+				Request:  Request{Method: http.MethodGet, URL: fmt.Sprintf("https://example.com/%d", n), Headers: make(http.Header)},
+				Response: Response{Code: http.StatusOK, Body: fmt.Sprintf("body %d", n), Headers: make(http.Header)},
+			}
+			for _, k := range headerKeys {
+				i.Request.Headers.Set(k, "value")
+				i.Response.Headers.Set(k, "value")
+				i.SetMetadata(k, "value")
+			}
+			if err := c.AddInteraction(i); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return c
+	}
+	save := func(c *Cassette) string {
+		if err := c.Save(); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(c.File())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	// The insertion order of the keys does not affect the output
+	keys := []string{"X-B", "Accept", "X-A", "Content-Type"}
+	first := save(newCassette(keys))
+	slices.Reverse(keys)
+	if second := save(newCassette(keys)); second != first {
+		t.Fatalf("got different output:\n%s\nwant:\n%s", second, first)
+	}
+	if a, b := strings.Index(first, "Accept:"), strings.Index(first, "X-B:"); a < 0 || b < a {
+		t.Fatalf("expected sorted header keys:\n%s", first)
+	}
+
+	// Saving a loaded cassette reproduces its file
+	c, err := Load(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := save(c); got != first {
+		t.Fatalf("got different output after loading:\n%s\nwant:\n%s", got, first)
+	}
+
+	// Replacing an interaction only changes the lines of that interaction
+	replaced := c.Interactions[1].Clone()
+	replaced.Response.Body = "changed"
+	if err := c.ReplaceInteraction(1, replaced); err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Split(save(c), "\n")
+	want := strings.Split(first, "\n")
+	if len(got) != len(want) {
+		t.Fatalf("got %d lines, want %d", len(got), len(want))
+	}
+	var changed []string
+	for n := range got {
+		if got[n] != want[n] {
+			changed = append(changed, got[n])
+		}
+	}
+	if len(changed) != 1 || strings.TrimSpace(changed[0]) != "body: changed" {
+		t.Fatalf("got changed lines %q, want the body of the replaced interaction", changed)
+	}
+}
+
+func TestCompressionLevel(t *testing.T) {
+	dir := t.TempDir()
+	body := strings.Repeat(`{"id": 1, "name": "go-vcr", "tags": ["a", "b", "c"]}`, 1000)
+
+	sizes := make(map[int]int64)
+	for _, level := range []int{gzip.BestSpeed, 0, gzip.BestCompression} {
+		name := filepath.Join(dir, fmt.Sprintf("level-%d", level))
+		c := New(name)
+		c.CompressionEnabled = true
+		c.CompressionLevel = level
+		if err := c.AddInteraction(&Interaction{
+			Request:  Request{Method: http.MethodGet, URL: "https://example.com/"},
+			Response: Response{Code: http.StatusOK, Body: body},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Save(); err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := os.Stat(c.File())
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes[level] = info.Size()
+
+		loaded, err := Load(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := loaded.Interactions[0].Response.Body; got != body {
+			t.Fatalf("got body of %d bytes, want %d bytes", len(got), len(body))
+		}
+	}
+	if sizes[gzip.BestSpeed] < sizes[gzip.BestCompression] {
+		t.Fatalf("got %d bytes at best speed, want at least %d bytes at best compression", sizes[gzip.BestSpeed], sizes[gzip.BestCompression])
+	}
+
+	c := New(filepath.Join(dir, "invalid"))
+	c.CompressionEnabled = true
+	c.CompressionLevel = 42
+	if err := c.Save(); err == nil {
+		t.Fatal("expected invalid compression level to fail saving")
+	}
+}
+
+func TestSaveToLoadFrom(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name       string
+		binary     bool
+		compressed bool
+	}{
+		{name: "yaml"},
+		{name: "yaml.gz", compressed: true},
+		{name: "cbor", binary: true},
+		{name: "cbor.gz", binary: true, compressed: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := New(filepath.Join(dir, test.name))
+			c.Binary = test.binary
+			c.CompressionEnabled = test.compressed
+			for n := range 3 {
+				if err := c.AddInteraction(&Interaction{
+					Request:  Request{Method: http.MethodGet, URL: fmt.Sprintf("https://example.com/%d", n)},
+					Response: Response{Code: http.StatusOK, Body: fmt.Sprintf("body %d", n)},
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var buf bytes.Buffer
+			if err := c.SaveTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			loaded := New(c.Name)
+			if err := loaded.LoadFrom(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if loaded.IsNew {
+				t.Fatal("expected loaded cassette not to be new")
+			}
+			if loaded.Binary != test.binary || loaded.CompressionEnabled != test.compressed {
+				t.Fatalf("got binary %v and compressed %v, want %v and %v", loaded.Binary, loaded.CompressionEnabled, test.binary, test.compressed)
+			}
+			if len(loaded.Interactions) != 3 {
+				t.Fatalf("got %d interactions, want 3", len(loaded.Interactions))
+			}
+
+			// Loaded interactions are matched like those of loaded files
+			req, err := c.Interactions[2].GetHTTPRequest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			i, err := loaded.GetInteraction(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if i.Response.Body != "body 2" {
+				t.Fatalf("got body %q, want %q", i.Response.Body, "body 2")
+			}
+		})
+	}
+
+	// Neither saving nor loading touches the file system
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("got %d files, want none", len(entries))
+	}
+}
+
+func TestOlderThan(t *testing.T) {
+	c := New("test_older_than")
+	now := time.Now()
+	c.Interactions = []*Interaction{
+		{ID: 0, RecordedAt: now.Add(-48 * time.Hour)},
+		{ID: 1, RecordedAt: now.Add(-1 * time.Hour)},
+		{ID: 2},
+	}
+
+	stale := c.OlderThan(24 * time.Hour)
+	if len(stale) != 1 || stale[0].ID != 0 {
+		t.Fatalf("unexpected stale interactions %v", stale)
+	}
+
+	if age := c.Interactions[1].Age(); age < time.Hour {
+		t.Fatalf("unexpected age %s", age)
+	}
+
+	if age := c.Interactions[2].Age(); age != 0 {
+		t.Fatalf("expected unknown age to be zero, got %s", age)
+	}
+}
+
+func TestGetHTTPResponseProto(t *testing.T) {
+	i := &Interaction{
+		Request: Request{Method: http.MethodGet, URL: "https://example.com/"},
+		Response: Response{
+			Proto:            "HTTP/2.0",
+			ProtoMajor:       2,
+			TransferEncoding: []string{"chunked"},
+			Headers: http.Header{
+				"content-type": {"text/plain"},
+				"Connection":   {"keep-alive"},
+			},
+			Code: http.StatusOK,
+		},
+	}
+
+	resp, err := i.GetHTTPResponse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ProtoMajor != 2 || resp.Proto != "HTTP/2.0" {
+		t.Fatalf("unexpected protocol %s", resp.Proto)
+	}
+	if resp.TransferEncoding != nil {
+		t.Fatalf("unexpected transfer encoding %v", resp.TransferEncoding)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "text/plain" {
+		t.Fatalf("unexpected content type %q", got)
+	}
+	if got := resp.Header.Get("Connection"); got != "" {
+		t.Fatalf("unexpected connection header %q", got)
+	}
+	if _, ok := i.Response.Headers["content-type"]; !ok {
+		t.Fatal("recorded headers must not be modified")
+	}
+
+	DowngradeToHTTP1(resp)
+	if resp.ProtoMajor != 1 || resp.ProtoMinor != 1 || resp.Proto != "HTTP/1.1" {
+		t.Fatalf("unexpected protocol after downgrade %s", resp.Proto)
+	}
+
+	// Interactions without protocol are replayed as HTTP/1.1
+	i.Response.Proto, i.Response.ProtoMajor = "", 0
+	resp, err = i.GetHTTPResponse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ProtoMajor != 1 || resp.ProtoMinor != 1 {
+		t.Fatalf("unexpected default protocol %s", resp.Proto)
+	}
+}
+
+func TestGetHTTPResponseTrailer(t *testing.T) {
+	i := &Interaction{
+		Request: Request{Method: http.MethodGet, URL: "https://example.com/"},
+		Response: Response{
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			ContentLength: -1,
+			Body:          "hello",
+			Trailer:       http.Header{"grpc-status": {"0"}},
+			Code:          http.StatusOK,
+		},
+	}
+
+	resp, err := i.GetHTTPResponse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, ok := resp.Trailer["Grpc-Status"]; !ok || v != nil {
+		t.Fatalf("expected trailer to be announced without value, got %v", resp.Trailer)
+	}
+
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := resp.Trailer.Get("Grpc-Status"); got != "0" {
+		t.Fatalf("expected trailer after reading the body, got %q", got)
+	}
+}
+
+func TestGetHTTPResponseChunked(t *testing.T) {
+	i := &Interaction{
+		Request: Request{Method: http.MethodGet, URL: "https://example.com/"},
+		Response: Response{
+			Proto:            "HTTP/1.1",
+			ProtoMajor:       1,
+			ProtoMinor:       1,
+			TransferEncoding: []string{"chunked"},
+			ContentLength:    5,
+			Body:             "hello",
+			Headers:          http.Header{"Content-Length": {"5"}},
+			Code:             http.StatusOK,
+		},
+	}
+
+	resp, err := i.GetHTTPResponse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.ContentLength != -1 {
+		t.Fatalf("expected unknown content length, got %d", resp.ContentLength)
+	}
+	if got := resp.Header.Get("Content-Length"); got != "" {
+		t.Fatalf("unexpected Content-Length header %q", got)
+	}
+	if got := i.Response.Headers.Get("Content-Length"); got != "5" {
+		t.Fatal("recorded headers must not be modified")
+	}
+}
+
+func TestModified(t *testing.T) {
+	c := New(filepath.Join(t.TempDir(), "modified"))
+	if c.Modified() {
+		t.Fatal("expected new cassette not to be modified")
+	}
+
+	if err := c.AddInteraction(&Interaction{Request: Request{Method: http.MethodGet, URL: "https://example.com/"}}); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Modified() {
+		t.Fatal("expected cassette to be modified after adding an interaction")
+	}
+
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Modified() {
+		t.Fatal("expected cassette not to be modified after saving")
+	}
+
+	loaded, err := Load(c.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Modified() {
+		t.Fatal("expected loaded cassette not to be modified")
+	}
+}
+
+func TestInteractionClone(t *testing.T) {
+	parentID := 1
+	i := &Interaction{
+		ID:       2,
+		ParentID: &parentID,
+		Request: Request{
+			Method:  http.MethodPost,
+			URL:     "https://example.com/",
+			Headers: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+			Form:    url.Values{"name": {"go-vcr"}},
+		},
+		Response: Response{
+			Headers: http.Header{"Set-Cookie": {"a=1"}},
+			Trailer: http.Header{"Grpc-Status": {"0"}},
+			Code:    http.StatusOK,
+		},
+		Metadata: map[string]string{"test": "TestInteractionClone"},
+		Tags:     []string{"slow"},
+	}
+	i.replayed.Store(true)
+
+	clone := i.Clone()
+	if !clone.WasReplayed() || clone.ID != i.ID || *clone.ParentID != parentID {
+		t.Fatalf("got clone %+v, want a copy of %+v", clone, i)
+	}
+
+	clone.Request.Headers.Set("Content-Type", "text/plain")
+	clone.Request.Form.Set("name", "changed")
+	clone.Response.Headers["Set-Cookie"][0] = "a=2"
+	clone.Response.Trailer.Set("Grpc-Status", "1")
+	clone.Metadata["test"] = "changed"
+	clone.Tags[0] = "changed"
+	*clone.ParentID = 0
+
+	if i.Request.Headers.Get("Content-Type") != "application/x-www-form-urlencoded" ||
+		i.Request.Form.Get("name") != "go-vcr" ||
+		i.Response.Headers.Get("Set-Cookie") != "a=1" ||
+		i.Response.Trailer.Get("Grpc-Status") != "0" ||
+		i.Metadata["test"] != "TestInteractionClone" ||
+		i.Tags[0] != "slow" ||
+		parentID != 1 {
+		t.Fatalf("modifying the clone changed the interaction %+v", i)
+	}
+}
+
+func BenchmarkSave(b *testing.B) {
+	c := New(filepath.Join(b.TempDir(), "benchmark"))
+	for k := range 1000 {
+		i, err := c.AddStub(http.MethodGet, fmt.Sprintf("https://example.com/items/%d", k), http.StatusOK, fmt.Sprintf(`{"id": %d}`, k))
+		if err != nil {
+			b.Fatal(err)
+		}
+		i.Response.Headers.Set("Content-Type", "application/json")
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if err := c.Save(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
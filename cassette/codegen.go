@@ -0,0 +1,103 @@
+package cassette
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"net/url"
+	"slices"
+	"strconv"
+)
+
+// GenerateHandler writes the Go source of a self-contained [http.Handler],
+// which serves the recorded responses of the cassette, to w. The generated
+// file declares the package pkg, and a function with the given name
+// returning the handler, which only depends on the standard library.
+//
+// Requests are matched on their method, path and query string. If several
+// interactions share these, the first one is served. Requests, which do not
+// match any interaction, are answered with 404 Not Found.
+func GenerateHandler(w io.Writer, c *Cassette, pkg, name string) error {
+	if !token.IsIdentifier(pkg) {
+		return fmt.Errorf("invalid package name %q", pkg)
+	}
+	if !token.IsIdentifier(name) {
+		return fmt.Errorf("invalid function name %q", name)
+	}
+
+	c.Lock()
+	interactions := slices.Clone(c.Interactions)
+	c.Unlock()
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by govcr from %s. DO NOT EDIT.\n\n", c.File())
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	fmt.Fprintf(&buf, "import \"net/http\"\n\n")
+	fmt.Fprintf(&buf, "// %s returns an http.Handler serving the responses recorded in %s.\n", name, c.File())
+	fmt.Fprintf(&buf, "func %s() http.Handler {\n", name)
+	fmt.Fprintf(&buf, "return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {\n")
+	fmt.Fprintf(&buf, "switch {\n")
+
+	seen := make(map[string]bool)
+	for _, i := range interactions {
+		u, err := url.Parse(i.Request.URL)
+		if err != nil {
+			return fmt.Errorf("failed to parse request URL %s: %w", i.Request.URL, err)
+		}
+		path := u.Path
+		if path == "" {
+			path = "/"
+		}
+
+		key := i.Request.Method + " " + path + "?" + u.RawQuery
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		resp, err := i.GetHTTPResponse()
+		if err != nil {
+			return fmt.Errorf("failed to get response of interaction %d: %w", i.ID, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read response of interaction %d: %w", i.ID, err)
+		}
+
+		fmt.Fprintf(&buf, "case r.Method == %s && r.URL.Path == %s && r.URL.RawQuery == %s:\n",
+			strconv.Quote(i.Request.Method), strconv.Quote(path), strconv.Quote(u.RawQuery))
+		fmt.Fprintf(&buf, "// Interaction %d\n", i.ID)
+
+		header := canonicalHeader(resp.Header)
+		keys := make([]string, 0, len(header))
+		for k := range header {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			// The length and framing of the body are set by the server
+			if k == "Content-Length" || k == "Transfer-Encoding" {
+				continue
+			}
+			fmt.Fprintf(&buf, "w.Header()[%s] = %#v\n", strconv.Quote(k), header[k])
+		}
+		fmt.Fprintf(&buf, "w.WriteHeader(%d)\n", resp.StatusCode)
+		if len(body) > 0 {
+			fmt.Fprintf(&buf, "w.Write([]byte(%s))\n", strconv.Quote(string(body)))
+		}
+	}
+
+	fmt.Fprintf(&buf, "default:\nhttp.NotFound(w, r)\n")
+	fmt.Fprintf(&buf, "}\n})\n}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to format generated source: %w", err)
+	}
+
+	_, err = w.Write(src)
+	return err
+}
@@ -0,0 +1,75 @@
+package cassette
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGenerateHandler(t *testing.T) {
+	newInteraction := func(id int, method, url string, code int, body string) *Interaction {
+		return &Interaction{
+			ID: id,
+			Request: Request{
+				Method: method,
+				URL:    url,
+			},
+			Response: Response{
+				Code: code,
+				Body: body,
+				Headers: http.Header{
+					"content-type":   {"application/json"},
+					"Content-Length": {"8"},
+				},
+			},
+		}
+	}
+
+	c := New("testdata/users")
+	c.Interactions = []*Interaction{
+		newInteraction(0, http.MethodGet, "https://api.example.com/users/1", http.StatusOK, `{"id":1}`),
+		newInteraction(1, http.MethodGet, "https://api.example.com/users?page=2", http.StatusOK, "[\n\"`quoted`\"]"),
+		newInteraction(2, http.MethodDelete, "https://api.example.com/users/1", http.StatusNoContent, ""),
+		newInteraction(3, http.MethodGet, "https://api.example.com/users/1", http.StatusOK, `{"id":2}`),
+	}
+
+	var buf bytes.Buffer
+	if err := GenerateHandler(&buf, c, "fixtures", "UsersHandler"); err != nil {
+		t.Fatal(err)
+	}
+	src := buf.String()
+
+	if _, err := parser.ParseFile(token.NewFileSet(), "users.go", src, 0); err != nil {
+		t.Fatalf("generated source does not parse: %s\n%s", err, src)
+	}
+
+	for _, want := range []string{
+		"// Code generated by govcr from testdata/users.yaml. DO NOT EDIT.",
+		"package fixtures",
+		"func UsersHandler() http.Handler {",
+		`case r.Method == "GET" && r.URL.Path == "/users/1" && r.URL.RawQuery == "":`,
+		`case r.Method == "GET" && r.URL.Path == "/users" && r.URL.RawQuery == "page=2":`,
+		`case r.Method == "DELETE" && r.URL.Path == "/users/1" && r.URL.RawQuery == "":`,
+		`w.Header()["Content-Type"] = []string{"application/json"}`,
+		`w.Write([]byte("{\"id\":1}"))`,
+		"w.Write([]byte(\"[\\n\\\"`quoted`\\\"]\"))",
+		"w.WriteHeader(204)",
+		"http.NotFound(w, r)",
+	} {
+		if !strings.Contains(src, want) {
+			t.Fatalf("expected generated source to contain %s, got:\n%s", want, src)
+		}
+	}
+
+	// Only the first of several matching interactions is served
+	if strings.Contains(src, `{\"id\":2}`) || strings.Contains(src, "Content-Length") {
+		t.Fatalf("unexpected generated source:\n%s", src)
+	}
+
+	if err := GenerateHandler(&buf, c, "fixtures", "not valid"); err == nil {
+		t.Fatal("expected error for invalid function name")
+	}
+}
@@ -0,0 +1,97 @@
+package cassette
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// notModifiedHeaders are the headers of the recorded response, which are
+// preserved in a synthesized 304 Not Modified response, as per RFC 9110,
+// section 15.4.5.
+var notModifiedHeaders = []string{
+	"Cache-Control",
+	"Content-Location",
+	"Date",
+	"ETag",
+	"Expires",
+	"Last-Modified",
+	"Vary",
+}
+
+// GetHTTPConditionalResponse converts the recorded interaction response to
+// an http.Response for a request with the given If-None-Match and
+// If-Modified-Since headers.
+//
+// If the recorded response is a 200 response, whose ETag or Last-Modified
+// validators satisfy the conditions, a 304 Not Modified response is
+// synthesized, as described in RFC 9110, section 13. Otherwise the recorded
+// response is returned as is.
+func (i *Interaction) GetHTTPConditionalResponse(ifNoneMatch, ifModifiedSince string) (*http.Response, error) {
+	resp, err := i.GetHTTPResponse()
+	if err != nil {
+		return nil, err
+	}
+
+	if i.Response.Code != http.StatusOK || !notModified(resp.Header, ifNoneMatch, ifModifiedSince) {
+		return resp, nil
+	}
+
+	header := make(http.Header)
+	for _, h := range notModifiedHeaders {
+		if values := resp.Header.Values(h); len(values) > 0 {
+			header[http.CanonicalHeaderKey(h)] = values
+		}
+	}
+
+	resp.StatusCode = http.StatusNotModified
+	resp.Status = fmt.Sprintf("%d %s", http.StatusNotModified, http.StatusText(http.StatusNotModified))
+	resp.Header = header
+	resp.TransferEncoding = nil
+	resp.Trailer = nil
+	resp.ContentLength = 0
+	resp.Body = io.NopCloser(strings.NewReader(""))
+
+	return resp, nil
+}
+
+// notModified evaluates the If-None-Match and If-Modified-Since conditions
+// against the validators of the response. If-Modified-Since is only
+// evaluated, when If-None-Match is not present.
+func notModified(h http.Header, ifNoneMatch, ifModifiedSince string) bool {
+	if ifNoneMatch != "" {
+		etag := h.Get("ETag")
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || weakETag(candidate) == weakETag(etag) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ifModifiedSince == "" {
+		return false
+	}
+
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+	lastModified, err := http.ParseTime(h.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+
+	return !lastModified.After(since)
+}
+
+// weakETag returns the entity tag without the weakness indicator, since
+// If-None-Match uses the weak comparison function.
+func weakETag(etag string) string {
+	return strings.TrimPrefix(etag, "W/")
+}
@@ -0,0 +1,72 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestGetHTTPConditionalResponse(t *testing.T) {
+	i := &Interaction{
+		Request: Request{Method: http.MethodGet, URL: "https://example.com/"},
+		Response: Response{
+			Code:          http.StatusOK,
+			Status:        "200 OK",
+			Body:          "hello",
+			ContentLength: 5,
+			Headers: http.Header{
+				"Etag":           {`W/"v1"`},
+				"Last-Modified":  {"Wed, 21 Oct 2015 07:28:00 GMT"},
+				"Cache-Control":  {"max-age=60"},
+				"Content-Type":   {"text/plain"},
+				"Content-Length": {"5"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name            string
+		ifNoneMatch     string
+		ifModifiedSince string
+		wantCode        int
+	}{
+		{name: "unconditional", wantCode: http.StatusOK},
+		{name: "etag match", ifNoneMatch: `"v1"`, wantCode: http.StatusNotModified},
+		{name: "etag list", ifNoneMatch: `"v0", W/"v1"`, wantCode: http.StatusNotModified},
+		{name: "etag wildcard", ifNoneMatch: "*", wantCode: http.StatusNotModified},
+		{name: "etag mismatch", ifNoneMatch: `"v2"`, wantCode: http.StatusOK},
+		{name: "not modified since", ifModifiedSince: "Thu, 22 Oct 2015 07:28:00 GMT", wantCode: http.StatusNotModified},
+		{name: "modified since", ifModifiedSince: "Tue, 20 Oct 2015 07:28:00 GMT", wantCode: http.StatusOK},
+		{name: "etag takes precedence", ifNoneMatch: `"v2"`, ifModifiedSince: "Thu, 22 Oct 2015 07:28:00 GMT", wantCode: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := i.GetHTTPConditionalResponse(test.ifNoneMatch, test.ifModifiedSince)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != test.wantCode {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, test.wantCode)
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.wantCode != http.StatusNotModified {
+				return
+			}
+
+			if len(body) != 0 || resp.ContentLength != 0 {
+				t.Fatalf("unexpected body %q", body)
+			}
+			if resp.Header.Get("Content-Type") != "" || resp.Header.Get("Content-Length") != "" {
+				t.Fatalf("unexpected headers %v", resp.Header)
+			}
+			if resp.Header.Get("ETag") != `W/"v1"` || resp.Header.Get("Cache-Control") != "max-age=60" {
+				t.Fatalf("expected validators and caching headers, got %v", resp.Header)
+			}
+		})
+	}
+}
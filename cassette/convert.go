@@ -0,0 +1,275 @@
+package cassette
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrUnsupportedFileFormat is returned when converting a cassette to a file,
+// whose extension does not denote a supported format.
+var ErrUnsupportedFileFormat = errors.New("unsupported cassette file format")
+
+// ConvertOption is a function which configures [Convert].
+type ConvertOption func(c *converter)
+
+// converter is the configuration of [Convert].
+type converter struct {
+	removeSource     bool
+	compressionLevel int
+}
+
+// WithConvertRemoveSource is a [ConvertOption], which removes the source
+// file after it was converted successfully, unless it is the destination
+// file.
+func WithConvertRemoveSource() ConvertOption {
+	return func(c *converter) {
+		c.removeSource = true
+	}
+}
+
+// WithConvertCompressionLevel is a [ConvertOption], which compresses the
+// destination file using the given compression level. For gzip, the level
+// ranges from [gzip.BestSpeed] to [gzip.BestCompression], and for zstd, it
+// is mapped to the closest level of the encoder, see
+// [zstd.EncoderLevelFromZstd].
+func WithConvertCompressionLevel(level int) ConvertOption {
+	return func(c *converter) {
+		c.compressionLevel = level
+	}
+}
+
+// Convert re-serializes the cassette file src to the file dst, converting
+// between formats and compressions, and upgrading cassettes of older format
+// versions to the current one. The format and compression of src are
+// detected from its contents, while those of dst are given by its
+// extensions, i.e. .yaml, .yml or .cbor, optionally followed by .gz or .zst.
+// The source and destination may be the same file, e.g. to upgrade its
+// format version in place.
+func Convert(src, dst string, opts ...ConvertOption) error {
+	cv := &converter{}
+	for _, opt := range opts {
+		opt(cv)
+	}
+
+	binary, compression, err := fileFormat(dst)
+	if err != nil {
+		return err
+	}
+
+	c, err := readCassetteFile(src)
+	if err != nil {
+		return err
+	}
+	c.Binary, c.CompressionEnabled = binary, compression == ".gz"
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch compression {
+	case ".gz":
+		level := cv.compressionLevel
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		if w, err = gzip.NewWriterLevel(&buf, level); err != nil {
+			return err
+		}
+	case ".zst":
+		var zopts []zstd.EOption
+		if cv.compressionLevel != 0 {
+			zopts = append(zopts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(cv.compressionLevel)))
+		}
+		if w, err = zstd.NewWriter(&buf, zopts...); err != nil {
+			return err
+		}
+	}
+
+	var out io.Writer = &buf
+	if w != nil {
+		out = w
+	}
+	if binary {
+		err = encodeBinary(out, c)
+	} else {
+		err = encodeYAML(out, c)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette file %s: %w", dst, err)
+	}
+	if w != nil {
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("failed to compress cassette file %s: %w", dst, err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(dst, buf.Bytes(), 0o666); err != nil {
+		return err
+	}
+
+	if cv.removeSource && filepath.Clean(src) != filepath.Clean(dst) {
+		return os.Remove(src)
+	}
+
+	return nil
+}
+
+// fileFormat returns the format and compression of a cassette file given
+// by its extensions.
+func fileFormat(file string) (binary bool, compression string, err error) {
+	name := file
+	for _, ext := range []string{".gz", ".zst"} {
+		if trimmed, ok := strings.CutSuffix(name, ext); ok {
+			name, compression = trimmed, ext
+			break
+		}
+	}
+
+	switch filepath.Ext(name) {
+	case ".yaml", ".yml":
+		return false, compression, nil
+	case ".cbor":
+		return true, compression, nil
+	default:
+		return false, "", fmt.Errorf("%w: %s", ErrUnsupportedFileFormat, file)
+	}
+}
+
+// readCassetteFile reads the cassette file, detecting its format and
+// compression, and upgrades it to the current format version.
+func readCassetteFile(file string) (*Cassette, error) {
+	f, _, binary, err := openCassetteFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette file %s: %w", file, err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette file %s: %w", file, err)
+	}
+
+	name := strings.TrimSuffix(strings.TrimSuffix(file, ".gz"), ".zst")
+	c := New(strings.TrimSuffix(name, filepath.Ext(name)))
+	c.IsNew = false
+	if binary {
+		err = decodeBinary(bytes.NewReader(data), c)
+	} else {
+		var header struct {
+			Version int `yaml:"version"`
+		}
+		if err = yaml.Unmarshal(data, &header); err == nil {
+			if header.Version == 1 {
+				err = decodeYAMLv1(data, c)
+			} else {
+				err = yaml.Unmarshal(data, c)
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode cassette file %s: %w", file, err)
+	}
+
+	if c.Version != CassetteFormatVersion {
+		return nil, fmt.Errorf("%w: found version %d, but reader supports version %d", ErrUnsupportedCassetteFormat, c.Version, CassetteFormatVersion)
+	}
+
+	c.nextInteractionId = len(c.Interactions)
+	if _, err := c.buildHashIndex(); err != nil {
+		return nil, fmt.Errorf("failed to build hash index for cassette %s: %w", file, err)
+	}
+
+	return c, nil
+}
+
+// encodeYAML writes the cassette as YAML document to w.
+func encodeYAML(w io.Writer, c *Cassette) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	// Honor the YAML structure specification
+	// http://www.yaml.org/spec/1.2/spec.html#id2760395
+	if _, err := w.Write([]byte("---\n")); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// cassetteV1 is the cassette format version 1, as written by go-vcr v1,
+// whose interactions have no ids, and whose durations are strings.
+type cassetteV1 struct {
+	Interactions []struct {
+		Request struct {
+			Body    string              `yaml:"body"`
+			Form    map[string][]string `yaml:"form"`
+			Headers map[string][]string `yaml:"headers"`
+			URL     string              `yaml:"url"`
+			Method  string              `yaml:"method"`
+		} `yaml:"request"`
+		Response struct {
+			Body     string              `yaml:"body"`
+			Headers  map[string][]string `yaml:"headers"`
+			Status   string              `yaml:"status"`
+			Code     int                 `yaml:"code"`
+			Duration string              `yaml:"duration"`
+		} `yaml:"response"`
+	} `yaml:"interactions"`
+}
+
+// decodeYAMLv1 decodes the cassette of format version 1, and upgrades it to
+// the current format version.
+func decodeYAMLv1(data []byte, c *Cassette) error {
+	var v1 cassetteV1
+	if err := yaml.Unmarshal(data, &v1); err != nil {
+		return err
+	}
+
+	c.Version = CassetteFormatVersion
+	c.Interactions = make([]*Interaction, 0, len(v1.Interactions))
+	for n, i := range v1.Interactions {
+		var duration time.Duration
+		if i.Response.Duration != "" {
+			d, err := time.ParseDuration(i.Response.Duration)
+			if err != nil {
+				return fmt.Errorf("invalid duration of interaction %d: %w", n, err)
+			}
+			duration = d
+		}
+
+		c.Interactions = append(c.Interactions, &Interaction{
+			ID: n,
+			Request: Request{
+				ContentLength: int64(len(i.Request.Body)),
+				Body:          i.Request.Body,
+				Form:          i.Request.Form,
+				Headers:       i.Request.Headers,
+				URL:           i.Request.URL,
+				Method:        i.Request.Method,
+			},
+			Response: Response{
+				ContentLength: int64(len(i.Response.Body)),
+				Body:          i.Response.Body,
+				Headers:       i.Response.Headers,
+				Status:        i.Response.Status,
+				Code:          i.Response.Code,
+				Duration:      duration,
+			},
+		})
+	}
+
+	return nil
+}
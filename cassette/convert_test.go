@@ -0,0 +1,119 @@
+package cassette
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConvert(t *testing.T) {
+	dir := t.TempDir()
+
+	v1 := `---
+version: 1
+interactions:
+- request:
+    body: ""
+    form: {}
+    headers: {}
+    url: https://example.com/
+    method: GET
+  response:
+    body: hello
+    headers: {}
+    status: 200 OK
+    code: 200
+    duration: 1.5ms
+- request:
+    body: name=go-vcr
+    form: {}
+    headers: {}
+    url: https://example.com/users
+    method: POST
+  response:
+    body: created
+    headers: {}
+    status: 201 Created
+    code: 201
+    duration: ""
+`
+	src := filepath.Join(dir, "v1.yaml")
+	if err := os.WriteFile(src, []byte(v1), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Version 1 cassettes are rejected by Load
+	if _, err := Load(filepath.Join(dir, "v1")); err == nil {
+		t.Fatal("expected version 1 cassette to fail loading")
+	}
+
+	tests := []struct {
+		dst        string
+		wantBinary bool
+	}{
+		{dst: "v2.yaml"},
+		{dst: "v2.yaml.gz"},
+		{dst: "v2.cbor", wantBinary: true},
+		{dst: "v2.cbor.zst", wantBinary: true},
+	}
+	for _, test := range tests {
+		t.Run(test.dst, func(t *testing.T) {
+			dst := filepath.Join(dir, test.dst)
+			if err := Convert(src, dst); err != nil {
+				t.Fatal(err)
+			}
+
+			c := New(filepath.Join(dir, "v2"))
+			c.IsNew = false
+			if err := c.Load(); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(dst)
+
+			if c.Binary != test.wantBinary {
+				t.Fatalf("got binary %v, want %v", c.Binary, test.wantBinary)
+			}
+			if c.Version != CassetteFormatVersion {
+				t.Fatalf("got version %d, want %d", c.Version, CassetteFormatVersion)
+			}
+			if len(c.Interactions) != 2 {
+				t.Fatalf("got %d interactions, want 2", len(c.Interactions))
+			}
+			for n, i := range c.Interactions {
+				if i.ID != n {
+					t.Fatalf("got interaction id %d, want %d", i.ID, n)
+				}
+			}
+			if got := c.Interactions[0].Response.Duration; got != 1500*time.Microsecond {
+				t.Fatalf("got duration %s, want 1.5ms", got)
+			}
+			if got := c.Interactions[1].Request; got.Method != http.MethodPost || got.Body != "name=go-vcr" || got.ContentLength != 11 {
+				t.Fatalf("got request %+v", got)
+			}
+		})
+	}
+
+	// The source is kept, unless requested otherwise
+	if _, err := os.Stat(src); err != nil {
+		t.Fatal(err)
+	}
+
+	// Upgrade the cassette in place
+	if err := Convert(src, src, WithConvertRemoveSource()); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load(filepath.Join(dir, "v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Interactions) != 2 {
+		t.Fatalf("got %d interactions, want 2", len(c.Interactions))
+	}
+
+	if err := Convert(src, filepath.Join(dir, "v2.json")); !errors.Is(err, ErrUnsupportedFileFormat) {
+		t.Fatalf("got error %v, want %v", err, ErrUnsupportedFileFormat)
+	}
+}
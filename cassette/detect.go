@@ -0,0 +1,122 @@
+package cassette
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// formatExtensions are the extensions of the cassette files, which are
+// detected when loading, in order of preference.
+var formatExtensions = []string{".yaml", ".yml", ".json", ".cbor"}
+
+// compressionExtensions are the extensions of the compressed cassette
+// files, which are detected when loading, in order of preference.
+var compressionExtensions = []string{"", ".gz", ".zst"}
+
+// Magic bytes of the compression formats.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Locate returns the name of the existing cassette file on disk. This is
+// the file returned by [Cassette.File], if it exists, or the first existing
+// file of the cassette with one of the .yaml, .yml, .json or .cbor
+// extensions, optionally followed by the .gz or .zst extensions. The
+// returned error satisfies [os.IsNotExist], if no cassette file exists.
+func (c *Cassette) Locate() (string, error) {
+	file := c.File()
+	_, err := os.Stat(file)
+	if !os.IsNotExist(err) {
+		return file, err
+	}
+
+	for _, ext := range formatExtensions {
+		for _, compression := range compressionExtensions {
+			candidate := c.Name + ext + compression
+			if info, statErr := os.Stat(candidate); statErr == nil && info.Mode().IsRegular() {
+				return candidate, nil
+			}
+		}
+	}
+
+	return file, err
+}
+
+// openCassetteFile opens the cassette file for reading, and detects its
+// compression and format from its magic bytes.
+func openCassetteFile(file string) (r io.ReadCloser, compressed, binary bool, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, false, false, err
+	}
+
+	cf, compressed, binary, err := newCassetteReader(f)
+	if err != nil {
+		f.Close()
+		return nil, false, false, err
+	}
+	cf.closers = append([]io.Closer{f}, cf.closers...)
+
+	return cf, compressed, binary, nil
+}
+
+// newCassetteReader returns a reader of the decompressed cassette read from
+// r, and detects its compression and format from its magic bytes. Closing
+// the returned reader does not close r.
+func newCassetteReader(r io.Reader) (cf *cassetteFile, compressed, binary bool, err error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(zstdMagic))
+
+	var reader io.Reader = br
+	var closers []io.Closer
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, false, false, fmt.Errorf("failed to create gzip reader: %w", err)
+		}
+		reader, compressed = gz, true
+		closers = append(closers, gz)
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, false, false, fmt.Errorf("failed to create zstd reader: %w", err)
+		}
+		zrc := zr.IOReadCloser()
+		reader = zrc
+		closers = append(closers, zrc)
+	}
+
+	// CBOR cassettes start with a map, while YAML and JSON documents never
+	// start with a byte in this range.
+	content := bufio.NewReader(reader)
+	if first, err := content.Peek(1); err == nil && first[0] >= 0xa0 && first[0] <= 0xbf {
+		binary = true
+	}
+
+	return &cassetteFile{Reader: content, closers: closers}, compressed, binary, nil
+}
+
+// cassetteFile is an opened, and possibly decompressed, cassette file.
+type cassetteFile struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Close closes the readers of the file.
+func (f *cassetteFile) Close() error {
+	var err error
+	for i := len(f.closers) - 1; i >= 0; i-- {
+		if closeErr := f.closers[i].Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,98 @@
+package cassette
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestLoadDetectsFormat(t *testing.T) {
+	dir := t.TempDir()
+
+	// Render the cassette in each of the formats
+	src := New(filepath.Join(dir, "src"))
+	if err := src.AddInteraction(&Interaction{
+		Request:  Request{Method: http.MethodGet, URL: "https://example.com/"},
+		Response: Response{Code: http.StatusOK, Body: "hello"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.Save(); err != nil {
+		t.Fatal(err)
+	}
+	yamlData, err := os.ReadFile(src.File())
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.Binary = true
+	if err := src.Save(); err != nil {
+		t.Fatal(err)
+	}
+	cborData, err := os.ReadFile(src.File())
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonData := []byte(`{"version": 2, "interactions": [{"id": 0, "request": {"method": "GET", "url": "https://example.com/"}, "response": {"code": 200, "body": "hello"}}]}`)
+
+	var gzData bytes.Buffer
+	gz := gzip.NewWriter(&gzData)
+	gz.Write(yamlData)
+	gz.Close()
+
+	var zstData bytes.Buffer
+	zw, err := zstd.NewWriter(&zstData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw.Write(cborData)
+	zw.Close()
+
+	tests := []struct {
+		file           string
+		data           []byte
+		wantCompressed bool
+		wantBinary     bool
+	}{
+		{file: "plain.yaml", data: yamlData},
+		{file: "short.yml", data: yamlData},
+		{file: "json.json", data: jsonData},
+		{file: "gzip.yaml.gz", data: gzData.Bytes(), wantCompressed: true},
+		{file: "mislabeled.yaml", data: gzData.Bytes(), wantCompressed: true},
+		{file: "binary.cbor", data: cborData, wantBinary: true},
+		{file: "zstd.cbor.zst", data: zstData.Bytes(), wantBinary: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			file := filepath.Join(dir, test.file)
+			if err := os.WriteFile(file, test.data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			base, _, _ := strings.Cut(test.file, ".")
+
+			c := New(filepath.Join(dir, base))
+			if located, err := c.Locate(); err != nil || located != file {
+				t.Fatalf("got located file %s and error %v, want %s", located, err, file)
+			}
+			if err := c.Load(); err != nil {
+				t.Fatal(err)
+			}
+			if len(c.Interactions) != 1 || c.Interactions[0].Response.Body != "hello" {
+				t.Fatalf("unexpected interactions %+v", c.Interactions)
+			}
+			if c.CompressionEnabled != test.wantCompressed || c.Binary != test.wantBinary {
+				t.Fatalf("got compressed %v and binary %v, want %v and %v", c.CompressionEnabled, c.Binary, test.wantCompressed, test.wantBinary)
+			}
+		})
+	}
+
+	if _, err := New(filepath.Join(dir, "missing")).Locate(); !os.IsNotExist(err) {
+		t.Fatalf("got error %v, want not exist error", err)
+	}
+}
@@ -0,0 +1,266 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	// diffContext is the number of unchanged lines shown around changes.
+	diffContext = 3
+
+	// maxDiffCells bounds the size of the table used to compute diffs.
+	// Larger inputs are shown as replaced as a whole.
+	maxDiffCells = 4 << 20
+)
+
+// diffOp is a line of a diff, prefixed with ' ', '-' or '+'.
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// DiffBodies returns a readable diff of the recorded and actual bodies of
+// the given content type, or an empty string, if they are structurally
+// equal. JSON bodies, as indicated by the content type, or by both bodies
+// being valid JSON if it is empty, are compared structurally, i.e.
+// regardless of their formatting and the order of object keys, and the
+// differences are shown line by line of the indented documents. URL encoded
+// forms are compared regardless of the order of their parameters. Other
+// bodies are compared as text, and their differences are shown as a unified
+// diff.
+func DiffBodies(recorded, actual string, contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		if r, ok := formLines(recorded); ok {
+			if a, ok := formLines(actual); ok {
+				recorded, actual = r, a
+			}
+		}
+
+	case mediaType == "", mediaType == "application/json", strings.HasSuffix(mediaType, "+json"):
+		if r, ok := normalizeJSON(recorded); ok {
+			if a, ok := normalizeJSON(actual); ok {
+				recorded, actual = r, a
+			}
+		}
+	}
+
+	return unifiedDiff("recorded", "actual", recorded, actual)
+}
+
+// normalizeJSON indents the body with the object keys sorted, and returns
+// false if it is not valid JSON.
+func normalizeJSON(body string) (string, bool) {
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return "", false
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return "", false
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), true
+}
+
+// formLines returns the parameters of the URL encoded form one per line,
+// sorted by their keys, and returns false if it is not a valid form.
+func formLines(body string) (string, bool) {
+	values, err := url.ParseQuery(body)
+	if err != nil {
+		return "", false
+	}
+
+	var lines []string
+	for _, k := range slices.Sorted(maps.Keys(values)) {
+		for _, v := range values[k] {
+			lines = append(lines, k+"="+v)
+		}
+	}
+	return strings.Join(lines, "\n"), true
+}
+
+// unifiedDiff returns a line based unified diff of a and b, or an empty
+// string, if they are equal.
+func unifiedDiff(nameA, nameB, a, b string) string {
+	if a == b {
+		return ""
+	}
+
+	ops := diffLines(strings.Split(a, "\n"), strings.Split(b, "\n"))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", nameA, nameB)
+
+	// Group the changes into hunks, including the surrounding context, and
+	// merge overlapping hunks.
+	var hunks [][2]int
+	for idx, op := range ops {
+		if op.kind == ' ' {
+			continue
+		}
+		start, end := max(idx-diffContext, 0), min(idx+diffContext+1, len(ops))
+		if n := len(hunks); n > 0 && start <= hunks[n-1][1] {
+			hunks[n-1][1] = end
+		} else {
+			hunks = append(hunks, [2]int{start, end})
+		}
+	}
+
+	// lineA and lineB are the 1-based line numbers at op pos
+	lineA, lineB, pos := 1, 1, 0
+	for _, hunk := range hunks {
+		for ; pos < hunk[0]; pos++ {
+			lineA, lineB = advanceLines(ops[pos], lineA, lineB)
+		}
+
+		var countA, countB int
+		for _, op := range ops[hunk[0]:hunk[1]] {
+			countA, countB = advanceLines(op, countA, countB)
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", lineA, countA, lineB, countB)
+		for _, op := range ops[hunk[0]:hunk[1]] {
+			fmt.Fprintf(&sb, "%c%s\n", op.kind, op.line)
+		}
+	}
+
+	return sb.String()
+}
+
+func advanceLines(op diffOp, lineA, lineB int) (int, int) {
+	if op.kind != '+' {
+		lineA++
+	}
+	if op.kind != '-' {
+		lineB++
+	}
+	return lineA, lineB
+}
+
+// diffLines computes the diff of the lines using their longest common
+// subsequence.
+func diffLines(a, b []string) []diffOp {
+	// Strip the common prefix and suffix
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	n, m := len(midA), len(midB)
+	if n*m > maxDiffCells {
+		for _, line := range midA {
+			ops = append(ops, diffOp{'-', line})
+		}
+		for _, line := range midB {
+			ops = append(ops, diffOp{'+', line})
+		}
+	} else {
+		// lcs[i][j] is the length of the LCS of midA[i:] and midB[j:]
+		lcs := make([][]int, n+1)
+		for i := range lcs {
+			lcs[i] = make([]int, m+1)
+		}
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < n || j < m {
+			switch {
+			case i < n && j < m && midA[i] == midB[j]:
+				ops = append(ops, diffOp{' ', midA[i]})
+				i++
+				j++
+			case i < n && (j == m || lcs[i+1][j] >= lcs[i][j+1]):
+				ops = append(ops, diffOp{'-', midA[i]})
+				i++
+			default:
+				ops = append(ops, diffOp{'+', midB[j]})
+				j++
+			}
+		}
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+
+	return ops
+}
+
+// HeaderDiff returns a table of the headers, whose values differ between the
+// expected and actual headers, or an empty string, if there are none.
+// Header keys are compared in their canonical form, and values regardless
+// of their order.
+func HeaderDiff(expected, actual http.Header) string {
+	expected, actual = canonicalHeader(expected), canonicalHeader(actual)
+
+	keys := make([]string, 0, len(expected)+len(actual))
+	for k := range expected {
+		keys = append(keys, k)
+	}
+	for k := range actual {
+		if _, ok := expected[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+
+	var sb strings.Builder
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "HEADER\tEXPECTED\tACTUAL")
+	differ := false
+	for _, k := range keys {
+		e, a := slices.Sorted(slices.Values(expected[k])), slices.Sorted(slices.Values(actual[k]))
+		if slices.Equal(e, a) {
+			continue
+		}
+		differ = true
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", k, formatHeaderValues(expected, k), formatHeaderValues(actual, k))
+	}
+	tw.Flush()
+
+	if !differ {
+		return ""
+	}
+	return sb.String()
+}
+
+func formatHeaderValues(h http.Header, key string) string {
+	values, ok := h[key]
+	if !ok {
+		return "(missing)"
+	}
+	return fmt.Sprintf("%q", values)
+}
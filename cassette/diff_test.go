@@ -0,0 +1,165 @@
+package cassette
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDiffBodies(t *testing.T) {
+	tests := []struct {
+		name        string
+		recorded    string
+		actual      string
+		contentType string
+		want        string
+	}{
+		{
+			name:        "json key order",
+			recorded:    `{"id":1,"name":"foo"}`,
+			actual:      `{ "name": "foo", "id": 1 }`,
+			contentType: "application/json; charset=utf-8",
+			want:        "",
+		},
+		{
+			name:        "json",
+			recorded:    `{"name":"foo","id":1,"url":"https://example.com/?a=1&b=2"}`,
+			actual:      `{"id":2,"name":"foo","url":"https://example.com/?a=1&b=2"}`,
+			contentType: "application/problem+json",
+			want: `--- recorded
++++ actual
+@@ -1,5 +1,5 @@
+ {
+-  "id": 1,
++  "id": 2,
+   "name": "foo",
+   "url": "https://example.com/?a=1&b=2"
+ }
+`,
+		},
+		{
+			name:     "sniffed json",
+			recorded: `[1.50, 2]`,
+			actual:   `[1.5, 2]`,
+			want: `--- recorded
++++ actual
+@@ -1,4 +1,4 @@
+ [
+-  1.50,
++  1.5,
+   2
+ ]
+`,
+		},
+		{
+			name:        "form",
+			recorded:    "b=2&a=1&c=3",
+			actual:      "a=1&c=4&b=2",
+			contentType: "application/x-www-form-urlencoded",
+			want: `--- recorded
++++ actual
+@@ -1,3 +1,3 @@
+ a=1
+ b=2
+-c=3
++c=4
+`,
+		},
+		{
+			name:        "equal text",
+			recorded:    "foo\nbar",
+			actual:      "foo\nbar",
+			contentType: "text/plain",
+			want:        "",
+		},
+		{
+			name:        "changed line",
+			recorded:    "a\nb\nc\nd\ne\nf\ng\nh\ni",
+			actual:      "a\nb\nc\nd\nE\nf\ng\nh\ni",
+			contentType: "text/plain",
+			want: `--- recorded
++++ actual
+@@ -2,7 +2,7 @@
+ b
+ c
+ d
+-e
++E
+ f
+ g
+ h
+`,
+		},
+		{
+			name:        "separate hunks",
+			recorded:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12",
+			actual:      "0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11",
+			contentType: "text/plain",
+			want: `--- recorded
++++ actual
+@@ -1,3 +1,4 @@
++0
+ 1
+ 2
+ 3
+@@ -9,4 +10,3 @@
+ 9
+ 10
+ 11
+-12
+`,
+		},
+		{
+			name:        "text",
+			recorded:    `{"id":1}`,
+			actual:      `{"id": 1}`,
+			contentType: "text/plain",
+			want: `--- recorded
++++ actual
+@@ -1,1 +1,1 @@
+-{"id":1}
++{"id": 1}
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := DiffBodies(test.recorded, test.actual, test.contentType); got != test.want {
+				t.Fatalf("got diff:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestHeaderDiff(t *testing.T) {
+	expected := http.Header{
+		"Content-Type": {"application/json"},
+		"x-request-id": {"1"},
+		"Vary":         {"Accept", "Origin"},
+	}
+	actual := http.Header{
+		"Content-Type": {"text/plain"},
+		"X-Request-Id": {"1"},
+		"Vary":         {"Origin", "Accept"},
+		"Etag":         {`"abc"`},
+	}
+
+	got := HeaderDiff(expected, actual)
+	lines := strings.Split(strings.TrimSpace(got), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected header line and 2 differing headers, got:\n%s", got)
+	}
+	for i, want := range []string{"HEADER", "Content-Type", "Etag"} {
+		if !strings.HasPrefix(lines[i], want) {
+			t.Fatalf("expected line %d to start with %s, got:\n%s", i, want, got)
+		}
+	}
+	if !strings.Contains(lines[2], "(missing)") {
+		t.Fatalf("expected missing header to be marked, got:\n%s", got)
+	}
+
+	if got := HeaderDiff(expected, expected); got != "" {
+		t.Fatalf("expected no diff, got:\n%s", got)
+	}
+}
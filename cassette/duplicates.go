@@ -0,0 +1,94 @@
+package cassette
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrDuplicateInteractions is returned when loading a cassette, whose
+// interactions match the same requests, if configured using
+// [DuplicatesError].
+var ErrDuplicateInteractions = errors.New("duplicate interactions")
+
+// DuplicatePolicy specifies how loading a cassette treats interactions,
+// which match the same requests, see [Cassette.Duplicates].
+type DuplicatePolicy int
+
+const (
+	// DuplicatesAllow loads cassettes with duplicate interactions silently,
+	// e.g. for polling the same endpoint repeatedly.
+	DuplicatesAllow DuplicatePolicy = iota
+
+	// DuplicatesWarn logs a warning listing the duplicate interactions.
+	DuplicatesWarn
+
+	// DuplicatesError fails loading the cassette with
+	// [ErrDuplicateInteractions].
+	DuplicatesError
+)
+
+// Duplicates returns the ids of the interactions, which share the same hash
+// of the matcher, grouped by hash in the order of their first interaction.
+// Such interactions are replayed in their recorded order, which makes the
+// replay depend on the order of the requests, or only the first one is
+// replayed, if the interactions are replayable.
+func (c *Cassette) Duplicates() [][]int {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.duplicates()
+}
+
+// duplicates returns the groups of duplicate interactions. The caller must
+// hold the lock of the cassette.
+func (c *Cassette) duplicates() [][]int {
+	var groups [][]int
+	for _, indices := range c.hashIndex {
+		if len(indices) < 2 {
+			continue
+		}
+		ids := make([]int, 0, len(indices))
+		for _, idx := range indices {
+			ids = append(ids, c.Interactions[idx].ID)
+		}
+		groups = append(groups, ids)
+	}
+
+	slices.SortFunc(groups, func(a, b []int) int {
+		return a[0] - b[0]
+	})
+
+	return groups
+}
+
+// checkDuplicates applies the duplicate policy of the cassette to its
+// loaded interactions.
+func (c *Cassette) checkDuplicates() error {
+	if c.OnDuplicates == DuplicatesAllow {
+		return nil
+	}
+
+	groups := c.duplicates()
+	if len(groups) == 0 {
+		return nil
+	}
+
+	if c.OnDuplicates == DuplicatesWarn {
+		slog.Warn("cassette has interactions matching the same requests", "cassette", c.Name, "ids", groups)
+		return nil
+	}
+
+	desc := make([]string, 0, len(groups))
+	for _, ids := range groups {
+		strs := make([]string, 0, len(ids))
+		for _, id := range ids {
+			strs = append(strs, strconv.Itoa(id))
+		}
+		desc = append(desc, strings.Join(strs, ", "))
+	}
+	return fmt.Errorf("%w: ids %s", ErrDuplicateInteractions, strings.Join(desc, "; "))
+}
@@ -0,0 +1,57 @@
+package cassette
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDuplicates(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "duplicates")
+
+	c := New(name)
+	for _, stub := range []struct {
+		method string
+		url    string
+	}{
+		{http.MethodGet, "https://example.com/status"},
+		{http.MethodPost, "https://example.com/users"},
+		{http.MethodGet, "https://example.com/status"},
+		{http.MethodGet, "https://example.com/users"},
+		{http.MethodPost, "https://example.com/users"},
+		{http.MethodGet, "https://example.com/status"},
+	} {
+		if _, err := c.AddStub(stub.method, stub.url, http.StatusOK, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := [][]int{{0, 2, 5}, {1, 4}}
+	if got := c.Duplicates(); !slices.EqualFunc(got, want, slices.Equal) {
+		t.Fatalf("got duplicates %v, want %v", got, want)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, policy := range []DuplicatePolicy{DuplicatesAllow, DuplicatesWarn} {
+		c := New(name)
+		c.OnDuplicates = policy
+		if err := c.Load(); err != nil {
+			t.Fatalf("got error %v with policy %d", err, policy)
+		}
+	}
+
+	c = New(name)
+	c.OnDuplicates = DuplicatesError
+	err := c.Load()
+	if !errors.Is(err, ErrDuplicateInteractions) {
+		t.Fatalf("got error %v, want %v", err, ErrDuplicateInteractions)
+	}
+	if !strings.Contains(err.Error(), "ids 0, 2, 5; 1, 4") {
+		t.Fatalf("got error %q, want it to list the ids", err)
+	}
+}
@@ -0,0 +1,276 @@
+package cassette
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// errNoLayout is returned, when the interactions of a YAML cassette file
+// cannot be located in its text.
+var errNoLayout = errors.New("interactions cannot be located")
+
+// Edit loads the named cassette, calls fn to modify it, e.g. to redact a
+// header or to rewrite the body of specific interactions, and saves it back.
+//
+// Unlike loading and saving the cassette, which re-encodes the whole file,
+// Edit preserves the original text of the interactions left unchanged by
+// fn, including their formatting and comments, so that post-processing a
+// cassette only changes the lines of the edited interactions. Removed
+// interactions are dropped, while added and changed ones are encoded as by
+// [Cassette.Save]. Binary and compressed cassettes, and YAML files, whose
+// layout cannot be preserved, are re-encoded as a whole, see [Cassette.Load].
+//
+// The file is left untouched, if fn returns an error.
+func Edit(name string, fn func(c *Cassette) error) error {
+	c := New(name)
+	file, err := c.Locate()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return err
+		}
+		return fmt.Errorf("failed to read cassette file %s: %w", file, err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err := c.LoadFrom(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to load cassette file %s: %w", file, err)
+	}
+
+	var orig *yamlLayout
+	if !c.Binary && !c.CompressionEnabled {
+		orig, _ = splitInteractions(data)
+	}
+	if orig != nil && len(orig.items) != len(c.Interactions) {
+		orig = nil
+	}
+
+	// Remember the encoding of the interactions, to detect changes.
+	unchanged := make(map[*Interaction]int, len(c.Interactions))
+	snapshots := make([][]byte, len(c.Interactions))
+	for n, i := range c.Interactions {
+		if snapshots[n], err = yaml.Marshal(i); err != nil {
+			return fmt.Errorf("failed to encode interaction %d: %w", i.ID, err)
+		}
+		unchanged[i] = n
+	}
+
+	if err := fn(c); err != nil {
+		return err
+	}
+
+	if orig == nil || c.Binary || c.CompressionEnabled {
+		return c.Save()
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if err := c.prepare(); err != nil {
+		return err
+	}
+	var fresh bytes.Buffer
+	if err := c.encode(&fresh); err != nil {
+		return err
+	}
+
+	reuse := make([]int, len(c.Interactions))
+	for n, i := range c.Interactions {
+		reuse[n] = -1
+		if idx, ok := unchanged[i]; ok {
+			data, err := yaml.Marshal(i)
+			if err != nil {
+				return fmt.Errorf("failed to encode interaction %d: %w", i.ID, err)
+			}
+			if bytes.Equal(data, snapshots[idx]) {
+				reuse[n] = idx
+			}
+		}
+	}
+
+	out, ok := spliceInteractions(c, orig, fresh.Bytes(), reuse)
+	if !ok {
+		// Fall back to the file written by Save
+		file = c.File()
+		out = fresh.Bytes()
+	}
+
+	if existing, err := os.ReadFile(file); err == nil && bytes.Equal(existing, out) {
+		return nil
+	}
+
+	return os.WriteFile(file, out, 0o666)
+}
+
+// yamlLayout is the text of a YAML cassette file split into the text of its
+// interactions and the text around them.
+type yamlLayout struct {
+	head, tail []byte
+	items      [][]byte
+
+	// indent is the column of the dashes of the interactions.
+	indent int
+}
+
+// splitInteractions splits the text of the YAML cassette file into the
+// lines of each interaction, and the lines before and after them.
+func splitInteractions(data []byte) (*yamlLayout, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) != 1 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, errNoLayout
+	}
+
+	// lineStart returns the offset of the given 1-based line.
+	var offsets []int
+	for off := 0; off < len(data); {
+		offsets = append(offsets, off)
+		next := bytes.IndexByte(data[off:], '\n')
+		if next < 0 {
+			break
+		}
+		off += next + 1
+	}
+	lineStart := func(line int) int {
+		if line-1 < len(offsets) {
+			return offsets[line-1]
+		}
+		return len(data)
+	}
+
+	root := doc.Content[0]
+	for k := 0; k+1 < len(root.Content); k += 2 {
+		if root.Content[k].Value != "interactions" {
+			continue
+		}
+
+		seq := root.Content[k+1]
+		if seq.Kind != yaml.SequenceNode || seq.Style&yaml.FlowStyle != 0 || len(seq.Content) == 0 {
+			return nil, errNoLayout
+		}
+
+		end := len(data)
+		if k+2 < len(root.Content) {
+			end = lineStart(root.Content[k+2].Line)
+		}
+
+		layout := &yamlLayout{indent: seq.Content[0].Column - 3}
+		starts := make([]int, 0, len(seq.Content))
+		for _, item := range seq.Content {
+			// Each interaction starts with a dash on the line of its
+			// first key.
+			start := lineStart(item.Line)
+			dash := start + item.Column - 3
+			if item.Column-3 != layout.indent || dash < start || dash >= end || data[dash] != '-' {
+				return nil, errNoLayout
+			}
+			if len(bytes.TrimLeft(data[start:dash], " ")) != 0 {
+				return nil, errNoLayout
+			}
+			starts = append(starts, start)
+		}
+
+		layout.head = data[:starts[0]]
+		layout.tail = data[end:]
+		for n, start := range starts {
+			next := end
+			if n+1 < len(starts) {
+				next = starts[n+1]
+			}
+			layout.items = append(layout.items, data[start:next])
+		}
+
+		return layout, nil
+	}
+
+	return nil, errNoLayout
+}
+
+// spliceInteractions returns the text of the cassette, which keeps the text
+// of orig for the interactions with a reuse index, and takes the text of
+// the other interactions from the freshly encoded cassette. It returns
+// false, if the result does not decode to the cassette.
+func spliceInteractions(c *Cassette, orig *yamlLayout, fresh []byte, reuse []int) ([]byte, bool) {
+	// Removing all interactions leaves no lines of the sequence.
+	if len(c.Interactions) == 0 {
+		return nil, false
+	}
+
+	encoded, err := splitInteractions(fresh)
+	if err != nil || len(encoded.items) != len(c.Interactions) {
+		return nil, false
+	}
+
+	var out bytes.Buffer
+	out.Write(orig.head)
+	for n, idx := range reuse {
+		var item []byte
+		if idx < 0 {
+			item = reindent(encoded.items[n], encoded.indent, orig.indent)
+		} else {
+			item = orig.items[idx]
+		}
+		out.Write(item)
+		if !bytes.HasSuffix(item, []byte("\n")) {
+			out.WriteByte('\n')
+		}
+	}
+	out.Write(orig.tail)
+
+	// Make sure the text decodes to the same cassette.
+	var check Cassette
+	if err := yaml.Unmarshal(out.Bytes(), &check); err != nil || len(check.Interactions) != len(reuse) {
+		return nil, false
+	}
+	for n, idx := range reuse {
+		// The hashes computed when loading are not part of the text.
+		if idx >= 0 {
+			check.Interactions[n].Hash = c.Interactions[n].Hash
+		}
+	}
+	got, err := yaml.Marshal(&check)
+	if err != nil {
+		return nil, false
+	}
+	want, err := yaml.Marshal(c)
+	if err != nil || !bytes.Equal(got, want) {
+		return nil, false
+	}
+
+	return out.Bytes(), true
+}
+
+// reindent shifts the non-empty lines of the text from the indentation
+// from to the indentation to.
+func reindent(text []byte, from, to int) []byte {
+	if from == to {
+		return text
+	}
+
+	lines := bytes.SplitAfter(text, []byte("\n"))
+	var out bytes.Buffer
+	for _, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			out.Write(line)
+			continue
+		}
+		if to > from {
+			out.Write(bytes.Repeat([]byte(" "), to-from))
+			out.Write(line)
+			continue
+		}
+		trimmed := bytes.TrimLeft(line, " ")
+		strip := min(from-to, len(line)-len(trimmed))
+		out.Write(line[strip:])
+	}
+
+	return out.Bytes()
+}
@@ -0,0 +1,105 @@
+package cassette
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEdit(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "edit")
+
+	// A hand-formatted cassette with comments and a two space indentation
+	data := `---
+version: 2
+interactions:
+  # The users are listed first
+  - id: 0
+    request: {method: GET, url: "https://example.com/users", host: example.com, proto: HTTP/1.1, proto_major: 1, proto_minor: 1, content_length: 0}
+    response: {code: 200, status: 200 OK, body: "[]", headers: {}, proto: HTTP/1.1, proto_major: 1, proto_minor: 1, content_length: 2, duration: 0s}
+  - id: 1
+    request: {method: POST, url: "https://example.com/users", host: example.com, proto: HTTP/1.1, proto_major: 1, proto_minor: 1, content_length: 0}
+    response:
+      code: 201
+      status: 201 Created
+      body: '{"token":"secret"}'
+      headers: {Set-Cookie: [session=secret]}
+      proto: HTTP/1.1
+      proto_major: 1
+      proto_minor: 1
+      content_length: 18
+      duration: 0s
+  - id: 2 # deleted below
+    request: {method: DELETE, url: "https://example.com/users/1", host: example.com, proto: HTTP/1.1, proto_major: 1, proto_minor: 1, content_length: 0}
+    response: {code: 204, status: 204 No Content, body: "", headers: {}, proto: HTTP/1.1, proto_major: 1, proto_minor: 1, content_length: 0, duration: 0s}
+`
+	if err := os.WriteFile(name+".yaml", []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Failing edits leave the file untouched
+	errEdit := errors.New("edit failed")
+	err := Edit(name, func(c *Cassette) error {
+		c.Interactions[0].Response.Body = "changed"
+		return errEdit
+	})
+	if !errors.Is(err, errEdit) {
+		t.Fatalf("got error %v, want %v", err, errEdit)
+	}
+	if got, err := os.ReadFile(name + ".yaml"); err != nil || string(got) != data {
+		t.Fatalf("got file %q, %v, want it untouched", got, err)
+	}
+
+	// Editing nothing leaves the file untouched
+	if err := Edit(name, func(c *Cassette) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(name + ".yaml"); err != nil || string(got) != data {
+		t.Fatalf("got file %q, %v, want it untouched", got, err)
+	}
+
+	err = Edit(name, func(c *Cassette) error {
+		c.Interactions[1].Response.Headers.Del("Set-Cookie")
+		c.Interactions[1].Response.Body = `{"token":"REDACTED"}`
+		c.Interactions[2].DiscardOnSave = true
+
+		i, err := NewInteraction().Request(http.MethodGet, "https://example.com/users/2").RespStatus(http.StatusNotFound).Build()
+		if err != nil {
+			return err
+		}
+		return c.AddInteraction(i)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(name + ".yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unchanged := data[:strings.Index(data, "  - id: 1")]
+	if !strings.HasPrefix(string(got), unchanged) {
+		t.Fatalf("got file\n%s\nwant unchanged prefix\n%s", got, unchanged)
+	}
+	if strings.Contains(string(got), "secret") || strings.Contains(string(got), "deleted below") {
+		t.Fatalf("got file\n%s\nwant edits applied", got)
+	}
+
+	c, err := Load(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Interactions) != 3 {
+		t.Fatalf("got %d interactions, want 3", len(c.Interactions))
+	}
+	if got := c.Interactions[1].Response.Body; got != `{"token":"REDACTED"}` {
+		t.Fatalf("got body %q", got)
+	}
+	if got := c.Interactions[2]; got.ID != 2 || got.Request.URL != "https://example.com/users/2" || got.Response.Code != http.StatusNotFound {
+		t.Fatalf("got interaction %+v", got)
+	}
+}
@@ -0,0 +1,196 @@
+package cassette
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrUnsupportedContentEncoding is returned when decoding or encoding a body
+// using an unsupported content coding.
+var ErrUnsupportedContentEncoding = errors.New("unsupported content encoding")
+
+// contentCodec decodes and encodes bodies using a content coding.
+type contentCodec struct {
+	decode func(r io.Reader) (io.ReadCloser, error)
+	encode func(w io.Writer) (io.WriteCloser, error)
+}
+
+// contentCodecs are the supported content codings, as registered in the
+// HTTP Content Coding Registry.
+var contentCodecs = map[string]contentCodec{
+	"gzip": {
+		decode: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+		encode: func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+	},
+	"x-gzip": {
+		decode: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+		encode: func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+	},
+	"deflate": {
+		decode: decodeDeflate,
+		encode: func(w io.Writer) (io.WriteCloser, error) { return zlib.NewWriter(w), nil },
+	},
+	"br": {
+		decode: func(r io.Reader) (io.ReadCloser, error) { return io.NopCloser(brotli.NewReader(r)), nil },
+		encode: func(w io.Writer) (io.WriteCloser, error) { return brotli.NewWriter(w), nil },
+	},
+	"zstd": {
+		decode: func(r io.Reader) (io.ReadCloser, error) {
+			zr, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return zr.IOReadCloser(), nil
+		},
+		encode: func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) },
+	},
+}
+
+// decodeDeflate decodes the "deflate" content coding, which is the zlib
+// format as per RFC 9110, section 8.4.1.2. Some servers send raw deflate
+// data instead, which is decoded as well.
+func decodeDeflate(r io.Reader) (io.ReadCloser, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if zr, err := zlib.NewReader(bytes.NewReader(data)); err == nil {
+		return zr, nil
+	}
+
+	return flate.NewReader(bytes.NewReader(data)), nil
+}
+
+// contentEncodings parses the Content-Encoding header values into the list
+// of applied codings, in the order they were applied. The identity coding
+// is omitted.
+func contentEncodings(h http.Header) []string {
+	var encodings []string
+	for _, v := range headerValues(h, "Content-Encoding") {
+		for _, encoding := range strings.Split(v, ",") {
+			encoding = strings.ToLower(strings.TrimSpace(encoding))
+			if encoding != "" && encoding != "identity" {
+				encodings = append(encodings, encoding)
+			}
+		}
+	}
+	return encodings
+}
+
+// DecodeBody decodes the body using the given content codings, in reverse
+// order of their application.
+func DecodeBody(body string, encodings ...string) (string, error) {
+	data := []byte(body)
+	for _, encoding := range slices.Backward(encodings) {
+		codec, ok := contentCodecs[encoding]
+		if !ok {
+			return "", fmt.Errorf("%w: %s", ErrUnsupportedContentEncoding, encoding)
+		}
+
+		r, err := codec.decode(bytes.NewReader(data))
+		if err != nil {
+			return "", fmt.Errorf("failed to decode %s body: %w", encoding, err)
+		}
+		data, err = io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return "", fmt.Errorf("failed to decode %s body: %w", encoding, err)
+		}
+	}
+
+	return string(data), nil
+}
+
+// EncodeBody encodes the body using the given content codings, in order.
+func EncodeBody(body string, encodings ...string) (string, error) {
+	data := []byte(body)
+	for _, encoding := range encodings {
+		codec, ok := contentCodecs[encoding]
+		if !ok {
+			return "", fmt.Errorf("%w: %s", ErrUnsupportedContentEncoding, encoding)
+		}
+
+		var buf bytes.Buffer
+		w, err := codec.encode(&buf)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode %s body: %w", encoding, err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return "", fmt.Errorf("failed to encode %s body: %w", encoding, err)
+		}
+		if err := w.Close(); err != nil {
+			return "", fmt.Errorf("failed to encode %s body: %w", encoding, err)
+		}
+		data = buf.Bytes()
+	}
+
+	return string(data), nil
+}
+
+// DecodeResponseBody decodes the response body of the interaction according
+// to its Content-Encoding header, so that it is stored as readable text in
+// the cassette. The original encoding is kept in the DecodedContentEncoding
+// field of the response, and the body is encoded again on replay.
+//
+// Responses, which are not encoded, were already decoded, or whose body was
+// stored base64-encoded or not recorded, are left as is.
+func DecodeResponseBody(i *Interaction) error {
+	if i.Response.DecodedContentEncoding != "" || i.Response.BodyEncoding != "" || i.Response.BodyDigest != "" {
+		return nil
+	}
+
+	encodings := contentEncodings(i.Response.Headers)
+	if len(encodings) == 0 {
+		return nil
+	}
+
+	body, err := DecodeBody(i.Response.Body, encodings...)
+	if err != nil {
+		return err
+	}
+
+	i.Response.Body = body
+	i.Response.DecodedContentEncoding = strings.Join(encodings, ", ")
+
+	return nil
+}
+
+// encodeResponseBody encodes the body of a replayed response, which was
+// decoded when recording, and fixes up its content length.
+func encodeResponseBody(resp *http.Response, r Response) error {
+	encodings := strings.Split(r.DecodedContentEncoding, ", ")
+	body, err := EncodeBody(r.Body, encodings...)
+	if err != nil {
+		return err
+	}
+
+	setResponseBody(resp, body)
+
+	return nil
+}
+
+// setResponseBody replaces the body of the response, and fixes up its
+// content length.
+func setResponseBody(resp *http.Response, body string) {
+	resp.Body = io.NopCloser(strings.NewReader(body))
+	if resp.ContentLength >= 0 {
+		resp.ContentLength = int64(len(body))
+	}
+	if resp.Header.Get("Content-Length") != "" {
+		resp.Header = resp.Header.Clone()
+		resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+}
@@ -0,0 +1,94 @@
+package cassette
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestEncodeDecodeBody(t *testing.T) {
+	for _, encodings := range [][]string{{"gzip"}, {"x-gzip"}, {"deflate"}, {"br"}, {"zstd"}, {"deflate", "gzip"}, {"zstd", "br"}} {
+		encoded, err := EncodeBody("hello world", encodings...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if encoded == "hello world" {
+			t.Fatalf("%v: body was not encoded", encodings)
+		}
+
+		decoded, err := DecodeBody(encoded, encodings...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded != "hello world" {
+			t.Fatalf("%v: got %q", encodings, decoded)
+		}
+	}
+
+	// Raw deflate data, as sent by some servers
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("hello world"))
+	w.Close()
+	if decoded, err := DecodeBody(buf.String(), "deflate"); err != nil || decoded != "hello world" {
+		t.Fatalf("unexpected raw deflate result %q: %v", decoded, err)
+	}
+
+	if _, err := DecodeBody("", "compress"); !errors.Is(err, ErrUnsupportedContentEncoding) {
+		t.Fatalf("expected ErrUnsupportedContentEncoding, got %v", err)
+	}
+}
+
+func TestDecodeResponseBody(t *testing.T) {
+	encoded, err := EncodeBody("hello world", "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i := &Interaction{
+		Request: Request{Method: http.MethodGet, URL: "https://example.com/"},
+		Response: Response{
+			Code:          http.StatusOK,
+			Body:          encoded,
+			ContentLength: int64(len(encoded)),
+			Headers: http.Header{
+				"Content-Encoding": {"gzip"},
+				"Content-Length":   {strconv.Itoa(len(encoded))},
+			},
+		},
+	}
+
+	if err := DecodeResponseBody(i); err != nil {
+		t.Fatal(err)
+	}
+	if i.Response.Body != "hello world" || i.Response.DecodedContentEncoding != "gzip" {
+		t.Fatalf("unexpected decoded response %q (%s)", i.Response.Body, i.Response.DecodedContentEncoding)
+	}
+
+	// Decoding is idempotent
+	if err := DecodeResponseBody(i); err != nil || i.Response.Body != "hello world" {
+		t.Fatalf("unexpected second decode %q: %v", i.Response.Body, err)
+	}
+
+	resp, err := i.GetHTTPResponse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ContentLength != int64(len(body)) || resp.Header.Get("Content-Length") != strconv.Itoa(len(body)) {
+		t.Fatalf("inconsistent content length %d (%s) for body of %d bytes", resp.ContentLength, resp.Header.Get("Content-Length"), len(body))
+	}
+	if decoded, err := DecodeBody(string(body), "gzip"); err != nil || decoded != "hello world" {
+		t.Fatalf("unexpected replayed body %q: %v", decoded, err)
+	}
+}
@@ -0,0 +1,133 @@
+package cassette
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Handler returns an [http.Handler], which serves the recorded responses of
+// the store, i.e. a [Cassette] or a [Set], turning it into a mock server.
+//
+// Incoming requests are matched against the interactions using the matcher
+// of the cassettes. Since the mock server usually runs on a different host
+// than the recorded one, requests are matched against each of the recorded
+// origins, starting with the one of the incoming Host header. Incoming
+// requests carry headers added by the client, e.g. User-Agent or
+// Accept-Encoding, so a matcher ignoring these is usually needed, see
+// [Cassette.SetMatcher].
+//
+// Requests, which do not match any interaction, are answered with 404 Not
+// Found.
+func Handler(s Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		interaction, err := serverInteraction(s, r)
+		if err != nil {
+			code := http.StatusInternalServerError
+			if errors.Is(err, ErrInteractionNotFound) {
+				code = http.StatusNotFound
+			}
+			http.Error(w, fmt.Sprintf("cassette: %s", err), code)
+			return
+		}
+
+		resp, err := interaction.GetHTTPResponse()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("cassette: %s", err), http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+
+		for k, values := range resp.Header {
+			w.Header()[k] = values
+		}
+		w.Header().Del("Transfer-Encoding")
+		for k := range resp.Trailer {
+			w.Header().Add("Trailer", k)
+		}
+		w.WriteHeader(resp.StatusCode)
+		if _, err := io.Copy(w, resp.Body); err != nil {
+			return
+		}
+
+		// The trailer values are filled in once the body is read.
+		for k, values := range resp.Trailer {
+			w.Header()[k] = values
+		}
+	})
+}
+
+// serverInteraction finds the interaction matching the incoming server
+// request in the store, trying each of the recorded origins.
+func serverInteraction(s Store, r *http.Request) (*Interaction, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	for _, origin := range storeOrigins(s, r) {
+		// Turn the server request into a client request for the origin.
+		req := r.Clone(r.Context())
+		req.URL.Scheme = origin.Scheme
+		req.URL.Host = origin.Host
+		req.Host = origin.Host
+		req.RequestURI = ""
+		req.RemoteAddr = ""
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Servers keep the Content-Length header, which clients set
+		// from the ContentLength field instead.
+		req.Header.Del("Content-Length")
+
+		interaction, err := s.GetInteraction(req)
+		if errors.Is(err, ErrInteractionNotFound) {
+			continue
+		}
+		return interaction, err
+	}
+
+	return nil, ErrInteractionNotFound
+}
+
+// storeOrigins returns the recorded origins of the store, see
+// [Cassette.origins]. Stores of other types are assumed to have recorded the
+// origin of the incoming request.
+func storeOrigins(s Store, r *http.Request) []*url.URL {
+	if o, ok := s.(interface{ origins(host string) []*url.URL }); ok {
+		return o.origins(r.Host)
+	}
+
+	return []*url.URL{{Scheme: "http", Host: r.Host}}
+}
+
+// origins returns the distinct scheme and host pairs of the recorded
+// requests, with the ones of the given host first.
+func (c *Cassette) origins(host string) []*url.URL {
+	c.Lock()
+	defer c.Unlock()
+
+	var first, rest []*url.URL
+	seen := make(map[url.URL]bool)
+	for _, i := range c.Interactions {
+		u, err := url.Parse(i.Request.URL)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		origin := url.URL{Scheme: u.Scheme, Host: u.Host}
+		if seen[origin] {
+			continue
+		}
+		seen[origin] = true
+
+		if origin.Host == host {
+			first = append(first, &origin)
+		} else {
+			rest = append(rest, &origin)
+		}
+	}
+
+	return append(first, rest...)
+}
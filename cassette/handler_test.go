@@ -0,0 +1,94 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	newInteraction := func(method, url, reqBody string, code int, respBody string) *Interaction {
+		return &Interaction{
+			Request: Request{
+				Proto:         "HTTP/1.1",
+				ProtoMajor:    1,
+				ProtoMinor:    1,
+				Method:        method,
+				URL:           url,
+				Host:          "api.example.com",
+				Body:          reqBody,
+				ContentLength: int64(len(reqBody)),
+				Headers:       http.Header{},
+			},
+			Response: Response{
+				Code:    code,
+				Body:    respBody,
+				Headers: http.Header{"Content-Type": {"application/json"}},
+			},
+		}
+	}
+
+	c := New("test_handler")
+	c.ReplayableInteractions = true
+	if err := c.SetMatcher(NewMatcher(WithIgnoreUserAgent(), WithIgnoreHeaders("Accept-Encoding"))); err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []*Interaction{
+		newInteraction(http.MethodGet, "https://api.example.com/users/1", "", http.StatusOK, `{"id":1}`),
+		newInteraction(http.MethodPost, "https://api.example.com/users", `{"name":"foo"}`, http.StatusCreated, `{"id":2}`),
+	} {
+		if err := c.AddInteraction(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server := httptest.NewServer(Handler(c))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{name: "get", method: http.MethodGet, path: "/users/1", wantCode: http.StatusOK, wantBody: `{"id":1}`},
+		{name: "post", method: http.MethodPost, path: "/users", body: `{"name":"foo"}`, wantCode: http.StatusCreated, wantBody: `{"id":2}`},
+		{name: "different body", method: http.MethodPost, path: "/users", body: `{"name":"bar"}`, wantCode: http.StatusNotFound},
+		{name: "unknown path", method: http.MethodGet, path: "/users/3", wantCode: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, server.URL+test.path, strings.NewReader(test.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := server.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != test.wantCode {
+				t.Fatalf("got status %d, want %d: %s", resp.StatusCode, test.wantCode, body)
+			}
+			if test.wantBody == "" {
+				return
+			}
+			if string(body) != test.wantBody {
+				t.Fatalf("got body %q, want %q", body, test.wantBody)
+			}
+			if got := resp.Header.Get("Content-Type"); got != "application/json" {
+				t.Fatalf("got Content-Type %q, want application/json", got)
+			}
+		})
+	}
+}
@@ -1,26 +1,204 @@
 package cassette
 
 import (
-	"bytes"
 	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"hash"
-	"io"
+	"hash/fnv"
 	"net/http"
+	"net/url"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
 )
 
 const defaultDelimiter = "::"
 
+// HashAlgorithm specifies the hash function used for matching requests,
+// see [WithHashAlgorithm].
+type HashAlgorithm int
+
+const (
+	// HashSHA256 hashes requests using SHA-256, which is the default.
+	HashSHA256 HashAlgorithm = iota
+
+	// HashXXH64 hashes requests using the non-cryptographic xxHash64, which
+	// is much faster for requests with large bodies. Its hashes are prefixed
+	// with "xxh64:", so that they are not mistaken for SHA-256 hashes.
+	HashXXH64
+)
+
+// HashField is a set of request components, which are hashed for matching
+// requests, see [WithHashFields]. Fields are combined using the bitwise OR
+// operator, e.g. HashMethod|HashPath|HashBody.
+type HashField uint
+
+const (
+	// HashMethod hashes the request method.
+	HashMethod HashField = 1 << iota
+
+	// HashHost hashes the host of the request.
+	HashHost
+
+	// HashURL hashes the full URL of the request, without the ignored
+	// query parameters.
+	HashURL
+
+	// HashPath hashes the escaped path of the URL of the request.
+	HashPath
+
+	// HashQuery hashes the query of the URL of the request, without the
+	// ignored query parameters.
+	HashQuery
+
+	// HashProto hashes the protocol version of the request.
+	HashProto
+
+	// HashHeaders hashes the headers of the request, without the ignored
+	// headers.
+	HashHeaders
+
+	// HashBody hashes the body of the request.
+	HashBody
+
+	// HashContentLength hashes the content length of the request.
+	HashContentLength
+
+	// HashTrailer hashes the trailer of the request.
+	HashTrailer
+
+	// HashTransferEncoding hashes the transfer encoding of the request.
+	HashTransferEncoding
+
+	// HashRemoteAddr hashes the remote address of requests received by a
+	// server.
+	HashRemoteAddr
+
+	// HashRequestURI hashes the request URI of requests received by a
+	// server, without the ignored query parameters.
+	HashRequestURI
+)
+
+// DefaultHashFields are the request components hashed by the default
+// matcher.
+const DefaultHashFields = HashMethod | HashHost | HashURL | HashProto | HashHeaders | HashBody |
+	HashContentLength | HashTrailer | HashTransferEncoding | HashRemoteAddr | HashRequestURI
+
+// xxh64Prefix is the prefix of the hashes computed using [HashXXH64].
+const xxh64Prefix = "xxh64:"
+
+// hashAlgorithmOf returns the algorithm, which computed the given hash.
+func hashAlgorithmOf(hash string) HashAlgorithm {
+	_, hash = hashFingerprintOf(hash)
+	if strings.HasPrefix(hash, xxh64Prefix) {
+		return HashXXH64
+	}
+	return HashSHA256
+}
+
+// fingerprintPrefix is the prefix of the fingerprint, which precedes the
+// hashes computed by matchers hashing other request components than the
+// default matcher, see [matcherFingerprint].
+const fingerprintPrefix = "m-"
+
+// hashFingerprintOf returns the fingerprint of the matcher, which computed
+// the given hash, if any, and the hash without the fingerprint.
+func hashFingerprintOf(hash string) (fingerprint, rest string) {
+	if s, ok := strings.CutPrefix(hash, fingerprintPrefix); ok {
+		if fingerprint, rest, ok := strings.Cut(s, ":"); ok {
+			return fingerprint, rest
+		}
+	}
+	return "", hash
+}
+
+// matcherFingerprint returns the fingerprint of the hashed request
+// components and the ignored headers and query parameters of the matcher,
+// so that hashes persisted by differently configured matchers are
+// recomputed, when loading a cassette. The fingerprint of the default
+// configuration is empty, so that its hashes are not prefixed.
+func matcherFingerprint(m *defaultMatcher) string {
+	if m.fields == DefaultHashFields && len(m.ignoreHeaders) == 0 && len(m.ignoreQueryParams) == 0 {
+		return ""
+	}
+
+	headers := make([]string, len(m.ignoreHeaders))
+	for i, header := range m.ignoreHeaders {
+		headers[i] = http.CanonicalHeaderKey(header)
+	}
+	slices.Sort(headers)
+	params := slices.Sorted(slices.Values(m.ignoreQueryParams))
+
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%d;%s;%s", m.fields, strings.Join(slices.Compact(headers), ","), strings.Join(slices.Compact(params), ","))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // RequestHasher builds a deterministic hash from various request components.
 type RequestHasher struct {
-	hash  hash.Hash
-	first bool
+	hash   hash.Hash
+	prefix string
+	first  bool
+
+	// scratch is reused for writing parts to the hash, without converting
+	// them to byte slices.
+	scratch []byte
+}
+
+// maxPooledScratchSize is the size of the largest scratch buffer of a
+// pooled request hasher.
+const maxPooledScratchSize = 64 << 10
+
+// requestHasherPools hold the request hashers per hash algorithm, which
+// are reused for matching requests, see [acquireRequestHasher].
+var requestHasherPools = [...]sync.Pool{
+	HashSHA256: {New: func() any { return NewRequestHasherWithAlgorithm(HashSHA256) }},
+	HashXXH64:  {New: func() any { return NewRequestHasherWithAlgorithm(HashXXH64) }},
+}
+
+// acquireRequestHasher returns a pooled request hasher using the given hash
+// algorithm, which must be released with [releaseRequestHasher].
+func acquireRequestHasher(alg HashAlgorithm) *RequestHasher {
+	if int(alg) < 0 || int(alg) >= len(requestHasherPools) {
+		return NewRequestHasherWithAlgorithm(alg)
+	}
+	return requestHasherPools[alg].Get().(*RequestHasher)
+}
+
+// releaseRequestHasher resets the request hasher, and returns it to its
+// pool.
+func releaseRequestHasher(h *RequestHasher) {
+	// Large buffers are left to the garbage collector, instead of being
+	// retained by the pool.
+	if cap(h.scratch) > maxPooledScratchSize {
+		return
+	}
+
+	h.hash.Reset()
+	h.first = true
+	requestHasherPools[hashAlgorithmOf(h.prefix)].Put(h)
 }
 
 func NewRequestHasher() *RequestHasher {
+	return NewRequestHasherWithAlgorithm(HashSHA256)
+}
+
+// NewRequestHasherWithAlgorithm returns a [RequestHasher] using the given
+// hash algorithm.
+func NewRequestHasherWithAlgorithm(alg HashAlgorithm) *RequestHasher {
+	if alg == HashXXH64 {
+		return &RequestHasher{
+			hash:   xxhash.New(),
+			prefix: xxh64Prefix,
+			first:  true,
+		}
+	}
+
 	return &RequestHasher{
 		hash:  sha256.New(),
 		first: true,
@@ -28,30 +206,68 @@ func NewRequestHasher() *RequestHasher {
 }
 
 func (h *RequestHasher) Add(part string) {
+	h.scratch = h.scratch[:0]
 	if !h.first {
-		h.hash.Write([]byte(defaultDelimiter))
+		h.scratch = append(h.scratch, defaultDelimiter...)
 	}
 	h.first = false
-	h.hash.Write([]byte(part))
+	h.scratch = append(h.scratch, part...)
+	h.hash.Write(h.scratch)
+}
+
+// addBody adds the body of the request, which is streamed into the hash,
+// see [hashBody].
+func (h *RequestHasher) addBody(r *http.Request) error {
+	if !h.first {
+		h.scratch = append(h.scratch[:0], defaultDelimiter...)
+		h.hash.Write(h.scratch)
+	}
+	h.first = false
+	return hashBody(r, h.hash)
 }
 
 func (h *RequestHasher) AddInt(n int) {
-	h.Add(strconv.Itoa(n))
+	h.scratch = h.scratch[:0]
+	if !h.first {
+		h.scratch = append(h.scratch, defaultDelimiter...)
+	}
+	h.first = false
+	h.scratch = strconv.AppendInt(h.scratch, int64(n), 10)
+	h.hash.Write(h.scratch)
 }
 
 func (h *RequestHasher) Hash() string {
-	return fmt.Sprintf("%x", h.hash.Sum(nil))
+	sum := h.hash.Sum(h.scratch[:0])
+	h.scratch = append(sum, h.prefix...)
+	h.scratch = hex.AppendEncode(h.scratch, h.scratch[:len(sum)])
+	return string(h.scratch[len(sum):])
 }
 
-// serializeHeaders creates a deterministic string representation of http.Header.
-func serializeHeaders(h http.Header, ignore []string) string {
+// addHeaders adds a deterministic representation of the headers, except
+// the ignored ones.
+func (h *RequestHasher) addHeaders(header http.Header, ignore []string) {
+	h.scratch = h.scratch[:0]
+	if !h.first {
+		h.scratch = append(h.scratch, defaultDelimiter...)
+	}
+	h.first = false
+	h.scratch = appendHeaders(h.scratch, header, ignore)
+	h.hash.Write(h.scratch)
+}
+
+// appendHeaders appends a deterministic representation of http.Header to
+// dst, with the keys and the values sorted.
+func appendHeaders(dst []byte, h http.Header, ignore []string) []byte {
 	if len(h) == 0 {
-		return ""
+		return dst
 	}
 
-	headersToIgnore := make(map[string]struct{}, len(ignore))
-	for _, header := range ignore {
-		headersToIgnore[http.CanonicalHeaderKey(header)] = struct{}{}
+	var headersToIgnore map[string]struct{}
+	if len(ignore) > 0 {
+		headersToIgnore = make(map[string]struct{}, len(ignore))
+		for _, header := range ignore {
+			headersToIgnore[http.CanonicalHeaderKey(header)] = struct{}{}
+		}
 	}
 
 	keys := make([]string, 0, len(h))
@@ -62,18 +278,26 @@ func serializeHeaders(h http.Header, ignore []string) string {
 	}
 	sort.Strings(keys)
 
-	var b strings.Builder
 	for i, k := range keys {
 		// Copy values to avoid modifying the original header.
-		values := make([]string, len(h[k]))
-		copy(values, h[k])
-		sort.Strings(values)
-		b.WriteString(fmt.Sprintf("%s:%s", k, strings.Join(values, ",")))
+		values := h[k]
+		if !sort.StringsAreSorted(values) {
+			values = slices.Clone(values)
+			sort.Strings(values)
+		}
+		dst = append(dst, k...)
+		dst = append(dst, ':')
+		for j, v := range values {
+			if j > 0 {
+				dst = append(dst, ',')
+			}
+			dst = append(dst, v...)
+		}
 		if i < len(keys)-1 {
-			b.WriteString(";")
+			dst = append(dst, ';')
 		}
 	}
-	return b.String()
+	return dst
 }
 
 func serializeTransferEncoding(te []string) string {
@@ -87,42 +311,104 @@ func serializeTransferEncoding(te []string) string {
 	return strings.Join(copied, ",")
 }
 
-// defaultInteractionRequestHasher generates a hash from a live http.Request.
-func defaultInteractionRequestHasher(r *http.Request, ignoreHeaders []string) (string, error) {
-	// Read and restore the body so it can be used by subsequent handlers.
-	var bodyBytes []byte
-	if r.Body != nil && r.Body != http.NoBody {
-		var err error
-		bodyBytes, err = io.ReadAll(r.Body)
-		if err != nil {
-			return "", err
+// StripQueryParams removes the named query parameters from the given URL.
+// The order and encoding of the remaining parameters is preserved. If the URL
+// cannot be parsed it is returned unchanged.
+func StripQueryParams(rawURL string, names ...string) string {
+	if len(names) == 0 {
+		return rawURL
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.RawQuery == "" {
+		return rawURL
+	}
+
+	u.RawQuery = stripRawQuery(u.RawQuery, names)
+	return u.String()
+}
+
+func stripRawQuery(rawQuery string, names []string) string {
+	pairs := strings.Split(rawQuery, "&")
+	kept := pairs[:0]
+	for _, pair := range pairs {
+		key, _, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(key); err == nil {
+			key = unescaped
 		}
-		r.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+		if !slices.Contains(names, key) {
+			kept = append(kept, pair)
+		}
+	}
+	return strings.Join(kept, "&")
+}
+
+// defaultInteractionRequestHasher generates a hash from a live http.Request.
+func defaultInteractionRequestHasher(r *http.Request, m *defaultMatcher) (string, error) {
+	hasher := acquireRequestHasher(m.algorithm)
+	defer releaseRequestHasher(hasher)
+
+	fields := m.fields
+	if fields&HashMethod != 0 {
+		hasher.Add(r.Method)
+	}
+	if fields&HashHost != 0 {
+		hasher.Add(r.Host)
+	}
+	if fields&HashURL != 0 {
+		hasher.Add(StripQueryParams(r.URL.String(), m.ignoreQueryParams...))
+	}
+	if fields&HashPath != 0 {
+		hasher.Add(r.URL.EscapedPath())
+	}
+	if fields&HashQuery != 0 {
+		hasher.Add(stripRawQuery(r.URL.RawQuery, m.ignoreQueryParams))
+	}
+	if fields&HashProto != 0 {
+		hasher.Add(r.Proto)
+		hasher.AddInt(r.ProtoMajor)
+		hasher.AddInt(r.ProtoMinor)
+	}
+	if fields&HashHeaders != 0 {
+		hasher.addHeaders(r.Header, m.ignoreHeaders)
 	}
 
-	// Parse form for relevant methods. This is safe because the body was restored.
-	switch r.Method {
-	case http.MethodPost, http.MethodPut, http.MethodPatch:
-		if err := r.ParseForm(); err != nil {
+	if fields&HashBody != 0 {
+		// The body is streamed into the hash, and restored, so that it can
+		// be used by subsequent handlers.
+		if err := hasher.addBody(r); err != nil {
 			return "", err
 		}
-	}
 
-	hasher := NewRequestHasher()
+		// Parse form for relevant methods, which reads the restored body.
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+			err := r.ParseForm()
+			rewindBody(r)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
 
-	hasher.Add(r.Method)
-	hasher.Add(r.Host)
-	hasher.Add(r.URL.String())
-	hasher.Add(r.Proto)
-	hasher.AddInt(r.ProtoMajor)
-	hasher.AddInt(r.ProtoMinor)
-	hasher.Add(serializeHeaders(r.Header, ignoreHeaders))
-	hasher.Add(string(bodyBytes))
-	hasher.AddInt(int(r.ContentLength))
-	hasher.Add(serializeHeaders(r.Trailer, nil))
-	hasher.Add(serializeTransferEncoding(r.TransferEncoding))
-	hasher.Add(r.RemoteAddr)
-	hasher.Add(r.RequestURI)
+	if fields&HashContentLength != 0 {
+		hasher.AddInt(int(r.ContentLength))
+	}
+	if fields&HashTrailer != 0 {
+		hasher.addHeaders(r.Trailer, nil)
+	}
+	if fields&HashTransferEncoding != 0 {
+		hasher.Add(serializeTransferEncoding(r.TransferEncoding))
+	}
+	if fields&HashRemoteAddr != 0 {
+		hasher.Add(r.RemoteAddr)
+	}
+	if fields&HashRequestURI != 0 {
+		hasher.Add(StripQueryParams(r.RequestURI, m.ignoreQueryParams...))
+	}
 
+	if m.fingerprint != "" {
+		return fingerprintPrefix + m.fingerprint + ":" + hasher.Hash(), nil
+	}
 	return hasher.Hash(), nil
 }
@@ -0,0 +1,123 @@
+package cassette
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// LineEnding specifies how the line endings of text bodies are normalized,
+// see [NormalizeLineEndings].
+type LineEnding int
+
+const (
+	// PreserveLineEndings preserves the exact bytes of bodies, which is
+	// the default.
+	PreserveLineEndings LineEnding = iota
+
+	// LFLineEndings converts CRLF line endings to LF.
+	LFLineEndings
+
+	// CRLFLineEndings converts LF line endings to CRLF.
+	CRLFLineEndings
+)
+
+// convert returns the text with its line endings converted.
+func (le LineEnding) convert(text string) string {
+	switch le {
+	case LFLineEndings:
+		return strings.ReplaceAll(text, "\r\n", "\n")
+	case CRLFLineEndings:
+		return strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n")
+	}
+	return text
+}
+
+// NormalizeLineEndings converts the line endings of the text bodies of the
+// recorded request and response, so that cassettes recorded on Windows and
+// on Linux do not differ. Content lengths are adjusted, when the size of a
+// body changes. Bodies are text, if their Content-Type is text/*, JSON, XML,
+// JavaScript, YAML or URL-encoded form data. Bodies with a content encoding,
+// which were not decoded, see [DecodeResponseBody], and bodies, which were
+// not recorded, or are stored base64-encoded, are left as is.
+func NormalizeLineEndings(i *Interaction, le LineEnding) {
+	if le == PreserveLineEndings {
+		return
+	}
+
+	if isTextBody(i.Request.Headers) && i.Request.BodyDigest == "" {
+		i.Request.Body, i.Request.ContentLength = normalizeBody(i.Request.Body, i.Request.ContentLength, i.Request.Headers, le.convert)
+	}
+
+	r := &i.Response
+	if !isTextBody(r.Headers) || r.BodyDigest != "" || r.BodyEncoding != "" {
+		return
+	}
+	if r.DecodedContentEncoding == "" && len(contentEncodings(r.Headers)) > 0 {
+		return
+	}
+	r.Body, r.ContentLength = normalizeBody(r.Body, r.ContentLength, r.Headers, le.convert)
+}
+
+// isTextBody returns true, if the Content-Type header denotes a text body,
+// see [NormalizeLineEndings].
+func isTextBody(h http.Header) bool {
+	values := headerValues(h, "Content-Type")
+	if len(values) == 0 {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(values[0])
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		mediaType == "application/json", strings.HasSuffix(mediaType, "+json"),
+		mediaType == "application/xml", strings.HasSuffix(mediaType, "+xml"),
+		mediaType == "application/javascript",
+		mediaType == "application/yaml", mediaType == "application/x-yaml",
+		mediaType == "application/x-www-form-urlencoded":
+		return true
+	}
+	return false
+}
+
+// lineEndingMatcher converts the line endings of text request bodies before
+// passing the requests to the wrapped matcher.
+type lineEndingMatcher struct {
+	matcher    RequestMatcher
+	lineEnding LineEnding
+}
+
+// NewLineEndingMatcher returns a [RequestMatcher], which converts the line
+// endings of text request bodies, as described in [NormalizeLineEndings],
+// before generating a hash using the provided matcher, so that requests
+// sent on Windows and on Linux match the same interactions.
+func NewLineEndingMatcher(matcher RequestMatcher, le LineEnding) RequestMatcher {
+	return &lineEndingMatcher{matcher: matcher, lineEnding: le}
+}
+
+// Hash implements RequestMatcher.
+func (m *lineEndingMatcher) Hash(r *http.Request) (string, error) {
+	if m.lineEnding == PreserveLineEndings || !isTextBody(r.Header) {
+		return m.matcher.Hash(r)
+	}
+
+	bodyBytes, err := readBody(r)
+	if err != nil {
+		return "", err
+	}
+	if m.lineEnding.convert(string(bodyBytes)) == string(bodyBytes) {
+		return m.matcher.Hash(r)
+	}
+
+	nr := r.Clone(r.Context())
+	nr.Form = nil
+	nr.PostForm = nil
+	body, contentLength := normalizeBody(string(bodyBytes), r.ContentLength, nr.Header, m.lineEnding.convert)
+	nr.Body = newReplayableBody([]byte(body))
+	nr.ContentLength = contentLength
+
+	return m.matcher.Hash(nr)
+}
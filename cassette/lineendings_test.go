@@ -0,0 +1,87 @@
+package cassette
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeLineEndings(t *testing.T) {
+	newInteraction := func() *Interaction {
+		return &Interaction{
+			Request: Request{
+				Headers:       http.Header{"Content-Type": {"application/json"}, "Content-Length": {"9"}},
+				Body:          "{\r\n\"a\":1}",
+				ContentLength: 9,
+			},
+			Response: Response{
+				Headers:       http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+				Body:          "a\nb\r\nc\n",
+				ContentLength: 7,
+			},
+		}
+	}
+
+	i := newInteraction()
+	NormalizeLineEndings(i, LFLineEndings)
+	if i.Request.Body != "{\n\"a\":1}" || i.Request.ContentLength != 8 || i.Request.Headers.Get("Content-Length") != "8" {
+		t.Fatalf("got request body %q of length %d, %v", i.Request.Body, i.Request.ContentLength, i.Request.Headers)
+	}
+	if i.Response.Body != "a\nb\nc\n" || i.Response.ContentLength != 6 {
+		t.Fatalf("got response body %q of length %d", i.Response.Body, i.Response.ContentLength)
+	}
+
+	i = newInteraction()
+	NormalizeLineEndings(i, CRLFLineEndings)
+	if i.Response.Body != "a\r\nb\r\nc\r\n" || i.Response.ContentLength != 9 {
+		t.Fatalf("got response body %q of length %d", i.Response.Body, i.Response.ContentLength)
+	}
+
+	i = newInteraction()
+	NormalizeLineEndings(i, PreserveLineEndings)
+	if want := newInteraction(); i.Request.Body != want.Request.Body || i.Response.Body != want.Response.Body {
+		t.Fatalf("got bodies %q and %q, want them preserved", i.Request.Body, i.Response.Body)
+	}
+
+	// Binary and encoded bodies are left as is
+	i = newInteraction()
+	i.Request.Headers.Set("Content-Type", "application/octet-stream")
+	i.Response.Headers.Set("Content-Encoding", "gzip")
+	NormalizeLineEndings(i, LFLineEndings)
+	if want := newInteraction(); i.Request.Body != want.Request.Body || i.Response.Body != want.Response.Body {
+		t.Fatalf("got bodies %q and %q, want them preserved", i.Request.Body, i.Response.Body)
+	}
+}
+
+func TestLineEndingMatcher(t *testing.T) {
+	matcher := NewLineEndingMatcher(DefaultMatcher, LFLineEndings)
+
+	newRequest := func(body string) *http.Request {
+		r, err := http.NewRequest(http.MethodPost, "https://example.com/notes", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Content-Type", "text/plain")
+		return r
+	}
+
+	crlf, err := matcher.Hash(newRequest("a\r\nb\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lf, err := matcher.Hash(newRequest("a\nb\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crlf != lf {
+		t.Fatal("expected hashes of requests with different line endings to match")
+	}
+
+	exact, err := DefaultMatcher.Hash(newRequest("a\r\nb\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exact == lf {
+		t.Fatal("expected hashes of the default matcher to preserve line endings")
+	}
+}
@@ -0,0 +1,86 @@
+package cassette
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// ExportMarkdown writes a human readable Markdown summary of the cassette
+// to w, with a table listing the method, URL, status code, and request and
+// response bodies of the interactions, so that fixture changes can be
+// reviewed without reading the raw YAML.
+//
+// Bodies are truncated to maxBodyLength characters. A maxBodyLength of zero
+// or less disables truncation.
+func ExportMarkdown(w io.Writer, c *Cassette, maxBodyLength int) error {
+	c.Lock()
+	interactions := slices.Clone(c.Interactions)
+	c.Unlock()
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# %s\n\n", escapeMarkdown(c.Name))
+
+	switch len(interactions) {
+	case 0:
+		fmt.Fprintf(bw, "The cassette has no interactions.\n")
+		return bw.Flush()
+	case 1:
+		fmt.Fprintf(bw, "1 interaction.\n\n")
+	default:
+		fmt.Fprintf(bw, "%d interactions.\n\n", len(interactions))
+	}
+
+	fmt.Fprintf(bw, "| # | Method | URL | Status | Request body | Response body |\n")
+	fmt.Fprintf(bw, "|---|--------|-----|--------|--------------|---------------|\n")
+	for _, i := range interactions {
+		fmt.Fprintf(bw, "| %d | %s | %s | %d | %s | %s |\n",
+			i.ID,
+			escapeMarkdown(i.Request.Method),
+			escapeMarkdown(i.Request.URL),
+			i.Response.Code,
+			escapeMarkdown(truncateBody(i.Request.Body, maxBodyLength)),
+			escapeMarkdown(truncateBody(i.Response.Body, maxBodyLength)),
+		)
+	}
+
+	return bw.Flush()
+}
+
+// markdownReplacer escapes characters, which would break Markdown table
+// cells.
+var markdownReplacer = strings.NewReplacer(
+	"\\", "\\\\",
+	"|", "\\|",
+	"`", "\\`",
+	"*", "\\*",
+	"_", "\\_",
+	"<", "&lt;",
+	">", "&gt;",
+	"\r\n", " ",
+	"\n", " ",
+	"\r", " ",
+)
+
+// escapeMarkdown escapes the text for use in a Markdown table cell.
+func escapeMarkdown(s string) string {
+	return markdownReplacer.Replace(s)
+}
+
+// truncateBody truncates the body to at most n characters, marking it as
+// truncated. Bodies, which are not valid UTF-8, are summarized by their
+// size.
+func truncateBody(body string, n int) string {
+	if !utf8.ValidString(body) {
+		return fmt.Sprintf("(%d bytes of binary data)", len(body))
+	}
+	if n <= 0 || utf8.RuneCountInString(body) <= n {
+		return body
+	}
+
+	runes := []rune(body)
+	return string(runes[:n]) + "…"
+}
@@ -0,0 +1,48 @@
+package cassette
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestExportMarkdown(t *testing.T) {
+	c := New("testdata/users")
+	c.Interactions = []*Interaction{
+		{
+			ID:       0,
+			Request:  Request{Method: http.MethodGet, URL: "https://api.example.com/users?page_size=2"},
+			Response: Response{Code: http.StatusOK, Body: "[\n  {\"id\": 1},\n  {\"id\": 2}\n]"},
+		},
+		{
+			ID:       1,
+			Request:  Request{Method: http.MethodPost, URL: "https://api.example.com/users", Body: `{"name": "a|b"}`},
+			Response: Response{Code: http.StatusCreated, Body: "\x1f\x8b\x08\x00\xff"},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := ExportMarkdown(&buf, c, 12); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "# testdata/users\n" +
+		"\n" +
+		"2 interactions.\n" +
+		"\n" +
+		"| # | Method | URL | Status | Request body | Response body |\n" +
+		"|---|--------|-----|--------|--------------|---------------|\n" +
+		"| 0 | GET | https://api.example.com/users?page\\_size=2 | 200 |  | [   {\"id\": 1… |\n" +
+		"| 1 | POST | https://api.example.com/users | 201 | {\"name\": \"a\\|… | (5 bytes of binary data) |\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("got markdown:\n%s\nwant:\n%s", got, want)
+	}
+
+	buf.Reset()
+	if err := ExportMarkdown(&buf, New("empty"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "# empty\n\nThe cassette has no interactions.\n"; got != want {
+		t.Fatalf("got markdown:\n%s\nwant:\n%s", got, want)
+	}
+}
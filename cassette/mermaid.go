@@ -0,0 +1,72 @@
+package cassette
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// ExportMermaid writes a Mermaid sequence diagram of the interactions of the
+// cassette to w, showing the requests of the client to each of the hosts and
+// their responses in the recorded order.
+func ExportMermaid(w io.Writer, c *Cassette) error {
+	c.Lock()
+	interactions := slices.Clone(c.Interactions)
+	c.Unlock()
+
+	// Assign an alias to each host in order of appearance, since host names
+	// may contain characters not allowed in participant names.
+	hosts := make(map[string]string)
+	var participants []string
+	hostOf := make([]string, len(interactions))
+	targets := make([]string, len(interactions))
+	for idx, i := range interactions {
+		u, err := url.Parse(i.Request.URL)
+		if err != nil {
+			return fmt.Errorf("failed to parse request URL %s: %w", i.Request.URL, err)
+		}
+		host := u.Host
+		if host == "" {
+			host = i.Request.Host
+		}
+		if _, ok := hosts[host]; !ok {
+			hosts[host] = fmt.Sprintf("H%d", len(hosts)+1)
+			participants = append(participants, host)
+		}
+		hostOf[idx] = host
+		targets[idx] = u.RequestURI()
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "sequenceDiagram")
+	fmt.Fprintln(bw, "    participant Client")
+	for _, host := range participants {
+		fmt.Fprintf(bw, "    participant %s as %s\n", hosts[host], escapeMermaid(host))
+	}
+	for idx, i := range interactions {
+		alias := hosts[hostOf[idx]]
+		fmt.Fprintf(bw, "    Client->>%s: %s %s\n", alias, escapeMermaid(i.Request.Method), escapeMermaid(targets[idx]))
+		status := strings.TrimSpace(fmt.Sprintf("%d %s", i.Response.Code, http.StatusText(i.Response.Code)))
+		fmt.Fprintf(bw, "    %s-->>Client: %s\n", alias, escapeMermaid(status))
+	}
+
+	return bw.Flush()
+}
+
+// mermaidReplacer escapes characters, which have a special meaning in
+// Mermaid messages, using Mermaid entity codes.
+var mermaidReplacer = strings.NewReplacer(
+	"#", "#35;",
+	";", "#59;",
+	"\n", " ",
+	"\r", " ",
+)
+
+// escapeMermaid escapes the text for use in a Mermaid sequence diagram.
+func escapeMermaid(s string) string {
+	return mermaidReplacer.Replace(s)
+}
@@ -0,0 +1,45 @@
+package cassette
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestExportMermaid(t *testing.T) {
+	c := New("test_export_mermaid")
+	c.Interactions = []*Interaction{
+		{
+			Request:  Request{Method: http.MethodPost, URL: "https://auth.example.com/oauth/token"},
+			Response: Response{Code: http.StatusOK},
+		},
+		{
+			Request:  Request{Method: http.MethodGet, URL: "https://api.example.com/users?fields=id;name"},
+			Response: Response{Code: http.StatusOK},
+		},
+		{
+			Request:  Request{Method: http.MethodGet, URL: "https://auth.example.com/userinfo"},
+			Response: Response{Code: http.StatusUnauthorized},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := ExportMermaid(&buf, c); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `sequenceDiagram
+    participant Client
+    participant H1 as auth.example.com
+    participant H2 as api.example.com
+    Client->>H1: POST /oauth/token
+    H1-->>Client: 200 OK
+    Client->>H2: GET /users?fields=id#59;name
+    H2-->>Client: 200 OK
+    Client->>H1: GET /userinfo
+    H1-->>Client: 401 Unauthorized
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("got diagram:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,49 @@
+package cassette
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Part is a part of a multipart/form-data request body, which was recorded
+// separately, see [Request.Parts].
+type Part struct {
+	// Name is the name of the form field.
+	Name string `yaml:"name" cbor:"name"`
+
+	// Filename is the name of the uploaded file, if the part is a file.
+	Filename string `yaml:"filename,omitempty" cbor:"filename,omitempty"`
+
+	// ContentType is the Content-Type of the part, if any.
+	ContentType string `yaml:"content_type,omitempty" cbor:"content_type,omitempty"`
+
+	// Value is the value of a text field.
+	Value string `yaml:"value,omitempty" cbor:"value,omitempty"`
+
+	// File is the path of the file holding the content of a file part,
+	// relative to the directory of the cassette.
+	File string `yaml:"file,omitempty" cbor:"file,omitempty"`
+
+	// Digest is the digest of the content of a file part, in the form
+	// "sha256:<hex>".
+	Digest string `yaml:"digest,omitempty" cbor:"digest,omitempty"`
+
+	// Size is the size of the content of a file part.
+	Size int64 `yaml:"size,omitempty" cbor:"size,omitempty"`
+}
+
+// PartsBody returns a deterministic representation of the parts of a
+// multipart/form-data body, which requests recorded with their parts are
+// matched on, instead of their body. Text fields are represented by their
+// value, and file parts by their digest.
+func PartsBody(parts []Part) string {
+	var b strings.Builder
+	for _, p := range parts {
+		content := p.Value
+		if p.Filename != "" {
+			content = p.Digest
+		}
+		fmt.Fprintf(&b, "%q %q %q %q\n", p.Name, p.Filename, p.ContentType, content)
+	}
+	return b.String()
+}
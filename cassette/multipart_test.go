@@ -0,0 +1,33 @@
+package cassette
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestPartsBody(t *testing.T) {
+	parts := []Part{
+		{Name: "title", Value: "Q3"},
+		{Name: "report", Filename: "report.pdf", ContentType: "application/pdf", File: "c.parts/1.pdf", Digest: "sha256:1", Size: 3},
+	}
+	want := `"title" "" "" "Q3"` + "\n" + `"report" "report.pdf" "application/pdf" "sha256:1"` + "\n"
+	if got := PartsBody(parts); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	// Requests recorded with their parts are matched on them
+	r, recorded := getMatcherRequests(t)
+	r.Body = io.NopCloser(strings.NewReader(want))
+	recorded.Body, recorded.Parts = "", parts
+	if !hashesMatch(t, DefaultMatcher, r, recorded) {
+		t.Fatal("expected the request to match its parts")
+	}
+
+	i := &Interaction{Request: recorded}
+	clone := i.Clone()
+	clone.Request.Parts[0].Value = "Q4"
+	if i.Request.Parts[0].Value != "Q3" {
+		t.Fatal("expected the clone not to share the parts")
+	}
+}
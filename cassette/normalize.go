@@ -0,0 +1,209 @@
+package cassette
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+// Normalizer rewrites non-deterministic values such as identifiers and
+// timestamps found in a string to stable placeholders. Normalizers are
+// applied to request URLs, header values and bodies, and may be composed
+// using [ChainNormalizers].
+type Normalizer func(s string) string
+
+// Placeholders used by the built-in normalizers. The placeholders keep the
+// format of the value they replace, so that clients parsing normalized
+// responses continue to work.
+const (
+	UUIDPlaceholder      = "00000000-0000-0000-0000-000000000000"
+	ULIDPlaceholder      = "00000000000000000000000000"
+	TimestampPlaceholder = "1970-01-01T00:00:00Z"
+	HTTPDatePlaceholder  = "Thu, 01 Jan 1970 00:00:00 GMT"
+	NumericIDPlaceholder = "0"
+)
+
+var (
+	uuidPattern      = regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)
+	ulidPattern      = regexp.MustCompile(`\b[0-7][0-9A-HJKMNP-TV-Z]{25}\b`)
+	timestampPattern = regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?\b`)
+	httpDatePattern  = regexp.MustCompile(`\b(Mon|Tue|Wed|Thu|Fri|Sat|Sun), \d{2} (Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec) \d{4} \d{2}:\d{2}:\d{2} GMT\b`)
+	numericIDPattern = regexp.MustCompile(`/\d+(/|\?|#|"|$)`)
+)
+
+// NormalizeUUIDs returns a [Normalizer] which replaces UUIDs with
+// [UUIDPlaceholder].
+func NormalizeUUIDs() Normalizer {
+	return func(s string) string {
+		return uuidPattern.ReplaceAllString(s, UUIDPlaceholder)
+	}
+}
+
+// NormalizeULIDs returns a [Normalizer] which replaces ULIDs with
+// [ULIDPlaceholder].
+func NormalizeULIDs() Normalizer {
+	return func(s string) string {
+		return ulidPattern.ReplaceAllString(s, ULIDPlaceholder)
+	}
+}
+
+// NormalizeTimestamps returns a [Normalizer] which replaces RFC 3339
+// timestamps with [TimestampPlaceholder] and HTTP dates (RFC 9110, section
+// 5.6.7) with [HTTPDatePlaceholder].
+func NormalizeTimestamps() Normalizer {
+	return func(s string) string {
+		s = timestampPattern.ReplaceAllString(s, TimestampPlaceholder)
+		return httpDatePattern.ReplaceAllString(s, HTTPDatePlaceholder)
+	}
+}
+
+// NormalizeNumericIDs returns a [Normalizer] which replaces purely numeric
+// path segments, e.g. /users/1234/orders, with [NumericIDPlaceholder].
+func NormalizeNumericIDs() Normalizer {
+	return func(s string) string {
+		// Adjacent segments share a delimiter, so repeat until stable.
+		for {
+			normalized := numericIDPattern.ReplaceAllString(s, "/"+NumericIDPlaceholder+"$1")
+			if normalized == s {
+				return s
+			}
+			s = normalized
+		}
+	}
+}
+
+// ChainNormalizers composes the given normalizers into a single
+// [Normalizer], which applies them in order.
+func ChainNormalizers(normalizers ...Normalizer) Normalizer {
+	return func(s string) string {
+		for _, n := range normalizers {
+			s = n(s)
+		}
+		return s
+	}
+}
+
+// DefaultNormalizers returns the set of all built-in normalizers.
+func DefaultNormalizers() []Normalizer {
+	return []Normalizer{
+		NormalizeUUIDs(),
+		NormalizeULIDs(),
+		NormalizeTimestamps(),
+		NormalizeNumericIDs(),
+	}
+}
+
+// NormalizeInteraction applies the normalizers to the URL, headers and
+// bodies of the recorded request and response. Content lengths are adjusted
+// when normalizing changes the size of a body.
+func NormalizeInteraction(i *Interaction, normalizers ...Normalizer) {
+	n := ChainNormalizers(normalizers...)
+
+	i.Request.URL = n(i.Request.URL)
+	i.Request.RequestURI = n(i.Request.RequestURI)
+	normalizeHeader(i.Request.Headers, n)
+	i.Request.Body, i.Request.ContentLength = normalizeBody(i.Request.Body, i.Request.ContentLength, i.Request.Headers, n)
+	for k, values := range i.Request.Form {
+		for idx, v := range values {
+			values[idx] = n(v)
+		}
+		i.Request.Form[k] = values
+	}
+
+	normalizeHeader(i.Response.Headers, n)
+	i.Response.Body, i.Response.ContentLength = normalizeBody(i.Response.Body, i.Response.ContentLength, i.Response.Headers, n)
+}
+
+// CanonicalizeHeaders canonicalizes the keys of the headers and trailers of
+// the recorded request and response, see [http.CanonicalHeaderKey], and
+// sorts the values of each header, so that clients sending headers with
+// different casing or order between runs do not produce spurious diffs of
+// the cassette. The values of keys differing in case only are merged.
+func CanonicalizeHeaders(i *Interaction) {
+	i.Request.Headers = sortedHeader(i.Request.Headers)
+	i.Request.Trailer = sortedHeader(i.Request.Trailer)
+	i.Response.Headers = sortedHeader(i.Response.Headers)
+	i.Response.Trailer = sortedHeader(i.Response.Trailer)
+}
+
+// sortedHeader returns a copy of the header with canonical keys and sorted
+// values.
+func sortedHeader(h http.Header) http.Header {
+	h = canonicalHeader(h)
+	for _, values := range h {
+		slices.Sort(values)
+	}
+	return h
+}
+
+func normalizeHeader(h http.Header, n Normalizer) {
+	for k, values := range h {
+		if http.CanonicalHeaderKey(k) == "Content-Length" {
+			continue
+		}
+		for idx, v := range values {
+			values[idx] = n(v)
+		}
+	}
+}
+
+// normalizeBody normalizes the body and fixes up the content length, if it
+// was describing the original body.
+func normalizeBody(body string, contentLength int64, h http.Header, n Normalizer) (string, int64) {
+	normalized := n(body)
+	if contentLength != int64(len(body)) || len(normalized) == len(body) {
+		return normalized, contentLength
+	}
+
+	contentLength = int64(len(normalized))
+	if h.Get("Content-Length") != "" {
+		h.Set("Content-Length", strconv.FormatInt(contentLength, 10))
+	}
+
+	return normalized, contentLength
+}
+
+// normalizingMatcher normalizes requests before passing them to the wrapped
+// matcher.
+type normalizingMatcher struct {
+	matcher    RequestMatcher
+	normalizer Normalizer
+}
+
+// NewNormalizingMatcher returns a [RequestMatcher] which normalizes the URL,
+// headers and body of a request using the given normalizers, before
+// generating a hash using the provided matcher.
+func NewNormalizingMatcher(matcher RequestMatcher, normalizers ...Normalizer) RequestMatcher {
+	return &normalizingMatcher{
+		matcher:    matcher,
+		normalizer: ChainNormalizers(normalizers...),
+	}
+}
+
+// Hash implements RequestMatcher.
+func (m *normalizingMatcher) Hash(r *http.Request) (string, error) {
+	bodyBytes, err := readBody(r)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(m.normalizer(r.URL.String()))
+	if err != nil {
+		return "", err
+	}
+
+	nr := r.Clone(r.Context())
+	nr.URL = u
+	nr.RequestURI = m.normalizer(r.RequestURI)
+	nr.Form = nil
+	nr.PostForm = nil
+	normalizeHeader(nr.Header, m.normalizer)
+
+	body, contentLength := normalizeBody(string(bodyBytes), r.ContentLength, nr.Header, m.normalizer)
+	nr.Body = newReplayableBody([]byte(body))
+	nr.ContentLength = contentLength
+
+	return m.matcher.Hash(nr)
+}
@@ -0,0 +1,162 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNormalizers(t *testing.T) {
+	tests := []struct {
+		name       string
+		normalizer Normalizer
+		in         string
+		want       string
+	}{
+		{
+			name:       "UUID",
+			normalizer: NormalizeUUIDs(),
+			in:         `{"id":"3F2504E0-4F89-11D3-9A0C-0305E82C3301"}`,
+			want:       `{"id":"00000000-0000-0000-0000-000000000000"}`,
+		},
+		{
+			name:       "ULID",
+			normalizer: NormalizeULIDs(),
+			in:         "/orders/01ARZ3NDEKTSV4RRFFQ69G5FAV",
+			want:       "/orders/00000000000000000000000000",
+		},
+		{
+			name:       "RFC 3339 timestamp",
+			normalizer: NormalizeTimestamps(),
+			in:         `{"created_at":"2024-05-01T12:34:56.789+02:00"}`,
+			want:       `{"created_at":"1970-01-01T00:00:00Z"}`,
+		},
+		{
+			name:       "HTTP date",
+			normalizer: NormalizeTimestamps(),
+			in:         "Wed, 21 Oct 2015 07:28:00 GMT",
+			want:       "Thu, 01 Jan 1970 00:00:00 GMT",
+		},
+		{
+			name:       "numeric IDs",
+			normalizer: NormalizeNumericIDs(),
+			in:         "https://example.com/users/42/posts/7?page=2",
+			want:       "https://example.com/users/0/posts/0?page=2",
+		},
+		{
+			name:       "numeric IDs keep mixed segments",
+			normalizer: NormalizeNumericIDs(),
+			in:         "https://example.com/v1/42abc",
+			want:       "https://example.com/v1/42abc",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.normalizer(test.in); got != test.want {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeInteraction(t *testing.T) {
+	i := &Interaction{
+		Request: Request{
+			URL:    "https://example.com/users/1234",
+			Method: http.MethodGet,
+		},
+		Response: Response{
+			Body:          `{"id":1234,"at":"2024-05-01T12:34:56.123456Z"}`,
+			ContentLength: 46,
+			Headers:       http.Header{"Content-Length": {"46"}},
+		},
+	}
+
+	NormalizeInteraction(i, DefaultNormalizers()...)
+
+	if i.Request.URL != "https://example.com/users/0" {
+		t.Fatalf("unexpected normalized URL %q", i.Request.URL)
+	}
+
+	wantBody := `{"id":1234,"at":"1970-01-01T00:00:00Z"}`
+	if i.Response.Body != wantBody {
+		t.Fatalf("got body %q, want %q", i.Response.Body, wantBody)
+	}
+
+	if i.Response.ContentLength != int64(len(wantBody)) {
+		t.Fatalf("got content length %d, want %d", i.Response.ContentLength, len(wantBody))
+	}
+
+	if got := i.Response.Headers.Get("Content-Length"); got != "39" {
+		t.Fatalf("got Content-Length header %q, want %q", got, "39")
+	}
+}
+
+func TestCanonicalizeHeaders(t *testing.T) {
+	original := http.Header{"accept": {"text/plain", "application/json"}}
+	i := &Interaction{
+		Request: Request{
+			Headers: http.Header{"x-trace": {"b"}, "X-Trace": {"a"}, "Accept": {"*/*"}},
+			Trailer: original,
+		},
+		Response: Response{
+			Headers: http.Header{"content-type": {"text/plain"}},
+		},
+	}
+
+	CanonicalizeHeaders(i)
+
+	if got := i.Request.Headers["X-Trace"]; !slices.Equal(got, []string{"a", "b"}) || len(i.Request.Headers) != 2 {
+		t.Fatalf("got request headers %v, want the values of X-Trace merged and sorted", i.Request.Headers)
+	}
+	if got := i.Request.Trailer["Accept"]; !slices.Equal(got, []string{"application/json", "text/plain"}) {
+		t.Fatalf("got request trailer %v, want sorted values", i.Request.Trailer)
+	}
+	if got := i.Response.Headers["Content-Type"]; !slices.Equal(got, []string{"text/plain"}) {
+		t.Fatalf("got response headers %v, want canonical keys", i.Response.Headers)
+	}
+	if got := original["accept"]; got[0] != "text/plain" {
+		t.Fatalf("got original values %v, want them unmodified", got)
+	}
+}
+
+func TestNormalizingMatcher(t *testing.T) {
+	matcher := NewNormalizingMatcher(DefaultMatcher, DefaultNormalizers()...)
+
+	newRequest := func(id string) *http.Request {
+		body := `{"request_id":"` + id + `"}`
+		r, err := http.NewRequest(http.MethodPost, "https://example.com/items/"+id, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	r1 := newRequest("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	r2 := newRequest("6ba7b811-9dad-11d1-80b4-00c04fd430c8")
+
+	hash1, err := matcher.Hash(r1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash2, err := matcher.Hash(r2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hash1 != hash2 {
+		t.Fatal("expected hashes of normalized requests to match")
+	}
+
+	// The original request body must still be readable.
+	body, err := io.ReadAll(r1.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), "6ba7b810") {
+		t.Fatalf("original request body was modified: %q", body)
+	}
+}
@@ -0,0 +1,263 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Body formats, which are supported by [PrettyPrintResponseBody].
+const (
+	// FormatJSON is the format of JSON bodies, i.e. bodies with the
+	// application/json media type, or a media type with the +json suffix.
+	FormatJSON = "json"
+
+	// FormatXML is the format of XML bodies, i.e. bodies with the
+	// application/xml or text/xml media type, or a media type with the +xml
+	// suffix.
+	FormatXML = "xml"
+)
+
+// ErrUnsupportedBodyFormat is returned when pretty-printing or compacting
+// a body using an unsupported format.
+var ErrUnsupportedBodyFormat = errors.New("unsupported body format")
+
+// PrettyPrintResponseBody pretty-prints the response body of the
+// interaction, if its Content-Type header denotes one of the given formats,
+// see [FormatJSON] and [FormatXML], so that changes of the body are easy to
+// review and diff. The format is kept in the PrettyPrinted field of the
+// response, and the body is compacted again on replay. The recorded content
+// length is set to the length of the compacted body, if it described the
+// original body, so that it matches the replayed body. The replayed body is
+// semantically equivalent to the recorded one, although insignificant
+// whitespace may differ.
+//
+// Responses, which have a content encoding, are only pretty-printed if they
+// were decoded, see [DecodeResponseBody]. Responses with an invalid body,
+// which were already pretty-printed, or whose body is stored base64-encoded,
+// are left as is.
+func PrettyPrintResponseBody(i *Interaction, formats ...string) error {
+	if i.Response.PrettyPrinted != "" || i.Response.BodyEncoding != "" || i.Response.Body == "" {
+		return nil
+	}
+	if i.Response.DecodedContentEncoding == "" && len(contentEncodings(i.Response.Headers)) > 0 {
+		return nil
+	}
+
+	format := bodyFormat(i.Response.Headers)
+	if format == "" || !slices.Contains(formats, format) {
+		return nil
+	}
+
+	body, err := PrettyPrintBody(i.Response.Body, format)
+	if err != nil {
+		// The body is not what its content type claims
+		return nil
+	}
+	compact, err := CompactBody(body, format)
+	if err != nil {
+		return err
+	}
+
+	r := &i.Response
+	_, r.ContentLength = normalizeBody(r.Body, r.ContentLength, r.Headers, func(string) string {
+		return compact
+	})
+	r.Body = body
+	r.PrettyPrinted = format
+
+	return nil
+}
+
+// PrettyPrintBody returns the body of the given format, see [FormatJSON]
+// and [FormatXML], indented by two spaces per level.
+func PrettyPrintBody(body, format string) (string, error) {
+	switch format {
+	case FormatJSON:
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, []byte(body), "", "  "); err != nil {
+			return "", fmt.Errorf("failed to pretty-print JSON body: %w", err)
+		}
+		return strings.TrimRight(buf.String(), " \t\r\n") + "\n", nil
+	case FormatXML:
+		s, err := formatXML(body, "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to pretty-print XML body: %w", err)
+		}
+		return s + "\n", nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedBodyFormat, format)
+	}
+}
+
+// CompactBody returns the body of the given format, see [FormatJSON] and
+// [FormatXML], with the insignificant whitespace removed.
+func CompactBody(body, format string) (string, error) {
+	switch format {
+	case FormatJSON:
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, []byte(body)); err != nil {
+			return "", fmt.Errorf("failed to compact JSON body: %w", err)
+		}
+		return buf.String(), nil
+	case FormatXML:
+		s, err := formatXML(body, "")
+		if err != nil {
+			return "", fmt.Errorf("failed to compact XML body: %w", err)
+		}
+		return s, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedBodyFormat, format)
+	}
+}
+
+// bodyFormat returns the format of the body according to the Content-Type
+// header, or an empty string, if the format is not supported.
+func bodyFormat(h http.Header) string {
+	values := headerValues(h, "Content-Type")
+	if len(values) == 0 {
+		return ""
+	}
+	mediaType, _, err := mime.ParseMediaType(values[0])
+	if err != nil {
+		return ""
+	}
+
+	switch {
+	case mediaType == "application/json", strings.HasSuffix(mediaType, "+json"):
+		return FormatJSON
+	case mediaType == "application/xml", mediaType == "text/xml", strings.HasSuffix(mediaType, "+xml"):
+		return FormatXML
+	}
+	return ""
+}
+
+// formatXML writes the XML document without the whitespace between the
+// children of element-only elements, and indents these children using the
+// given indent, unless it is empty. Elements are element-only, if they
+// have child markup, and their text consists of line breaks and
+// indentation only. The text of other elements, including mixed content
+// and whitespace, is kept as is, and their children are not indented, so
+// that the content of the document is not changed.
+func formatXML(body, indent string) (string, error) {
+	d := xml.NewDecoder(strings.NewReader(body))
+	d.Strict = true
+
+	var tokens []xml.Token
+	for {
+		t, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		tokens = append(tokens, xml.CopyToken(t))
+	}
+	elementOnly := xmlElementOnly(tokens)
+
+	var buf bytes.Buffer
+	// indented tracks whether the children of the open elements are
+	// indented, starting with the document itself.
+	indented := []bool{true}
+	for idx, t := range tokens {
+		parentIndented := indented[len(indented)-1]
+		if _, end := t.(xml.EndElement); end {
+			indented = indented[:len(indented)-1]
+		}
+
+		if text, ok := t.(xml.CharData); ok {
+			if !parentIndented {
+				buf.WriteString(xmlTextReplacer.Replace(string(text)))
+			}
+			continue
+		}
+		if indent != "" && parentIndented && idx > 0 {
+			buf.WriteString("\n" + strings.Repeat(indent, len(indented)-1))
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			buf.WriteString("<" + xmlName(t.Name))
+			for _, attr := range t.Attr {
+				buf.WriteString(" " + xmlName(attr.Name) + `="` + xmlAttrReplacer.Replace(attr.Value) + `"`)
+			}
+			buf.WriteString(">")
+			indented = append(indented, parentIndented && elementOnly[idx])
+		case xml.EndElement:
+			buf.WriteString("</" + xmlName(t.Name) + ">")
+		case xml.Comment:
+			buf.WriteString("<!--" + string(t) + "-->")
+		case xml.ProcInst:
+			buf.WriteString("<?" + t.Target)
+			if len(t.Inst) > 0 {
+				buf.WriteString(" " + string(t.Inst))
+			}
+			buf.WriteString("?>")
+		case xml.Directive:
+			buf.WriteString("<!" + string(t) + ">")
+		}
+	}
+
+	return buf.String(), nil
+}
+
+// xmlElementOnly returns whether the elements started by the tokens at the
+// given indices are element-only, see [formatXML].
+func xmlElementOnly(tokens []xml.Token) map[int]bool {
+	elementOnly := make(map[int]bool)
+	var open []int
+	for idx, t := range tokens {
+		if len(open) > 0 {
+			parent := open[len(open)-1]
+			switch t := t.(type) {
+			case xml.CharData:
+				if len(bytes.Trim(t, " \t\r\n")) > 0 || !bytes.ContainsRune(t, '\n') {
+					elementOnly[parent] = false
+				}
+			case xml.StartElement, xml.Comment, xml.ProcInst, xml.Directive:
+				if _, ok := elementOnly[parent]; !ok {
+					elementOnly[parent] = true
+				}
+			}
+		}
+
+		switch t.(type) {
+		case xml.StartElement:
+			open = append(open, idx)
+		case xml.EndElement:
+			open = open[:len(open)-1]
+		}
+	}
+
+	return elementOnly
+}
+
+// xmlName returns the name with its namespace prefix, as returned by
+// [xml.Decoder.RawToken].
+func xmlName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// xmlTextReplacer escapes XML text content.
+var xmlTextReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// xmlAttrReplacer escapes XML attribute values quoted with double quotes.
+var xmlAttrReplacer = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	`"`, "&quot;",
+	"\t", "&#x9;",
+	"\n", "&#xA;",
+	"\r", "&#xD;",
+)
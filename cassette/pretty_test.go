@@ -0,0 +1,162 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestPrettyPrintBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		body    string
+		pretty  string
+		compact string
+	}{
+		{
+			name:    "json",
+			format:  FormatJSON,
+			body:    `{"id":1,"tags":["a","b"],"empty":{}}` + "\n",
+			pretty:  "{\n  \"id\": 1,\n  \"tags\": [\n    \"a\",\n    \"b\"\n  ],\n  \"empty\": {}\n}\n",
+			compact: `{"id":1,"tags":["a","b"],"empty":{}}`,
+		},
+		{
+			name:    "xml",
+			format:  FormatXML,
+			body:    `<?xml version="1.0"?><ns:user id="1" note="a &amp; &quot;b&quot;"><name>Jo &lt;3</name><empty/><p>Hello <b>world</b>!</p><!-- c --></ns:user>`,
+			pretty:  "<?xml version=\"1.0\"?>\n<ns:user id=\"1\" note=\"a &amp; &quot;b&quot;\">\n  <name>Jo &lt;3</name>\n  <empty></empty>\n  <p>Hello <b>world</b>!</p>\n  <!-- c -->\n</ns:user>\n",
+			compact: `<?xml version="1.0"?><ns:user id="1" note="a &amp; &quot;b&quot;"><name>Jo &lt;3</name><empty></empty><p>Hello <b>world</b>!</p><!-- c --></ns:user>`,
+		},
+		{
+			name:    "xml indented",
+			format:  FormatXML,
+			body:    "<list>\n\t<item>a</item>\n\t<item> </item>\n</list>\n",
+			pretty:  "<list>\n  <item>a</item>\n  <item> </item>\n</list>\n",
+			compact: "<list><item>a</item><item> </item></list>",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pretty, err := PrettyPrintBody(test.body, test.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pretty != test.pretty {
+				t.Fatalf("got pretty body:\n%s\nwant:\n%s", pretty, test.pretty)
+			}
+
+			compact, err := CompactBody(pretty, test.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if compact != test.compact {
+				t.Fatalf("got compact body %q, want %q", compact, test.compact)
+			}
+		})
+	}
+}
+
+func TestPrettyPrintXMLMixedContent(t *testing.T) {
+	// Compacting the pretty-printed body restores compact bodies exactly
+	for _, body := range []string{
+		`<a> </a>`,
+		`<p><b>x</b> <i>y</i></p>`,
+		`<p><b>x</b>y</p>`,
+		`<doc><p>Hello <b><i>big</i> world</b>!</p><list><item>a</item></list></doc>`,
+		`<pre>  line 1
+  line 2</pre>`,
+	} {
+		pretty, err := PrettyPrintBody(body, FormatXML)
+		if err != nil {
+			t.Fatal(err)
+		}
+		compact, err := CompactBody(pretty, FormatXML)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if compact != body {
+			t.Fatalf("got compact body %q of pretty body %q, want %q", compact, pretty, body)
+		}
+	}
+}
+
+func TestPrettyPrintResponseBody(t *testing.T) {
+	body := `{"id":1}`
+	i := &Interaction{
+		Request: Request{Method: http.MethodGet, URL: "https://example.com/"},
+		Response: Response{
+			Code:          http.StatusOK,
+			Body:          body,
+			ContentLength: int64(len(body)),
+			Headers: http.Header{
+				"Content-Type":   {"application/vnd.api+json; charset=utf-8"},
+				"Content-Length": {strconv.Itoa(len(body))},
+			},
+		},
+	}
+
+	// Only the given formats are pretty-printed
+	if err := PrettyPrintResponseBody(i, FormatXML); err != nil {
+		t.Fatal(err)
+	}
+	if i.Response.Body != body || i.Response.PrettyPrinted != "" {
+		t.Fatalf("unexpected pretty-printed body %q", i.Response.Body)
+	}
+
+	if err := PrettyPrintResponseBody(i, FormatJSON, FormatXML); err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\n  \"id\": 1\n}\n"; i.Response.Body != want || i.Response.PrettyPrinted != FormatJSON {
+		t.Fatalf("got body %q as %q, want %q as %q", i.Response.Body, i.Response.PrettyPrinted, want, FormatJSON)
+	}
+
+	resp, err := i.GetHTTPResponse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != body {
+		t.Fatalf("got replayed body %q, want %q", data, body)
+	}
+	if resp.ContentLength != int64(len(body)) || resp.Header.Get("Content-Length") != strconv.Itoa(len(body)) {
+		t.Fatalf("got content length %d and header %q, want %d", resp.ContentLength, resp.Header.Get("Content-Length"), len(body))
+	}
+
+	// The content length is set to the length of the replayed body
+	indented := "{\n\t\"id\": 1\n}"
+	i.Response = Response{
+		Code:          http.StatusOK,
+		Body:          indented,
+		ContentLength: int64(len(indented)),
+		Headers: http.Header{
+			"Content-Type":   {"application/json"},
+			"Content-Length": {strconv.Itoa(len(indented))},
+		},
+	}
+	if err := PrettyPrintResponseBody(i, FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	if i.Response.ContentLength != int64(len(body)) || i.Response.Headers.Get("Content-Length") != strconv.Itoa(len(body)) {
+		t.Fatalf("got content length %d and header %q, want %d", i.Response.ContentLength, i.Response.Headers.Get("Content-Length"), len(body))
+	}
+
+	// Invalid and encoded bodies are left as is
+	for _, r := range []Response{
+		{Body: `{"id":`, Headers: http.Header{"Content-Type": {"application/json"}}},
+		{Body: "\x1f\x8b", Headers: http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {"gzip"}}},
+	} {
+		i := &Interaction{Response: r}
+		if err := PrettyPrintResponseBody(i, FormatJSON); err != nil {
+			t.Fatal(err)
+		}
+		if i.Response.Body != r.Body || i.Response.PrettyPrinted != "" {
+			t.Fatalf("unexpected pretty-printed body %q", i.Response.Body)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package cassette
+
+import (
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Find returns the interactions satisfying the predicate in recording order,
+// e.g. for asserting what was recorded:
+//
+//	if n := len(c.FindByMethod(http.MethodPost)); n != 2 {
+//		t.Fatalf("recorded %d POST requests, want 2", n)
+//	}
+func (c *Cassette) Find(pred func(i *Interaction) bool) []*Interaction {
+	c.Lock()
+	defer c.Unlock()
+
+	interactions := make([]*Interaction, 0)
+	for _, i := range c.Interactions {
+		if pred(i) {
+			interactions = append(interactions, i)
+		}
+	}
+
+	return interactions
+}
+
+// FindByPath returns the interactions, whose request URL path matches the
+// glob pattern, in which "*" matches any sequence of characters, e.g.
+// "/orders/*". Interactions with an invalid request URL never match.
+func (c *Cassette) FindByPath(pattern string) []*Interaction {
+	re := CompileGlob(pattern)
+	return c.Find(func(i *Interaction) bool {
+		u, err := url.Parse(i.Request.URL)
+		return err == nil && re.MatchString(u.Path)
+	})
+}
+
+// FindByMethod returns the interactions, whose request method equals the
+// given one, ignoring case.
+func (c *Cassette) FindByMethod(method string) []*Interaction {
+	return c.Find(func(i *Interaction) bool {
+		return strings.EqualFold(i.Request.Method, method)
+	})
+}
+
+// FindByStatus returns the interactions, whose response status code is any
+// of the given ones.
+func (c *Cassette) FindByStatus(codes ...int) []*Interaction {
+	return c.Find(func(i *Interaction) bool {
+		return slices.Contains(codes, i.Response.Code)
+	})
+}
+
+// CompileGlob compiles a glob pattern, in which "*" matches any sequence
+// of characters, into an anchored regular expression, e.g. for matching
+// URLs and paths.
+func CompileGlob(pattern string) *regexp.Regexp {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
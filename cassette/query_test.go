@@ -0,0 +1,58 @@
+package cassette
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	c := New("test_find")
+	for _, stub := range []struct {
+		method string
+		url    string
+		status int
+	}{
+		{http.MethodGet, "https://shop.example.com/orders", http.StatusOK},
+		{http.MethodPost, "https://shop.example.com/orders", http.StatusCreated},
+		{http.MethodPost, "https://shop.example.com/orders?dry_run=1", http.StatusCreated},
+		{http.MethodGet, "https://shop.example.com/orders/1", http.StatusNotFound},
+		{http.MethodDelete, "https://shop.example.com/orders/2", http.StatusNoContent},
+	} {
+		if _, err := c.AddStub(stub.method, stub.url, stub.status, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := func(interactions []*Interaction) []int {
+		ids := make([]int, 0, len(interactions))
+		for _, i := range interactions {
+			ids = append(ids, i.ID)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name string
+		got  []*Interaction
+		want []int
+	}{
+		{name: "path", got: c.FindByPath("/orders"), want: []int{0, 1, 2}},
+		{name: "path glob", got: c.FindByPath("/orders/*"), want: []int{3, 4}},
+		{name: "path none", got: c.FindByPath("/users"), want: []int{}},
+		{name: "method", got: c.FindByMethod(http.MethodPost), want: []int{1, 2}},
+		{name: "method case", got: c.FindByMethod("delete"), want: []int{4}},
+		{name: "status", got: c.FindByStatus(http.StatusCreated, http.StatusNoContent), want: []int{1, 2, 4}},
+		{name: "predicate", got: c.Find(func(i *Interaction) bool {
+			return i.Request.Method == http.MethodGet && i.Response.Code == http.StatusOK
+		}), want: []int{0}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ids(test.got); !slices.Equal(got, test.want) {
+				t.Fatalf("got interactions %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package cassette
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidRange is returned when parsing a malformed Range header.
+	ErrInvalidRange = errors.New("invalid range")
+
+	// ErrInvalidContentRange is returned when a recorded partial response
+	// has a malformed Content-Range header, or one which does not match
+	// the recorded body.
+	ErrInvalidContentRange = errors.New("invalid content range")
+)
+
+// ByteRange is a range of bytes, where both Start and End are inclusive, as
+// used by the Range and Content-Range headers (RFC 9110, section 14).
+type ByteRange struct {
+	Start int64
+	End   int64
+}
+
+// Length returns the number of bytes in the range.
+func (r ByteRange) Length() int64 {
+	return r.End - r.Start + 1
+}
+
+// ContentRange returns the value of the Content-Range header describing the
+// range of a representation of the given size.
+func (r ByteRange) ContentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.Start, r.End, size)
+}
+
+// ParseRange parses a Range header for a representation of the given size.
+// Ranges exceeding the size are truncated, and unsatisfiable ranges are
+// omitted, so that the result is empty if none of the ranges can be
+// satisfied.
+func ParseRange(header string, size int64) ([]ByteRange, error) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRange, header)
+	}
+
+	ranges := make([]ByteRange, 0)
+	for _, part := range strings.Split(spec, ",") {
+		first, last, ok := strings.Cut(strings.TrimSpace(part), "-")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRange, header)
+		}
+
+		var r ByteRange
+		if first == "" {
+			// Suffix range, e.g. bytes=-500 for the last 500 bytes
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidRange, header)
+			}
+			if n == 0 || size == 0 {
+				continue
+			}
+			r = ByteRange{Start: max(size-n, 0), End: size - 1}
+		} else {
+			start, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || start < 0 {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidRange, header)
+			}
+			end := size - 1
+			if last != "" {
+				end, err = strconv.ParseInt(last, 10, 64)
+				if err != nil || end < start {
+					return nil, fmt.Errorf("%w: %q", ErrInvalidRange, header)
+				}
+			}
+			if start >= size {
+				continue
+			}
+			r = ByteRange{Start: start, End: min(end, size-1)}
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	return ranges, nil
+}
+
+// ParseContentRange parses the Content-Range header of a partial response.
+// The returned size is -1, if the complete length is unknown.
+func ParseContentRange(header string) (ByteRange, int64, error) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes ")
+	if !ok {
+		return ByteRange{}, 0, fmt.Errorf("%w: %q", ErrInvalidContentRange, header)
+	}
+
+	rangeSpec, sizeSpec, ok := strings.Cut(spec, "/")
+	if !ok {
+		return ByteRange{}, 0, fmt.Errorf("%w: %q", ErrInvalidContentRange, header)
+	}
+
+	size := int64(-1)
+	if sizeSpec != "*" {
+		var err error
+		size, err = strconv.ParseInt(sizeSpec, 10, 64)
+		if err != nil || size < 0 {
+			return ByteRange{}, 0, fmt.Errorf("%w: %q", ErrInvalidContentRange, header)
+		}
+	}
+
+	first, last, ok := strings.Cut(rangeSpec, "-")
+	if !ok {
+		return ByteRange{}, 0, fmt.Errorf("%w: %q", ErrInvalidContentRange, header)
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return ByteRange{}, 0, fmt.Errorf("%w: %q", ErrInvalidContentRange, header)
+	}
+	end, err := strconv.ParseInt(last, 10, 64)
+	if err != nil || start < 0 || end < start || (size >= 0 && end >= size) {
+		return ByteRange{}, 0, fmt.Errorf("%w: %q", ErrInvalidContentRange, header)
+	}
+
+	return ByteRange{Start: start, End: end}, size, nil
+}
+
+// GetHTTPRangeResponse converts the recorded interaction response to an
+// http.Response for a request with the given Range and If-Range headers.
+//
+// If the recorded response is a complete 200 response, or a 206 response
+// covering the requested range, a 206 Partial Content response with a
+// matching Content-Range is synthesized. If the range cannot be satisfied, a
+// 416 Range Not Satisfiable response is returned. If the range is not
+// covered by a recorded partial response, [ErrInteractionNotFound] is
+// returned.
+//
+// The recorded response is returned as is, when no range was requested,
+// multiple ranges were requested, or the If-Range validator does not match
+// the recorded ETag or Last-Modified header.
+func (i *Interaction) GetHTTPRangeResponse(rangeHeader, ifRange string) (*http.Response, error) {
+	resp, err := i.GetHTTPResponse()
+	if err != nil {
+		return nil, err
+	}
+
+	if rangeHeader == "" || !ifRangeMatches(resp.Header, ifRange) {
+		return resp, nil
+	}
+
+	// The body may have been encoded again, so the ranges refer to the
+	// replayed body, rather than the recorded one.
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	// available is the range of the representation recorded in the body
+	body := string(data)
+	size := int64(len(body))
+	available := ByteRange{Start: 0, End: size - 1}
+	switch i.Response.Code {
+	case http.StatusOK:
+	case http.StatusPartialContent:
+		available, size, err = ParseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return nil, err
+		}
+		if available.Length() != int64(len(body)) {
+			return nil, fmt.Errorf("%w: %s does not match body of %d bytes", ErrInvalidContentRange, resp.Header.Get("Content-Range"), len(body))
+		}
+		if size < 0 {
+			size = available.End + 1
+		}
+	default:
+		return resp, nil
+	}
+
+	ranges, err := ParseRange(rangeHeader, size)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(ranges) > 1:
+		// Servers may ignore multiple ranges instead of responding
+		// with multipart/byteranges.
+		return resp, nil
+	case len(ranges) == 0:
+		return rangeResponse(resp, http.StatusRequestedRangeNotSatisfiable, fmt.Sprintf("bytes */%d", size), ""), nil
+	}
+
+	r := ranges[0]
+	if r.Start < available.Start || r.End > available.End {
+		return nil, fmt.Errorf("%w: range %s is not covered by recorded %s", ErrInteractionNotFound, rangeHeader, available.ContentRange(size))
+	}
+
+	offset := r.Start - available.Start
+	return rangeResponse(resp, http.StatusPartialContent, r.ContentRange(size), body[offset:offset+r.Length()]), nil
+}
+
+// ifRangeMatches returns true, if the If-Range validator is empty, or
+// matches the ETag or Last-Modified header.
+func ifRangeMatches(h http.Header, ifRange string) bool {
+	if ifRange == "" {
+		return true
+	}
+	// Weak entity tags must not be used for ranges
+	if strings.HasPrefix(ifRange, "W/") {
+		return false
+	}
+	return ifRange == h.Get("ETag") || ifRange == h.Get("Last-Modified")
+}
+
+// rangeResponse rewrites the response to a response with the given status,
+// Content-Range and body.
+func rangeResponse(resp *http.Response, code int, contentRange, body string) *http.Response {
+	resp.StatusCode = code
+	resp.Status = fmt.Sprintf("%d %s", code, http.StatusText(code))
+	resp.TransferEncoding = nil
+	resp.ContentLength = int64(len(body))
+	resp.Body = io.NopCloser(strings.NewReader(body))
+	resp.Trailer = nil
+
+	resp.Header = resp.Header.Clone()
+	if resp.Header == nil {
+		resp.Header = make(http.Header)
+	}
+	resp.Header.Set("Content-Range", contentRange)
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	return resp
+}
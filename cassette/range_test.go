@@ -0,0 +1,144 @@
+package cassette
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    []ByteRange
+		wantErr bool
+	}{
+		{header: "bytes=0-4", want: []ByteRange{{0, 4}}},
+		{header: "bytes=5-", want: []ByteRange{{5, 9}}},
+		{header: "bytes=-3", want: []ByteRange{{7, 9}}},
+		{header: "bytes=8-20", want: []ByteRange{{8, 9}}},
+		{header: "bytes=0-1, 4-5", want: []ByteRange{{0, 1}, {4, 5}}},
+		{header: "bytes=10-", want: []ByteRange{}},
+		{header: "bytes=5-4", wantErr: true},
+		{header: "items=0-4", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseRange(test.header, 10)
+		if test.wantErr {
+			if !errors.Is(err, ErrInvalidRange) {
+				t.Fatalf("%s: expected ErrInvalidRange, got %v", test.header, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", test.header, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("%s: got %v, want %v", test.header, got, test.want)
+		}
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	r, size, err := ParseContentRange("bytes 2-5/10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r != (ByteRange{2, 5}) || size != 10 {
+		t.Fatalf("unexpected range %v of %d", r, size)
+	}
+
+	if _, size, err := ParseContentRange("bytes 2-5/*"); err != nil || size != -1 {
+		t.Fatalf("unexpected size %d: %v", size, err)
+	}
+
+	for _, header := range []string{"bytes 5-2/10", "bytes 2-10/10", "2-5/10"} {
+		if _, _, err := ParseContentRange(header); !errors.Is(err, ErrInvalidContentRange) {
+			t.Fatalf("%s: expected ErrInvalidContentRange, got %v", header, err)
+		}
+	}
+}
+
+func TestGetHTTPRangeResponse(t *testing.T) {
+	full := &Interaction{
+		Request: Request{Method: http.MethodGet, URL: "https://example.com/file"},
+		Response: Response{
+			Code:          http.StatusOK,
+			Status:        "200 OK",
+			Body:          "0123456789",
+			ContentLength: 10,
+			Headers:       http.Header{"Etag": {`"v1"`}, "Content-Length": {"10"}},
+		},
+	}
+
+	partial := &Interaction{
+		Request: Request{Method: http.MethodGet, URL: "https://example.com/file"},
+		Response: Response{
+			Code:          http.StatusPartialContent,
+			Status:        "206 Partial Content",
+			Body:          "2345",
+			ContentLength: 4,
+			Headers:       http.Header{"Content-Range": {"bytes 2-5/10"}},
+		},
+	}
+
+	tests := []struct {
+		name             string
+		interaction      *Interaction
+		rangeHeader      string
+		ifRange          string
+		wantCode         int
+		wantBody         string
+		wantContentRange string
+		wantErr          error
+	}{
+		{name: "no range", interaction: full, wantCode: 200, wantBody: "0123456789"},
+		{name: "full", interaction: full, rangeHeader: "bytes=2-4", wantCode: 206, wantBody: "234", wantContentRange: "bytes 2-4/10"},
+		{name: "suffix", interaction: full, rangeHeader: "bytes=-2", wantCode: 206, wantBody: "89", wantContentRange: "bytes 8-9/10"},
+		{name: "unsatisfiable", interaction: full, rangeHeader: "bytes=20-", wantCode: 416, wantContentRange: "bytes */10"},
+		{name: "if-range match", interaction: full, rangeHeader: "bytes=0-0", ifRange: `"v1"`, wantCode: 206, wantBody: "0", wantContentRange: "bytes 0-0/10"},
+		{name: "if-range mismatch", interaction: full, rangeHeader: "bytes=0-0", ifRange: `"v2"`, wantCode: 200, wantBody: "0123456789"},
+		{name: "partial", interaction: partial, rangeHeader: "bytes=3-4", wantCode: 206, wantBody: "34", wantContentRange: "bytes 3-4/10"},
+		{name: "partial not covered", interaction: partial, rangeHeader: "bytes=0-4", wantErr: ErrInteractionNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := test.interaction.GetHTTPRangeResponse(test.rangeHeader, test.ifRange)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("expected %v, got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != test.wantCode {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, test.wantCode)
+			}
+			if string(body) != test.wantBody {
+				t.Fatalf("got body %q, want %q", body, test.wantBody)
+			}
+			if resp.ContentLength != int64(len(test.wantBody)) {
+				t.Fatalf("got content length %d", resp.ContentLength)
+			}
+			if got := resp.Header.Get("Content-Range"); got != test.wantContentRange {
+				t.Fatalf("got Content-Range %q, want %q", got, test.wantContentRange)
+			}
+		})
+	}
+
+	// A Content-Range, which does not match the recorded body
+	partial.Response.Headers.Set("Content-Range", "bytes 2-8/10")
+	if _, err := partial.GetHTTPRangeResponse("bytes=3-4", ""); !errors.Is(err, ErrInvalidContentRange) {
+		t.Fatalf("expected ErrInvalidContentRange, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package cassette
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ErrSecretsDetected is returned when saving a cassette with a
+// [SecretScanner], which detected secrets in the recorded interactions.
+var ErrSecretsDetected = errors.New("secrets detected in cassette")
+
+// SecretPattern describes a kind of secret to look for.
+type SecretPattern struct {
+	// Name is a human-readable name of the secret kind
+	Name string
+
+	// Pattern matches the secret
+	Pattern *regexp.Regexp
+}
+
+// DefaultSecretPatterns are the secret patterns used by [NewSecretScanner].
+var DefaultSecretPatterns = []SecretPattern{
+	{Name: "AWS access key ID", Pattern: regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{Name: "GitHub token", Pattern: regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{82})\b`)},
+	{Name: "JSON Web Token", Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`)},
+	{Name: "private key", Pattern: regexp.MustCompile(`-----BEGIN ([A-Z]+ )*PRIVATE KEY-----`)},
+}
+
+// SecretFinding describes a secret detected in an interaction.
+type SecretFinding struct {
+	// InteractionID is the id of the interaction containing the secret
+	InteractionID int
+
+	// Kind is the name of the matched [SecretPattern]
+	Kind string
+
+	// Location describes where the secret was found, e.g.
+	// "request.headers.Authorization"
+	Location string
+}
+
+// SecretsError is returned by [Cassette.Save] when secrets were detected. It
+// wraps [ErrSecretsDetected].
+type SecretsError struct {
+	Findings []SecretFinding
+}
+
+// InteractionIDs returns the sorted ids of the offending interactions.
+func (e *SecretsError) InteractionIDs() []int {
+	ids := make([]int, 0, len(e.Findings))
+	for _, f := range e.Findings {
+		if !slices.Contains(ids, f.InteractionID) {
+			ids = append(ids, f.InteractionID)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// Error implements the error interface.
+func (e *SecretsError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: interactions %v", ErrSecretsDetected, e.InteractionIDs())
+	for _, f := range e.Findings {
+		fmt.Fprintf(&b, "\n  interaction %d: %s in %s", f.InteractionID, f.Kind, f.Location)
+	}
+	return b.String()
+}
+
+// Unwrap returns [ErrSecretsDetected].
+func (e *SecretsError) Unwrap() error {
+	return ErrSecretsDetected
+}
+
+// SecretScanner scans interactions for leaked credentials before a cassette
+// is saved.
+type SecretScanner struct {
+	// Patterns are the secret patterns to look for
+	Patterns []SecretPattern
+
+	// Allowlist contains values, which are known to be safe, e.g. example
+	// keys from documentation, and are not reported even if they match
+	// one of the patterns.
+	Allowlist []string
+}
+
+// NewSecretScanner creates a new [SecretScanner] using the
+// [DefaultSecretPatterns] and the given allow-listed values.
+func NewSecretScanner(allowlist ...string) *SecretScanner {
+	return &SecretScanner{
+		Patterns:  DefaultSecretPatterns,
+		Allowlist: allowlist,
+	}
+}
+
+// Scan returns the secrets detected in the given interactions.
+func (s *SecretScanner) Scan(interactions []*Interaction) []SecretFinding {
+	findings := make([]SecretFinding, 0)
+	for _, i := range interactions {
+		report := func(location, value string) {
+			for _, p := range s.Patterns {
+				for _, match := range p.Pattern.FindAllString(value, -1) {
+					if slices.Contains(s.Allowlist, match) {
+						continue
+					}
+					findings = append(findings, SecretFinding{
+						InteractionID: i.ID,
+						Kind:          p.Name,
+						Location:      location,
+					})
+					break
+				}
+			}
+		}
+
+		report("request.url", i.Request.URL)
+		report("request.body", i.Request.Body)
+		scanHeader("request.headers", i.Request.Headers, report)
+		scanHeader("request.trailer", i.Request.Trailer, report)
+		scanHeader("request.form", http.Header(i.Request.Form), report)
+		report("response.body", i.Response.Body)
+		scanHeader("response.headers", i.Response.Headers, report)
+		scanHeader("response.trailer", i.Response.Trailer, report)
+	}
+
+	return findings
+}
+
+func scanHeader(prefix string, h http.Header, report func(location, value string)) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range h[k] {
+			report(prefix+"."+k, v)
+		}
+	}
+}
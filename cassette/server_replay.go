@@ -16,6 +16,8 @@ import (
 type ReplayAssertFunc func(t *testing.T, expected *Interaction, actual *httptest.ResponseRecorder)
 
 // DefaultReplayAssertFunc compares the response status code, body, and headers.
+// Mismatching bodies are reported as a structural diff, see [DiffBodies], and
+// mismatching headers as a table, see [HeaderDiff].
 // It can be overridden for more specific tests or to use your preferred assertion libraries
 var DefaultReplayAssertFunc ReplayAssertFunc = func(t *testing.T, expected *Interaction, actual *httptest.ResponseRecorder) {
 	if expected.Response.Code != actual.Result().StatusCode {
@@ -23,11 +25,21 @@ var DefaultReplayAssertFunc ReplayAssertFunc = func(t *testing.T, expected *Inte
 	}
 
 	if expected.Response.Body != actual.Body.String() {
-		t.Errorf("body does not match: expected=%s actual=%s", expected.Response.Body, actual.Body.String())
+		diff := DiffBodies(expected.Response.Body, actual.Body.String(), expected.Response.Headers.Get("Content-Type"))
+		if diff == "" {
+			// The bodies differ in their formatting only
+			diff = fmt.Sprintf("expected=%q actual=%q\n", expected.Response.Body, actual.Body.String())
+		}
+		t.Errorf("body does not match:\n%s", diff)
 	}
 
 	if !headersEqual(expected.Response.Headers, actual.Header()) {
-		t.Errorf("header values do not match. expected=%v actual=%v", expected.Response.Headers, actual.Header())
+		diff := HeaderDiff(expected.Response.Headers, actual.Header())
+		if diff == "" {
+			// The headers differ in the case of their keys only
+			diff = fmt.Sprintf("expected=%v actual=%v\n", expected.Response.Headers, actual.Header())
+		}
+		t.Errorf("header values do not match:\n%s", diff)
 	}
 }
 
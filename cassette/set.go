@@ -0,0 +1,156 @@
+package cassette
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+)
+
+// ErrEmptySet is returned when adding an interaction to a [Set] without
+// cassettes.
+var ErrEmptySet = errors.New("cassette set is empty")
+
+// Store is the surface for matching and recording interactions, which is
+// implemented by [Cassette] and [Set].
+type Store interface {
+	// GetInteraction returns the interaction matching the request, or
+	// [ErrInteractionNotFound].
+	GetInteraction(r *http.Request) (*Interaction, error)
+
+	// AddInteraction records a new interaction.
+	AddInteraction(i *Interaction) error
+}
+
+var (
+	_ Store = (*Cassette)(nil)
+	_ Store = (*Set)(nil)
+)
+
+// Set aggregates multiple cassettes, e.g. fixtures split into topical files,
+// into a single [Store]. Requests are matched against the cassettes in the
+// given order, each using its own matcher, while new interactions are added
+// to the first cassette only. Create it using [NewSet] or [LoadSet].
+type Set struct {
+	cassettes []*Cassette
+}
+
+// NewSet returns a new [Set] of the given cassettes.
+func NewSet(cassettes ...*Cassette) *Set {
+	return &Set{cassettes: slices.Clone(cassettes)}
+}
+
+// LoadSet loads the named cassettes, see [Load], and returns a new [Set] of
+// them.
+func LoadSet(names ...string) (*Set, error) {
+	cassettes := make([]*Cassette, 0, len(names))
+	for _, name := range names {
+		c, err := Load(name)
+		if err != nil {
+			return nil, err
+		}
+		cassettes = append(cassettes, c)
+	}
+
+	return NewSet(cassettes...), nil
+}
+
+// Cassettes returns the cassettes of the set in order.
+func (s *Set) Cassettes() []*Cassette {
+	return slices.Clone(s.cassettes)
+}
+
+// Len returns the total number of interactions of the cassettes.
+func (s *Set) Len() int {
+	n := 0
+	for _, c := range s.cassettes {
+		c.Lock()
+		n += len(c.Interactions)
+		c.Unlock()
+	}
+
+	return n
+}
+
+// GetInteraction returns the interaction of the first cassette matching the
+// request, or [ErrInteractionNotFound], if none matches. Cassettes, whose
+// matching interactions have all been replayed, still match, see
+// [Cassette.GetInteraction].
+func (s *Set) GetInteraction(r *http.Request) (*Interaction, error) {
+	// Read and hash the request only once for all cassettes
+	if requestCacheFromContext(r.Context()) == nil {
+		orig := r
+		r = r.WithContext(ContextWithRequestCache(r.Context()))
+		defer func() {
+			orig.Body = r.Body
+		}()
+	}
+
+	for _, c := range s.cassettes {
+		i, err := c.GetInteraction(r)
+		if errors.Is(err, ErrInteractionNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cassette %s: %w", c.Name, err)
+		}
+		return i, nil
+	}
+
+	return nil, ErrInteractionNotFound
+}
+
+// AddInteraction appends a new interaction to the first cassette of the set.
+func (s *Set) AddInteraction(i *Interaction) error {
+	if len(s.cassettes) == 0 {
+		return ErrEmptySet
+	}
+
+	return s.cassettes[0].AddInteraction(i)
+}
+
+// SetMatcher replaces the matcher of each cassette, see
+// [Cassette.SetMatcher].
+func (s *Set) SetMatcher(m RequestMatcher) error {
+	for _, c := range s.cassettes {
+		if err := c.SetMatcher(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Save saves the first cassette of the set, which holds the interactions
+// added to the set, while the other cassettes are left untouched.
+func (s *Set) Save() error {
+	if len(s.cassettes) == 0 {
+		return ErrEmptySet
+	}
+
+	return s.cassettes[0].Save()
+}
+
+// origins returns the distinct recorded origins of the cassettes, with the
+// ones of the given host first, see [Cassette.origins].
+func (s *Set) origins(host string) []*url.URL {
+	var first, rest []*url.URL
+	seen := make(map[url.URL]bool)
+	for _, c := range s.cassettes {
+		for _, origin := range c.origins("") {
+			if seen[*origin] {
+				continue
+			}
+			seen[*origin] = true
+
+			if origin.Host == host {
+				first = append(first, origin)
+			} else {
+				rest = append(rest, origin)
+			}
+		}
+	}
+
+	return append(first, rest...)
+}
@@ -0,0 +1,118 @@
+package cassette
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	dir := t.TempDir()
+
+	users := New(filepath.Join(dir, "users"))
+	if _, err := users.AddStub(http.MethodGet, "https://api.example.com/users", http.StatusOK, "users"); err != nil {
+		t.Fatal(err)
+	}
+	orders := New(filepath.Join(dir, "orders"))
+	if _, err := orders.AddStub(http.MethodGet, "https://shop.example.com/orders", http.StatusOK, "orders"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.AddStub(http.MethodGet, "https://shop.example.com/orders", http.StatusOK, "first"); err != nil {
+		t.Fatal(err)
+	}
+
+	set := NewSet(users, orders)
+	if got := set.Len(); got != 3 {
+		t.Fatalf("got %d interactions, want 3", got)
+	}
+
+	// The first matching cassette wins
+	r, err := http.NewRequest(http.MethodGet, "https://shop.example.com/orders", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i, err := set.GetInteraction(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.Response.Body != "first" {
+		t.Fatalf("got body %q, want first", i.Response.Body)
+	}
+
+	r, err = http.NewRequest(http.MethodGet, "https://api.example.com/missing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := set.GetInteraction(r); !errors.Is(err, ErrInteractionNotFound) {
+		t.Fatalf("got error %v, want %v", err, ErrInteractionNotFound)
+	}
+
+	// New interactions are added to and saved with the first cassette
+	created, err := NewInteraction().Request(http.MethodPost, "https://api.example.com/users").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := set.AddInteraction(created); err != nil {
+		t.Fatal(err)
+	}
+	if len(users.Interactions) != 3 || len(orders.Interactions) != 1 {
+		t.Fatalf("got %d and %d interactions, want 3 and 1", len(users.Interactions), len(orders.Interactions))
+	}
+	if err := set.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadSet(filepath.Join(dir, "users"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Len(); got != 3 {
+		t.Fatalf("got %d interactions, want 3", got)
+	}
+	if _, err := LoadSet(filepath.Join(dir, "orders")); err == nil {
+		t.Fatal("expected loading unsaved cassette to fail")
+	}
+
+	if err := NewSet().AddInteraction(created); !errors.Is(err, ErrEmptySet) {
+		t.Fatalf("got error %v, want %v", err, ErrEmptySet)
+	}
+}
+
+func TestSetHandler(t *testing.T) {
+	users := New("users")
+	users.ReplayableInteractions = true
+	if _, err := users.AddStub(http.MethodGet, "https://api.example.com/users", http.StatusOK, "users"); err != nil {
+		t.Fatal(err)
+	}
+	orders := New("orders")
+	orders.ReplayableInteractions = true
+	if _, err := orders.AddStub(http.MethodGet, "https://shop.example.com/orders", http.StatusOK, "orders"); err != nil {
+		t.Fatal(err)
+	}
+
+	set := NewSet(users, orders)
+	if err := set.SetMatcher(NewMatcher(WithIgnoreUserAgent(), WithIgnoreHeaders("Accept-Encoding"))); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(Handler(set))
+	defer server.Close()
+
+	for path, want := range map[string]string{"/users": "users", "/orders": "orders"} {
+		resp, err := server.Client().Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK || string(body) != want {
+			t.Fatalf("got %d %q for %s, want 200 %q", resp.StatusCode, body, path, want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package cassette
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// shardSuffix separates the name of a sharded cassette from the index of
+// the shard.
+const shardSuffix = ".shard-"
+
+// ShardName returns the name of the given shard of the named cassette, e.g.
+// "fixtures/load.shard-3" for shard 3 of the cassette "fixtures/load".
+func ShardName(name string, shard int) string {
+	return name + shardSuffix + strconv.Itoa(shard)
+}
+
+// LoadShards loads the shards of the named cassette, as written by a
+// recorder configured with shards, and merges their interactions into a
+// single cassette, ordered by shard. The ids of the interactions are
+// renumbered accordingly. It fails with [ErrCassetteNotFound], if the
+// cassette has no shards.
+//
+// The merged cassette is not saved, but saving it writes the interactions
+// of all shards into the single cassette file.
+func LoadShards(name string) (*Cassette, error) {
+	shards, err := shardIndices(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(shards) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrCassetteNotFound, ShardName(name, 0))
+	}
+
+	c := New(name)
+	c.IsNew = false
+	for _, shard := range shards {
+		s, err := Load(ShardName(name, shard))
+		if err != nil {
+			return nil, err
+		}
+
+		offset := len(c.Interactions)
+		for _, i := range s.Interactions {
+			i.ID += offset
+			if i.ParentID != nil {
+				parentID := *i.ParentID + offset
+				i.ParentID = &parentID
+			}
+			c.Interactions = append(c.Interactions, i)
+		}
+	}
+
+	c.nextInteractionId = len(c.Interactions)
+	if _, err := c.buildHashIndex(); err != nil {
+		return nil, fmt.Errorf("failed to build hash index for cassette %s: %w", name, err)
+	}
+
+	return c, nil
+}
+
+// shardIndices returns the sorted indices of the shards of the named
+// cassette, which exist on disk.
+func shardIndices(name string) ([]int, error) {
+	entries, err := os.ReadDir(filepath.Dir(name))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to list shards of cassette %s: %w", name, err)
+	}
+
+	prefix := filepath.Base(name) + shardSuffix
+	shards := make([]int, 0)
+	for _, entry := range entries {
+		index, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		index, ok = strings.CutSuffix(index, ".yaml")
+		if !ok {
+			index, ok = strings.CutSuffix(index, ".cbor")
+		}
+		if !ok {
+			continue
+		}
+		shard, err := strconv.Atoi(index)
+		if err != nil || shard < 0 {
+			continue
+		}
+		shards = append(shards, shard)
+	}
+	slices.Sort(shards)
+
+	return slices.Compact(shards), nil
+}
@@ -0,0 +1,77 @@
+package cassette
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadShards(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "load")
+
+	shards := map[int][]string{
+		0: {"/a", "/b"},
+		2: {"/c"},
+	}
+	for shard, paths := range shards {
+		c := New(ShardName(name, shard))
+		for _, p := range paths {
+			i := &Interaction{
+				Request:  Request{Method: http.MethodGet, URL: "https://example.com" + p},
+				Response: Response{Code: http.StatusOK, Body: p},
+			}
+			if err := c.AddInteraction(i); err != nil {
+				t.Fatal(err)
+			}
+		}
+		parentID := 0
+		c.Interactions[len(c.Interactions)-1].ParentID = &parentID
+		if err := c.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, want := ShardName(name, 2), name+".shard-2"; got != want {
+		t.Fatalf("got shard name %s, want %s", got, want)
+	}
+
+	for _, load := range []func(string) (*Cassette, error){LoadShards, Load} {
+		c, err := load(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Name != name || c.IsNew {
+			t.Fatalf("got cassette %s, new %v", c.Name, c.IsNew)
+		}
+
+		want := []struct {
+			body     string
+			parentID int
+		}{{"/a", -1}, {"/b", 0}, {"/c", 2}}
+		if len(c.Interactions) != len(want) {
+			t.Fatalf("got %d interactions, want %d", len(c.Interactions), len(want))
+		}
+		for n, w := range want {
+			i := c.Interactions[n]
+			if i.ID != n || i.Response.Body != w.body {
+				t.Fatalf("got interaction %d with body %s, want %d with body %s", i.ID, i.Response.Body, n, w.body)
+			}
+			if (w.parentID < 0) != (i.ParentID == nil) || (i.ParentID != nil && *i.ParentID != w.parentID) {
+				t.Fatalf("got parent id %v of interaction %d, want %d", i.ParentID, n, w.parentID)
+			}
+		}
+
+		req, err := c.Interactions[2].GetHTTPRequest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.GetInteraction(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := LoadShards(filepath.Join(filepath.Dir(name), "missing")); !errors.Is(err, ErrCassetteNotFound) {
+		t.Fatalf("got error %v, want %v", err, ErrCassetteNotFound)
+	}
+}
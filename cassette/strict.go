@@ -0,0 +1,84 @@
+package cassette
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// unknownFieldRe matches the errors reported by the YAML decoder for
+// unknown fields.
+var unknownFieldRe = regexp.MustCompile(`^line (\d+): field (.+) not found in type (\S+)$`)
+
+// decodeStrictYAML decodes the YAML cassette from r into c, failing on
+// unknown fields. The errors of unknown fields are annotated with the path
+// of the field, e.g. interactions[1].request.hedaers.
+func decodeStrictYAML(r io.Reader, c *Cassette) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	err = dec.Decode(c)
+
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return err
+	}
+
+	var doc yaml.Node
+	if yaml.Unmarshal(data, &doc) != nil {
+		return err
+	}
+	paths := make(map[int][]string)
+	collectKeyPaths(&doc, "", paths)
+
+	for n, msg := range typeErr.Errors {
+		m := unknownFieldRe.FindStringSubmatch(msg)
+		if m == nil {
+			continue
+		}
+		line, _ := strconv.Atoi(m[1])
+		for _, path := range paths[line] {
+			if path == m[2] || strings.HasSuffix(path, "."+m[2]) {
+				typeErr.Errors[n] = fmt.Sprintf("line %d: unknown field %s in type %s", line, path, m[3])
+				break
+			}
+		}
+	}
+
+	return err
+}
+
+// collectKeyPaths adds the paths of the mapping keys below the node to
+// paths, indexed by the line of the key.
+func collectKeyPaths(node *yaml.Node, path string, paths map[int][]string) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			collectKeyPaths(child, path, paths)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			collectKeyPaths(child, fmt.Sprintf("%s[%d]", path, i), paths)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			keyPath := key.Value
+			if path != "" {
+				keyPath = path + "." + key.Value
+			}
+			paths[key.Line] = append(paths[key.Line], keyPath)
+			collectKeyPaths(node.Content[i+1], keyPath, paths)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package cassette
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStrict(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "strict")
+
+	data := `---
+version: 2
+interactions:
+    - id: 0
+      request:
+        url: https://example.com/
+        method: GET
+      response:
+        body: ok
+        headers: {}
+        status: 200 OK
+        code: 200
+        duration: 0s
+    - id: 1
+      request:
+        url: https://example.com/users
+        method: POST
+        hedaers:
+            Content-Type:
+                - application/json
+      response: {body: created, status: 201 Created, code: 201, duraton: 1s}
+`
+	if err := os.WriteFile(name+".yaml", []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unknown fields are ignored by default
+	c := New(name)
+	c.ReadOnly = true
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Interactions) != 2 {
+		t.Fatalf("got %d interactions, want 2", len(c.Interactions))
+	}
+
+	c = New(name)
+	c.Strict = true
+	err := c.Load()
+	if err == nil {
+		t.Fatal("expected unknown fields to fail loading")
+	}
+	for _, want := range []string{
+		"line 18: unknown field interactions[1].request.hedaers in type cassette.Request",
+		"line 21: unknown field interactions[1].response.duraton in type cassette.Response",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("got error %q, want it to contain %q", err, want)
+		}
+	}
+}
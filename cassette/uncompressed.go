@@ -0,0 +1,85 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// RecompressResponse compresses the body of a response, which was
+// transparently decompressed by the [http.Transport] when recording, see
+// [http.Response.Uncompressed], using gzip, and restores its
+// Content-Encoding and Content-Length headers, as sent by the server. This
+// is useful for clients, which request and decode compressed responses
+// themselves.
+//
+// Other responses are left as is.
+func RecompressResponse(resp *http.Response) error {
+	if !resp.Uncompressed {
+		return nil
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	body, err := EncodeBody(string(data), "gzip")
+	if err != nil {
+		return err
+	}
+
+	resp.Body = io.NopCloser(strings.NewReader(body))
+	resp.Uncompressed = false
+	resp.Header = resp.Header.Clone()
+	if resp.Header == nil {
+		resp.Header = make(http.Header)
+	}
+	resp.Header.Set("Content-Encoding", "gzip")
+	if slices.Contains(resp.TransferEncoding, "chunked") {
+		resp.ContentLength = -1
+		return nil
+	}
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	return nil
+}
+
+// UncompressResponse presents a response, which was transparently
+// decompressed by the [http.Transport] when recording, see
+// [http.Response.Uncompressed], the same way as the transport does, i.e.
+// with a decoded body of unknown length, and without Content-Encoding and
+// Content-Length headers, even if they were restored by hooks, or by
+// replaying a decoded body, see [DecodeResponseBody].
+//
+// Other responses are left as is.
+func UncompressResponse(resp *http.Response) error {
+	if !resp.Uncompressed {
+		return nil
+	}
+
+	if encodings := contentEncodings(resp.Header); len(encodings) > 0 {
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		body, err := DecodeBody(string(data), encodings...)
+		if err != nil {
+			return err
+		}
+		resp.Body = io.NopCloser(strings.NewReader(body))
+	}
+
+	resp.ContentLength = -1
+	if resp.Header != nil {
+		resp.Header = resp.Header.Clone()
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRecompressResponse(t *testing.T) {
+	resp := &http.Response{
+		Header:        http.Header{"Content-Type": {"text/plain"}},
+		Body:          io.NopCloser(strings.NewReader("hello")),
+		ContentLength: -1,
+		Uncompressed:  true,
+	}
+
+	if err := RecompressResponse(resp); err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Uncompressed || resp.Header.Get("Content-Encoding") != "gzip" || resp.ContentLength != int64(len(data)) {
+		t.Fatalf("got uncompressed %t, headers %v and length %d, want a gzip-compressed response", resp.Uncompressed, resp.Header, resp.ContentLength)
+	}
+	if body, err := DecodeBody(string(data), "gzip"); err != nil || body != "hello" {
+		t.Fatalf("got decoded body %q, %v, want %q", body, err, "hello")
+	}
+}
+
+func TestUncompressResponse(t *testing.T) {
+	body, err := EncodeBody("hello", "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := &http.Response{
+		Header:        http.Header{"Content-Encoding": {"gzip"}, "Content-Length": {"25"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: 25,
+		Uncompressed:  true,
+	}
+
+	if err := UncompressResponse(resp); err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" || resp.ContentLength != -1 || len(resp.Header) != 0 {
+		t.Fatalf("got body %q, length %d and headers %v, want a decompressed response", data, resp.ContentLength, resp.Header)
+	}
+}
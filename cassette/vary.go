@@ -0,0 +1,162 @@
+package cassette
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// varyHeaders returns the header names listed in the Vary header of the
+// response. It returns false, if the response has no Vary header, or varies
+// on "*", in which case it cannot be matched using the Vary header.
+func varyHeaders(resp Response) ([]string, bool) {
+	var names []string
+	for k, values := range resp.Headers {
+		if http.CanonicalHeaderKey(k) != "Vary" {
+			continue
+		}
+		for _, v := range values {
+			for _, name := range strings.Split(v, ",") {
+				name = strings.TrimSpace(name)
+				if name == "*" {
+					return nil, false
+				}
+				if name != "" {
+					names = append(names, http.CanonicalHeaderKey(name))
+				}
+			}
+		}
+	}
+
+	return names, len(names) > 0
+}
+
+// headerValues returns the values of the header, comparing header keys in
+// their canonical form, since recorded headers are not guaranteed to be
+// canonicalized.
+func headerValues(h http.Header, name string) []string {
+	var values []string
+	for k, v := range h {
+		if http.CanonicalHeaderKey(k) == name {
+			values = append(values, v...)
+		}
+	}
+	return values
+}
+
+// varyMatches returns true, if the interaction does not vary on any request
+// header, or the request has the same values of the varied headers as the
+// recorded request.
+func varyMatches(r *http.Request, i *Interaction) bool {
+	names, ok := varyHeaders(i.Response)
+	if !ok {
+		return true
+	}
+
+	for _, name := range names {
+		if !slices.Equal(headerValues(r.Header, name), headerValues(i.Request.Headers, name)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// headerlessHash hashes the request without any headers using the matcher
+// of the cassette.
+func (c *Cassette) headerlessHash(r *http.Request) (string, error) {
+	return HashWith(c.Matcher, r, func(r *http.Request) {
+		r.Header = make(http.Header)
+	})
+}
+
+// varyCandidates returns the indices of the interactions, whose responses
+// carry a Vary header, and which match the request on everything but the
+// headers, and on exactly the varied headers.
+func (c *Cassette) varyCandidates(r *http.Request) ([]int, error) {
+	if c.varyIndex == nil {
+		if err := c.buildVaryIndex(); err != nil {
+			return nil, err
+		}
+	}
+	if len(c.varyIndex) == 0 {
+		return nil, nil
+	}
+
+	reqHash, err := c.headerlessHash(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(slices.Clone(c.varyIndex[reqHash]), func(idx int) bool {
+		return !varyMatches(r, c.Interactions[idx])
+	}), nil
+}
+
+// buildVaryIndex indexes the interactions, whose responses carry a Vary
+// header, by the hash of their request without any headers, so that
+// matching a request does not scan all interactions. The index is built on
+// first use, updated when interactions are added or replaced, and reset,
+// when the matcher changes.
+func (c *Cassette) buildVaryIndex() error {
+	c.varyIndex = make(map[string][]int)
+	for idx, i := range c.Interactions {
+		if err := c.indexVary(idx, i); err != nil {
+			c.varyIndex = nil
+			return err
+		}
+	}
+
+	return nil
+}
+
+// varyHash returns the hash of the request of the interaction without any
+// headers, and false, if its response does not carry a Vary header.
+func (c *Cassette) varyHash(i *Interaction) (string, bool, error) {
+	if _, ok := varyHeaders(i.Response); !ok {
+		return "", false, nil
+	}
+
+	req, err := i.GetHTTPRequest()
+	if err != nil {
+		return "", false, err
+	}
+	hash, err := c.headerlessHash(req)
+	if err != nil {
+		return "", false, err
+	}
+
+	return hash, true, nil
+}
+
+// indexVary adds the interaction at the given index to the vary index, see
+// [Cassette.buildVaryIndex]. The indices are kept sorted, so that replay
+// order is preserved.
+func (c *Cassette) indexVary(idx int, i *Interaction) error {
+	hash, ok, err := c.varyHash(i)
+	if !ok || err != nil {
+		return err
+	}
+
+	indices := append(c.varyIndex[hash], idx)
+	slices.Sort(indices)
+	c.varyIndex[hash] = indices
+
+	return nil
+}
+
+// unindexVary removes the interaction at the given index from the vary
+// index, see [Cassette.buildVaryIndex].
+func (c *Cassette) unindexVary(idx int, i *Interaction) error {
+	hash, ok, err := c.varyHash(i)
+	if !ok || err != nil {
+		return err
+	}
+
+	c.varyIndex[hash] = slices.DeleteFunc(c.varyIndex[hash], func(n int) bool { return n == idx })
+	if len(c.varyIndex[hash]) == 0 {
+		delete(c.varyIndex, hash)
+	}
+
+	return nil
+}
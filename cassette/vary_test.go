@@ -0,0 +1,119 @@
+package cassette
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestMatchVary(t *testing.T) {
+	newInteraction := func(lang, userAgent, body string) *Interaction {
+		return &Interaction{
+			Request: Request{
+				Proto:      "HTTP/1.1",
+				ProtoMajor: 1,
+				ProtoMinor: 1,
+				Method:     http.MethodGet,
+				URL:        "https://example.com/greeting",
+				Host:       "example.com",
+				Headers: http.Header{
+					"Accept-Language": {lang},
+					"User-Agent":      {userAgent},
+				},
+			},
+			Response: Response{
+				Code:    http.StatusOK,
+				Body:    body,
+				Headers: http.Header{"Vary": {"Accept-Language"}},
+			},
+		}
+	}
+
+	newRequest := func(lang, userAgent string) *http.Request {
+		r, err := http.NewRequest(http.MethodGet, "https://example.com/greeting", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Accept-Language", lang)
+		r.Header.Set("User-Agent", userAgent)
+		return r
+	}
+
+	tests := []struct {
+		name      string
+		matcher   RequestMatcher
+		matchVary bool
+		lang      string
+		wantBody  string
+		wantErr   error
+	}{
+		{name: "false miss", matcher: DefaultMatcher, lang: "de", wantErr: ErrInteractionNotFound},
+		{name: "vary match", matcher: DefaultMatcher, matchVary: true, lang: "de", wantBody: "Hallo"},
+		{name: "vary miss", matcher: DefaultMatcher, matchVary: true, lang: "fr", wantErr: ErrInteractionNotFound},
+		{name: "false match", matcher: NewMatcher(WithIgnoreHeaders("Accept-Language", "User-Agent")), lang: "fr", wantBody: "Hello"},
+		{name: "no false match", matcher: NewMatcher(WithIgnoreHeaders("Accept-Language", "User-Agent")), matchVary: true, lang: "fr", wantErr: ErrInteractionNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := New("test_match_vary")
+			c.ReplayableInteractions = true
+			c.MatchVary = test.matchVary
+			if err := c.SetMatcher(test.matcher); err != nil {
+				t.Fatal(err)
+			}
+			for _, i := range []*Interaction{
+				newInteraction("en", "curl/8.0", "Hello"),
+				newInteraction("de", "curl/8.0", "Hallo"),
+			} {
+				if err := c.AddInteraction(i); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			i, err := c.GetInteraction(newRequest(test.lang, "go-vcr"))
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("expected %v, got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if i.Response.Body != test.wantBody {
+				t.Fatalf("got body %q, want %q", i.Response.Body, test.wantBody)
+			}
+		})
+	}
+
+	t.Run("index updates", func(t *testing.T) {
+		c := New("test_match_vary_index")
+		c.ReplayableInteractions = true
+		c.MatchVary = true
+		if err := c.AddInteraction(newInteraction("en", "curl/8.0", "Hello")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.GetInteraction(newRequest("en", "go-vcr")); err != nil {
+			t.Fatal(err)
+		}
+
+		// Interactions added and replaced after building the index are
+		// matched
+		if err := c.AddInteraction(newInteraction("de", "curl/8.0", "Hallo")); err != nil {
+			t.Fatal(err)
+		}
+		if i, err := c.GetInteraction(newRequest("de", "go-vcr")); err != nil || i.Response.Body != "Hallo" {
+			t.Fatalf("got interaction %v, %v, want the added one", i, err)
+		}
+		if err := c.ReplaceInteraction(0, newInteraction("fr", "curl/8.0", "Bonjour")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.GetInteraction(newRequest("en", "go-vcr")); !errors.Is(err, ErrInteractionNotFound) {
+			t.Fatalf("expected %v, got %v", ErrInteractionNotFound, err)
+		}
+		if i, err := c.GetInteraction(newRequest("fr", "go-vcr")); err != nil || i.Response.Body != "Bonjour" {
+			t.Fatalf("got interaction %v, %v, want the replaced one", i, err)
+		}
+	})
+}
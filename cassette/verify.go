@@ -0,0 +1,240 @@
+package cassette
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// defaultVerifyIgnoreHeaders are the response headers, which change on
+// every response, or follow from the body, and are not compared by
+// [Verify].
+var defaultVerifyIgnoreHeaders = []string{"Age", "Content-Length", "Date"}
+
+// Drift describes how the live response to a recorded request differs from
+// the recorded response.
+type Drift struct {
+	// Interaction is the recorded interaction.
+	Interaction *Interaction
+
+	// Code is the status code of the live response.
+	Code int
+
+	// Headers is a table of the differing response headers, see
+	// [HeaderDiff], or empty if the headers match.
+	Headers string
+
+	// Body is a diff of the recorded and live response bodies, see
+	// [DiffBodies], or empty if the bodies are structurally equal.
+	Body string
+
+	// Err is the error, which occurred sending the request or reading the
+	// live response.
+	Err error
+}
+
+// String returns a human readable report of the drift.
+func (d Drift) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "interaction %d: %s %s\n", d.Interaction.ID, d.Interaction.Request.Method, d.Interaction.Request.URL)
+	if d.Err != nil {
+		fmt.Fprintf(&sb, "error: %s\n", d.Err)
+		return sb.String()
+	}
+	if d.Code != d.Interaction.Response.Code {
+		fmt.Fprintf(&sb, "status code: recorded=%d live=%d\n", d.Interaction.Response.Code, d.Code)
+	}
+	if d.Headers != "" {
+		fmt.Fprintf(&sb, "headers:\n%s", d.Headers)
+	}
+	if d.Body != "" {
+		fmt.Fprintf(&sb, "body:\n%s", d.Body)
+	}
+	return sb.String()
+}
+
+// VerifyOption is a function which configures [Verify].
+type VerifyOption func(v *verifier)
+
+// verifier is the configuration of [Verify].
+type verifier struct {
+	ignoreHeaders []string
+	unsafeMethods bool
+}
+
+// WithVerifyIgnoreHeaders is a [VerifyOption], which excludes the given
+// response headers from the comparison, in addition to the Age,
+// Content-Length and Date headers.
+func WithVerifyIgnoreHeaders(headers ...string) VerifyOption {
+	return func(v *verifier) {
+		v.ignoreHeaders = append(v.ignoreHeaders, headers...)
+	}
+}
+
+// WithVerifyUnsafeMethods is a [VerifyOption], which verifies interactions
+// with unsafe methods as well, e.g. POST or DELETE. These are skipped by
+// default, since sending them to the live API may have side effects.
+func WithVerifyUnsafeMethods() VerifyOption {
+	return func(v *verifier) {
+		v.unsafeMethods = true
+	}
+}
+
+// Verify sends the recorded requests of the cassette to the live API using
+// the given client, and reports how the live responses differ from the
+// recorded ones in status code, headers and body. The cassette is left as
+// is, which allows detecting fixtures that have drifted from reality, e.g. in
+// a nightly job, without affecting the tests replaying them.
+//
+// Only interactions with safe methods, as defined by RFC 9110, section
+// 9.2.1, are verified, unless [WithVerifyUnsafeMethods] is used. Bodies are
+// compared with their content encoding removed.
+func Verify(ctx context.Context, c *Cassette, client *http.Client, opts ...VerifyOption) ([]Drift, error) {
+	v := &verifier{ignoreHeaders: slices.Clone(defaultVerifyIgnoreHeaders)}
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	c.Lock()
+	interactions := make([]*Interaction, len(c.Interactions))
+	copy(interactions, c.Interactions)
+	c.Unlock()
+
+	drifts := make([]Drift, 0)
+	for _, i := range interactions {
+		if !v.unsafeMethods && !isSafeMethod(i.Request.Method) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return drifts, err
+		}
+
+		drift := v.verify(ctx, client, i)
+		if drift.Err != nil || drift.Code != i.Response.Code || drift.Headers != "" || drift.Body != "" {
+			drifts = append(drifts, drift)
+		}
+	}
+
+	return drifts, nil
+}
+
+// verify sends the recorded request of the interaction, and compares the
+// live response with the recorded one.
+func (v *verifier) verify(ctx context.Context, client *http.Client, i *Interaction) Drift {
+	drift := Drift{Interaction: i}
+
+	req, err := i.GetHTTPRequest()
+	if err != nil {
+		drift.Err = err
+		return drift
+	}
+	req = req.WithContext(ctx)
+	req.Header = req.Header.Clone()
+	req.RequestURI = ""
+	req.RemoteAddr = ""
+	if len(req.Form) > 0 && i.Request.Body == "" {
+		req.Body = io.NopCloser(strings.NewReader(req.Form.Encode()))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		drift.Err = err
+		return drift
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		drift.Err = fmt.Errorf("failed to read live response body: %w", err)
+		return drift
+	}
+	drift.Code = resp.StatusCode
+
+	drift.Body, err = diffResponseBody(i.Response, data, resp.Header)
+	if err != nil {
+		drift.Err = err
+		return drift
+	}
+
+	recordedHeaders, liveHeaders := canonicalHeader(i.Response.Headers), canonicalHeader(resp.Header)
+	for _, h := range v.ignoreHeaders {
+		recordedHeaders.Del(h)
+		liveHeaders.Del(h)
+	}
+	// The encoding of the bodies may differ, e.g. when the response body
+	// was decoded before recording.
+	recordedHeaders.Del("Content-Encoding")
+	liveHeaders.Del("Content-Encoding")
+	drift.Headers = HeaderDiff(recordedHeaders, liveHeaders)
+
+	return drift
+}
+
+// diffResponseBody returns a diff of the recorded response body and the
+// live one, see [DiffBodies], after decoding both, or an empty string, if
+// they match. Bodies, which were not recorded, are compared by their digest.
+func diffResponseBody(r Response, data []byte, h http.Header) (string, error) {
+	if r.BodyDigest != "" {
+		sum := sha256.Sum256(data)
+		if live := "sha256:" + hex.EncodeToString(sum[:]); live != r.BodyDigest {
+			return fmt.Sprintf("recorded body digest %s, live body digest %s\n", r.BodyDigest, live), nil
+		}
+		return "", nil
+	}
+
+	var err error
+	recorded := r.Body
+	if r.BodyEncoding != "" {
+		recorded, err = decodeResponseBody(recorded, r.BodyEncoding)
+		if err != nil {
+			return "", fmt.Errorf("failed to decode recorded response body: %w", err)
+		}
+	}
+	if r.DecodedContentEncoding == "" {
+		recorded, err = decodedBody(recorded, r.Headers)
+		if err != nil {
+			return "", fmt.Errorf("failed to decode recorded response body: %w", err)
+		}
+	}
+	live, err := decodedBody(string(data), h)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode live response body: %w", err)
+	}
+	if format := r.PrettyPrinted; format != "" {
+		// Compare the bodies regardless of their formatting
+		recorded, err = CompactBody(recorded, format)
+		if err != nil {
+			return "", fmt.Errorf("failed to compact recorded response body: %w", err)
+		}
+		if compacted, err := CompactBody(live, format); err == nil {
+			live = compacted
+		}
+	}
+
+	return DiffBodies(recorded, live, r.Headers.Get("Content-Type")), nil
+}
+
+// decodedBody returns the body with the content codings of the headers
+// removed.
+func decodedBody(body string, h http.Header) (string, error) {
+	encodings := contentEncodings(h)
+	if len(encodings) == 0 {
+		return body, nil
+	}
+	return DecodeBody(body, encodings...)
+}
+
+// isSafeMethod returns true, if the method is safe as defined by RFC 9110,
+// section 9.2.1.
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
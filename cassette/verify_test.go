@@ -0,0 +1,84 @@
+package cassette
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", "Mon, 02 Jan 2006 15:04:05 GMT")
+		w.Header().Set("X-Request-Id", r.URL.Path)
+		switch r.URL.Path {
+		case "/same":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"id":1}`)
+		case "/body":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"id":1,"name":"bar"}`)
+		case "/header":
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprint(w, `{"id":1}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	newInteraction := func(id int, method, path string, code int, body string) *Interaction {
+		return &Interaction{
+			ID: id,
+			Request: Request{
+				Proto:      "HTTP/1.1",
+				ProtoMajor: 1,
+				ProtoMinor: 1,
+				Method:     method,
+				URL:        server.URL + path,
+				Headers:    http.Header{},
+			},
+			Response: Response{
+				Code: code,
+				Body: body,
+				Headers: http.Header{
+					"Content-Type": {"application/json"},
+					"Date":         {"Sun, 01 Jan 2006 00:00:00 GMT"},
+				},
+			},
+		}
+	}
+
+	c := New("test_verify")
+	c.Interactions = []*Interaction{
+		newInteraction(0, http.MethodGet, "/same", http.StatusOK, `{"id":1}`),
+		newInteraction(1, http.MethodGet, "/body", http.StatusOK, `{"id":1,"name":"foo"}`),
+		newInteraction(2, http.MethodGet, "/header", http.StatusOK, `{"id":1}`),
+		newInteraction(3, http.MethodGet, "/gone", http.StatusOK, `{"id":1}`),
+		newInteraction(4, http.MethodPost, "/body", http.StatusOK, `{"id":1}`),
+	}
+
+	drifts, err := Verify(context.Background(), c, server.Client(), WithVerifyIgnoreHeaders("X-Request-Id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(drifts) != 3 {
+		for _, d := range drifts {
+			t.Log(d)
+		}
+		t.Fatalf("got %d drifts, want 3", len(drifts))
+	}
+
+	if d := drifts[0]; d.Interaction.ID != 1 || d.Headers != "" || !strings.Contains(d.Body, `-  "name": "foo"`) || !strings.Contains(d.Body, `+  "name": "bar"`) {
+		t.Fatalf("unexpected body drift:\n%s", d)
+	}
+	if d := drifts[1]; d.Interaction.ID != 2 || d.Body != "" || !strings.Contains(d.Headers, "Content-Type") {
+		t.Fatalf("unexpected header drift:\n%s", d)
+	}
+	if d := drifts[2]; d.Interaction.ID != 3 || d.Code != http.StatusNotFound || !strings.Contains(d.String(), "status code: recorded=200 live=404") {
+		t.Fatalf("unexpected status drift:\n%s", d)
+	}
+}
@@ -0,0 +1,260 @@
+// Command govcr provides tools for working with go-vcr cassettes.
+//
+// Usage:
+//
+//	govcr serve [-addr :8080] [-match-headers] <cassette>...
+//	govcr verify [-unsafe] [-ignore-headers Header,...] <cassette>...
+//	govcr gen [-pkg fixtures] [-func Handler] [-o file.go] <cassette>
+//	govcr export [-format markdown|mermaid] [-max-body 80] <cassette>
+//	govcr convert [-keep] [-level 0] -to yaml|cbor[.gz|.zst] <cassette>...
+//
+// Cassettes are given by their name, without the .yaml or .cbor extension.
+//
+// The serve command serves the recorded responses of the cassettes as a mock
+// server. Requests are matched against the cassettes in the given order, see
+// [cassette.Set]. Interactions may be replayed any number of times, and request
+// headers are ignored when matching, unless -match-headers is given.
+//
+// The verify command sends the recorded requests to the live API, and
+// reports the responses, which have drifted from the recorded ones. It exits
+// with status 1, if any drift was found. See [cassette.Verify].
+//
+// The gen command generates the Go source of a self-contained http.Handler
+// serving the recorded responses of a cassette, which does not depend on
+// go-vcr or the cassette file at runtime. See [cassette.GenerateHandler].
+//
+// The export command writes a human readable summary of a cassette to
+// stdout, either as Markdown table, or as Mermaid sequence diagram. See
+// [cassette.ExportMarkdown] and [cassette.ExportMermaid].
+//
+// The convert command converts cassettes between YAML and the binary CBOR
+// format, optionally compressed using gzip or zstd, and upgrades cassettes
+// of older format versions. The original files are replaced, unless -keep is
+// given. See [cassette.Convert].
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/goware/go-vcr/cassette"
+)
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch cmd := flag.Arg(0); cmd {
+	case "serve":
+		err = serve(flag.Args()[1:])
+	case "verify":
+		err = verify(flag.Args()[1:])
+	case "gen":
+		err = gen(flag.Args()[1:])
+	case "export":
+		err = export(flag.Args()[1:])
+	case "convert":
+		err = convert(flag.Args()[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "govcr: unknown command %q\n", cmd)
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "govcr: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: govcr serve [-addr :8080] [-match-headers] <cassette>...")
+	fmt.Fprintln(os.Stderr, "       govcr verify [-unsafe] [-ignore-headers Header,...] <cassette>...")
+	fmt.Fprintln(os.Stderr, "       govcr gen [-pkg fixtures] [-func Handler] [-o file.go] <cassette>")
+	fmt.Fprintln(os.Stderr, "       govcr export [-format markdown|mermaid] [-max-body 80] <cassette>")
+	fmt.Fprintln(os.Stderr, "       govcr convert [-keep] [-level 0] -to yaml|cbor[.gz|.zst] <cassette>...")
+}
+
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	matchHeaders := fs.Bool("match-headers", false, "match requests on their headers")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	set, err := cassette.LoadSet(fs.Args()...)
+	if err != nil {
+		return err
+	}
+	for _, c := range set.Cassettes() {
+		c.ReplayableInteractions = true
+	}
+
+	if !*matchHeaders {
+		if err := set.SetMatcher(headerlessMatcher{cassette.DefaultMatcher}); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("serving cassettes", "cassettes", fs.Args(), "addr", *addr, "interactions", set.Len())
+
+	return http.ListenAndServe(*addr, cassette.Handler(set))
+}
+
+// errDrift is returned by the verify command, when drift was found.
+var errDrift = errors.New("recorded responses have drifted")
+
+func verify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	unsafe := fs.Bool("unsafe", false, "verify interactions with unsafe methods, e.g. POST")
+	ignoreHeaders := fs.String("ignore-headers", "", "comma separated response headers to ignore")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	var opts []cassette.VerifyOption
+	if *unsafe {
+		opts = append(opts, cassette.WithVerifyUnsafeMethods())
+	}
+	if *ignoreHeaders != "" {
+		opts = append(opts, cassette.WithVerifyIgnoreHeaders(strings.Split(*ignoreHeaders, ",")...))
+	}
+
+	drifted := false
+	for _, name := range fs.Args() {
+		c, err := cassette.Load(name)
+		if err != nil {
+			return err
+		}
+
+		drifts, err := cassette.Verify(context.Background(), c, http.DefaultClient, opts...)
+		if err != nil {
+			return err
+		}
+		for _, d := range drifts {
+			fmt.Printf("%s: %s\n", c.File(), d)
+		}
+		drifted = drifted || len(drifts) > 0
+	}
+
+	if drifted {
+		return errDrift
+	}
+	return nil
+}
+
+func gen(args []string) error {
+	fs := flag.NewFlagSet("gen", flag.ExitOnError)
+	pkg := fs.String("pkg", "fixtures", "package name of the generated file")
+	name := fs.String("func", "Handler", "name of the generated function")
+	output := fs.String("o", "", "output file, defaults to stdout")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	c, err := cassette.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := cassette.GenerateHandler(&buf, c, *pkg, *name); err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return os.WriteFile(*output, buf.Bytes(), 0o644)
+}
+
+func export(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "markdown", "output format, markdown or mermaid")
+	maxBody := fs.Int("max-body", 80, "maximum length of bodies, 0 disables truncation")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	c, err := cassette.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "markdown":
+		return cassette.ExportMarkdown(os.Stdout, c, *maxBody)
+	case "mermaid":
+		return cassette.ExportMermaid(os.Stdout, c)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+func convert(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	to := fs.String("to", "", "target format, yaml or cbor, optionally followed by .gz or .zst")
+	keep := fs.Bool("keep", false, "keep the original files")
+	level := fs.Int("level", 0, "compression level, 0 uses the default level")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	opts := []cassette.ConvertOption{cassette.WithConvertCompressionLevel(*level)}
+	if !*keep {
+		opts = append(opts, cassette.WithConvertRemoveSource())
+	}
+
+	for _, name := range fs.Args() {
+		src, err := cassette.New(name).Locate()
+		if err != nil {
+			return err
+		}
+		if err := cassette.Convert(src, name+"."+*to, opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// headerlessMatcher matches requests ignoring their headers, which differ
+// between clients.
+type headerlessMatcher struct {
+	matcher cassette.RequestMatcher
+}
+
+func (m headerlessMatcher) Hash(r *http.Request) (string, error) {
+	return cassette.HashWith(m.matcher, r, func(r *http.Request) {
+		r.Header = make(http.Header)
+	})
+}
@@ -2,10 +2,12 @@ module github.com/goware/go-vcr
 
 go 1.23
 
-require gopkg.in/yaml.v3 v3.0.1
-
 require (
-	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/stretchr/testify v1.10.0 // indirect
+	github.com/andybalholm/brotli v1.1.1
+	github.com/cespare/xxhash/v2 v2.3.0
+	github.com/fxamacker/cbor/v2 v2.9.2
+	github.com/klauspost/compress v1.17.11
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require github.com/x448/float16 v0.8.4 // indirect
@@ -0,0 +1,96 @@
+package recorder
+
+import (
+	"mime"
+	"strings"
+
+	"github.com/goware/go-vcr/cassette"
+)
+
+// BodyPolicy specifies how response bodies of a media type are stored in
+// the cassette, see [WithBodyPolicy].
+type BodyPolicy int
+
+const (
+	// BodyInline stores the body as is, which is the default.
+	BodyInline BodyPolicy = iota
+
+	// BodyBase64 stores the body base64-encoded, e.g. for images, see
+	// [cassette.Base64EncodeResponseBody].
+	BodyBase64
+
+	// BodyDrop stores only the digest and the length of the body, e.g. for
+	// videos, see [cassette.DropResponseBody].
+	BodyDrop
+
+	// BodyPrettyPrint stores the body pretty-printed, if its format is
+	// supported, e.g. for JSON, see [cassette.PrettyPrintResponseBody].
+	BodyPrettyPrint
+)
+
+// WithBodyPolicy is an [Option], which configures how the [Recorder] stores
+// response bodies in the cassette by their media type, e.g.
+//
+//	recorder.WithBodyPolicy(map[string]recorder.BodyPolicy{
+//		"text/*":           recorder.BodyInline,
+//		"image/*":          recorder.BodyBase64,
+//		"video/*":          recorder.BodyDrop,
+//		"application/json": recorder.BodyPrettyPrint,
+//	})
+//
+// Media types are matched exactly first, then by their type using a
+// "type/*" key, and finally by the "*/*" key. Parameters of the
+// Content-Type header are ignored. Bodies of other media types are stored
+// as is. See [BodyPolicyHook] for details.
+func WithBodyPolicy(policies map[string]BodyPolicy) Option {
+	return func(r *Recorder) {
+		if r.bodyPolicies == nil {
+			r.bodyPolicies = make(map[string]BodyPolicy, len(policies))
+		}
+		for mediaType, policy := range policies {
+			r.bodyPolicies[strings.ToLower(mediaType)] = policy
+		}
+	}
+}
+
+// BodyPolicyHook returns a [HookFunc], which applies the policy for the
+// media type of the response body of the interaction, see
+// [WithBodyPolicy]. The body is stored according to the policy when the
+// cassette is saved, and replayed as recorded, i.e. base64-encoded bodies
+// are decoded again, and dropped bodies are replayed as zero bytes.
+//
+// The hook must be registered as a [BeforeSaveHook], after the hooks
+// operating on the bodies.
+func BodyPolicyHook(policies map[string]BodyPolicy) HookFunc {
+	return func(i *cassette.Interaction) error {
+		mediaType, _, err := mime.ParseMediaType(i.Response.Headers.Get("Content-Type"))
+		if err != nil {
+			mediaType = ""
+		}
+
+		switch bodyPolicyFor(policies, strings.ToLower(mediaType)) {
+		case BodyBase64:
+			return cassette.Base64EncodeResponseBody(i)
+		case BodyDrop:
+			cassette.DropResponseBody(i)
+		case BodyPrettyPrint:
+			return cassette.PrettyPrintResponseBody(i, cassette.FormatJSON, cassette.FormatXML)
+		}
+
+		return nil
+	}
+}
+
+// bodyPolicyFor returns the policy for the media type, which is matched as
+// described in [WithBodyPolicy].
+func bodyPolicyFor(policies map[string]BodyPolicy, mediaType string) BodyPolicy {
+	if policy, ok := policies[mediaType]; ok && mediaType != "" {
+		return policy
+	}
+	if typ, _, ok := strings.Cut(mediaType, "/"); ok {
+		if policy, ok := policies[typ+"/*"]; ok {
+			return policy
+		}
+	}
+	return policies["*/*"]
+}
@@ -0,0 +1,107 @@
+package recorder_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/goware/go-vcr/cassette"
+	"github.com/goware/go-vcr/recorder"
+)
+
+func TestBodyPolicy(t *testing.T) {
+	bodies := map[string]struct {
+		contentType string
+		body        string
+	}{
+		"/logo.png":  {"image/png", "\x89PNG\r\n\x1a\n"},
+		"/intro.mp4": {"video/mp4", "not worth storing"},
+		"/user":      {"application/json; charset=utf-8", `{"id":1,"tags":["a","b"]}`},
+		"/readme":    {"text/plain", "hello"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b := bodies[r.URL.Path]
+		w.Header().Set("Content-Type", b.contentType)
+		io.WriteString(w, b.body)
+	}))
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_body_policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := recorder.WithBodyPolicy(map[string]recorder.BodyPolicy{
+		"text/*":           recorder.BodyInline,
+		"image/*":          recorder.BodyBase64,
+		"video/*":          recorder.BodyDrop,
+		"application/json": recorder.BodyPrettyPrint,
+	})
+	paths := []string{"/logo.png", "/intro.mp4", "/user", "/readme"}
+
+	get := func(rec *recorder.Recorder, path string) string {
+		t.Helper()
+		resp, err := rec.GetDefaultClient().Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	rec, err := recorder.New(cassPath, recorder.WithMode(recorder.ModeRecordOnly), policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		if got := get(rec, path); got != bodies[path].body {
+			t.Fatalf("got body %q for %s, want %q", got, path, bodies[path].body)
+		}
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cassette.Load(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := make(map[string]cassette.Response)
+	for _, i := range c.Interactions {
+		stored[strings.TrimPrefix(i.Request.URL, server.URL)] = i.Response
+	}
+	if r := stored["/logo.png"]; r.BodyEncoding != cassette.BodyEncodingBase64 || r.Body != "iVBORw0KGgo=" {
+		t.Errorf("got image body %q encoded as %q, want it base64-encoded", r.Body, r.BodyEncoding)
+	}
+	if r := stored["/intro.mp4"]; r.Body != "" || r.BodyDigest == "" || r.BodyLength != int64(len(bodies["/intro.mp4"].body)) {
+		t.Errorf("got video body %q, digest %q and length %d, want it dropped", r.Body, r.BodyDigest, r.BodyLength)
+	}
+	if r := stored["/user"]; r.PrettyPrinted != cassette.FormatJSON || !strings.Contains(r.Body, "\n") {
+		t.Errorf("got JSON body %q, want it pretty-printed", r.Body)
+	}
+	if r := stored["/readme"]; r.Body != "hello" || r.BodyEncoding != "" {
+		t.Errorf("got text body %q encoded as %q, want it inline", r.Body, r.BodyEncoding)
+	}
+
+	rec, err = recorder.New(cassPath, recorder.WithMode(recorder.ModeReplayOnly), policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	for _, path := range paths {
+		want := bodies[path].body
+		if path == "/intro.mp4" {
+			want = strings.Repeat("\x00", len(want))
+		}
+		if got := get(rec, path); got != want {
+			t.Errorf("got replayed body %q for %s, want %q", got, path, want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package recorder
+
+import "sync"
+
+// defaultCassetteDir is the directory, which relative cassette names are
+// resolved against by the recorders created by [New], see
+// [SetDefaultCassetteDir].
+var defaultCassetteDir struct {
+	sync.Mutex
+	dir string
+}
+
+// SetDefaultCassetteDir sets the directory, which relative cassette names
+// are resolved against by the recorders created by [New] afterwards, so that
+// tests can use short cassette names, while the repository controls where
+// the cassettes live, e.g. in TestMain:
+//
+//	recorder.SetDefaultCassetteDir("testdata/fixtures")
+//
+// Scratch recordings may be kept in the temporary directory of the OS,
+// e.g. filepath.Join(os.TempDir(), "cassettes"). The directory is
+// overridden by [WithCassetteDir] and by the [EnvCassetteDir] environment
+// variable. An empty directory resolves cassette names against the current
+// working directory, which is the default.
+func SetDefaultCassetteDir(dir string) {
+	defaultCassetteDir.Lock()
+	defer defaultCassetteDir.Unlock()
+	defaultCassetteDir.dir = dir
+}
+
+// DefaultCassetteDir returns the directory set by [SetDefaultCassetteDir].
+func DefaultCassetteDir() string {
+	defaultCassetteDir.Lock()
+	defer defaultCassetteDir.Unlock()
+	return defaultCassetteDir.dir
+}
+
+// WithCassetteDir is an [Option], which configures the directory, which
+// relative cassette names are resolved against by the [Recorder], instead
+// of the one set by [SetDefaultCassetteDir]. The directory is overridden by
+// the [EnvCassetteDir] environment variable.
+func WithCassetteDir(dir string) Option {
+	return func(r *Recorder) {
+		r.cassetteDir = dir
+	}
+}
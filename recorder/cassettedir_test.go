@@ -0,0 +1,42 @@
+package recorder_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goware/go-vcr/cassette"
+	"github.com/goware/go-vcr/recorder"
+)
+
+func TestDefaultCassetteDir(t *testing.T) {
+	t.Setenv(recorder.EnvCassetteDir, "")
+	dir := t.TempDir()
+	recorder.SetDefaultCassetteDir(dir)
+	t.Cleanup(func() { recorder.SetDefaultCassetteDir("") })
+
+	wantPath := func(t *testing.T, err error, want string) {
+		t.Helper()
+		if !errors.Is(err, cassette.ErrCassetteNotFound) || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected cassette to be resolved to %s, got %v", want, err)
+		}
+	}
+
+	t.Run("default", func(t *testing.T) {
+		_, err := recorder.New("missing", recorder.WithMode(recorder.ModeReplayOnly))
+		wantPath(t, err, filepath.Join(dir, "missing.yaml"))
+	})
+
+	t.Run("option", func(t *testing.T) {
+		other := t.TempDir()
+		_, err := recorder.New("missing", recorder.WithMode(recorder.ModeReplayOnly), recorder.WithCassetteDir(other))
+		wantPath(t, err, filepath.Join(other, "missing.yaml"))
+	})
+
+	t.Run("absolute name", func(t *testing.T) {
+		name := filepath.Join(t.TempDir(), "missing")
+		_, err := recorder.New(name, recorder.WithMode(recorder.ModeReplayOnly))
+		wantPath(t, err, name+".yaml")
+	})
+}
@@ -0,0 +1,350 @@
+package recorder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/goware/go-vcr/cassette"
+)
+
+// cassetteContextKey is the context key for the cassette selected for a
+// request.
+type cassetteContextKey struct{}
+
+// ContextWithCassette returns a copy of the parent context, which selects
+// the named cassette for requests using it. Requests carrying a cassette
+// name are recorded into, and replayed from the named cassette, instead of
+// the one the [Recorder] was created with. The cassettes are created or
+// loaded on first use according to the mode and options of the recorder, and
+// are saved when the recorder is stopped.
+//
+// This allows a single recorder, e.g. one used by a middleware serving many
+// tenants, to capture traffic into separate cassettes.
+func ContextWithCassette(parent context.Context, name string) context.Context {
+	return context.WithValue(parent, cassetteContextKey{}, name)
+}
+
+// CassetteFromContext returns the name of the cassette selected by the
+// context, or an empty string if none is selected.
+func CassetteFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(cassetteContextKey{}).(string)
+	return name
+}
+
+// WithSplitByHost is an [Option], which configures the [Recorder] to record
+// requests using the default cassette into a separate cassette per
+// destination host, and replay them from it. The per-host cassettes are
+// named after the default cassette and the host name, e.g.
+// "fixtures/checkout.api.example.com" for the default cassette
+// "fixtures/checkout", which allows refreshing the fixtures of each provider
+// independently. Requests selecting a cassette using [ContextWithCassette]
+// are not split.
+func WithSplitByHost(val bool) Option {
+	return func(r *Recorder) {
+		r.splitByHost = val
+	}
+}
+
+// WithShards is an [Option], which configures the [Recorder] to record
+// requests using the default cassette into n shards, and replay them from
+// these. Each shard is a separate cassette, named as returned by
+// [cassette.ShardName], and requests are assigned to the shards by their
+// hash according to the matcher of the recorder. This avoids contention on a
+// single cassette, when many goroutines record simultaneously, e.g. in load
+// tests. The number of shards must not change between recording and replay.
+//
+// The shards are merged by [cassette.Load] and [cassette.LoadShards].
+func WithShards(n int) Option {
+	return func(r *Recorder) {
+		r.shards = n
+	}
+}
+
+// WithReplayCassettes is an [Option], which configures the [Recorder] to
+// replay interactions from the named cassettes in addition to its default
+// cassette, e.g. to split large fixtures into topical files. New
+// interactions are only recorded into the default cassette, while the named
+// cassettes are never modified. The named cassettes must exist, and are
+// consulted in the given order before the default cassette, in the modes
+// replaying interactions, i.e. [ModeReplayOnly], [ModeReplayWithNewEpisodes]
+// and [ModeRecordOnce].
+func WithReplayCassettes(names ...string) Option {
+	return func(r *Recorder) {
+		r.replayCassetteNames = append(r.replayCassetteNames, names...)
+	}
+}
+
+// loadReplayCassettes loads the cassettes configured using
+// [WithReplayCassettes].
+func (rec *Recorder) loadReplayCassettes() error {
+	cassettes := make([]*cassette.Cassette, 0, len(rec.replayCassetteNames))
+	for _, name := range rec.replayCassetteNames {
+		c, err := rec.getCassette(name, ModeReplayOnly, rec.matcher)
+		if err != nil {
+			return err
+		}
+		cassettes = append(cassettes, c)
+	}
+	rec.replayCassettes = cassette.NewSet(cassettes...)
+
+	return nil
+}
+
+// replayFromCassettes returns the interaction of the cassettes configured
+// using [WithReplayCassettes] matching the request, or nil, if none matches
+// or the mode does not replay interactions.
+func (rec *Recorder) replayFromCassettes(r *http.Request) (*cassette.Interaction, error) {
+	switch rec.Mode() {
+	case ModeReplayOnly, ModeReplayWithNewEpisodes, ModeRecordOnce:
+	default:
+		return nil, nil
+	}
+
+	rec.mu.RLock()
+	set := rec.replayCassettes
+	rec.mu.RUnlock()
+
+	i, err := set.GetInteraction(r)
+	if errors.Is(err, cassette.ErrInteractionNotFound) {
+		return nil, nil
+	}
+
+	return i, err
+}
+
+// cassetteFor returns the cassette selected for the given request.
+func (rec *Recorder) cassetteFor(r *http.Request) (*cassette.Cassette, error) {
+	name := CassetteFromContext(r.Context())
+	mode := rec.Mode()
+
+	rec.mu.RLock()
+	shards, matcher, defaultName := rec.shards, rec.matcher, rec.cassetteName
+	rec.mu.RUnlock()
+
+	shard := -1
+	if shards > 1 && (name == "" || name == defaultName) {
+		hash, err := cassette.HashRequest(matcher, r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash request: %w", err)
+		}
+		h := fnv.New32a()
+		h.Write([]byte(hash))
+		shard = int(h.Sum32() % uint32(shards))
+	}
+
+	for {
+		rec.mu.RLock()
+		c, key, err := rec.lookupCassetteLocked(r, name, shard)
+		rec.mu.RUnlock()
+		if c != nil || err != nil {
+			return c, err
+		}
+
+		// Check again, as the cassette may be loaded meanwhile, and load it
+		// without holding the lock, so that requests to other cassettes are
+		// not blocked by reading it from disk.
+		rec.mu.Lock()
+		c, key, err = rec.lookupCassetteLocked(r, name, shard)
+		if c != nil || err != nil {
+			rec.mu.Unlock()
+			return c, err
+		}
+		if loading, ok := rec.loading[key]; ok {
+			rec.mu.Unlock()
+			select {
+			case <-loading:
+				continue
+			case <-r.Context().Done():
+				return nil, r.Context().Err()
+			}
+		}
+		loading := make(chan struct{})
+		rec.loading[key] = loading
+		matcher, version := rec.matcher, rec.matcherVersion
+		rec.mu.Unlock()
+
+		c, err = rec.getCassette(key, mode, matcher)
+		if err == nil {
+			// Apply the start hooks without holding the lock, as they may
+			// use the recorder.
+			err = rec.startCassette(c, mode)
+		}
+
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		delete(rec.loading, key)
+		close(loading)
+		if err != nil {
+			return nil, err
+		}
+		if version != rec.matcherVersion {
+			// The matcher was replaced while loading the cassette
+			if err := c.SetMatcher(rec.matcher); err != nil {
+				return nil, err
+			}
+		}
+		rec.cassettes[key] = c
+
+		return c, nil
+	}
+}
+
+// lookupCassetteLocked returns the cassette selected for the given request,
+// if it is already loaded, and the name of the cassette otherwise. The
+// caller must hold rec.mu.
+func (rec *Recorder) lookupCassetteLocked(r *http.Request, name string, shard int) (*cassette.Cassette, string, error) {
+	if name == "" || name == rec.cassetteName {
+		if rec.cassette == nil {
+			return nil, "", ErrNoCassetteInserted
+		}
+		name = rec.cassetteName
+		if host := hostCassetteSuffix(r); rec.splitByHost && host != "" {
+			name += "." + host
+		}
+		if shard >= 0 {
+			name = cassette.ShardName(name, shard)
+		}
+		if name == rec.cassetteName {
+			return rec.cassette, name, nil
+		}
+	}
+
+	return rec.cassettes[name], name, nil
+}
+
+// hostCassetteSuffix returns the destination host of the request for use
+// in the name of its per-host cassette.
+func hostCassetteSuffix(r *http.Request) string {
+	host := r.URL.Hostname()
+	if host == "" {
+		host, _, _ = strings.Cut(r.Host, ":")
+	}
+	// Colons of IPv6 addresses are not valid in file names on all
+	// platforms.
+	return strings.ReplaceAll(host, ":", "_")
+}
+
+// defaultCassette creates or loads the named default cassette of the
+// recorder, see [Recorder.getCassette].
+func (rec *Recorder) defaultCassette(name string, mode Mode) (*cassette.Cassette, error) {
+	if (rec.splitByHost || rec.shards > 1 || rec.shared) && mode == ModeReplayOnly {
+		// The interactions are replayed from the per-host cassettes,
+		// shards or cassettes of the tests, so that the default cassette
+		// need not exist.
+		mode = ModeRecordOnly
+	}
+	return rec.getCassette(name, mode, rec.matcher)
+}
+
+// allCassettesLocked returns the default cassette of the recorder, if one
+// is inserted, followed by the additional cassettes sorted by name. The
+// caller must hold rec.mu.
+func (rec *Recorder) allCassettesLocked() []*cassette.Cassette {
+	names := make([]string, 0, len(rec.cassettes))
+	for name := range rec.cassettes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	cassettes := make([]*cassette.Cassette, 0, len(names)+1)
+	if rec.cassette != nil {
+		cassettes = append(cassettes, rec.cassette)
+	}
+	for _, name := range names {
+		cassettes = append(cassettes, rec.cassettes[name])
+	}
+
+	return cassettes
+}
+
+// InsertCassette replaces the default cassette of the recorder with the
+// named one, which is created or loaded according to the current mode and
+// the options of the recorder. The previously inserted cassette is ejected,
+// i.e. saved as if the recorder was stopped.
+func (rec *Recorder) InsertCassette(name string) error {
+	mode := rec.Mode()
+
+	rec.mu.Lock()
+	c, err := rec.defaultCassette(name, mode)
+	rec.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// Apply the start hooks without holding the lock, as they may use the
+	// recorder.
+	if err := rec.startCassette(c, mode); err != nil {
+		return err
+	}
+
+	rec.mu.Lock()
+	old := rec.cassette
+	rec.cassette, rec.cassetteName = c, name
+	rec.mu.Unlock()
+
+	if old == nil {
+		return nil
+	}
+
+	return rec.releaseCassette(old, true)
+}
+
+// EjectCassette saves the default cassette of the recorder as if the
+// recorder was stopped, and removes it from the recorder. Requests using the
+// default cassette fail with [ErrNoCassetteInserted], until another cassette
+// is inserted using [Recorder.InsertCassette].
+func (rec *Recorder) EjectCassette() error {
+	rec.mu.Lock()
+	old := rec.cassette
+	rec.cassette, rec.cassetteName = nil, ""
+	rec.mu.Unlock()
+
+	if old == nil {
+		return ErrNoCassetteInserted
+	}
+
+	return rec.releaseCassette(old, true)
+}
+
+// ResetCassette discards the interactions recorded into the default
+// cassette and the replay state of its interactions, by creating or loading
+// it again, without saving it.
+func (rec *Recorder) ResetCassette() error {
+	mode := rec.Mode()
+
+	rec.mu.Lock()
+	if rec.cassette == nil {
+		rec.mu.Unlock()
+		return ErrNoCassetteInserted
+	}
+	c, err := rec.defaultCassette(rec.cassetteName, mode)
+	if err != nil {
+		rec.mu.Unlock()
+		return err
+	}
+	old := rec.cassette
+	rec.cassette = c
+	rec.mu.Unlock()
+
+	return rec.releaseCassette(old, false)
+}
+
+// releaseCassette stops the cassette, which is no longer used by the
+// recorder, saving it if requested.
+func (rec *Recorder) releaseCassette(c *cassette.Cassette, save bool) error {
+	var err error
+	if save {
+		err = rec.stopCassette(c)
+	}
+
+	rec.mu.Lock()
+	delete(rec.refreshed, c)
+	rec.mu.Unlock()
+
+	return err
+}
@@ -0,0 +1,237 @@
+package recorder_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/goware/go-vcr/cassette"
+	"github.com/goware/go-vcr/recorder"
+)
+
+func TestContextWithCassette(t *testing.T) {
+	server := newEchoHttpServer()
+	serverUrl := server.URL
+
+	dir := t.TempDir()
+	defaultPath := filepath.Join(dir, "default")
+	tenantPaths := map[string]string{
+		"/api/v1/foo": filepath.Join(dir, "tenant-foo"),
+		"/api/v1/bar": filepath.Join(dir, "tenant-bar"),
+	}
+
+	tests := []testCase{
+		{
+			method:            http.MethodGet,
+			wantBody:          "GET go-vcr\n",
+			wantStatus:        http.StatusOK,
+			wantContentLength: 11,
+			path:              "/api/v1/foo",
+		},
+		{
+			method:            http.MethodPost,
+			body:              "bar",
+			wantBody:          "POST go-vcr\nbar",
+			wantStatus:        http.StatusOK,
+			wantContentLength: 15,
+			path:              "/api/v1/bar",
+		},
+	}
+
+	run := func(rec *recorder.Recorder) {
+		t.Helper()
+		for _, test := range tests {
+			ctx := recorder.ContextWithCassette(context.Background(), tenantPaths[test.path])
+			if err := test.run(ctx, rec.GetDefaultClient(), serverUrl); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	rec, err := recorder.New(defaultPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(rec)
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	if len(rec.Interactions()) != 0 {
+		t.Fatal("expected no interactions in the default cassette")
+	}
+
+	for path, cassPath := range tenantPaths {
+		c, err := cassette.Load(cassPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(c.Interactions) != 1 {
+			t.Fatalf("expected 1 interaction in %s, got %d", cassPath, len(c.Interactions))
+		}
+		if got := c.Interactions[0].Request.URL; got != serverUrl+path {
+			t.Fatalf("unexpected interaction %s in %s", got, cassPath)
+		}
+	}
+
+	// Replay from the tenant cassettes
+	rec, err = recorder.New(defaultPath, recorder.WithMode(recorder.ModeReplayWithNewEpisodes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+	run(rec)
+}
+
+func TestContextWithCassetteConcurrent(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+
+	dir := t.TempDir()
+	tenantPath := filepath.Join(dir, "tenant")
+	rec, err := recorder.New(filepath.Join(dir, "default"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Concurrent requests to a cassette, which is not loaded yet, are
+	// recorded into the same cassette
+	const n = 16
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for idx := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			test := testCase{
+				method:            http.MethodGet,
+				wantBody:          "GET go-vcr\n",
+				wantStatus:        http.StatusOK,
+				wantContentLength: 11,
+				path:              fmt.Sprintf("/api/v1/%d", idx),
+			}
+			ctx := recorder.ContextWithCassette(context.Background(), tenantPath)
+			errs <- test.run(ctx, rec.GetDefaultClient(), server.URL)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cassette.Load(tenantPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Interactions) != n {
+		t.Fatalf("expected %d interactions, got %d", n, len(c.Interactions))
+	}
+}
+
+func TestReplayCassettes(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+
+	dir := t.TempDir()
+
+	// Split the fixtures into topical cassettes
+	topics := map[string]string{"users": "/api/v1/users", "orders": "/api/v1/orders"}
+	for name, path := range topics {
+		c := cassette.New(filepath.Join(dir, name))
+		if _, err := c.AddStub(http.MethodGet, server.URL+path, http.StatusOK, name); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	usersData, err := os.ReadFile(filepath.Join(dir, "users.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := recorder.New(filepath.Join(dir, "primary"),
+		recorder.WithReplayCassettes(filepath.Join(dir, "users"), filepath.Join(dir, "orders")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []testCase{
+		{
+			method:            http.MethodGet,
+			wantBody:          "users",
+			wantStatus:        http.StatusOK,
+			wantContentLength: 5,
+			path:              "/api/v1/users",
+		},
+		{
+			method:            http.MethodGet,
+			wantBody:          "orders",
+			wantStatus:        http.StatusOK,
+			wantContentLength: 6,
+			path:              "/api/v1/orders",
+		},
+		{
+			method:            http.MethodGet,
+			wantBody:          "GET go-vcr\n",
+			wantStatus:        http.StatusOK,
+			wantContentLength: 11,
+			path:              "/api/v1/products",
+		},
+	}
+	for _, test := range tests {
+		if err := test.run(context.Background(), rec.GetDefaultClient(), server.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// New interactions are only recorded into the primary cassette
+	primary, err := cassette.Load(filepath.Join(dir, "primary"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(primary.Interactions) != 1 || primary.Interactions[0].Request.URL != server.URL+"/api/v1/products" {
+		t.Fatalf("got primary interactions %+v", primary.Interactions)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "users.yaml")); err != nil || !bytes.Equal(data, usersData) {
+		t.Fatalf("expected replay cassette to be unchanged, got error %v", err)
+	}
+
+	// All cassettes are replayed
+	rec, err = recorder.New(filepath.Join(dir, "primary"),
+		recorder.WithMode(recorder.ModeReplayOnly),
+		recorder.WithReplayCassettes(filepath.Join(dir, "users"), filepath.Join(dir, "orders")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+	for _, test := range tests {
+		if err := test.run(context.Background(), rec.GetDefaultClient(), server.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The replay cassettes must exist
+	_, err = recorder.New(filepath.Join(dir, "primary"), recorder.WithReplayCassettes(filepath.Join(dir, "missing")))
+	if !errors.Is(err, cassette.ErrCassetteNotFound) {
+		t.Fatalf("got error %v, want %v", err, cassette.ErrCassetteNotFound)
+	}
+}
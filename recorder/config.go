@@ -0,0 +1,251 @@
+package recorder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goware/go-vcr/cassette"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileName is the name of the project config file, see [FindConfig].
+const ConfigFileName = ".vcr.yaml"
+
+// Config is the project-level configuration of the recorders created by
+// [New], which is loaded from the project config file, see [FindConfig], so
+// that repositories share their defaults instead of copying recorder
+// options, e.g.
+//
+//	mode: replay
+//	cassette_dir: testdata/cassettes
+//	redact:
+//	  headers: [Authorization, X-Api-Key]
+//	  form_fields: [password]
+//	  presets: [set-cookie, pii]
+//	matcher:
+//	  fields: [method, path, body]
+//	  ignore_headers: [User-Agent]
+//
+// The configuration provides defaults only, which are overridden by the
+// options passed to [New], and by the environment, see [WithEnvOverrides].
+type Config struct {
+	// Mode is the mode of the recorder, see [ParseMode].
+	Mode string `yaml:"mode,omitempty"`
+
+	// CassetteDir is the directory, which relative cassette names are
+	// resolved against, see [WithCassetteDir], unless set using
+	// [SetDefaultCassetteDir]. A relative directory is resolved against the
+	// directory of the config file.
+	CassetteDir string `yaml:"cassette_dir,omitempty"`
+
+	// Redact configures the redaction of the interactions before saving
+	// the cassette.
+	Redact RedactConfig `yaml:"redact,omitempty"`
+
+	// Matcher configures the matcher of the recorder, see [WithMatcher].
+	Matcher MatcherConfig `yaml:"matcher,omitempty"`
+}
+
+// RedactConfig configures the redaction hooks of kind [BeforeSaveHook],
+// which are registered by the project config file.
+type RedactConfig struct {
+	// Headers are redacted using [RedactHeaders].
+	Headers []string `yaml:"headers,omitempty"`
+
+	// FormFields are redacted using [RedactFormFields].
+	FormFields []string `yaml:"form_fields,omitempty"`
+
+	// Presets are the built-in redaction hooks to register, which are
+	// "set-cookie" for [RedactSetCookie], "aws-signatures" for
+	// [RedactAWSSignatures], "pii" for [AnonymizePII] and "volatile" for
+	// [StripVolatileFields].
+	Presets []string `yaml:"presets,omitempty"`
+}
+
+// MatcherConfig configures the default matcher, see [cassette.NewMatcher].
+// The matcher is only configured, if any of its fields are set.
+type MatcherConfig struct {
+	// Fields are the hashed request components, see
+	// [cassette.WithHashFields], which are "method", "host", "url",
+	// "path", "query", "proto", "headers", "body", "content_length",
+	// "trailer", "transfer_encoding", "remote_addr" and "request_uri".
+	Fields []string `yaml:"fields,omitempty"`
+
+	// IgnoreHeaders are the headers ignored when matching, see
+	// [cassette.WithIgnoreHeaders].
+	IgnoreHeaders []string `yaml:"ignore_headers,omitempty"`
+
+	// IgnoreQueryParams are the query parameters ignored when matching,
+	// see [cassette.WithIgnoreQueryParams].
+	IgnoreQueryParams []string `yaml:"ignore_query_params,omitempty"`
+}
+
+// hashFieldNames are the names of the hashed request components in the
+// project config file.
+var hashFieldNames = map[string]cassette.HashField{
+	"method":            cassette.HashMethod,
+	"host":              cassette.HashHost,
+	"url":               cassette.HashURL,
+	"path":              cassette.HashPath,
+	"query":             cassette.HashQuery,
+	"proto":             cassette.HashProto,
+	"headers":           cassette.HashHeaders,
+	"body":              cassette.HashBody,
+	"content_length":    cassette.HashContentLength,
+	"trailer":           cassette.HashTrailer,
+	"transfer_encoding": cassette.HashTransferEncoding,
+	"remote_addr":       cassette.HashRemoteAddr,
+	"request_uri":       cassette.HashRequestURI,
+}
+
+// redactPresets are the built-in redaction hooks, which are registered by
+// name in the project config file.
+var redactPresets = map[string]func() HookFunc{
+	"set-cookie":     RedactSetCookie,
+	"aws-signatures": RedactAWSSignatures,
+	"pii":            AnonymizePII,
+	"volatile":       StripVolatileFields,
+}
+
+// FindConfig returns the path of the project config file, which is
+// searched for in dir and its parent directories, up to the root of the Go
+// module, i.e. the first directory containing a go.mod file. An empty path
+// is returned, if there is no config file.
+func FindConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		path := filepath.Join(dir, ConfigFileName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return "", nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// LoadConfig loads the project config file at path. Unknown fields, modes,
+// hash fields and redaction presets are rejected, so that typos do not go
+// unnoticed.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	if cfg.Mode != "" {
+		if _, err := ParseMode(cfg.Mode); err != nil {
+			return nil, fmt.Errorf("invalid mode in config file %s: %w", path, err)
+		}
+	}
+	if cfg.CassetteDir != "" && !filepath.IsAbs(cfg.CassetteDir) {
+		cfg.CassetteDir = filepath.Join(filepath.Dir(path), cfg.CassetteDir)
+	}
+	for _, preset := range cfg.Redact.Presets {
+		if _, ok := redactPresets[strings.ToLower(preset)]; !ok {
+			return nil, fmt.Errorf("unknown redaction preset %q in config file %s", preset, path)
+		}
+	}
+	for _, field := range cfg.Matcher.Fields {
+		if _, ok := hashFieldNames[strings.ToLower(field)]; !ok {
+			return nil, fmt.Errorf("unknown hash field %q in config file %s", field, path)
+		}
+	}
+
+	return cfg, nil
+}
+
+// Options returns the options configuring a [Recorder] as specified by the
+// config.
+func (c *Config) Options() []Option {
+	var opts []Option
+	if c.Mode != "" {
+		if mode, err := ParseMode(c.Mode); err == nil {
+			opts = append(opts, WithMode(mode))
+		}
+	}
+	if c.CassetteDir != "" && DefaultCassetteDir() == "" {
+		opts = append(opts, WithCassetteDir(c.CassetteDir))
+	}
+
+	if len(c.Redact.Headers) > 0 {
+		opts = append(opts, withBuiltinHook(RedactHeaders(c.Redact.Headers...), BeforeSaveHook))
+	}
+	if len(c.Redact.FormFields) > 0 {
+		opts = append(opts, withBuiltinHook(RedactFormFields(c.Redact.FormFields...), BeforeSaveHook))
+	}
+	for _, preset := range c.Redact.Presets {
+		if hook, ok := redactPresets[strings.ToLower(preset)]; ok {
+			opts = append(opts, withBuiltinHook(hook(), BeforeSaveHook))
+		}
+	}
+
+	m := c.Matcher
+	if len(m.Fields) > 0 || len(m.IgnoreHeaders) > 0 || len(m.IgnoreQueryParams) > 0 {
+		var matcherOpts []cassette.MatcherOption
+		if len(m.Fields) > 0 {
+			var fields cassette.HashField
+			for _, field := range m.Fields {
+				fields |= hashFieldNames[strings.ToLower(field)]
+			}
+			matcherOpts = append(matcherOpts, cassette.WithHashFields(fields))
+		}
+		if len(m.IgnoreHeaders) > 0 {
+			matcherOpts = append(matcherOpts, cassette.WithIgnoreHeaders(m.IgnoreHeaders...))
+		}
+		if len(m.IgnoreQueryParams) > 0 {
+			matcherOpts = append(matcherOpts, cassette.WithIgnoreQueryParams(m.IgnoreQueryParams...))
+		}
+		opts = append(opts, WithMatcher(cassette.NewMatcher(matcherOpts...)))
+	}
+
+	return opts
+}
+
+// projectConfigOptions returns the options specified by the project config
+// file, which is either specified by the [EnvConfig] environment variable,
+// or searched for in the working directory, see [FindConfig].
+func projectConfigOptions() ([]Option, error) {
+	path := os.Getenv(EnvConfig)
+	switch strings.ToLower(path) {
+	case "off":
+		return nil, nil
+	case "":
+		var err error
+		if path, err = FindConfig("."); err != nil || path == "" {
+			return nil, err
+		}
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg.Options(), nil
+}
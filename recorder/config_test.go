@@ -0,0 +1,145 @@
+package recorder_test
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goware/go-vcr/cassette"
+	"github.com/goware/go-vcr/recorder"
+)
+
+func TestFindConfig(t *testing.T) {
+	root := t.TempDir()
+	module := filepath.Join(root, "module")
+	pkg := filepath.Join(module, "pkg", "client")
+	if err := os.MkdirAll(pkg, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(module, "go.mod"), []byte("module example.com/module\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The search stops at the root of the module
+	if err := os.WriteFile(filepath.Join(root, recorder.ConfigFileName), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := recorder.FindConfig(pkg); err != nil || path != "" {
+		t.Fatalf("got config file %q, %v, want none", path, err)
+	}
+
+	want := filepath.Join(module, recorder.ConfigFileName)
+	if err := os.WriteFile(want, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := recorder.FindConfig(pkg); err != nil || path != want {
+		t.Fatalf("got config file %q, %v, want %q", path, err, want)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{name: "unknown field", config: "mdoe: replay\n", wantErr: "field mdoe not found"},
+		{name: "invalid mode", config: "mode: bogus\n", wantErr: "invalid mode"},
+		{name: "unknown preset", config: "redact:\n  presets: [cookies]\n", wantErr: "unknown redaction preset"},
+		{name: "unknown hash field", config: "matcher:\n  fields: [verb]\n", wantErr: "unknown hash field"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), recorder.ConfigFileName)
+			if err := os.WriteFile(path, []byte(test.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := recorder.LoadConfig(path); err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("got error %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestProjectConfig(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, recorder.ConfigFileName)
+	config := `mode: record-only
+cassette_dir: cassettes
+redact:
+  headers: [Authorization]
+matcher:
+  fields: [method, path]
+`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(recorder.EnvConfig, path)
+	t.Setenv(recorder.EnvMode, "")
+	t.Setenv(recorder.EnvCassetteDir, "")
+
+	get := func(rec *recorder.Recorder, query string) error {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/api?"+query, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := rec.GetDefaultClient().Do(req)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	rec, err := recorder.New("project_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Mode() != recorder.ModeRecordOnly {
+		t.Fatalf("got mode %s, want %s", rec.Mode(), recorder.ModeRecordOnly)
+	}
+
+	// The redaction configured by the project is kept, when the hooks are
+	// replaced
+	rec.ReplaceHooks()
+	if err := get(rec, "page=1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cassette.Load(filepath.Join(dir, "cassettes", "project_config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Interactions[0].Request.Headers.Get("Authorization"); got != recorder.RedactedValue {
+		t.Fatalf("got Authorization header %q, want it redacted", got)
+	}
+
+	// Options override the config, and requests are matched on the method
+	// and path only
+	rec, err = recorder.New("project_config", recorder.WithMode(recorder.ModeReplayOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+	if err := get(rec, "page=2"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("off", func(t *testing.T) {
+		t.Setenv(recorder.EnvConfig, "off")
+		_, err := recorder.New("project_config", recorder.WithMode(recorder.ModeReplayOnly))
+		if !errors.Is(err, cassette.ErrCassetteNotFound) {
+			t.Fatalf("got error %v, want %v", err, cassette.ErrCassetteNotFound)
+		}
+	})
+}
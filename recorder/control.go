@@ -0,0 +1,163 @@
+package recorder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// controlMode is the JSON representation of the recorder mode used by the
+// control API.
+type controlMode struct {
+	Mode string `json:"mode"`
+}
+
+// controlCassette is the JSON representation of the inserted cassette used
+// by the control API.
+type controlCassette struct {
+	Name         string `json:"name"`
+	Interactions int    `json:"interactions,omitempty"`
+	New          bool   `json:"new,omitempty"`
+}
+
+// controlInteraction is the JSON representation of an interaction used by
+// the control API.
+type controlInteraction struct {
+	ID       int    `json:"id"`
+	Method   string `json:"method"`
+	URL      string `json:"url"`
+	Code     int    `json:"code"`
+	Replayed bool   `json:"replayed"`
+}
+
+// controlError is the JSON representation of an error used by the control
+// API.
+type controlError struct {
+	Error string `json:"error"`
+}
+
+// ControlHandler returns an [http.Handler], which exposes an API for
+// managing the recorder remotely, e.g. by end-to-end test suites driving a
+// binary, which uses the recorder. The API has the following endpoints,
+// which accept and return JSON:
+//
+//	GET    /mode          returns the mode, e.g. {"mode": "replay"}
+//	PUT    /mode          switches the mode, see [Recorder.SetMode]
+//	GET    /cassette      returns the name of the inserted cassette
+//	PUT    /cassette      inserts the cassette {"name": "..."}, see [Recorder.InsertCassette]
+//	DELETE /cassette      ejects the cassette, see [Recorder.EjectCassette]
+//	GET    /interactions  lists the interactions of the inserted cassette
+//	POST   /reset         resets the inserted cassette, see [Recorder.ResetCassette]
+//
+// The handler can be mounted under a prefix using [http.StripPrefix]. It
+// performs no authentication, and must not be exposed publicly.
+func (rec *Recorder) ControlHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /mode", func(w http.ResponseWriter, r *http.Request) {
+		writeControlJSON(w, http.StatusOK, controlMode{Mode: rec.Mode().String()})
+	})
+
+	mux.HandleFunc("PUT /mode", func(w http.ResponseWriter, r *http.Request) {
+		var body controlMode
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeControlError(w, http.StatusBadRequest, err)
+			return
+		}
+		mode, err := ParseMode(body.Mode)
+		if err != nil {
+			writeControlError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := rec.SetMode(mode); err != nil {
+			writeControlError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeControlJSON(w, http.StatusOK, controlMode{Mode: mode.String()})
+	})
+
+	mux.HandleFunc("GET /cassette", func(w http.ResponseWriter, r *http.Request) {
+		c := rec.Cassette()
+		if c == nil {
+			writeControlError(w, http.StatusNotFound, ErrNoCassetteInserted)
+			return
+		}
+		writeControlJSON(w, http.StatusOK, controlCassette{
+			Name:         c.Name,
+			Interactions: len(rec.Interactions()),
+			New:          c.IsNew,
+		})
+	})
+
+	mux.HandleFunc("PUT /cassette", func(w http.ResponseWriter, r *http.Request) {
+		var body controlCassette
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeControlError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := rec.InsertCassette(body.Name); err != nil {
+			code := http.StatusInternalServerError
+			if errors.Is(err, ErrNoCassetteName) {
+				code = http.StatusBadRequest
+			}
+			writeControlError(w, code, err)
+			return
+		}
+		writeControlJSON(w, http.StatusOK, controlCassette{Name: rec.Cassette().Name})
+	})
+
+	mux.HandleFunc("DELETE /cassette", func(w http.ResponseWriter, r *http.Request) {
+		if err := rec.EjectCassette(); err != nil {
+			code := http.StatusInternalServerError
+			if errors.Is(err, ErrNoCassetteInserted) {
+				code = http.StatusConflict
+			}
+			writeControlError(w, code, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("GET /interactions", func(w http.ResponseWriter, r *http.Request) {
+		if rec.Cassette() == nil {
+			writeControlError(w, http.StatusNotFound, ErrNoCassetteInserted)
+			return
+		}
+		interactions := make([]controlInteraction, 0)
+		for _, i := range rec.Interactions() {
+			interactions = append(interactions, controlInteraction{
+				ID:       i.ID,
+				Method:   i.Request.Method,
+				URL:      i.Request.URL,
+				Code:     i.Response.Code,
+				Replayed: i.WasReplayed(),
+			})
+		}
+		writeControlJSON(w, http.StatusOK, interactions)
+	})
+
+	mux.HandleFunc("POST /reset", func(w http.ResponseWriter, r *http.Request) {
+		if err := rec.ResetCassette(); err != nil {
+			code := http.StatusInternalServerError
+			if errors.Is(err, ErrNoCassetteInserted) {
+				code = http.StatusConflict
+			}
+			writeControlError(w, code, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	return mux
+}
+
+func writeControlJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeControlError(w http.ResponseWriter, code int, err error) {
+	writeControlJSON(w, code, controlError{Error: fmt.Sprintf("recorder: %s", err)})
+}
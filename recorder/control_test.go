@@ -0,0 +1,124 @@
+package recorder_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/goware/go-vcr/recorder"
+)
+
+func TestControlHandler(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_control_handler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPath := cassPath + "_other"
+
+	rec, err := recorder.New(cassPath, recorder.WithMode(recorder.ModeRecordOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	control := httptest.NewServer(rec.ControlHandler())
+	defer control.Close()
+
+	call := func(method, path, body string, wantStatus int, v any) {
+		t.Helper()
+
+		req, err := http.NewRequest(method, control.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := control.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != wantStatus {
+			t.Fatalf("%s %s: got status %d, want %d: %s", method, path, resp.StatusCode, wantStatus, data)
+		}
+		if v != nil {
+			if err := json.Unmarshal(data, v); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	type interaction struct {
+		ID       int    `json:"id"`
+		Method   string `json:"method"`
+		Replayed bool   `json:"replayed"`
+	}
+
+	client := rec.GetDefaultClient()
+	ctx := context.Background()
+	tc := testCase{method: http.MethodGet, wantBody: "GET go-vcr\n", wantStatus: http.StatusOK, wantContentLength: 11, path: "/api/v1/foo"}
+
+	var mode struct {
+		Mode string `json:"mode"`
+	}
+	call(http.MethodGet, "/mode", "", http.StatusOK, &mode)
+	if mode.Mode != "record" {
+		t.Fatalf("got mode %q, want record", mode.Mode)
+	}
+
+	// Record an interaction
+	if err := tc.run(ctx, client, server.URL); err != nil {
+		t.Fatal(err)
+	}
+	var interactions []interaction
+	call(http.MethodGet, "/interactions", "", http.StatusOK, &interactions)
+	if len(interactions) != 1 || interactions[0].Method != http.MethodGet || interactions[0].Replayed {
+		t.Fatalf("unexpected interactions: %+v", interactions)
+	}
+
+	// Inserting another cassette saves the previous one
+	call(http.MethodPut, "/cassette", `{"name": "`+otherPath+`"}`, http.StatusOK, nil)
+	if _, err := os.Stat(cassPath + ".yaml"); err != nil {
+		t.Fatalf("cassette was not saved: %s", err)
+	}
+
+	// Requests fail with no cassette inserted
+	call(http.MethodDelete, "/cassette", "", http.StatusNoContent, nil)
+	call(http.MethodDelete, "/cassette", "", http.StatusConflict, nil)
+	call(http.MethodGet, "/interactions", "", http.StatusNotFound, nil)
+	if _, err := client.Get(server.URL); !errors.Is(err, recorder.ErrNoCassetteInserted) {
+		t.Fatalf("expected %v, got %v", recorder.ErrNoCassetteInserted, err)
+	}
+
+	// Replay the recorded interaction
+	call(http.MethodPut, "/mode", `{"mode": "replay"}`, http.StatusOK, nil)
+	call(http.MethodPut, "/mode", `{"mode": "bogus"}`, http.StatusBadRequest, nil)
+	call(http.MethodPut, "/cassette", `{"name": "`+cassPath+`"}`, http.StatusOK, nil)
+	server.Close()
+	if err := tc.run(ctx, client, server.URL); err != nil {
+		t.Fatal(err)
+	}
+	call(http.MethodGet, "/interactions", "", http.StatusOK, &interactions)
+	if len(interactions) != 1 || !interactions[0].Replayed {
+		t.Fatalf("unexpected interactions: %+v", interactions)
+	}
+
+	// Resetting the cassette resets the replay state
+	call(http.MethodPost, "/reset", "", http.StatusNoContent, nil)
+	call(http.MethodGet, "/interactions", "", http.StatusOK, &interactions)
+	if len(interactions) != 1 || interactions[0].Replayed {
+		t.Fatalf("unexpected interactions: %+v", interactions)
+	}
+}
@@ -0,0 +1,80 @@
+package recorder
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/goware/go-vcr/cassette"
+)
+
+// cookieExpiresPattern matches the Expires attribute of a Set-Cookie header.
+var cookieExpiresPattern = regexp.MustCompile(`(?i)(;\s*expires\s*=\s*)([^;]+)`)
+
+// cookieTimeFormats are the date formats used by the Expires attribute.
+var cookieTimeFormats = []string{
+	http.TimeFormat,
+	time.RFC1123,
+	"Mon, 02-Jan-2006 15:04:05 MST",
+	"Monday, 02-Jan-06 15:04:05 MST",
+	time.ANSIC,
+}
+
+// ShiftCookieExpiry returns a [HookFunc], which shifts the Expires attribute
+// of the Set-Cookie headers of replayed responses by the time elapsed since
+// the interaction was recorded. Without it, an [http.CookieJar] discards the
+// cookies of an old cassette as expired, and the client does not send them
+// with the next requests, as it did during recording. Cookies using the
+// Max-Age attribute are relative already, and are left as is.
+//
+// The hook must be registered as a [BeforeResponseReplayHook]. Interactions
+// without a recording time are left as is.
+func ShiftCookieExpiry() HookFunc {
+	return func(i *cassette.Interaction) error {
+		if !i.WasReplayed() || i.RecordedAt.IsZero() {
+			return nil
+		}
+
+		shift := time.Since(i.RecordedAt).Truncate(time.Second)
+		if shift <= 0 {
+			return nil
+		}
+
+		for k, values := range i.Response.Headers {
+			if http.CanonicalHeaderKey(k) != "Set-Cookie" {
+				continue
+			}
+			for idx, v := range values {
+				values[idx] = shiftCookieExpires(v, shift)
+			}
+		}
+
+		return nil
+	}
+}
+
+// WithShiftCookieExpiry is an [Option], which configures the [Recorder] to
+// shift the expiry of replayed cookies, so that a client cookie jar
+// accumulates the same cookies as during recording. See
+// [ShiftCookieExpiry] for details.
+func WithShiftCookieExpiry() Option {
+	return withBuiltinHook(ShiftCookieExpiry(), BeforeResponseReplayHook)
+}
+
+// shiftCookieExpires shifts the Expires attribute of the Set-Cookie header
+// by the given duration. Unparsable dates are left as is.
+func shiftCookieExpires(v string, shift time.Duration) string {
+	return cookieExpiresPattern.ReplaceAllStringFunc(v, func(attr string) string {
+		m := cookieExpiresPattern.FindStringSubmatch(attr)
+		date := strings.TrimSpace(m[2])
+		for _, layout := range cookieTimeFormats {
+			expires, err := time.Parse(layout, date)
+			if err != nil {
+				continue
+			}
+			return m[1] + expires.Add(shift).UTC().Format(http.TimeFormat)
+		}
+		return attr
+	})
+}
@@ -0,0 +1,107 @@
+package recorder_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goware/go-vcr/cassette"
+	"github.com/goware/go-vcr/recorder"
+)
+
+func TestShiftCookieExpiry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/", Expires: time.Now().Add(time.Hour)})
+		default:
+			if _, err := r.Cookie("session"); err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}
+	}))
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_shift_cookie_expiry")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := func(rec *recorder.Recorder) error {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return err
+		}
+		client := rec.GetDefaultClient()
+		client.Jar = jar
+
+		for _, path := range []string{"/login", "/me"} {
+			resp, err := client.Get(server.URL + path)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return errors.New(resp.Status)
+			}
+		}
+		return nil
+	}
+
+	rec, err := recorder.New(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session(rec); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pretend the cassette was recorded two days ago
+	c, err := cassette.Load(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range c.Interactions {
+		i.RecordedAt = i.RecordedAt.Add(-48 * time.Hour)
+		for idx, v := range i.Response.Headers.Values("Set-Cookie") {
+			before, _, _ := strings.Cut(v, "; Expires=")
+			i.Response.Headers["Set-Cookie"][idx] = before + "; Expires=" + time.Now().Add(-47*time.Hour).UTC().Format(http.TimeFormat)
+		}
+	}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err = recorder.New(cassPath, recorder.WithMode(recorder.ModeReplayOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session(rec); !errors.Is(err, cassette.ErrInteractionNotFound) {
+		t.Fatalf("expected expired cookie not to be sent, got %v", err)
+	}
+	rec.Stop()
+
+	rec, err = recorder.New(
+		cassPath,
+		recorder.WithMode(recorder.ModeReplayOnly),
+		recorder.WithShiftCookieExpiry(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+	if err := session(rec); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := rec.Interactions()[0].Response.Headers.Get("Set-Cookie"); !strings.Contains(got, time.Now().Add(-47*time.Hour).UTC().Format("02 Jan 2006")) {
+		t.Fatalf("recorded cookie must not be modified, got %q", got)
+	}
+}
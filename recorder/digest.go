@@ -0,0 +1,120 @@
+package recorder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// WithDigestOnly is an [Option], which configures the [Recorder] to record
+// only the SHA-256 digest and the length of the bodies of requests, whose
+// URL matches any of the given glob patterns, as described in
+// [WithPassthroughPattern], and of their responses. This keeps huge,
+// uninteresting bodies, e.g. artifact downloads, out of the cassette.
+//
+// Such requests are matched on the digest of their body, see
+// [cassette.Request.BodyDigest], so that a request with a different body is
+// not replayed. Their responses are replayed with a synthesized body of the
+// recorded length, which consists of zero bytes, see
+// [cassette.Response.BodyDigest]. The bodies of requests handled by the
+// [Recorder.HTTPMiddleware] are recorded as is.
+func WithDigestOnly(patterns ...string) Option {
+	return func(r *Recorder) {
+		r.digestOnly = append(r.digestOnly, compileGlobs(patterns)...)
+	}
+}
+
+// isDigestOnly returns true, if only the digests of the bodies of the
+// request and its response are recorded, see [WithDigestOnly].
+func (rec *Recorder) isDigestOnly(r *http.Request) bool {
+	return len(rec.digestOnly) > 0 && matchURL(r, rec.digestOnly)
+}
+
+// digestBody streams the body into a SHA-256 hash, and returns its digest
+// in the form "sha256:<hex>", and its length.
+func digestBody(body io.Reader) (string, int64, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, body)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+type digestOnlyBodyContextKey struct{}
+
+// digestOnlyBody is the body of a response, of which only the digest is
+// recorded, see [WithDigestOnly]. The body is written to a temporary file,
+// so that the client receives the real body while recording.
+type digestOnlyBody struct {
+	// path is the path of the temporary file, if the response was
+	// captured.
+	path string
+}
+
+// contextWithDigestOnlyBody returns a copy of the parent context carrying
+// the body of the response, of which only the digest is recorded.
+func contextWithDigestOnlyBody(parent context.Context, body *digestOnlyBody) context.Context {
+	return context.WithValue(parent, digestOnlyBodyContextKey{}, body)
+}
+
+// spoolResponseBody streams the body of the response to the request into a
+// SHA-256 hash, see [digestBody], while writing it to a temporary file, if
+// the context of the request carries a [digestOnlyBody].
+func spoolResponseBody(r *http.Request, body io.Reader) (string, int64, error) {
+	spooled, _ := r.Context().Value(digestOnlyBodyContextKey{}).(*digestOnlyBody)
+	if spooled == nil {
+		return digestBody(body)
+	}
+
+	f, err := os.CreateTemp("", "go-vcr-body-*")
+	if err != nil {
+		return "", 0, err
+	}
+	spooled.path = f.Name()
+
+	digest, n, err := digestBody(io.TeeReader(body, f))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to spool response body: %w", err)
+	}
+
+	return digest, n, nil
+}
+
+// open returns a reader of the spooled body, which removes the temporary
+// file, when closed.
+func (b *digestOnlyBody) open() (io.ReadCloser, error) {
+	f, err := os.Open(b.path)
+	if err != nil {
+		return nil, err
+	}
+	return &tempFileBody{File: f}, nil
+}
+
+// remove removes the temporary file, if any.
+func (b *digestOnlyBody) remove() {
+	if b.path != "" {
+		os.Remove(b.path)
+	}
+}
+
+// tempFileBody is a body read from a temporary file, which is removed, when
+// the body is closed.
+type tempFileBody struct {
+	*os.File
+}
+
+// Close implements io.Closer.
+func (b *tempFileBody) Close() error {
+	err := b.File.Close()
+	os.Remove(b.Name())
+	return err
+}
@@ -0,0 +1,107 @@
+package recorder_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/goware/go-vcr/cassette"
+	"github.com/goware/go-vcr/recorder"
+)
+
+func TestDigestOnly(t *testing.T) {
+	artifact := bytes.Repeat([]byte("artifact"), 1<<16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/artifacts/build.tar":
+			w.Write(artifact)
+		default:
+			body, _ := io.ReadAll(r.Body)
+			fmt.Fprintf(w, "%s %s", r.URL.Path, body)
+		}
+	}))
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_digest_only")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(rec *recorder.Recorder, path string) ([]byte, error) {
+		resp, err := rec.GetDefaultClient().Get(server.URL + path)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		return io.ReadAll(resp.Body)
+	}
+	upload := func(rec *recorder.Recorder, body string) ([]byte, error) {
+		resp, err := rec.GetDefaultClient().Post(server.URL+"/artifacts/upload", "text/plain", strings.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		return io.ReadAll(resp.Body)
+	}
+
+	rec, err := recorder.New(cassPath,
+		recorder.WithMode(recorder.ModeRecordOnly),
+		recorder.WithDigestOnly("*/artifacts/*"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := get(rec, "/artifacts/build.tar"); err != nil || !bytes.Equal(got, artifact) {
+		t.Fatalf("got body of %d bytes, %v, want the artifact", len(got), err)
+	}
+	if got, err := upload(rec, "report"); err != nil || string(got) != "/artifacts/upload report" {
+		t.Fatalf("got body %q, %v", got, err)
+	}
+	if got, err := get(rec, "/status"); err != nil || string(got) != "/status " {
+		t.Fatalf("got body %q, %v", got, err)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cassette.Load(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	download := c.Interactions[0].Response
+	if want := fmt.Sprintf("sha256:%x", sha256.Sum256(artifact)); download.Body != "" || download.BodyDigest != want || download.BodyLength != int64(len(artifact)) {
+		t.Errorf("got body %q, digest %q and length %d, want digest %q and length %d", download.Body, download.BodyDigest, download.BodyLength, want, len(artifact))
+	}
+	if req := c.Interactions[1].Request; req.Body != "" || req.BodyDigest != fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("report"))) {
+		t.Errorf("got request body %q and digest %q, want the digest only", req.Body, req.BodyDigest)
+	}
+	if resp := c.Interactions[2].Response; resp.Body != "/status " || resp.BodyDigest != "" {
+		t.Errorf("got body %q and digest %q, want the body only", resp.Body, resp.BodyDigest)
+	}
+
+	rec, err = recorder.New(cassPath,
+		recorder.WithMode(recorder.ModeReplayOnly),
+		recorder.WithDigestOnly("*/artifacts/*"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	// The body of the download is synthesized
+	if got, err := get(rec, "/artifacts/build.tar"); err != nil || !bytes.Equal(got, make([]byte, len(artifact))) {
+		t.Fatalf("got body of %d bytes, %v, want %d zero bytes", len(got), err, len(artifact))
+	}
+	if _, err := upload(rec, "report"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := upload(rec, "other report"); !errors.Is(err, cassette.ErrInteractionNotFound) {
+		t.Fatalf("got error %v, want %v", err, cassette.ErrInteractionNotFound)
+	}
+}
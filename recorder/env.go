@@ -0,0 +1,65 @@
+package recorder
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Environment variables, which override the configuration of a [Recorder]
+// created by [New], unless disabled using [WithEnvOverrides].
+const (
+	// EnvMode overrides the mode of the recorder. See [ParseMode] for the
+	// supported values, e.g. VCR_MODE=record.
+	EnvMode = "VCR_MODE"
+
+	// EnvCassetteDir specifies the directory, which relative cassette
+	// names are resolved against.
+	EnvCassetteDir = "VCR_CASSETTE_DIR"
+
+	// EnvOffline enables offline mode, when set to a true value, e.g.
+	// VCR_OFFLINE=1. See [WithOffline] for details.
+	EnvOffline = "VCR_OFFLINE"
+
+	// EnvConfig specifies the path of the project config file, which is
+	// used instead of searching for it, or "off" to not load any config
+	// file. See [Config] for details. It is read regardless of
+	// [WithEnvOverrides].
+	EnvConfig = "VCR_CONFIG"
+)
+
+// WithEnvOverrides is an [Option], which configures whether the [Recorder]
+// configuration may be overridden using the [EnvMode], [EnvCassetteDir] and
+// [EnvOffline] environment variables. Environment overrides are enabled by
+// default.
+func WithEnvOverrides(val bool) Option {
+	return func(r *Recorder) {
+		r.envOverrides = val
+	}
+}
+
+// applyEnvOverrides applies the configuration overrides specified in the
+// environment.
+func (rec *Recorder) applyEnvOverrides() error {
+	if val, ok := os.LookupEnv(EnvMode); ok && val != "" {
+		mode, err := ParseMode(val)
+		if err != nil {
+			return fmt.Errorf("invalid %s environment variable: %w", EnvMode, err)
+		}
+		rec.mode = mode
+	}
+
+	if dir := os.Getenv(EnvCassetteDir); dir != "" {
+		rec.cassetteDir = dir
+	}
+
+	if val, ok := os.LookupEnv(EnvOffline); ok && val != "" {
+		offline, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("invalid %s environment variable: %w", EnvOffline, err)
+		}
+		rec.offline = rec.offline || offline
+	}
+
+	return nil
+}
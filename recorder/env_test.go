@@ -0,0 +1,110 @@
+package recorder_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goware/go-vcr/cassette"
+	"github.com/goware/go-vcr/recorder"
+)
+
+func TestParseMode(t *testing.T) {
+	for _, mode := range []recorder.Mode{
+		recorder.ModeRecordOnly,
+		recorder.ModeReplayOnly,
+		recorder.ModeReplayWithNewEpisodes,
+		recorder.ModeRecordOnce,
+		recorder.ModePassthrough,
+		recorder.ModeRefresh,
+	} {
+		got, err := recorder.ParseMode(mode.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != mode {
+			t.Fatalf("got mode %s, want %s", got, mode)
+		}
+	}
+
+	if _, err := recorder.ParseMode("bogus"); !errors.Is(err, recorder.ErrInvalidMode) {
+		t.Fatalf("expected ErrInvalidMode, got %v", err)
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(recorder.EnvMode, "passthrough")
+	t.Setenv(recorder.EnvCassetteDir, dir)
+
+	t.Run("enabled", func(t *testing.T) {
+		rec, err := recorder.New("env_overrides", recorder.WithMode(recorder.ModeReplayWithNewEpisodes))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rec.Stop()
+
+		if rec.Mode() != recorder.ModePassthrough {
+			t.Fatalf("expected mode to be overridden, got %s", rec.Mode())
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		rec, err := recorder.New(
+			"env_overrides",
+			recorder.WithMode(recorder.ModeReplayWithNewEpisodes),
+			recorder.WithEnvOverrides(false),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rec.Stop()
+
+		if rec.Mode() != recorder.ModeReplayWithNewEpisodes {
+			t.Fatalf("expected mode not to be overridden, got %s", rec.Mode())
+		}
+	})
+
+	t.Run("cassette dir", func(t *testing.T) {
+		t.Setenv(recorder.EnvMode, "replay")
+		_, err := recorder.New("missing")
+		if err == nil {
+			t.Fatal("expected missing cassette error")
+		}
+		if want := filepath.Join(dir, "missing.yaml"); !errors.Is(err, cassette.ErrCassetteNotFound) || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected cassette to be resolved to %s, got %v", want, err)
+		}
+	})
+
+	t.Run("invalid mode", func(t *testing.T) {
+		t.Setenv(recorder.EnvMode, "bogus")
+		if _, err := recorder.New("env_overrides"); !errors.Is(err, recorder.ErrInvalidMode) {
+			t.Fatalf("expected ErrInvalidMode, got %v", err)
+		}
+	})
+}
+
+func TestEnvOffline(t *testing.T) {
+	t.Setenv(recorder.EnvOffline, "true")
+
+	cassPath, err := newCassettePath("test_env_offline")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := recorder.New(cassPath, recorder.WithMode(recorder.ModePassthrough))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	if _, err := rec.GetDefaultClient().Get("http://127.0.0.1:1/"); !errors.Is(err, recorder.ErrRealNetworkBlocked) {
+		t.Fatalf("expected ErrRealNetworkBlocked, got %v", err)
+	}
+
+	t.Setenv(recorder.EnvOffline, "bogus")
+	if _, err := recorder.New(cassPath); err == nil {
+		t.Fatal("expected invalid environment variable error")
+	}
+}
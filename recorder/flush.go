@@ -0,0 +1,95 @@
+package recorder
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// WithFlushOnSignal is an [Option], which configures the [Recorder] to stop,
+// saving the recorded interactions, when the process receives any of the
+// given signals, or os.Interrupt and SIGTERM, if none are given. The signal
+// is raised again afterwards, so that the process terminates as it would
+// without the handler. This prevents losing long recording sessions, when a
+// test binary is interrupted, e.g. by a timeout of the CI. The handler is
+// removed, when the recorder is stopped.
+func WithFlushOnSignal(signals ...os.Signal) Option {
+	return func(r *Recorder) {
+		if len(signals) == 0 {
+			signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+		}
+		r.flushSignals = signals
+	}
+}
+
+// watchSignals registers the handler for the signals configured using
+// [WithFlushOnSignal].
+func (rec *Recorder) watchSignals() {
+	if len(rec.flushSignals) == 0 {
+		return
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, rec.flushSignals...)
+
+	rec.stopSignals = func() {
+		signal.Stop(ch)
+		close(done)
+	}
+
+	go func() {
+		select {
+		case sig := <-ch:
+			if err := rec.Stop(); err != nil {
+				slog.Warn("failed to save recordings on signal", "signal", sig, "error", err)
+			}
+
+			// Terminate the process as without the handler.
+			p, err := os.FindProcess(os.Getpid())
+			if err == nil {
+				err = p.Signal(sig)
+			}
+			if err != nil {
+				os.Exit(1)
+			}
+
+		case <-done:
+		}
+	}()
+}
+
+// unwatchSignals removes the handler registered by [Recorder.watchSignals].
+func (rec *Recorder) unwatchSignals() {
+	rec.mu.Lock()
+	stop := rec.stopSignals
+	rec.stopSignals = nil
+	rec.mu.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+}
+
+// FlushOnPanic stops the recorder, saving the recorded interactions, if the
+// calling goroutine panics, and continues panicking afterwards. It must be
+// deferred directly, e.g. at the top of goroutines started by the code under
+// test, whose panics would otherwise terminate the process before the
+// recorder is stopped:
+//
+//	go func() {
+//		defer rec.FlushOnPanic()
+//		...
+//	}()
+//
+// Panics of the test goroutine itself are handled by [NewWithT], whose
+// cleanup stops the recorder.
+func (rec *Recorder) FlushOnPanic() {
+	if v := recover(); v != nil {
+		if err := rec.Stop(); err != nil {
+			slog.Warn("failed to save recordings on panic", "panic", v, "error", err)
+		}
+		panic(v)
+	}
+}
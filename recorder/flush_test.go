@@ -0,0 +1,105 @@
+//go:build unix
+
+package recorder_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/goware/go-vcr/cassette"
+	"github.com/goware/go-vcr/recorder"
+)
+
+func TestFlushOnPanic(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_flush_on_panic")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := recorder.New(cassPath, recorder.WithMode(recorder.ModeRecordOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc := testCase{method: http.MethodGet, wantBody: "GET go-vcr\n", wantStatus: http.StatusOK, wantContentLength: 11, path: "/api/v1/foo"}
+	panicked := make(chan any)
+	go func() {
+		defer func() {
+			panicked <- recover()
+		}()
+		defer rec.FlushOnPanic()
+
+		if err := tc.run(context.Background(), rec.GetDefaultClient(), server.URL); err != nil {
+			t.Error(err)
+		}
+		panic("boom")
+	}()
+
+	if v := <-panicked; v != "boom" {
+		t.Fatalf("got panic %v, want boom", v)
+	}
+
+	c, err := cassette.Load(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Interactions) != 1 {
+		t.Fatalf("got %d interactions, want 1", len(c.Interactions))
+	}
+}
+
+func TestFlushOnSignal(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_flush_on_signal")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Catch the signal raised again by the recorder, which would terminate
+	// the test otherwise.
+	caught := make(chan os.Signal, 2)
+	signal.Notify(caught, syscall.SIGUSR1)
+	defer signal.Stop(caught)
+
+	rec, err := recorder.New(cassPath, recorder.WithMode(recorder.ModeRecordOnly), recorder.WithFlushOnSignal(syscall.SIGUSR1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	tc := testCase{method: http.MethodGet, wantBody: "GET go-vcr\n", wantStatus: http.StatusOK, wantContentLength: 11, path: "/api/v1/foo"}
+	if err := tc.run(context.Background(), rec.GetDefaultClient(), server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+
+	// The signal is received once when sent, and once when raised again.
+	for range 2 {
+		select {
+		case <-caught:
+		case <-time.After(5 * time.Second):
+			t.Fatal("signal was not raised again")
+		}
+	}
+
+	c, err := cassette.Load(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Interactions) != 1 {
+		t.Fatalf("got %d interactions, want 1", len(c.Interactions))
+	}
+}
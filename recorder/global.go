@@ -0,0 +1,82 @@
+package recorder
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+)
+
+var (
+	// ErrGlobalAlreadyInstalled is returned when attempting to install a
+	// recorder as the global transport, while another one is installed.
+	ErrGlobalAlreadyInstalled = errors.New("a recorder is already installed as the global transport")
+
+	// ErrGlobalNotInstalled is returned when attempting to uninstall a
+	// recorder, which is not installed as the global transport.
+	ErrGlobalNotInstalled = errors.New("recorder is not installed as the global transport")
+)
+
+// global tracks the recorder installed as [http.DefaultTransport].
+var global struct {
+	sync.Mutex
+	recorder  *Recorder
+	transport http.RoundTripper
+}
+
+// InstallGlobal replaces [http.DefaultTransport] with the recorder, so that
+// HTTP clients, which were not created using [Recorder.GetDefaultClient],
+// e.g. http.DefaultClient or a zero-value http.Client, are recorded as well.
+// Only one recorder may be installed at a time, and
+// [ErrGlobalAlreadyInstalled] is returned when attempting to install
+// another one. The original transport is restored by
+// [Recorder.UninstallGlobal], or when the recorder is stopped.
+//
+// Since [http.DefaultTransport] is shared by the whole process, the recorder
+// should not be installed while requests are in flight, e.g. from parallel
+// tests.
+func (rec *Recorder) InstallGlobal() error {
+	global.Lock()
+	defer global.Unlock()
+
+	if global.recorder != nil {
+		return ErrGlobalAlreadyInstalled
+	}
+
+	global.recorder = rec
+	global.transport = http.DefaultTransport
+	http.DefaultTransport = rec
+
+	return nil
+}
+
+// UninstallGlobal restores the [http.DefaultTransport], which was replaced
+// by [Recorder.InstallGlobal]. It returns [ErrGlobalNotInstalled] if the
+// recorder is not installed.
+func (rec *Recorder) UninstallGlobal() error {
+	global.Lock()
+	defer global.Unlock()
+
+	if global.recorder != rec {
+		return ErrGlobalNotInstalled
+	}
+
+	http.DefaultTransport = global.transport
+	global.recorder = nil
+	global.transport = nil
+
+	return nil
+}
+
+// defaultRealTransport returns the transport to use for real requests by
+// default. When a recorder is installed globally, the original
+// [http.DefaultTransport] is returned, so that recorders are not chained.
+func defaultRealTransport() http.RoundTripper {
+	global.Lock()
+	defer global.Unlock()
+
+	if global.recorder != nil {
+		return global.transport
+	}
+
+	return http.DefaultTransport
+}
@@ -0,0 +1,65 @@
+package recorder_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/goware/go-vcr/cassette"
+	"github.com/goware/go-vcr/recorder"
+)
+
+func TestInstallGlobal(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_install_global")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := http.DefaultTransport
+	rec, err := recorder.New(cassPath, recorder.WithMode(recorder.ModeRecordOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rec.InstallGlobal(); err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := recorder.New(cassPath, recorder.WithMode(recorder.ModePassthrough))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.InstallGlobal(); !errors.Is(err, recorder.ErrGlobalAlreadyInstalled) {
+		t.Fatalf("expected ErrGlobalAlreadyInstalled, got %v", err)
+	}
+	if err := other.UninstallGlobal(); !errors.Is(err, recorder.ErrGlobalNotInstalled) {
+		t.Fatalf("expected ErrGlobalNotInstalled, got %v", err)
+	}
+
+	// A zero-value client uses the default transport
+	resp, err := (&http.Client{}).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if http.DefaultTransport != original {
+		t.Fatal("default transport was not restored when stopping the recorder")
+	}
+
+	c, err := cassette.Load(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(c.Interactions); n != 1 {
+		t.Fatalf("expected 1 recorded interaction, got %d", n)
+	}
+}
@@ -0,0 +1,164 @@
+package recorder
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/goware/go-vcr/cassette"
+)
+
+// HookID identifies a hook added at runtime using [Recorder.AddHook].
+type HookID uint64
+
+// InteractionPredicate is a predicate, which determines whether a hook
+// applies to an interaction, see [WithHookIf].
+type InteractionPredicate func(i *cassette.Interaction) bool
+
+// WithHookIf is an [Option], which configures the [Recorder] to invoke the
+// provided hook at the specified playback stage, but only for interactions
+// satisfying the predicate, e.g. [MatchHosts], [MatchPattern] or
+// [MatchContentType].
+func WithHookIf(predicate InteractionPredicate, handler HookFunc, kind HookKind) Option {
+	return WithHook(func(i *cassette.Interaction) error {
+		if !predicate(i) {
+			return nil
+		}
+		return handler(i)
+	}, kind)
+}
+
+// MatchHosts returns an [InteractionPredicate], which is satisfied by the
+// interactions with a request to any of the given hosts. Hosts are matched
+// as described in [WithOnlyHosts].
+func MatchHosts(hosts ...string) InteractionPredicate {
+	return func(i *cassette.Interaction) bool {
+		r, ok := interactionRequest(i)
+		return ok && matchHost(r, hosts)
+	}
+}
+
+// MatchPattern returns an [InteractionPredicate], which is satisfied by the
+// interactions with a request URL matching any of the given glob patterns,
+// as described in [WithPassthroughPattern].
+func MatchPattern(patterns ...string) InteractionPredicate {
+	res := compileGlobs(patterns)
+	return func(i *cassette.Interaction) bool {
+		r, ok := interactionRequest(i)
+		return ok && matchURL(r, res)
+	}
+}
+
+// MatchContentType returns an [InteractionPredicate], which is satisfied by
+// the interactions with a response of any of the given media types, e.g.
+// "application/json". Parameters of the Content-Type header are ignored.
+func MatchContentType(mediaTypes ...string) InteractionPredicate {
+	return func(i *cassette.Interaction) bool {
+		mediaType, _, err := mime.ParseMediaType(i.Response.Headers.Get("Content-Type"))
+		if err != nil {
+			return false
+		}
+		return slices.ContainsFunc(mediaTypes, func(t string) bool {
+			return strings.EqualFold(t, mediaType)
+		})
+	}
+}
+
+// interactionRequest returns a request with the URL and host of the
+// recorded request, for matching it like a live request.
+func interactionRequest(i *cassette.Interaction) (*http.Request, bool) {
+	u, err := url.Parse(i.Request.URL)
+	if err != nil {
+		return nil, false
+	}
+	return &http.Request{URL: u, Host: i.Request.Host}, true
+}
+
+// newBuiltinHook creates a new hook, which implements one of the options of
+// the recorder, and which is kept by [Recorder.ReplaceHooks].
+func newBuiltinHook(handler HookFunc, kind HookKind) *Hook {
+	hook := NewHook(handler, kind)
+	hook.builtin = true
+	return hook
+}
+
+// withBuiltinHook is an [Option], which configures the [Recorder] to invoke
+// the provided hook implementing one of its options, see [newBuiltinHook].
+func withBuiltinHook(handler HookFunc, kind HookKind) Option {
+	return func(r *Recorder) {
+		r.hooks = append(r.hooks, newBuiltinHook(handler, kind))
+	}
+}
+
+// AddHook registers the hook at runtime, e.g. for a single subtest of a
+// recorder shared between subtests. The hook is invoked after the hooks of
+// the same kind, which were registered before. The returned id removes the
+// hook again using [Recorder.RemoveHook].
+func (rec *Recorder) AddHook(hook *Hook) HookID {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.nextHookID++
+	h := *hook
+	h.id, h.builtin = rec.nextHookID, false
+	rec.setHooksLocked(append(rec.userHooksLocked(), &h))
+
+	return h.id
+}
+
+// RemoveHook removes the hook, which was registered using
+// [Recorder.AddHook]. It reports whether the hook was found.
+func (rec *Recorder) RemoveHook(id HookID) bool {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	hooks := rec.userHooksLocked()
+	n := len(hooks)
+	hooks = slices.DeleteFunc(hooks, func(h *Hook) bool {
+		return h.id == id
+	})
+	rec.setHooksLocked(hooks)
+
+	return len(hooks) != n
+}
+
+// ReplaceHooks replaces the hooks of the recorder, including those
+// configured using [WithHook] and added using [Recorder.AddHook], with the
+// given hooks. The hooks implementing options of the recorder, e.g.
+// [WithStripQueryParams] or [WithPrettyPrint], are kept.
+func (rec *Recorder) ReplaceHooks(hooks ...*Hook) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.setHooksLocked(slices.Clone(hooks))
+}
+
+// userHooksLocked returns a copy of the hooks, which do not implement
+// options of the recorder. The caller must hold the lock.
+func (rec *Recorder) userHooksLocked() []*Hook {
+	return slices.DeleteFunc(slices.Clone(rec.hooks), func(h *Hook) bool {
+		return h.builtin
+	})
+}
+
+// setHooksLocked sets the hooks of the recorder, keeping the hooks
+// implementing its options, which run before the other hooks after capture,
+// and after the other hooks before save. The hooks are replaced instead of
+// modified, as they may be applied concurrently. The caller must hold the
+// lock.
+func (rec *Recorder) setHooksLocked(hooks []*Hook) {
+	var leading, trailing []*Hook
+	for _, h := range rec.hooks {
+		switch {
+		case !h.builtin:
+		case h.Kind == AfterCaptureHook:
+			leading = append(leading, h)
+		default:
+			trailing = append(trailing, h)
+		}
+	}
+
+	rec.hooks = slices.Concat(leading, hooks, trailing)
+}
@@ -0,0 +1,195 @@
+package recorder_test
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/goware/go-vcr/cassette"
+	"github.com/goware/go-vcr/recorder"
+)
+
+func TestRuntimeHooks(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_runtime_hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	markHook := func(mark string) recorder.HookFunc {
+		return func(i *cassette.Interaction) error {
+			i.SetMetadata(mark, "true")
+			return nil
+		}
+	}
+	rec, err := recorder.New(cassPath,
+		recorder.WithStripQueryParams("token"),
+		recorder.WithHook(markHook("a"), recorder.AfterCaptureHook),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	request := func(path string) []string {
+		t.Helper()
+		test := testCase{
+			method:            http.MethodGet,
+			wantBody:          "GET go-vcr\n",
+			wantStatus:        http.StatusOK,
+			wantContentLength: 11,
+			path:              path + "?token=secret",
+		}
+		if err := test.run(context.Background(), rec.GetDefaultClient(), server.URL); err != nil {
+			t.Fatal(err)
+		}
+
+		interactions := rec.Interactions()
+		i := interactions[len(interactions)-1]
+		if i.Request.URL != server.URL+path {
+			t.Fatalf("got URL %q, want the token to be stripped", i.Request.URL)
+		}
+		var marks []string
+		for mark := range i.Metadata {
+			marks = append(marks, mark)
+		}
+		slices.Sort(marks)
+		return marks
+	}
+
+	if got := request("/1"); !slices.Equal(got, []string{"a"}) {
+		t.Fatalf("got marks %q, want [a]", got)
+	}
+
+	id := rec.AddHook(recorder.NewHook(markHook("b"), recorder.AfterCaptureHook))
+	if got := request("/2"); !slices.Equal(got, []string{"a", "b"}) {
+		t.Fatalf("got marks %q, want [a b]", got)
+	}
+
+	if !rec.RemoveHook(id) {
+		t.Fatal("expected hook to be removed")
+	}
+	if rec.RemoveHook(id) {
+		t.Fatal("expected removed hook not to be found")
+	}
+	if got := request("/3"); !slices.Equal(got, []string{"a"}) {
+		t.Fatalf("got marks %q, want [a]", got)
+	}
+
+	// Replacing the hooks keeps the hooks implementing options
+	rec.ReplaceHooks(recorder.NewHook(markHook("c"), recorder.AfterCaptureHook))
+	if got := request("/4"); !slices.Equal(got, []string{"c"}) {
+		t.Fatalf("got marks %q, want [c]", got)
+	}
+}
+
+func TestHookIf(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_hook_if")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	markHook := func(mark string) recorder.HookFunc {
+		return func(i *cassette.Interaction) error {
+			i.SetMetadata(mark, "true")
+			return nil
+		}
+	}
+	rec, err := recorder.New(cassPath,
+		recorder.WithHookIf(recorder.MatchPattern("*/api/*"), markHook("api"), recorder.AfterCaptureHook),
+		recorder.WithHookIf(recorder.MatchContentType("text/plain"), markHook("text"), recorder.AfterCaptureHook),
+		recorder.WithHookIf(recorder.MatchContentType("application/json"), markHook("json"), recorder.AfterCaptureHook),
+		recorder.WithHookIf(recorder.MatchHosts("127.0.0.1"), markHook("local"), recorder.AfterCaptureHook),
+		recorder.WithHookIf(recorder.MatchHosts("*.example.com"), markHook("example"), recorder.AfterCaptureHook),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	tests := []struct {
+		path      string
+		wantMarks []string
+	}{
+		{path: "/api/v1/foo", wantMarks: []string{"api", "local", "text"}},
+		{path: "/health", wantMarks: []string{"local", "text"}},
+	}
+	for _, test := range tests {
+		tc := testCase{
+			method:            http.MethodGet,
+			wantBody:          "GET go-vcr\n",
+			wantStatus:        http.StatusOK,
+			wantContentLength: 11,
+			path:              test.path,
+		}
+		if err := tc.run(context.Background(), rec.GetDefaultClient(), server.URL); err != nil {
+			t.Fatal(err)
+		}
+
+		interactions := rec.Interactions()
+		var marks []string
+		for mark := range interactions[len(interactions)-1].Metadata {
+			marks = append(marks, mark)
+		}
+		slices.Sort(marks)
+		if !slices.Equal(marks, test.wantMarks) {
+			t.Fatalf("got marks %q for %s, want %q", marks, test.path, test.wantMarks)
+		}
+	}
+}
+
+func TestReplaceHooksKeepsOptions(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_replace_hooks_keeps_options")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := recorder.New(cassPath,
+		recorder.WithAnonymizePII(),
+		recorder.WithHook(recorder.RedactHeaders("X-Api-Key"), recorder.BeforeSaveHook),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replacing the hooks drops the redaction of the header, but keeps the
+	// anonymization configured by the option
+	rec.ReplaceHooks()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Api-Key", "secret")
+	req.Header.Set("X-User", "jane@corp.io")
+	resp, err := rec.GetDefaultClient().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cassette.Load(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := c.Interactions[0]
+	if got := i.Request.Headers.Get("X-User"); strings.Contains(got, "jane") {
+		t.Fatalf("got header %q, want the email address to be anonymized", got)
+	}
+	if got := i.Request.Headers.Get("X-Api-Key"); got != "secret" {
+		t.Fatalf("got header %q, want the replaced hook not to be applied", got)
+	}
+}
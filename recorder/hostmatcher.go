@@ -0,0 +1,59 @@
+package recorder
+
+import (
+	"net/http"
+
+	"github.com/goware/go-vcr/cassette"
+)
+
+// WithHostMatcher is an [Option], which configures the [Recorder] to match
+// requests to the given hosts using the provided [cassette.RequestMatcher],
+// instead of the matcher configured using [WithMatcher], e.g. strictly for
+// the own API, and loosely on the method and path only for third parties:
+//
+//	recorder.WithHostMatcher(
+//		cassette.NewMatcher(cassette.WithHashFields(cassette.HashMethod|cassette.HashPath)),
+//		"*.stripe.com", "api.github.com",
+//	)
+//
+// Hosts are matched as described in [WithOnlyHosts]. The hashes are
+// prefixed with the host of the request, so that loose matchers shared by
+// several hosts do not replay the interactions of one host for another. If
+// the option is used multiple times, the matcher of the first matching hosts
+// is used. The query parameters, headers and normalizers configured for the
+// recorder apply to all matchers.
+func WithHostMatcher(matcher cassette.RequestMatcher, hosts ...string) Option {
+	return func(r *Recorder) {
+		r.hostMatchers = append(r.hostMatchers, hostMatcher{hosts: hosts, matcher: matcher})
+	}
+}
+
+// hostMatcher is a matcher for requests to the given hosts, see
+// [WithHostMatcher].
+type hostMatcher struct {
+	hosts   []string
+	matcher cassette.RequestMatcher
+}
+
+// hostRoutingMatcher passes requests to the matcher of the first matching
+// hosts, or to the wrapped matcher.
+type hostRoutingMatcher struct {
+	matcher cassette.RequestMatcher
+	hosts   []hostMatcher
+}
+
+// Hash implements cassette.RequestMatcher.
+func (m *hostRoutingMatcher) Hash(r *http.Request) (string, error) {
+	for _, h := range m.hosts {
+		if !matchHost(r, h.hosts) {
+			continue
+		}
+		hash, err := h.matcher.Hash(r)
+		if err != nil {
+			return "", err
+		}
+		host, _ := requestHost(r)
+		return host + ":" + hash, nil
+	}
+	return m.matcher.Hash(r)
+}
@@ -0,0 +1,131 @@
+package recorder_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/goware/go-vcr/cassette"
+	"github.com/goware/go-vcr/recorder"
+)
+
+func TestHostMatcher(t *testing.T) {
+	ownAPI := newEchoHttpServer()
+	defer ownAPI.Close()
+	thirdParty := newEchoHttpServer()
+	defer thirdParty.Close()
+
+	cassPath, err := newCassettePath("test_host_matcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := []recorder.Option{
+		recorder.WithHostMatcher(
+			cassette.NewMatcher(cassette.WithHashFields(cassette.HashMethod|cassette.HashPath)),
+			strings.TrimPrefix(thirdParty.URL, "http://"),
+		),
+	}
+	get := func(rec *recorder.Recorder, url string) error {
+		resp, err := rec.GetDefaultClient().Get(url)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	rec, err := recorder.New(cassPath, append(opts, recorder.WithMode(recorder.ModeRecordOnly))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, url := range []string{ownAPI.URL + "/items?page=1", thirdParty.URL + "/items?page=1"} {
+		if err := get(rec, url); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err = recorder.New(cassPath, append(opts, recorder.WithMode(recorder.ModeReplayOnly), recorder.WithReplayableInteractions(true))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	// Requests to the third party are matched on the method and path only
+	if err := get(rec, thirdParty.URL+"/items?page=2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := get(rec, ownAPI.URL+"/items?page=1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := get(rec, ownAPI.URL+"/items?page=2"); !errors.Is(err, cassette.ErrInteractionNotFound) {
+		t.Fatalf("got error %v, want %v", err, cassette.ErrInteractionNotFound)
+	}
+}
+
+func TestHostMatcherSharedByHosts(t *testing.T) {
+	newServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, name)
+		}))
+	}
+	github := newServer("github")
+	defer github.Close()
+	stripe := newServer("stripe")
+	defer stripe.Close()
+
+	cassPath, err := newCassettePath("test_host_matcher_shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := []recorder.Option{
+		recorder.WithHostMatcher(
+			cassette.NewMatcher(cassette.WithHashFields(cassette.HashMethod|cassette.HashPath)),
+			strings.TrimPrefix(github.URL, "http://"), strings.TrimPrefix(stripe.URL, "http://"),
+		),
+	}
+	get := func(rec *recorder.Recorder, url string) string {
+		t.Helper()
+		resp, err := rec.GetDefaultClient().Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+
+	rec, err := recorder.New(cassPath, append(opts, recorder.WithMode(recorder.ModeRecordOnly))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get(rec, stripe.URL+"/v1/users")
+	get(rec, github.URL+"/v1/users")
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err = recorder.New(cassPath, append(opts, recorder.WithMode(recorder.ModeReplayOnly))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	// Requests with the same method and path are matched per host
+	if body := get(rec, github.URL+"/v1/users"); body != "github" {
+		t.Fatalf("got body %q, want %q", body, "github")
+	}
+	if body := get(rec, stripe.URL+"/v1/users"); body != "stripe" {
+		t.Fatalf("got body %q, want %q", body, "stripe")
+	}
+}
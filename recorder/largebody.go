@@ -0,0 +1,187 @@
+package recorder
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goware/go-vcr/cassette"
+)
+
+// LargeBodyPolicy specifies how request bodies exceeding the limit
+// configured with [WithLargeBodyLimit] are recorded.
+type LargeBodyPolicy int
+
+const (
+	// LargeBodySample records the digest of the body, and a sample of its
+	// first bytes up to the limit.
+	LargeBodySample LargeBodyPolicy = iota
+
+	// LargeBodySpill records the digest of the body, and writes the whole
+	// body to a file next to the cassette, see [cassette.Request.BodyFile].
+	LargeBodySpill
+)
+
+// WithLargeBodyLimit is an [Option], which configures the [Recorder] to
+// stream request bodies larger than limit bytes to a temporary file, instead
+// of holding them in memory, e.g. for file uploads or backups. Such requests
+// are matched on the digest of their body, see
+// [cassette.Request.BodyDigest], and the body is recorded according to the
+// policy. A limit of zero, which is the default, disables the streaming.
+//
+// The limit applies to requests sent by the client only, and not to the
+// requests handled by the [Recorder.HTTPMiddleware].
+func WithLargeBodyLimit(limit int64, policy LargeBodyPolicy) Option {
+	return func(r *Recorder) {
+		r.largeBodyLimit = limit
+		r.largeBodyPolicy = policy
+	}
+}
+
+type spooledBodyContextKey struct{}
+
+// spooledBody is a large or multipart/form-data request body, which was
+// written to a temporary file.
+type spooledBody struct {
+	// path is the path of the temporary file.
+	path string
+
+	// digest is the digest of the body, in the form "sha256:<hex>".
+	digest string
+
+	// sample holds the first bytes of the body up to the limit.
+	sample []byte
+
+	// digestOnly specifies whether only the digest of the body is
+	// recorded, see [WithDigestOnly].
+	digestOnly bool
+
+	// header is the header of the request sent to the server, if the
+	// request is matched using a different header.
+	header http.Header
+
+	// parts are the parts of a multipart/form-data body, see
+	// [WithMultipartParts].
+	parts []spooledPart
+}
+
+// open returns a reader of the body.
+func (b *spooledBody) open() (io.ReadCloser, error) {
+	return os.Open(b.path)
+}
+
+// spooledBodyFromContext returns the spooled body of the request carrying
+// the context, or nil.
+func spooledBodyFromContext(ctx context.Context) *spooledBody {
+	body, _ := ctx.Value(spooledBodyContextKey{}).(*spooledBody)
+	return body
+}
+
+// spoolBody writes the body of the request to a temporary file, if it
+// exceeds the configured limit, or if only its digest is recorded, see
+// [WithDigestOnly], while computing its digest. The returned
+// request carries the spooled body in its context, and its body is replaced
+// with the digest, which it is matched on. The returned function removes the
+// temporary file. Multipart bodies are spooled as described in
+// [spoolMultipartBody], if their parts are recorded.
+func (rec *Recorder) spoolBody(r *http.Request) (*http.Request, func(), error) {
+	noop := func() {}
+	if r.Body == nil || r.Body == http.NoBody {
+		return r, noop, nil
+	}
+
+	limit := rec.largeBodyLimit
+	digestOnly := rec.isDigestOnly(r)
+	if !digestOnly && rec.multipartParts {
+		if boundary, contentType, ok := multipartBoundary(r); ok {
+			return spoolMultipartBody(r, boundary, contentType)
+		}
+	}
+	if digestOnly {
+		limit = 0
+	} else if limit <= 0 {
+		return r, noop, nil
+	}
+	if r.ContentLength > 0 && r.ContentLength <= limit {
+		return r, noop, nil
+	}
+
+	// Bodies of unknown length may not exceed the limit after all
+	head, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return nil, noop, err
+	}
+	r = r.WithContext(r.Context())
+	if int64(len(head)) <= limit {
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(head), r.Body))
+		return r, noop, nil
+	}
+
+	f, err := os.CreateTemp("", "go-vcr-body-*")
+	if err != nil {
+		return nil, noop, err
+	}
+	cleanup := func() { os.Remove(f.Name()) }
+
+	h := sha256.New()
+	w := io.MultiWriter(f, h)
+	_, err = w.Write(head)
+	if err == nil {
+		_, err = io.Copy(w, r.Body)
+	}
+	r.Body.Close()
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return nil, noop, fmt.Errorf("failed to spool request body: %w", err)
+	}
+
+	spooled := &spooledBody{
+		path:       f.Name(),
+		digest:     "sha256:" + hex.EncodeToString(h.Sum(nil)),
+		sample:     head[:limit],
+		digestOnly: digestOnly,
+	}
+	r = r.WithContext(context.WithValue(r.Context(), spooledBodyContextKey{}, spooled))
+	r.Body = io.NopCloser(strings.NewReader(spooled.digest))
+
+	return r, cleanup, nil
+}
+
+// recordSpooledBody records the spooled body of the interaction according
+// to the configured policy.
+func (rec *Recorder) recordSpooledBody(c *cassette.Cassette, i *cassette.Interaction, spooled *spooledBody) error {
+	if spooled.parts != nil {
+		return recordParts(c, i, spooled)
+	}
+
+	i.Request.BodyDigest = spooled.digest
+	if spooled.digestOnly {
+		i.Request.Body = ""
+		return nil
+	}
+	if rec.largeBodyPolicy != LargeBodySpill {
+		i.Request.Body = string(spooled.sample)
+		return nil
+	}
+
+	// Bodies are stored by their digest, so that repeated uploads of the
+	// same body share the file.
+	i.Request.Body = ""
+	i.Request.BodyFile = filepath.ToSlash(filepath.Join(filepath.Base(c.Name)+".bodies", strings.TrimPrefix(spooled.digest, "sha256:")))
+	path := filepath.Join(filepath.Dir(c.File()), filepath.FromSlash(i.Request.BodyFile))
+	if err := copyFile(spooled.path, path); err != nil {
+		return fmt.Errorf("failed to write request body file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package recorder_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goware/go-vcr/cassette"
+	"github.com/goware/go-vcr/recorder"
+)
+
+func TestLargeBodyLimit(t *testing.T) {
+	const size = 8 << 20
+	const limit = 1024
+
+	// newBody returns a body of unknown length, which is not held in
+	// memory, and its digest.
+	newBody := func(seed int64) (func() io.Reader, string) {
+		h := sha256.New()
+		io.Copy(h, io.LimitReader(rand.New(rand.NewSource(seed)), size))
+		return func() io.Reader {
+			return io.LimitReader(rand.New(rand.NewSource(seed)), size)
+		}, "sha256:" + hex.EncodeToString(h.Sum(nil))
+	}
+	body, digest := newBody(1)
+	otherBody, _ := newBody(2)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := sha256.New()
+		n, _ := io.Copy(h, r.Body)
+		fmt.Fprintf(w, "%d sha256:%x", n, h.Sum(nil))
+	}))
+	defer server.Close()
+
+	upload := func(rec *recorder.Recorder, body io.Reader) (string, error) {
+		resp, err := rec.GetDefaultClient().Post(server.URL+"/upload", "application/octet-stream", body)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		return string(data), err
+	}
+	want := fmt.Sprintf("%d %s", size, digest)
+
+	policies := map[string]recorder.LargeBodyPolicy{
+		"sample": recorder.LargeBodySample,
+		"spill":  recorder.LargeBodySpill,
+	}
+	for name, policy := range policies {
+		t.Run(name, func(t *testing.T) {
+			cassPath, err := newCassettePath("test_large_body_limit")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rec, err := recorder.New(cassPath,
+				recorder.WithMode(recorder.ModeRecordOnly),
+				recorder.WithLargeBodyLimit(limit, policy),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err := upload(rec, body()); err != nil || got != want {
+				t.Fatalf("got response %q, %v, want %q", got, err, want)
+			}
+			// Bodies within the limit are recorded as is
+			if got, err := upload(rec, strings.NewReader("small")); err != nil || !strings.HasPrefix(got, "5 ") {
+				t.Fatalf("got response %q, %v", got, err)
+			}
+			if err := rec.Stop(); err != nil {
+				t.Fatal(err)
+			}
+
+			c, err := cassette.Load(cassPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := c.Interactions[0].Request
+			if req.BodyDigest != digest {
+				t.Errorf("got body digest %q, want %q", req.BodyDigest, digest)
+			}
+			switch policy {
+			case recorder.LargeBodySample:
+				if len(req.Body) != limit || req.BodyFile != "" {
+					t.Errorf("got body of %d bytes and body file %q, want sample of %d bytes", len(req.Body), req.BodyFile, limit)
+				}
+			case recorder.LargeBodySpill:
+				info, err := os.Stat(filepath.Join(filepath.Dir(c.File()), req.BodyFile))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if req.Body != "" || info.Size() != size {
+					t.Errorf("got body of %d bytes and body file of %d bytes, want body file of %d bytes", len(req.Body), info.Size(), size)
+				}
+			}
+			if small := c.Interactions[1].Request; small.Body != "small" || small.BodyDigest != "" {
+				t.Errorf("got body %q and digest %q, want body %q", small.Body, small.BodyDigest, "small")
+			}
+
+			// Replay the large body, which is matched on its digest
+			rec, err = recorder.New(cassPath,
+				recorder.WithMode(recorder.ModeReplayOnly),
+				recorder.WithLargeBodyLimit(limit, policy),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rec.Stop()
+
+			if got, err := upload(rec, body()); err != nil || got != want {
+				t.Fatalf("got replayed response %q, %v, want %q", got, err, want)
+			}
+			if _, err := upload(rec, otherBody()); !errors.Is(err, cassette.ErrInteractionNotFound) {
+				t.Fatalf("got error %v, want %v", err, cassette.ErrInteractionNotFound)
+			}
+		})
+	}
+}
@@ -1,35 +1,199 @@
 package recorder
 
 import (
+	"bufio"
 	"bytes"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"sync"
 )
 
-// bufferPool reduces allocations for request body buffering in the middleware.
+// bufferPool reduces allocations for buffering request bodies in the
+// middleware, and for reading response bodies while recording.
 var bufferPool = sync.Pool{
 	New: func() any {
 		return new(bytes.Buffer)
 	},
 }
 
+// maxPooledBufferSize is the size of the largest buffer returned to the
+// pool, so that the pool does not retain the buffers of large bodies.
+const maxPooledBufferSize = 1 << 20
+
+// getBuffer returns an empty buffer from the pool.
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBuffer returns the buffer to the pool, unless it is too large.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBufferSize {
+		bufferPool.Put(buf)
+	}
+}
+
+// MiddlewareOption is a function which configures the middleware returned
+// by [Recorder.Middleware].
+type MiddlewareOption func(m *middleware)
+
+// middleware is the configuration of the recording middleware.
+type middleware struct {
+	// filters are the predicates, which all must be satisfied by the
+	// requests to be recorded.
+	filters []func(r *http.Request) bool
+
+	// cassetteFunc returns the name of the cassette, into which the
+	// request is recorded.
+	cassetteFunc func(r *http.Request) string
+}
+
+// WithIncludeRoutes is a [MiddlewareOption], which configures the
+// middleware to record only requests, whose URL path matches any of the
+// given glob patterns, in which "*" matches any sequence of characters, e.g.
+// "/api/*".
+func WithIncludeRoutes(patterns ...string) MiddlewareOption {
+	res := compileGlobs(patterns)
+	return WithRouteFilter(func(r *http.Request) bool {
+		return matchPath(r, res)
+	})
+}
+
+// WithExcludeRoutes is a [MiddlewareOption], which configures the
+// middleware to skip recording requests, whose URL path matches any of the
+// given glob patterns, e.g. "/healthz" or "/static/*". The patterns are
+// matched as described in [WithIncludeRoutes].
+func WithExcludeRoutes(patterns ...string) MiddlewareOption {
+	res := compileGlobs(patterns)
+	return WithRouteFilter(func(r *http.Request) bool {
+		return !matchPath(r, res)
+	})
+}
+
+// WithRouteFilter is a [MiddlewareOption], which configures the middleware
+// to record only requests satisfying the given predicate. Requests, which
+// are not recorded, are passed to the handler as is.
+func WithRouteFilter(filter func(r *http.Request) bool) MiddlewareOption {
+	return func(m *middleware) {
+		m.filters = append(m.filters, filter)
+	}
+}
+
+// WithCassetteFunc is a [MiddlewareOption], which configures the middleware
+// to record each request into the cassette named by the given function, e.g.
+// to record per-scenario fixtures from a single server. Requests, for which
+// the function returns an empty name, are recorded into the cassette of the
+// [Recorder]. The cassettes are managed as described in [ContextWithCassette].
+func WithCassetteFunc(fn func(r *http.Request) string) MiddlewareOption {
+	return func(m *middleware) {
+		m.cassetteFunc = fn
+	}
+}
+
+// WithCassetteHeader is a [MiddlewareOption], which configures the
+// middleware to record each request into the cassette named by the value of
+// the given request header, e.g. X-Test-Scenario. Names, which are not local
+// paths, e.g. ones containing "..", are ignored.
+func WithCassetteHeader(header string) MiddlewareOption {
+	return WithCassetteFunc(func(r *http.Request) string {
+		name := r.Header.Get(header)
+		if name == "" || !filepath.IsLocal(name) {
+			return ""
+		}
+		return name
+	})
+}
+
+// WithCassettePathPrefixes is a [MiddlewareOption], which configures the
+// middleware to record each request into the cassette mapped to the longest
+// prefix of its URL path, e.g. {"/checkout/": "testdata/checkout"}.
+func WithCassettePathPrefixes(prefixes map[string]string) MiddlewareOption {
+	return WithCassetteFunc(func(r *http.Request) string {
+		var longest, name string
+		for prefix, cassetteName := range prefixes {
+			if strings.HasPrefix(r.URL.Path, prefix) && len(prefix) > len(longest) {
+				longest, name = prefix, cassetteName
+			}
+		}
+		return name
+	})
+}
+
+// shouldRecord returns true, if the request satisfies all filters.
+func (m *middleware) shouldRecord(r *http.Request) bool {
+	for _, filter := range m.filters {
+		if !filter(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPath returns true, if the URL path of the request matches any of the
+// given regular expressions.
+func matchPath(r *http.Request, res []*regexp.Regexp) bool {
+	for _, re := range res {
+		if re.MatchString(r.URL.Path) {
+			return true
+		}
+	}
+	return false
+}
+
 // HTTPMiddleware intercepts and records all incoming requests and the server's response
 func (rec *Recorder) HTTPMiddleware(next http.Handler) http.Handler {
+	return rec.Middleware()(next)
+}
+
+// Middleware returns a middleware, which intercepts and records incoming
+// requests and the server's response like [Recorder.HTTPMiddleware], and is
+// configured using the given options, e.g. to skip recording health checks.
+func (rec *Recorder) Middleware(opts ...MiddlewareOption) func(next http.Handler) http.Handler {
+	m := &middleware{}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return rec.middlewareHandler(m, next)
+	}
+}
+
+func (rec *Recorder) middlewareHandler(m *middleware, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !m.shouldRecord(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var cassetteName string
+		if m.cassetteFunc != nil {
+			cassetteName = m.cassetteFunc(r)
+		}
+
 		ww := newPassthrough(w)
 
 		// Get a pooled buffer for the request body.
-		body := bufferPool.Get().(*bytes.Buffer)
-		body.Reset()
-		defer bufferPool.Put(body)
+		body := getBuffer()
+		defer putBuffer(body)
 
 		// Tee the body so it can be read by the next handler and by the recorder
 		r.Body = io.NopCloser(io.TeeReader(r.Body, body))
 
 		next.ServeHTTP(ww, r)
 
+		if ww.hijacked {
+			slog.Warn("not recording response of hijacked connection", "method", r.Method, "url", r.URL)
+			return
+		}
+
 		r.Body = io.NopCloser(bytes.NewReader(body.Bytes()))
 
 		// On the server side, requests do not have Host and Scheme so it must be set
@@ -37,39 +201,86 @@ func (rec *Recorder) HTTPMiddleware(next http.Handler) http.Handler {
 		r.URL.Scheme = "http"
 
 		// copy headers from real response
+		result := ww.recorder.Result()
 		for k, vv := range ww.real.Header() {
 			for _, v := range vv {
-				ww.recorder.Result().Header.Add(k, v)
+				result.Header.Add(k, v)
 			}
 		}
 
-		_, _ = rec.executeAndRecord(r, ww.recorder.Result())
+		// Responses streamed without a known length are sent chunked
+		if ww.flushed && result.Header.Get("Content-Length") == "" {
+			result.TransferEncoding = []string{"chunked"}
+			result.ContentLength = -1
+		}
+
+		if cassetteName != "" {
+			r = r.WithContext(ContextWithCassette(r.Context(), cassetteName))
+		}
+
+		_, _ = rec.executeAndRecord(r, result)
 	})
 }
 
-var _ http.ResponseWriter = &passthroughWriter{}
+var (
+	_ http.ResponseWriter = &passthroughWriter{}
+	_ http.Flusher        = &passthroughWriter{}
+	_ http.Hijacker       = &passthroughWriter{}
+)
 
 // passthroughWriter uses the original ResponseWriter and an httptest.ResponseRecorder
 // so the middleware can capture response details and passthrough to the client
 type passthroughWriter struct {
 	recorder *httptest.ResponseRecorder
 	real     http.ResponseWriter
+
+	// flushed specifies whether the response was flushed before the
+	// handler returned, i.e. it was streamed to the client.
+	flushed bool
+
+	// hijacked specifies whether the handler took over the connection, in
+	// which case the response cannot be recorded.
+	hijacked bool
 }
 
-func newPassthrough(real http.ResponseWriter) passthroughWriter {
-	return passthroughWriter{recorder: httptest.NewRecorder(), real: real}
+func newPassthrough(real http.ResponseWriter) *passthroughWriter {
+	return &passthroughWriter{recorder: httptest.NewRecorder(), real: real}
 }
 
-func (p passthroughWriter) Header() http.Header {
+func (p *passthroughWriter) Header() http.Header {
 	return p.real.Header()
 }
 
-func (p passthroughWriter) Write(in []byte) (int, error) {
+func (p *passthroughWriter) Write(in []byte) (int, error) {
 	_, _ = p.recorder.Write(in)
 	return p.real.Write(in)
 }
 
-func (p passthroughWriter) WriteHeader(statusCode int) {
+func (p *passthroughWriter) WriteHeader(statusCode int) {
 	p.recorder.WriteHeader(statusCode)
 	p.real.WriteHeader(statusCode)
 }
+
+// Flush implements the [http.Flusher] interface, so that streaming
+// handlers, e.g. serving server-sent events, are flushed to the client
+// right away, while the response is still being recorded.
+func (p *passthroughWriter) Flush() {
+	p.flushed = true
+	p.recorder.Flush()
+	_ = http.NewResponseController(p.real).Flush()
+}
+
+// Hijack implements the [http.Hijacker] interface. Responses of hijacked
+// connections are not recorded.
+func (p *passthroughWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(p.real).Hijack()
+	if err == nil {
+		p.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap returns the original ResponseWriter for [http.ResponseController].
+func (p *passthroughWriter) Unwrap() http.ResponseWriter {
+	return p.real
+}
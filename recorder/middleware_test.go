@@ -0,0 +1,281 @@
+package recorder_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/goware/go-vcr/cassette"
+	"github.com/goware/go-vcr/recorder"
+)
+
+func TestMiddlewareRouteFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []recorder.MiddlewareOption
+		wantPaths []string
+	}{
+		{
+			name:      "no filter",
+			wantPaths: []string{"/healthz", "/static/app.js", "/api/users", "/api/orders"},
+		},
+		{
+			name:      "exclude",
+			opts:      []recorder.MiddlewareOption{recorder.WithExcludeRoutes("/healthz", "/static/*")},
+			wantPaths: []string{"/api/users", "/api/orders"},
+		},
+		{
+			name:      "include",
+			opts:      []recorder.MiddlewareOption{recorder.WithIncludeRoutes("/api/*")},
+			wantPaths: []string{"/api/users", "/api/orders"},
+		},
+		{
+			name: "include and predicate",
+			opts: []recorder.MiddlewareOption{
+				recorder.WithIncludeRoutes("/api/*"),
+				recorder.WithRouteFilter(func(r *http.Request) bool {
+					return !strings.HasSuffix(r.URL.Path, "/orders")
+				}),
+			},
+			wantPaths: []string{"/api/users"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cassPath, err := newCassettePath("test_middleware_route_filter")
+			if err != nil {
+				t.Fatal(err)
+			}
+			rec, err := recorder.New(cassPath, recorder.WithMode(recorder.ModeRecordOnly))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rec.Stop()
+
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("ok"))
+			})
+			server := httptest.NewServer(rec.Middleware(test.opts...)(handler))
+			defer server.Close()
+
+			for _, p := range []string{"/healthz", "/static/app.js", "/api/users", "/api/orders"} {
+				resp, err := http.Get(server.URL + p)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					t.Fatalf("got status %d for %s", resp.StatusCode, p)
+				}
+			}
+
+			var paths []string
+			for _, i := range rec.Interactions() {
+				paths = append(paths, strings.TrimPrefix(i.Request.URL, "http://go-vcr"))
+			}
+			if !slices.Equal(paths, test.wantPaths) {
+				t.Fatalf("got recorded paths %v, want %v", paths, test.wantPaths)
+			}
+		})
+	}
+}
+
+func TestMiddlewareCassetteRouting(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  recorder.MiddlewareOption
+	}{
+		{name: "header", opt: recorder.WithCassetteHeader("X-Scenario")},
+		{name: "path prefixes", opt: recorder.WithCassettePathPrefixes(map[string]string{
+			"/checkout/": "checkout",
+			"/search/":   "search",
+		})},
+		{name: "func", opt: recorder.WithCassetteFunc(func(r *http.Request) string {
+			first, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+			if first == "checkout" || first == "search" {
+				return first
+			}
+			return ""
+		})},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cassPath, err := newCassettePath("default")
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := path.Dir(cassPath)
+			t.Setenv(recorder.EnvCassetteDir, dir)
+
+			rec, err := recorder.New(cassPath, recorder.WithMode(recorder.ModeRecordOnly))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("ok"))
+			})
+			server := httptest.NewServer(rec.Middleware(test.opt)(handler))
+			defer server.Close()
+
+			requests := []struct {
+				path     string
+				scenario string
+			}{
+				{path: "/checkout/cart", scenario: "checkout"},
+				{path: "/checkout/pay", scenario: "checkout"},
+				{path: "/search/q", scenario: "search"},
+				{path: "/home"},
+				{path: "/escape", scenario: "../escape"},
+			}
+			for _, r := range requests {
+				req, err := http.NewRequest(http.MethodGet, server.URL+r.path, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if r.scenario != "" {
+					req.Header.Set("X-Scenario", r.scenario)
+				}
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+			}
+
+			if err := rec.Stop(); err != nil {
+				t.Fatal(err)
+			}
+
+			for name, wantPaths := range map[string][]string{
+				cassPath:                   {"/home", "/escape"},
+				path.Join(dir, "checkout"): {"/checkout/cart", "/checkout/pay"},
+				path.Join(dir, "search"):   {"/search/q"},
+			} {
+				c, err := cassette.Load(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var paths []string
+				for _, i := range c.Interactions {
+					paths = append(paths, strings.TrimPrefix(i.Request.URL, "http://go-vcr"))
+				}
+				if !slices.Equal(paths, wantPaths) {
+					t.Fatalf("got paths %v in cassette %s, want %v", paths, name, wantPaths)
+				}
+			}
+		})
+	}
+}
+
+func TestMiddlewareStreaming(t *testing.T) {
+	cassPath, err := newCassettePath("test_middleware_streaming")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := recorder.New(cassPath, recorder.WithMode(recorder.ModeRecordOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	received := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: 1\n\n")
+		flusher.Flush()
+
+		// Wait for the client to receive the first event before sending
+		// the next one.
+		<-received
+		fmt.Fprint(w, "data: 2\n\n")
+	})
+	server := httptest.NewServer(rec.HTTPMiddleware(handler))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "data: 1\n" {
+		t.Fatalf("got first line %q, want %q", line, "data: 1\n")
+	}
+	close(received)
+	if _, err := io.ReadAll(reader); err != nil {
+		t.Fatal(err)
+	}
+
+	interactions := rec.Interactions()
+	if len(interactions) != 1 {
+		t.Fatalf("got %d interactions, want 1", len(interactions))
+	}
+	i := interactions[0]
+	if i.Response.Body != "data: 1\n\ndata: 2\n\n" {
+		t.Fatalf("got recorded body %q", i.Response.Body)
+	}
+	if !slices.Equal(i.Response.TransferEncoding, []string{"chunked"}) {
+		t.Fatalf("got recorded transfer encoding %v, want chunked", i.Response.TransferEncoding)
+	}
+}
+
+func TestMiddlewareHijack(t *testing.T) {
+	cassPath, err := newCassettePath("test_middleware_hijack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := recorder.New(cassPath, recorder.WithMode(recorder.ModeRecordOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		buf.Flush()
+	})
+	server := httptest.NewServer(rec.HTTPMiddleware(handler))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "hijacked" {
+		t.Fatalf("got body %q, want hijacked", body)
+	}
+
+	if n := len(rec.Interactions()); n != 0 {
+		t.Fatalf("got %d interactions, want none for hijacked connection", n)
+	}
+}
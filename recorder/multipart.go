@@ -0,0 +1,214 @@
+package recorder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goware/go-vcr/cassette"
+)
+
+// WithMultipartParts is an [Option], which configures the [Recorder] to
+// record the parts of multipart/form-data request bodies separately,
+// instead of the body, see [cassette.Request.Parts]. Text fields are
+// recorded inline, and file parts are written to files next to the
+// cassette, with their filename and content type recorded, which makes
+// uploads easy to review.
+//
+// Such requests are matched on their parts, see [cassette.PartsBody],
+// regardless of the boundary of the body, which is removed from the
+// recorded Content-Type header. The option must be used when replaying
+// such requests as well. It applies to requests sent by the client only,
+// and not to the requests handled by the [Recorder.HTTPMiddleware].
+func WithMultipartParts(val bool) Option {
+	return func(r *Recorder) {
+		r.multipartParts = val
+	}
+}
+
+// spooledPart is a part of a spooled multipart/form-data body.
+type spooledPart struct {
+	cassette.Part
+
+	// path is the path of the temporary file holding the content of a
+	// file part.
+	path string
+}
+
+// multipartBoundary returns the boundary of a multipart/form-data request,
+// and the Content-Type header without the boundary, or false if the
+// request is not a multipart/form-data request.
+func multipartBoundary(r *http.Request) (string, string, bool) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return "", "", false
+	}
+
+	boundary := params["boundary"]
+	delete(params, "boundary")
+
+	return boundary, mime.FormatMediaType(mediaType, params), true
+}
+
+// spoolMultipartBody writes the multipart/form-data body of the request to
+// a temporary file, which is sent to the server, and the content of its
+// file parts to temporary files, while computing their digests. The
+// returned request carries the spooled body in its context, and its body is
+// replaced with its parts, which it is matched on, see [cassette.PartsBody].
+// The returned function removes the temporary files.
+func spoolMultipartBody(r *http.Request, boundary, contentType string) (*http.Request, func(), error) {
+	spooled := &spooledBody{header: r.Header}
+	cleanup := func() {
+		if spooled.path != "" {
+			os.Remove(spooled.path)
+		}
+		for _, p := range spooled.parts {
+			if p.path != "" {
+				os.Remove(p.path)
+			}
+		}
+	}
+
+	err := spooled.spoolParts(r.Body, boundary)
+	r.Body.Close()
+	if err != nil {
+		cleanup()
+		return nil, func() {}, fmt.Errorf("failed to spool multipart body: %w", err)
+	}
+
+	parts := make([]cassette.Part, len(spooled.parts))
+	for i, p := range spooled.parts {
+		parts[i] = p.Part
+	}
+
+	r = r.WithContext(context.WithValue(r.Context(), spooledBodyContextKey{}, spooled))
+	r.Header = r.Header.Clone()
+	r.Header.Set("Content-Type", contentType)
+	r.Body = io.NopCloser(strings.NewReader(cassette.PartsBody(parts)))
+
+	return r, cleanup, nil
+}
+
+// spoolParts writes the body to a temporary file, and parses its parts.
+func (b *spooledBody) spoolParts(body io.Reader, boundary string) error {
+	f, err := os.CreateTemp("", "go-vcr-body-*")
+	if err != nil {
+		return err
+	}
+	b.path = f.Name()
+	_, err = io.Copy(f, body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	f, err = os.Open(b.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	mr := multipart.NewReader(f, boundary)
+	for {
+		part, err := mr.NextRawPart()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		p := spooledPart{Part: cassette.Part{
+			Name:        part.FormName(),
+			Filename:    part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+		}}
+		if p.Filename == "" {
+			value, err := io.ReadAll(part)
+			if err != nil {
+				return err
+			}
+			p.Value = string(value)
+		} else if err := p.spool(part); err != nil {
+			return err
+		}
+		b.parts = append(b.parts, p)
+	}
+}
+
+// spool writes the content of the file part to a temporary file, while
+// computing its digest.
+func (p *spooledPart) spool(content io.Reader) error {
+	f, err := os.CreateTemp("", "go-vcr-part-*")
+	if err != nil {
+		return err
+	}
+	p.path = f.Name()
+
+	h := sha256.New()
+	p.Size, err = io.Copy(io.MultiWriter(f, h), content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	p.Digest = "sha256:" + hex.EncodeToString(h.Sum(nil))
+
+	return err
+}
+
+// recordParts records the parts of the spooled multipart/form-data body of
+// the interaction, and writes the content of its file parts to files next
+// to the cassette. Files are stored by the digest of their content, so that
+// repeated uploads of the same file share it.
+func recordParts(c *cassette.Cassette, i *cassette.Interaction, spooled *spooledBody) error {
+	i.Request.Body = ""
+	i.Request.Parts = make([]cassette.Part, len(spooled.parts))
+	for idx, p := range spooled.parts {
+		part := p.Part
+		if p.path != "" {
+			name := strings.TrimPrefix(part.Digest, "sha256:") + filepath.Ext(part.Filename)
+			part.File = filepath.ToSlash(filepath.Join(filepath.Base(c.Name)+".parts", name))
+			if err := copyFile(p.path, filepath.Join(filepath.Dir(c.File()), filepath.FromSlash(part.File))); err != nil {
+				return fmt.Errorf("failed to write multipart file: %w", err)
+			}
+		}
+		i.Request.Parts[idx] = part
+	}
+
+	return nil
+}
+
+// copyFile copies the file at src to dst, creating the directory of dst if
+// missing.
+func copyFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,124 @@
+package recorder_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goware/go-vcr/cassette"
+	"github.com/goware/go-vcr/recorder"
+)
+
+func TestMultipartParts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f, header, err := r.FormFile("report")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		data, _ := io.ReadAll(f)
+		fmt.Fprintf(w, "%s %s %d", r.FormValue("title"), header.Filename, len(data))
+	}))
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_multipart_parts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// upload sends a multipart body, whose boundary is random
+	report := []byte("%PDF-1.7\x00\x01\x02")
+	upload := func(rec *recorder.Recorder, content []byte) (string, error) {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		if err := mw.WriteField("title", "Q3"); err != nil {
+			return "", err
+		}
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", `form-data; name="report"; filename="report.pdf"`)
+		h.Set("Content-Type", "application/pdf")
+		part, err := mw.CreatePart(h)
+		if err != nil {
+			return "", err
+		}
+		part.Write(content)
+		if err := mw.Close(); err != nil {
+			return "", err
+		}
+
+		resp, err := rec.GetDefaultClient().Post(server.URL+"/upload", mw.FormDataContentType(), &body)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		return string(data), err
+	}
+
+	rec, err := recorder.New(cassPath,
+		recorder.WithMode(recorder.ModeRecordOnly),
+		recorder.WithMultipartParts(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("Q3 report.pdf %d", len(report))
+	if got, err := upload(rec, report); err != nil || got != want {
+		t.Fatalf("got response %q, %v, want %q", got, err, want)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cassette.Load(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := c.Interactions[0].Request
+	if req.Body != "" || len(req.Parts) != 2 {
+		t.Fatalf("got body %q and %d parts, want 2 parts", req.Body, len(req.Parts))
+	}
+	if got := req.Headers.Get("Content-Type"); got != "multipart/form-data" {
+		t.Errorf("got Content-Type %q, want it without boundary", got)
+	}
+	if p := req.Parts[0]; p.Name != "title" || p.Value != "Q3" || p.File != "" {
+		t.Errorf("got text part %+v, want it inline", p)
+	}
+	p := req.Parts[1]
+	if p.Name != "report" || p.Filename != "report.pdf" || p.ContentType != "application/pdf" || p.Size != int64(len(report)) || filepath.Ext(p.File) != ".pdf" {
+		t.Errorf("got file part %+v", p)
+	}
+	if data, err := os.ReadFile(filepath.Join(filepath.Dir(c.File()), p.File)); err != nil || !bytes.Equal(data, report) {
+		t.Errorf("got file %q, %v, want %q", data, err, report)
+	}
+
+	// The parts are matched regardless of the boundary
+	rec, err = recorder.New(cassPath,
+		recorder.WithMode(recorder.ModeReplayOnly),
+		recorder.WithMultipartParts(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	if got, err := upload(rec, report); err != nil || got != want {
+		t.Fatalf("got replayed response %q, %v, want %q", got, err, want)
+	}
+	if _, err := upload(rec, []byte("%PDF-1.7 other")); !errors.Is(err, cassette.ErrInteractionNotFound) {
+		t.Fatalf("got error %v, want %v", err, cassette.ErrInteractionNotFound)
+	}
+}
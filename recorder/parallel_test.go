@@ -0,0 +1,116 @@
+package recorder_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/goware/go-vcr/cassette"
+	"github.com/goware/go-vcr/recorder"
+)
+
+func TestParallelSubtests(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_parallel_subtests")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 16
+	newCase := func(k int) testCase {
+		body := fmt.Sprintf("body %d", k)
+		return testCase{
+			method:            http.MethodPost,
+			body:              body,
+			wantBody:          "POST go-vcr\n" + body,
+			wantStatus:        http.StatusOK,
+			wantContentLength: len("POST go-vcr\n" + body),
+			path:              fmt.Sprintf("/api/v1/%d", k),
+		}
+	}
+
+	// The hooks modify the interactions replayed concurrently, which must
+	// not share their headers and metadata.
+	hook := func(i *cassette.Interaction) error {
+		i.Response.Headers.Set("X-Hook", "1")
+		i.SetMetadata("hooked", "1")
+		return nil
+	}
+
+	// send sends the request of the test case, and modifies the headers of
+	// the response, which are not shared with other requests.
+	send := func(client *http.Client, tc testCase) error {
+		if err := tc.run(context.Background(), client, server.URL); err != nil {
+			return err
+		}
+
+		resp, err := client.Post(server.URL+tc.path, "text/plain", strings.NewReader(tc.body))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if got := resp.Header.Get("X-Hook"); got != "1" {
+			return fmt.Errorf("got X-Hook header %q, want 1", got)
+		}
+		resp.Header.Set("X-Hook", tc.body)
+
+		return nil
+	}
+
+	for _, mode := range []recorder.Mode{recorder.ModeRecordOnly, recorder.ModeReplayOnly, recorder.ModeReplayWithNewEpisodes} {
+		rec, err := recorder.New(cassPath,
+			recorder.WithMode(mode),
+			recorder.WithSkipRequestLatency(true),
+			recorder.WithReplayableInteractions(mode != recorder.ModeReplayWithNewEpisodes),
+			recorder.WithHook(hook, recorder.AfterCaptureHook),
+			recorder.WithHook(hook, recorder.BeforeResponseReplayHook),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The parent test waits for its parallel subtests to finish
+		t.Run(mode.String(), func(t *testing.T) {
+			for k := range n {
+				t.Run(fmt.Sprint(k), func(t *testing.T) {
+					t.Parallel()
+
+					// The subtests send the same requests repeatedly,
+					// and thus replay the same interactions, and new
+					// ones in the mode recording new episodes. The
+					// requests are sent concurrently within the subtest
+					// as well, which runs sequentially with -parallel=1.
+					cases := []testCase{newCase(k % 4), newCase(k % 4), newCase(k % 4)}
+					if mode == recorder.ModeReplayWithNewEpisodes {
+						cases = append(cases, newCase(n+k))
+					}
+
+					var wg sync.WaitGroup
+					for _, tc := range cases {
+						wg.Add(1)
+						go func() {
+							defer wg.Done()
+							if err := send(rec.GetDefaultClient(), tc); err != nil {
+								t.Error(err)
+							}
+						}()
+					}
+					wg.Wait()
+
+					for _, i := range rec.Interactions() {
+						_ = i.WasReplayed()
+					}
+				})
+			}
+		})
+
+		if err := rec.Stop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
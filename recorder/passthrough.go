@@ -0,0 +1,177 @@
+package recorder
+
+import (
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/goware/go-vcr/cassette"
+)
+
+// WithOnlyHosts is an [Option], which configures the [Recorder] to record
+// and replay only requests to the given hosts. Requests to any other host are
+// passed through to the original endpoint without being recorded.
+//
+// Hosts are matched either with or without the port, e.g. "example.com"
+// matches both example.com and example.com:8080. A leading "*." matches any
+// subdomain, e.g. "*.example.com" matches api.example.com. The hosts of
+// multiple WithOnlyHosts options are combined.
+func WithOnlyHosts(hosts ...string) Option {
+	return func(r *Recorder) {
+		r.onlyHosts = append(r.onlyHosts, hosts...)
+	}
+}
+
+// WithIgnoreHosts is an [Option], which configures the [Recorder] to pass
+// through requests to the given hosts without recording them, e.g.
+// telemetry endpoints or local sidecars. Hosts are matched as described in
+// [WithOnlyHosts].
+func WithIgnoreHosts(hosts ...string) Option {
+	return WithPassthrough(func(r *http.Request) bool {
+		return matchHost(r, hosts)
+	})
+}
+
+// matchHost returns true, if the host of the request matches any of the
+// given host patterns.
+func matchHost(r *http.Request, patterns []string) bool {
+	host, hostname := requestHost(r)
+
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(hostname, "."+suffix) || strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+
+		if pattern == host || pattern == hostname {
+			return true
+		}
+	}
+
+	return false
+}
+
+// requestHost returns the lowercase host of the request, with and without
+// its port.
+func requestHost(r *http.Request) (host, hostname string) {
+	host = r.URL.Host
+	if host == "" {
+		host = r.Host
+	}
+	host = strings.ToLower(host)
+	hostname = host
+	if r.URL.Host != "" {
+		hostname = strings.ToLower(r.URL.Hostname())
+	}
+	return host, hostname
+}
+
+// WithPassthroughPattern is an [Option], which configures the [Recorder] to
+// pass through requests, whose URL matches any of the given glob patterns.
+// Patterns are matched against the URL without the query string and
+// fragment, e.g. https://example.com/health, where "*" matches any sequence
+// of characters. For example "*/health" matches health checks of any host,
+// and "https://metrics.internal/*" matches all requests to the given host.
+func WithPassthroughPattern(patterns ...string) Option {
+	return WithPassthroughRegexp(compileGlobs(patterns)...)
+}
+
+// WithPassthroughRegexp is an [Option], which configures the [Recorder] to
+// pass through requests, whose URL matches any of the given regular
+// expressions. The expressions are matched against the URL without the
+// query string and fragment, as described in [WithPassthroughPattern].
+func WithPassthroughRegexp(res ...*regexp.Regexp) Option {
+	return WithPassthrough(func(r *http.Request) bool {
+		return matchURL(r, res)
+	})
+}
+
+// PassthroughRule describes requests, which are passed through to the
+// original endpoint without being recorded, by their method, host and path,
+// see [WithPassthroughRules]. A request satisfies the rule, if it matches all
+// of the non-empty criteria.
+type PassthroughRule struct {
+	// Methods are the methods of the requests, e.g. GET. Any method
+	// matches, if empty.
+	Methods []string
+
+	// Hosts are the hosts of the requests, matched as described in
+	// [WithOnlyHosts]. Any host matches, if empty.
+	Hosts []string
+
+	// Paths are glob patterns matched against the URL path of the
+	// requests, e.g. "/metrics" or "/debug/*", where "*" matches any
+	// sequence of characters. Any path matches, if empty.
+	Paths []string
+}
+
+// WithPassthroughRules is an [Option], which configures the [Recorder] to
+// pass through requests satisfying any of the given rules, e.g. to always
+// pass through GET /metrics requests to localhost:
+//
+//	recorder.WithPassthroughRules(recorder.PassthroughRule{
+//		Methods: []string{http.MethodGet},
+//		Hosts:   []string{"localhost"},
+//		Paths:   []string{"/metrics"},
+//	})
+//
+// Unlike a [PassthroughFunc], the rules are matched without reading the
+// request body.
+func WithPassthroughRules(rules ...PassthroughRule) Option {
+	paths := make([][]*regexp.Regexp, len(rules))
+	for i, rule := range rules {
+		paths[i] = compileGlobs(rule.Paths)
+	}
+
+	return WithPassthrough(func(r *http.Request) bool {
+		for i, rule := range rules {
+			if len(rule.Methods) > 0 && !slices.Contains(rule.Methods, r.Method) {
+				continue
+			}
+			if len(rule.Hosts) > 0 && !matchHost(r, rule.Hosts) {
+				continue
+			}
+			if len(paths[i]) > 0 && !matchPath(r, paths[i]) {
+				continue
+			}
+			return true
+		}
+		return false
+	})
+}
+
+// compileGlobs compiles the glob patterns as described in
+// [cassette.CompileGlob].
+func compileGlobs(patterns []string) []*regexp.Regexp {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		res = append(res, cassette.CompileGlob(pattern))
+	}
+	return res
+}
+
+// matchURL returns true, if the URL of the request without the query string
+// and fragment matches any of the given regular expressions.
+func matchURL(r *http.Request, res []*regexp.Regexp) bool {
+	u := *r.URL
+	u.RawQuery = ""
+	u.ForceQuery = false
+	u.Fragment = ""
+	u.RawFragment = ""
+	if u.Host == "" {
+		u.Host = r.Host
+	}
+	target := u.String()
+
+	for _, re := range res {
+		if re.MatchString(target) {
+			return true
+		}
+	}
+
+	return false
+}
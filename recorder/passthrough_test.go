@@ -0,0 +1,245 @@
+package recorder_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/goware/go-vcr/recorder"
+)
+
+func TestHostFilters(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test := testCase{
+		method:            http.MethodGet,
+		wantBody:          "GET go-vcr\n",
+		wantStatus:        http.StatusOK,
+		wantContentLength: 11,
+		path:              "/api/v1/foo",
+	}
+
+	tests := []struct {
+		name         string
+		opts         []recorder.Option
+		wantRecorded int
+	}{
+		{name: "only hosts match", opts: []recorder.Option{recorder.WithOnlyHosts(u.Hostname())}, wantRecorded: 1},
+		{name: "only hosts mismatch", opts: []recorder.Option{recorder.WithOnlyHosts("*.example.com")}, wantRecorded: 0},
+		{name: "only hosts combined", opts: []recorder.Option{recorder.WithOnlyHosts("*.example.com"), recorder.WithOnlyHosts(u.Host)}, wantRecorded: 1},
+		{name: "ignore hosts match", opts: []recorder.Option{recorder.WithIgnoreHosts(u.Host)}, wantRecorded: 0},
+		{name: "ignore hosts mismatch", opts: []recorder.Option{recorder.WithIgnoreHosts("example.com")}, wantRecorded: 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cassPath, err := newCassettePath("test_host_filters")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rec, err := recorder.New(cassPath, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rec.Stop()
+
+			if err := test.run(context.Background(), rec.GetDefaultClient(), server.URL); err != nil {
+				t.Fatal(err)
+			}
+
+			if n := len(rec.Interactions()); n != tc.wantRecorded {
+				t.Fatalf("expected %d recorded interactions, got %d", tc.wantRecorded, n)
+			}
+		})
+	}
+}
+
+func TestPassthroughPattern(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_passthrough_pattern")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := recorder.New(
+		cassPath,
+		recorder.WithPassthroughPattern("*/health", "https://metrics.internal/*"),
+		recorder.WithPassthroughRegexp(regexp.MustCompile(`/static/.+\.css$`)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	paths := map[string]bool{
+		"/health":                false,
+		"/health?verbose=1":      false,
+		"/static/main.css":       false,
+		"/api/v1/health/details": true,
+		"/api/v1/foo":            true,
+	}
+
+	for path := range paths {
+		test := testCase{
+			method:            http.MethodGet,
+			wantBody:          "GET go-vcr\n",
+			wantStatus:        http.StatusOK,
+			wantContentLength: 11,
+			path:              path,
+		}
+		if err := test.run(context.Background(), rec.GetDefaultClient(), server.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recorded := make(map[string]bool)
+	for _, i := range rec.Interactions() {
+		recorded[strings.TrimPrefix(i.Request.URL, server.URL)] = true
+	}
+
+	for path, wantRecorded := range paths {
+		if recorded[path] != wantRecorded {
+			t.Errorf("%s: got recorded %v, want %v", path, recorded[path], wantRecorded)
+		}
+	}
+}
+
+func TestPassthroughRules(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cassPath, err := newCassettePath("test_passthrough_rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := recorder.New(
+		cassPath,
+		recorder.WithPassthroughRules(
+			recorder.PassthroughRule{
+				Methods: []string{http.MethodGet},
+				Hosts:   []string{u.Hostname()},
+				Paths:   []string{"/metrics"},
+			},
+			recorder.PassthroughRule{
+				Hosts: []string{"example.com"},
+			},
+			recorder.PassthroughRule{
+				Paths: []string{"/debug/*"},
+			},
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	tests := []struct {
+		method       string
+		path         string
+		wantRecorded bool
+	}{
+		{method: http.MethodGet, path: "/metrics", wantRecorded: false},
+		{method: http.MethodPost, path: "/metrics", wantRecorded: true},
+		{method: http.MethodGet, path: "/metrics/details", wantRecorded: true},
+		{method: http.MethodPost, path: "/debug/pprof", wantRecorded: false},
+		{method: http.MethodGet, path: "/api/v1/foo", wantRecorded: true},
+	}
+	for _, test := range tests {
+		tc := testCase{
+			method:            test.method,
+			wantBody:          test.method + " go-vcr\n",
+			wantStatus:        http.StatusOK,
+			wantContentLength: len(test.method) + 8,
+			path:              test.path,
+		}
+		if err := tc.run(context.Background(), rec.GetDefaultClient(), server.URL); err != nil {
+			t.Fatal(err)
+		}
+
+		interactions := rec.Interactions()
+		recorded := len(interactions) > 0 && interactions[len(interactions)-1].Request.URL == server.URL+test.path &&
+			interactions[len(interactions)-1].Request.Method == test.method
+		if recorded != test.wantRecorded {
+			t.Errorf("%s %s: got recorded %v, want %v", test.method, test.path, recorded, test.wantRecorded)
+		}
+	}
+}
+
+func TestBlockHosts(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cassPath, err := newCassettePath("test_block_hosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorded := testCase{
+		method:            http.MethodGet,
+		wantBody:          "GET go-vcr\n",
+		wantStatus:        http.StatusOK,
+		wantContentLength: 11,
+		path:              "/api/v1/foo",
+	}
+
+	rec, err := recorder.New(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := recorded.run(context.Background(), rec.GetDefaultClient(), server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err = recorder.New(
+		cassPath,
+		recorder.WithMode(recorder.ModeReplayWithNewEpisodes),
+		recorder.WithBlockHosts(serverURL.Hostname()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	// Recorded interactions for blocked hosts are still replayed
+	if err := recorded.run(context.Background(), rec.GetDefaultClient(), server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := rec.GetDefaultClient().Get(server.URL + "/api/v1/bar"); !errors.Is(err, recorder.ErrHostBlocked) {
+		t.Fatalf("expected ErrHostBlocked, got %v", err)
+	}
+
+	if err := rec.SetMode(recorder.ModePassthrough); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rec.GetDefaultClient().Get(server.URL + recorded.path); !errors.Is(err, recorder.ErrHostBlocked) {
+		t.Fatalf("expected ErrHostBlocked in passthrough mode, got %v", err)
+	}
+}
@@ -27,11 +27,23 @@ package recorder
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"maps"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/goware/go-vcr/cassette"
@@ -41,6 +53,10 @@ import (
 // created without specifying a cassette name.
 var ErrNoCassetteName = errors.New("no cassette name specified")
 
+// ErrNoCassetteInserted is returned when the cassette of the recorder was
+// ejected using [Recorder.EjectCassette], and no other cassette was inserted.
+var ErrNoCassetteInserted = errors.New("no cassette inserted")
+
 // Mode represents the mode of operation of the recorder
 type Mode int
 
@@ -79,12 +95,60 @@ const (
 	// endpoints using the real HTTP transport.  In this mode no cassette
 	// will be created.
 	ModePassthrough
+
+	// ModeRefresh specifies that VCR will forward all HTTP requests to the
+	// endpoints using the real HTTP transport, and overwrite the matching
+	// recorded interactions in place with the fresh responses. Requests
+	// without a matching interaction are recorded as new interactions,
+	// and interactions which were not exercised are preserved. If the
+	// cassette file is missing it will be created.
+	ModeRefresh
 )
 
 // ErrInvalidMode is returned when attempting to start the recorder with invalid
 // mode
 var ErrInvalidMode = errors.New("invalid recorder mode")
 
+// modeNames maps the recorder modes to their names as used by [ParseMode]
+// and [Mode.String].
+var modeNames = map[Mode]string{
+	ModeRecordOnly:            "record",
+	ModeReplayOnly:            "replay",
+	ModeReplayWithNewEpisodes: "replay-with-new-episodes",
+	ModeRecordOnce:            "record-once",
+	ModePassthrough:           "passthrough",
+	ModeRefresh:               "refresh",
+}
+
+// String returns the name of the mode.
+func (m Mode) String() string {
+	if name, ok := modeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// ParseMode returns the [Mode] with the given name. Valid names are
+// "record", "replay", "replay-with-new-episodes", "record-once",
+// "passthrough" and "refresh". The "record-only" and "replay-only" aliases
+// are accepted as well.
+func ParseMode(name string) (Mode, error) {
+	switch name = strings.ToLower(strings.TrimSpace(name)); name {
+	case "record-only":
+		return ModeRecordOnly, nil
+	case "replay-only":
+		return ModeReplayOnly, nil
+	}
+
+	for mode, modeName := range modeNames {
+		if modeName == name {
+			return mode, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %q", ErrInvalidMode, name)
+}
+
 // HookFunc represents a function, which will be invoked in different stages of
 // the playback. The hook functions allow for plugging in to the playback and
 // transform an interaction, if needed. For example a hook function might redact
@@ -94,6 +158,13 @@ var ErrInvalidMode = errors.New("invalid recorder mode")
 // replay mode.
 type HookFunc func(i *cassette.Interaction) error
 
+// RequestAwareHookFunc represents a [HookFunc], which also receives the
+// originating HTTP request of the interaction, so that it can make decisions
+// based on the values of the request context, e.g. the tenant or test case.
+// The request is nil for the hooks of kind [BeforeSaveHook] and
+// [OnRecorderStopHook], which are not invoked for a specific request.
+type RequestAwareHookFunc func(r *http.Request, i *cassette.Interaction) error
+
 // Hook kinds
 type HookKind int
 
@@ -122,8 +193,19 @@ type Hook struct {
 	// Handler is the function which will be invoked
 	Handler HookFunc
 
+	// RequestHandler is the function which will be invoked with the
+	// originating request, if Handler is nil.
+	RequestHandler RequestAwareHookFunc
+
 	// Kind represents the hook kind
 	Kind HookKind
+
+	// id identifies the hooks added using [Recorder.AddHook].
+	id HookID
+
+	// builtin specifies whether the hook was created by the recorder to
+	// implement one of its options, e.g. [WithStripQueryParams].
+	builtin bool
 }
 
 // NewHook creates a new hook.
@@ -135,11 +217,44 @@ func NewHook(handler HookFunc, kind HookKind) *Hook {
 	return hook
 }
 
+// NewRequestAwareHook creates a new hook, whose handler receives the
+// originating request of the interaction.
+func NewRequestAwareHook(handler RequestAwareHookFunc, kind HookKind) *Hook {
+	hook := &Hook{
+		RequestHandler: handler,
+		Kind:           kind,
+	}
+	return hook
+}
+
+// StartHookFunc represents a function, which will be invoked when the
+// recorder starts using a cassette, i.e. when the recorder is created, a
+// cassette is inserted using [Recorder.InsertCassette], or a cassette is
+// first selected for a request, e.g. using [ContextWithCassette],
+// [WithSplitByHost], [WithShards] or [NewShared]. It has access to the
+// cassette and the mode of the recorder, and is useful for seeding metadata,
+// asserting preconditions, or logging which fixture is in use. It is the
+// counterpart of the hooks of kind [OnRecorderStopHook].
+type StartHookFunc func(c *cassette.Cassette, mode Mode) error
+
+// RequestHookFunc represents a function, which will be invoked on the live
+// request before it is sent to the real server, e.g. to inject credentials
+// from the environment, or to rewrite hosts. Unlike a [HookFunc], it does not
+// see the recorded interaction, and its changes are not recorded, so that
+// the recorded requests still match the requests replayed later on.
+type RequestHookFunc func(r *http.Request) error
+
 // PassthroughFunc is a predicate which determines whether a specific HTTP
 // request is to be forwarded to the original endpoint. It should return true
 // when a request needs to be passed through, and false otherwise.
 type PassthroughFunc func(req *http.Request) bool
 
+// passthrough is a registered [PassthroughFunc]. Passthrough functions are
+// registered by reference, so that they can be removed again.
+type passthrough struct {
+	fn PassthroughFunc
+}
+
 // ErrUnsafeRequestMethod is returned when the [Recorder] was configured to
 // block unsafe methods, and an attempt to use such was invoked. Safe Methods
 // are defined as part of RFC 9110, section 9.2.1.
@@ -153,20 +268,83 @@ var safeMethods = map[string]bool{
 	http.MethodTrace:   true,
 }
 
+// ErrRealNetworkBlocked is returned when the [Recorder] was configured to run
+// offline, and a request would have been sent to the real endpoint.
+var ErrRealNetworkBlocked = errors.New("real network access is blocked")
+
+// offlineRoundTripper is used as the real transport, when the [Recorder] runs
+// offline.
+type offlineRoundTripper struct{}
+
+func (offlineRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("%w: %s %s", ErrRealNetworkBlocked, req.Method, req.URL)
+}
+
+// ErrHostBlocked is returned when the [Recorder] was configured to block
+// requests to a host using [WithBlockHosts], and a request to such host would
+// have been sent to the real endpoint.
+var ErrHostBlocked = errors.New("requests to host are blocked")
+
+type blockHostsRoundTripper struct {
+	RoundTripper http.RoundTripper
+	hosts        []string
+}
+
+func (r *blockHostsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if matchHost(req, r.hosts) {
+		return nil, fmt.Errorf("%w: %s", ErrHostBlocked, req.URL.Host)
+	}
+	return r.RoundTripper.RoundTrip(req)
+}
+
+type beforeRequestRoundTripper struct {
+	RoundTripper http.RoundTripper
+	hooks        []RequestHookFunc
+}
+
+func (r *beforeRequestRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The hooks must not modify the request of the caller, which is
+	// recorded as is.
+	req = req.Clone(req.Context())
+	for _, hook := range r.hooks {
+		if err := hook(req); err != nil {
+			return nil, err
+		}
+	}
+	return r.RoundTripper.RoundTrip(req)
+}
+
 type blockUnsafeMethodsRoundTripper struct {
 	RoundTripper http.RoundTripper
+	methods      []string
+	allowHosts   []string
 }
 
 func (r *blockUnsafeMethodsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
-	if !safeMethods[req.Method] {
-		return nil, ErrUnsafeRequestMethod
+	unsafe := !safeMethods[req.Method]
+	if r.methods != nil {
+		unsafe = slices.Contains(r.methods, req.Method)
+	}
+	if unsafe && !matchHost(req, r.allowHosts) {
+		return nil, fmt.Errorf("%w: %s %s", ErrUnsafeRequestMethod, req.Method, req.URL)
 	}
 	return r.RoundTripper.RoundTrip(req)
 }
 
 // Recorder represents a type used to record and replay client and server
 // interactions.
+//
+// A Recorder is safe for concurrent use, e.g. by the parallel subtests of a
+// test sharing a single recorder. Each request is replayed from a copy of
+// the interaction, which the hooks of kind [BeforeResponseReplayHook] and
+// the client may modify. With replayable interactions, a request is always
+// answered with the first matching interaction, regardless of the order of
+// concurrent requests; otherwise, concurrent requests matching the same
+// interactions are answered in the order, in which they reach the recorder.
 type Recorder struct {
+	// mu guards the configuration, which may be changed at runtime.
+	mu sync.RWMutex
+
 	// Cassette used by the recorder
 	cassette *cassette.Cassette
 
@@ -186,17 +364,148 @@ type Recorder struct {
 	// the server.
 	blockUnsafeMethods bool
 
+	// unsafeMethods are the methods, which are blocked instead of the
+	// methods, which are not safe, if not nil.
+	unsafeMethods []string
+
+	// unsafeAllowHosts are the hosts, which are exempt from blocking
+	// unsafe methods.
+	unsafeAllowHosts []string
+
+	// offline specifies whether all requests to the real endpoints are
+	// blocked.
+	offline bool
+
+	// blockHosts are the hosts, which must never be reached by real
+	// requests.
+	blockHosts []string
+
+	// downgradeHTTP1 specifies whether responses recorded over HTTP/2 are
+	// replayed as HTTP/1.1 responses.
+	downgradeHTTP1 bool
+
+	// bufferChunked specifies whether responses recorded with chunked
+	// transfer encoding are replayed with the length of their body.
+	bufferChunked bool
+
+	// repairContentLength specifies whether the content length of replayed
+	// responses is fixed up after applying the hooks.
+	repairContentLength bool
+
+	// uncompressedPolicy specifies how responses, which were transparently
+	// decompressed when recording, are presented to the client.
+	uncompressedPolicy UncompressedPolicy
+
+	// rangeRequests specifies whether responses to range requests are
+	// synthesized from the recorded responses.
+	rangeRequests bool
+
+	// largeBodyLimit is the size of request bodies, above which they are
+	// streamed to a temporary file.
+	largeBodyLimit int64
+
+	// largeBodyPolicy specifies how large request bodies are recorded.
+	largeBodyPolicy LargeBodyPolicy
+
+	// multipartParts specifies whether the parts of multipart/form-data
+	// request bodies are recorded separately.
+	multipartParts bool
+
+	// digestOnly are the URL patterns of the requests, whose bodies are
+	// recorded as digests only.
+	digestOnly []*regexp.Regexp
+
+	// conditionalRequests specifies whether conditional requests are
+	// replayed with synthesized 304 Not Modified responses.
+	conditionalRequests bool
+
+	// varyMatching specifies whether interactions with a Vary header are
+	// matched on the varied headers.
+	varyMatching bool
+
+	// replayTags restricts replay to the interactions with any of the
+	// tags.
+	replayTags []string
+
+	// replayExcludeTags excludes the interactions with any of the tags from
+	// replay.
+	replayExcludeTags []string
+
+	// decodeContent specifies whether encoded response bodies are stored
+	// decoded in the cassette.
+	decodeContent bool
+
+	// prettyPrint are the formats of the response bodies, which are
+	// pretty-printed before saving.
+	prettyPrint []string
+
+	// canonicalHeaders specifies whether header keys are canonicalized
+	// and their values sorted before saving.
+	canonicalHeaders bool
+
+	// lineEndings specifies how the line endings of text bodies are
+	// normalized before matching and saving.
+	lineEndings cassette.LineEnding
+
+	// bodyPolicies are the policies for storing response bodies by their
+	// media type.
+	bodyPolicies map[string]BodyPolicy
+
+	// collapseRedirects specifies whether recorded redirect chains are
+	// replayed as their final response.
+	collapseRedirects bool
+
+	// reportUnused specifies whether the interactions, which were never
+	// replayed, and the requests, which missed the cassette, are logged when
+	// stopping the recorder.
+	reportUnused bool
+
+	// captured are the interactions captured by the recorder, as opposed
+	// to the ones loaded from the cassette.
+	captured map[*cassette.Interaction]bool
+
+	// misses are the requests, which did not match any interaction of the
+	// cassette.
+	misses map[*cassette.Cassette][]string
+
 	// skipRequestLatency specifies whether to simulate the latency of the
 	// recorded interaction.
 	skipRequestLatency bool
 
 	// Passthrough handlers
-	passthroughs []PassthroughFunc
+	passthroughs []*passthrough
+
+	// onlyHosts are the hosts, to which requests are recorded and replayed,
+	// if any. Requests to any other host are passed through.
+	onlyHosts []string
 
 	// hooks is a list of hooks, which are invoked in different
 	// stages of the playback.
 	hooks []*Hook
 
+	// replayCassetteNames are the names of the cassettes, which are
+	// replayed in addition to the default cassette.
+	replayCassetteNames []string
+
+	// replayCassettes are the loaded cassettes named by
+	// replayCassetteNames.
+	replayCassettes *cassette.Set
+
+	// stubs are the synthetic interactions registered using
+	// [Recorder.Stub].
+	stubs []*cassette.Interaction
+
+	// nextHookID is the id of the last hook added using
+	// [Recorder.AddHook].
+	nextHookID HookID
+
+	// requestHooks are invoked on the live requests, before they are
+	// sent to the real server.
+	requestHooks []RequestHookFunc
+
+	// startHooks are invoked when the recorder starts using a cassette.
+	startHooks []StartHookFunc
+
 	// matcher generates hashes from HTTP requests for matching.
 	matcher cassette.RequestMatcher
 
@@ -204,7 +513,97 @@ type Recorder struct {
 	// replayed multiple times.
 	replayableInteractions bool
 
+	// normalizers rewrite non-deterministic values in requests and
+	// responses before matching and saving.
+	normalizers []cassette.Normalizer
+
+	// stripQueryParams are URL query parameters, which are removed from
+	// the recorded interactions and ignored when matching.
+	stripQueryParams []string
+
+	// hostMatchers are the matchers used for requests to specific hosts,
+	// instead of the matcher.
+	hostMatchers []hostMatcher
+
+	// cassetteTTL is the maximum age of a recorded interaction, after
+	// which it is re-recorded instead of replayed.
+	cassetteTTL time.Duration
+
+	// refreshed tracks the cassettes, in which expired interactions were
+	// re-recorded, and which need to be saved.
+	refreshed map[*cassette.Cassette]bool
+
+	// cassettes are the additional cassettes selected for individual
+	// requests using [ContextWithCassette], keyed by cassette name.
+	cassettes map[string]*cassette.Cassette
+
+	// loading tracks the additional cassettes being loaded, and is closed
+	// once the cassette is loaded.
+	loading map[string]chan struct{}
+
+	// matcherVersion is incremented whenever the matcher is replaced using
+	// [Recorder.SetMatcher].
+	matcherVersion int
+
+	// cassetteDir is the directory, which relative cassette names are
+	// resolved against.
+	cassetteDir string
+
+	// failOnEmptyCassette specifies whether loading an existing cassette
+	// without interactions fails in the replaying modes.
+	failOnEmptyCassette bool
+
+	// splitByHost specifies whether requests using the default cassette
+	// are recorded into, and replayed from a separate cassette per
+	// destination host.
+	splitByHost bool
+
+	// shards is the number of shards, which requests using the default
+	// cassette are recorded into.
+	shards int
+
+	// envOverrides specifies whether the recorder configuration may be
+	// overridden using environment variables.
+	envOverrides bool
+
+	// secretScanner scans interactions for leaked credentials before
+	// saving the cassette.
+	secretScanner *cassette.SecretScanner
+
+	// opts are the options, which the recorder was created with, and which
+	// are shared with its child recorders, see [Recorder.Run].
+	opts []Option
+
+	// binaryFormat specifies whether cassettes are stored in the binary
+	// CBOR format.
+	binaryFormat bool
+
 	withCompression bool
+
+	// shared specifies whether the recorder is shared between tests, which
+	// use cassettes of their own, see [NewShared].
+	shared bool
+
+	// flushSignals are the signals, on which the recorder is stopped.
+	flushSignals []os.Signal
+
+	// stopSignals removes the signal handler of the recorder.
+	stopSignals func()
+
+	// onDuplicates specifies how loading cassettes treats duplicate
+	// interactions.
+	onDuplicates cassette.DuplicatePolicy
+
+	// strictDecoding specifies whether loading cassettes fails on unknown
+	// fields.
+	strictDecoding bool
+
+	// readOnlyCassette specifies whether the cassette files are never
+	// written.
+	readOnlyCassette bool
+
+	// compressionLevel is the gzip compression level of the cassettes.
+	compressionLevel int
 }
 
 // Option is a function which configures the [Recorder].
@@ -216,6 +615,59 @@ func WithCompression(val bool) Option {
 	}
 }
 
+// WithCompressionLevel is an [Option], which configures the [Recorder] to
+// compress cassettes using the given gzip compression level, from
+// [compress/gzip.BestSpeed] to [compress/gzip.BestCompression]. Lower levels
+// trade some size for much faster saves of large cassettes. It only has an
+// effect, if compression is enabled, see [WithCompression].
+func WithCompressionLevel(level int) Option {
+	return func(r *Recorder) {
+		r.compressionLevel = level
+	}
+}
+
+// WithBinaryFormat is an [Option], which configures the [Recorder] to store
+// cassettes in the compact binary CBOR format, instead of YAML. This is
+// useful for very large cassettes, where the time to parse YAML and the size
+// of the files dominate. See [cassette.Convert] for converting cassettes to
+// YAML for a review.
+func WithBinaryFormat(val bool) Option {
+	return func(r *Recorder) {
+		r.binaryFormat = val
+	}
+}
+
+// WithDuplicateCheck is an [Option], which configures how the [Recorder]
+// treats cassettes, whose interactions match the same requests, when
+// loading them, i.e. to log a warning listing their ids using
+// [cassette.DuplicatesWarn], or to fail using [cassette.DuplicatesError].
+func WithDuplicateCheck(policy cassette.DuplicatePolicy) Option {
+	return func(r *Recorder) {
+		r.onDuplicates = policy
+	}
+}
+
+// WithStrictDecoding is an [Option], which configures the [Recorder] to fail
+// loading YAML cassettes with unknown fields, e.g. typos in hand-edited
+// cassettes, instead of ignoring them. See [cassette.Cassette.Strict].
+func WithStrictDecoding(val bool) Option {
+	return func(r *Recorder) {
+		r.strictDecoding = val
+	}
+}
+
+// WithReadOnlyCassette is an [Option], which configures the [Recorder] to
+// never write the cassette files, regardless of the mode, e.g. to protect
+// hand-redacted fixtures from being clobbered by a replay run. Interactions
+// recorded in modes, which would normally save them, are replayed for the
+// lifetime of the recorder, but are discarded by [Recorder.Stop], without
+// applying the before-save hooks. See [cassette.Cassette.ReadOnly].
+func WithReadOnlyCassette(val bool) Option {
+	return func(r *Recorder) {
+		r.readOnlyCassette = val
+	}
+}
+
 // WithMode is an [Option], which configures the [Recorder] to run in the
 // specified mode.
 func WithMode(mode Mode) Option {
@@ -234,13 +686,244 @@ func WithRealTransport(rt http.RoundTripper) Option {
 
 // WithBlockUnsafeMethods is an [Option], which configures the [Recorder] to
 // block HTTP requests, which are not considered "Safe Methods", according to
-// RFC 9110, section 9.2.1.
+// RFC 9110, section 9.2.1. See [WithUnsafeMethods] and [WithAllowUnsafeHosts]
+// for customizing the blocked requests.
 func WithBlockUnsafeMethods(val bool) Option {
 	return func(r *Recorder) {
 		r.blockUnsafeMethods = val
 	}
 }
 
+// WithUnsafeMethods is an [Option], which configures the [Recorder] to
+// consider exactly the given methods unsafe, instead of the methods, which
+// are not safe according to RFC 9110, e.g. to block GET requests to
+// endpoints with side effects, or to allow POST requests to endpoints
+// without. It only has an effect, if blocking unsafe methods is enabled, see
+// [WithBlockUnsafeMethods].
+func WithUnsafeMethods(methods ...string) Option {
+	return func(r *Recorder) {
+		// A non-nil empty list blocks no methods at all
+		r.unsafeMethods = append(slices.Clip(r.unsafeMethods), methods...)
+		if r.unsafeMethods == nil {
+			r.unsafeMethods = []string{}
+		}
+	}
+}
+
+// WithAllowUnsafeHosts is an [Option], which configures the [Recorder] to
+// exempt requests to the given hosts from blocking unsafe methods, see
+// [WithBlockUnsafeMethods]. Hosts are matched as described in
+// [WithOnlyHosts].
+func WithAllowUnsafeHosts(hosts ...string) Option {
+	return func(r *Recorder) {
+		r.unsafeAllowHosts = append(r.unsafeAllowHosts, hosts...)
+	}
+}
+
+// WithOffline is an [Option], which configures the [Recorder] to block all
+// requests, which would be sent to the real endpoints, including the ones
+// in [ModePassthrough] and the ones matching a [PassthroughFunc]. Such
+// requests fail with [ErrRealNetworkBlocked], which guarantees that tests
+// never reach the network, even when a cassette is incomplete. Expired
+// interactions are replayed, regardless of the cassette TTL.
+//
+// Offline mode may also be enabled using the [EnvOffline] environment
+// variable.
+func WithOffline(val bool) Option {
+	return func(r *Recorder) {
+		r.offline = val
+	}
+}
+
+// WithBlockHosts is an [Option], which configures the [Recorder] to refuse
+// any real request to the given hosts with [ErrHostBlocked], regardless of
+// the mode, e.g. to make sure tests never reach production. Recorded
+// interactions for the hosts are still replayed. Hosts are matched as
+// described in [WithOnlyHosts].
+func WithBlockHosts(hosts ...string) Option {
+	return func(r *Recorder) {
+		r.blockHosts = append(r.blockHosts, hosts...)
+	}
+}
+
+// WithHTTP1Downgrade is an [Option], which configures the [Recorder] to
+// replay responses, which were recorded over HTTP/2, as HTTP/1.1 responses.
+// By default responses are replayed using the recorded protocol.
+func WithHTTP1Downgrade(val bool) Option {
+	return func(r *Recorder) {
+		r.downgradeHTTP1 = val
+	}
+}
+
+// WithPreserveChunked is an [Option], which configures whether the
+// [Recorder] replays responses, which were recorded with chunked transfer
+// encoding, as chunked responses without a Content-Length, which is the
+// default. When set to false, such responses are replayed with the length
+// of the recorded body, as if they were sent at once, unless they carry
+// trailers, which only exist on chunked responses.
+func WithPreserveChunked(val bool) Option {
+	return func(r *Recorder) {
+		r.bufferChunked = !val
+	}
+}
+
+// WithContentLengthRepair is an [Option], which configures whether the
+// [Recorder] fixes up the content length of replayed responses after
+// applying the hooks of kind [BeforeResponseReplayHook], so that clients do
+// not receive a body whose length disagrees with its headers, which is the
+// default. See [cassette.RepairContentLength] for details.
+func WithContentLengthRepair(val bool) Option {
+	return func(r *Recorder) {
+		r.repairContentLength = val
+	}
+}
+
+// WithRangeRequests is an [Option], which configures the [Recorder] to ignore
+// the Range and If-Range headers when matching requests, and to replay
+// requests for a range with a 206 Partial Content response synthesized from
+// the matching recorded response. This allows resumable downloads, which
+// request varying ranges, to be replayed. See
+// [cassette.Interaction.GetHTTPRangeResponse] for details.
+func WithRangeRequests(val bool) Option {
+	return func(r *Recorder) {
+		r.rangeRequests = val
+	}
+}
+
+// WithConditionalRequests is an [Option], which configures the [Recorder] to
+// ignore the If-None-Match and If-Modified-Since headers when matching
+// requests, and to replay a 304 Not Modified response, when the conditions
+// are satisfied by the validators of the matching recorded response. See
+// [cassette.Interaction.GetHTTPConditionalResponse] for details.
+func WithConditionalRequests(val bool) Option {
+	return func(r *Recorder) {
+		r.conditionalRequests = val
+	}
+}
+
+// WithVaryMatching is an [Option], which configures the [Recorder] to match
+// requests against interactions, whose recorded response carries a Vary
+// header, on exactly the varied request headers, as an HTTP cache would.
+// Other headers are ignored for such interactions, while the rest of the
+// request is matched as usual. See [cassette.Cassette.MatchVary].
+func WithVaryMatching(val bool) Option {
+	return func(r *Recorder) {
+		r.varyMatching = val
+	}
+}
+
+// WithReplayTags is an [Option], which configures the [Recorder] to replay
+// only the interactions, which have at least one of the given tags. This
+// allows test groups to use different slices of a large shared cassette.
+// Interactions are tagged using [cassette.ContextWithTags] when recording,
+// or by a hook calling [cassette.Interaction.AddTag].
+// See [cassette.Cassette.IncludeTags].
+func WithReplayTags(tags ...string) Option {
+	return func(r *Recorder) {
+		r.replayTags = append(r.replayTags, tags...)
+	}
+}
+
+// WithReplayExcludeTags is an [Option], which configures the [Recorder] to
+// never replay the interactions, which have any of the given tags. See
+// [cassette.Cassette.ExcludeTags].
+func WithReplayExcludeTags(tags ...string) Option {
+	return func(r *Recorder) {
+		r.replayExcludeTags = append(r.replayExcludeTags, tags...)
+	}
+}
+
+// WithDecodeContent is an [Option], which configures the [Recorder] to decode
+// response bodies according to their Content-Encoding header, so that they
+// are stored as readable text in the cassette. The original encoding is
+// recorded, and the body is encoded again on replay, which keeps the
+// Content-Encoding and Content-Length headers consistent. Bodies with
+// unsupported content codings are stored as is.
+//
+// The bodies are decoded right after capturing, so that hooks operate on the
+// decoded bodies.
+func WithDecodeContent(val bool) Option {
+	return func(r *Recorder) {
+		r.decodeContent = val
+	}
+}
+
+// WithPrettyPrint is an [Option], which configures the [Recorder] to
+// pretty-print response bodies of the given formats, e.g.
+// [cassette.FormatJSON], before saving the cassette, which makes reviewing
+// and diffing the recorded bodies much easier. The bodies are compacted again
+// on replay, with the Content-Length header fixed up accordingly. See
+// [cassette.PrettyPrintResponseBody] for details.
+//
+// The bodies are pretty-printed after the other hooks of kind
+// [BeforeSaveHook] were applied.
+func WithPrettyPrint(formats ...string) Option {
+	return func(r *Recorder) {
+		r.prettyPrint = append(r.prettyPrint, formats...)
+	}
+}
+
+// WithCanonicalHeaders is an [Option], which configures the [Recorder] to
+// canonicalize the keys of the recorded headers, and to sort the values of
+// each header before saving the cassette, so that clients emitting headers
+// with different casing or order between runs do not produce spurious diffs.
+// See [cassette.CanonicalizeHeaders] for details.
+//
+// Note, that the order of the values of some headers, e.g. Via, is
+// significant, and is not preserved.
+func WithCanonicalHeaders(val bool) Option {
+	return func(r *Recorder) {
+		r.canonicalHeaders = val
+	}
+}
+
+// WithLineEndings is an [Option], which configures the [Recorder] to
+// convert the line endings of text request and response bodies, so that
+// cassettes recorded on Windows and on Linux do not differ. Requests are
+// converted before matching, and interactions are converted before the
+// cassette is saved on disk. See [cassette.NormalizeLineEndings] for
+// details. By default, the exact bytes of the bodies are preserved.
+func WithLineEndings(le cassette.LineEnding) Option {
+	return func(r *Recorder) {
+		r.lineEndings = le
+	}
+}
+
+// WithFailOnEmptyCassette is an [Option], which configures the [Recorder]
+// to fail with [cassette.ErrEmptyCassette], when the cassette exists, but
+// contains no interactions in [ModeReplayOnly] and [ModeRecordOnce], which
+// almost always indicates a botched earlier recording. Otherwise the
+// requests fail later with [cassette.ErrInteractionNotFound].
+func WithFailOnEmptyCassette(val bool) Option {
+	return func(r *Recorder) {
+		r.failOnEmptyCassette = val
+	}
+}
+
+// WithCollapseRedirects is an [Option], which configures the [Recorder] to
+// replay recorded redirect chains as their final response, instead of
+// replaying each hop of the chain. This is useful for clients, which do not
+// follow redirects. Each hop of a redirect chain followed by the client is
+// recorded as a separate interaction, linked to the previous hop by its
+// ParentID. See [cassette.Cassette.FollowRedirects].
+func WithCollapseRedirects(val bool) Option {
+	return func(r *Recorder) {
+		r.collapseRedirects = val
+	}
+}
+
+// WithUnusedReport is an [Option], which configures the [Recorder] to log a
+// summary of the interactions, which were never replayed, and of the
+// requests, which did not match any interaction, when the recorder is
+// stopped. Unlike a strict check, this never fails, which helps pruning
+// stale interactions from cassettes incrementally. The summary is logged
+// using the default [slog.Logger].
+func WithUnusedReport(val bool) Option {
+	return func(r *Recorder) {
+		r.reportUnused = val
+	}
+}
+
 // WithSkipRequestLatency is an [Option], which configures the [Recorder] whether
 // to simulate the latency of the recorded interaction.  When set to false it
 // will block for the period of time taken by the original request to simulate
@@ -256,7 +939,7 @@ func WithSkipRequestLatency(val bool) Option {
 // [PassthroughFunc] predicate.
 func WithPassthrough(passfunc PassthroughFunc) Option {
 	return func(r *Recorder) {
-		r.passthroughs = append(r.passthroughs, passfunc)
+		r.passthroughs = append(r.passthroughs, &passthrough{fn: passfunc})
 	}
 }
 
@@ -269,6 +952,39 @@ func WithHook(handler HookFunc, kind HookKind) Option {
 	}
 }
 
+// WithOnRecorderStartHook is an [Option], which configures the [Recorder] to
+// invoke the provided hook when it starts using a cassette, see
+// [StartHookFunc]. The hooks are invoked in the order they were added, and
+// an error returned by a hook is returned by [New],
+// [Recorder.InsertCassette], or the request selecting the cassette
+// respectively.
+func WithOnRecorderStartHook(handler StartHookFunc) Option {
+	return func(r *Recorder) {
+		r.startHooks = append(r.startHooks, handler)
+	}
+}
+
+// WithBeforeRequestHook is an [Option], which configures the [Recorder] to
+// invoke the provided hook on the live request, before it is sent to the real
+// server, see [RequestHookFunc]. The hooks are invoked in the order they were
+// added, and before requests to blocked hosts are rejected, see
+// [WithBlockHosts], so that rewritten hosts are checked.
+func WithBeforeRequestHook(handler RequestHookFunc) Option {
+	return func(r *Recorder) {
+		r.requestHooks = append(r.requestHooks, handler)
+	}
+}
+
+// WithRequestAwareHook is an [Option], which configures the [Recorder] to
+// invoke the provided hook at the specified playback stage, along with the
+// originating request of the interaction, see [RequestAwareHookFunc].
+func WithRequestAwareHook(handler RequestAwareHookFunc, kind HookKind) Option {
+	return func(r *Recorder) {
+		hook := NewRequestAwareHook(handler, kind)
+		r.hooks = append(r.hooks, hook)
+	}
+}
+
 // WithMatcher is an [Option] that configures the [Recorder] to use the
 // provided [cassette.RequestMatcher] for matching HTTP requests against recorded
 // interactions.
@@ -302,6 +1018,64 @@ func WithHasher(hasher HasherFunc) Option {
 	}
 }
 
+// queryStrippingMatcher removes query parameters from a request before
+// passing it to the wrapped matcher.
+type queryStrippingMatcher struct {
+	matcher cassette.RequestMatcher
+	params  []string
+}
+
+func (m *queryStrippingMatcher) Hash(r *http.Request) (string, error) {
+	u, err := url.Parse(cassette.StripQueryParams(r.URL.String(), m.params...))
+	if err != nil {
+		return "", err
+	}
+
+	return cassette.HashWith(m.matcher, r, func(stripped *http.Request) {
+		stripped.URL = u
+		stripped.RequestURI = cassette.StripQueryParams(r.RequestURI, m.params...)
+	})
+}
+
+// headerIgnoringMatcher removes headers from a request before passing it to
+// the wrapped matcher.
+type headerIgnoringMatcher struct {
+	matcher cassette.RequestMatcher
+	headers []string
+}
+
+func (m *headerIgnoringMatcher) Hash(r *http.Request) (string, error) {
+	return cassette.HashWith(m.matcher, r, func(stripped *http.Request) {
+		for _, h := range m.headers {
+			stripped.Header.Del(h)
+		}
+	})
+}
+
+// WithStripQueryParams is an [Option], which configures the [Recorder] to
+// remove the specified URL query parameters from the recorded interactions,
+// and to ignore them when matching requests. This is useful for signed URLs,
+// which contain secrets and change on every request.
+func WithStripQueryParams(params ...string) Option {
+	return func(r *Recorder) {
+		r.stripQueryParams = append(r.stripQueryParams, params...)
+	}
+}
+
+// WithCassetteTTL is an [Option], which configures the [Recorder] to
+// re-record interactions, which were recorded more than ttl ago, instead of
+// replaying them. Expired interactions are replaced in place and the
+// cassette is saved when the recorder is stopped. If the real endpoint
+// cannot be reached, the expired interaction is replayed instead.
+//
+// Interactions without a recording time never expire. The TTL has no effect
+// in [ModeReplayOnly], which never performs real requests.
+func WithCassetteTTL(ttl time.Duration) Option {
+	return func(r *Recorder) {
+		r.cassetteTTL = ttl
+	}
+}
+
 // WithReplayableInteractions is an [Option], which configures the [Recorder] to
 // allow replaying interactions multiple times. This is useful in situations
 // when you need to hit the same endpoint multiple times and want to replay the
@@ -312,71 +1086,317 @@ func WithReplayableInteractions(val bool) Option {
 	}
 }
 
-// New creates a new [Recorder] and configures it using the provided options.
-func New(cassetteName string, opts ...Option) (*Recorder, error) {
-	r := &Recorder{
-		cassetteName:           cassetteName,
-		mode:                   ModeRecordOnce,
-		realTransport:          http.DefaultTransport,
-		passthroughs:           make([]PassthroughFunc, 0),
-		hooks:                  make([]*Hook, 0),
-		blockUnsafeMethods:     false,
-		skipRequestLatency:     false,
-		matcher:                cassette.DefaultMatcher,
-		replayableInteractions: false,
+// WithNormalizers is an [Option], which configures the [Recorder] to
+// normalize non-deterministic values, such as UUIDs or timestamps, using the
+// provided [cassette.Normalizer] functions. Requests are normalized before
+// matching, and interactions are normalized before the cassette is saved on
+// disk. See [cassette.DefaultNormalizers] for the set of built-in
+// normalizers.
+func WithNormalizers(normalizers ...cassette.Normalizer) Option {
+	return func(r *Recorder) {
+		r.normalizers = append(r.normalizers, normalizers...)
 	}
+}
 
-	for _, opt := range opts {
+// WithSecretScanner is an [Option], which configures the [Recorder] to scan
+// the recorded interactions for leaked credentials before saving the
+// cassette. When secrets are detected, [Recorder.Stop] fails with a
+// [*cassette.SecretsError] listing the offending interactions, and the
+// cassette is not written on disk. Redaction hooks of kind [BeforeSaveHook]
+// are applied before scanning.
+func WithSecretScanner(scanner *cassette.SecretScanner) Option {
+	return func(r *Recorder) {
+		r.secretScanner = scanner
+	}
+}
+
+// WithAnonymizePII is an [Option], which configures the [Recorder] to
+// replace IP addresses and email addresses in the recorded interactions with
+// deterministic fake values, right before the cassette is saved on disk.
+func WithAnonymizePII() Option {
+	return withBuiltinHook(AnonymizePII(), BeforeSaveHook)
+}
+
+// WithStripVolatileFields is an [Option], which configures the [Recorder] to
+// remove the fields of the interactions changing whenever the test runs,
+// e.g. the Date header and the response duration, right before the cassette
+// is saved on disk. See [StripVolatileFields] for details.
+func WithStripVolatileFields() Option {
+	return withBuiltinHook(StripVolatileFields(), BeforeSaveHook)
+}
+
+// NormalizeHook returns a [HookFunc], which normalizes the interaction using
+// the given normalizers.
+func NormalizeHook(normalizers ...cassette.Normalizer) HookFunc {
+	return func(i *cassette.Interaction) error {
+		cassette.NormalizeInteraction(i, normalizers...)
+		return nil
+	}
+}
+
+// LineEndingsHook returns a [HookFunc], which converts the line endings of
+// the text bodies of the interaction, see [cassette.NormalizeLineEndings].
+func LineEndingsHook(le cassette.LineEnding) HookFunc {
+	return func(i *cassette.Interaction) error {
+		cassette.NormalizeLineEndings(i, le)
+		return nil
+	}
+}
+
+// DecodeContentHook returns a [HookFunc], which decodes the response body of
+// the interaction according to its Content-Encoding header. See
+// [cassette.DecodeResponseBody] for details. Bodies with unsupported content
+// codings are left as is.
+func DecodeContentHook() HookFunc {
+	return func(i *cassette.Interaction) error {
+		err := cassette.DecodeResponseBody(i)
+		if errors.Is(err, cassette.ErrUnsupportedContentEncoding) {
+			return nil
+		}
+		return err
+	}
+}
+
+// PrettyPrintHook returns a [HookFunc], which pretty-prints the response
+// body of the interaction, if it has one of the given formats. See
+// [cassette.PrettyPrintResponseBody] for details.
+func PrettyPrintHook(formats ...string) HookFunc {
+	return func(i *cassette.Interaction) error {
+		return cassette.PrettyPrintResponseBody(i, formats...)
+	}
+}
+
+// CanonicalHeadersHook returns a [HookFunc], which canonicalizes the headers
+// of the interaction. See [cassette.CanonicalizeHeaders] for details.
+func CanonicalHeadersHook() HookFunc {
+	return func(i *cassette.Interaction) error {
+		cassette.CanonicalizeHeaders(i)
+		return nil
+	}
+}
+
+// New creates a new [Recorder] and configures it using the provided options.
+func New(cassetteName string, opts ...Option) (*Recorder, error) {
+	r := &Recorder{
+		cassetteName:           cassetteName,
+		mode:                   ModeRecordOnce,
+		realTransport:          defaultRealTransport(),
+		passthroughs:           make([]*passthrough, 0),
+		hooks:                  make([]*Hook, 0),
+		blockUnsafeMethods:     false,
+		skipRequestLatency:     false,
+		matcher:                cassette.DefaultMatcher,
+		replayableInteractions: false,
+		repairContentLength:    true,
+		envOverrides:           true,
+		cassetteDir:            DefaultCassetteDir(),
+		refreshed:              make(map[*cassette.Cassette]bool),
+		cassettes:              make(map[string]*cassette.Cassette),
+		loading:                make(map[string]chan struct{}),
+		captured:               make(map[*cassette.Interaction]bool),
+		misses:                 make(map[*cassette.Cassette][]string),
+		opts:                   opts,
+	}
+
+	// The project config file provides the defaults, which are
+	// overridden by the options.
+	configOpts, err := projectConfigOptions()
+	if err != nil {
+		return nil, err
+	}
+	for _, opt := range configOpts {
+		opt(r)
+	}
+
+	for _, opt := range opts {
 		opt(r)
 	}
 
+	if r.envOverrides {
+		if err := r.applyEnvOverrides(); err != nil {
+			return nil, err
+		}
+	}
+
+	r.matcher = r.wrapMatcher(r.matcher)
+
+	if len(r.stripQueryParams) > 0 {
+		stripHook := func(i *cassette.Interaction) error {
+			i.Request.URL = cassette.StripQueryParams(i.Request.URL, r.stripQueryParams...)
+			i.Request.RequestURI = cassette.StripQueryParams(i.Request.RequestURI, r.stripQueryParams...)
+			return nil
+		}
+		r.hooks = append([]*Hook{newBuiltinHook(stripHook, AfterCaptureHook)}, r.hooks...)
+	}
+
+	if r.decodeContent {
+		r.hooks = append([]*Hook{newBuiltinHook(DecodeContentHook(), AfterCaptureHook)}, r.hooks...)
+	}
+
+	if len(r.normalizers) > 0 {
+		r.hooks = append(r.hooks, newBuiltinHook(NormalizeHook(r.normalizers...), BeforeSaveHook))
+	}
+
+	if r.canonicalHeaders {
+		r.hooks = append(r.hooks, newBuiltinHook(CanonicalHeadersHook(), BeforeSaveHook))
+	}
+
+	if r.lineEndings != cassette.PreserveLineEndings {
+		r.hooks = append(r.hooks, newBuiltinHook(LineEndingsHook(r.lineEndings), BeforeSaveHook))
+	}
+
+	if len(r.prettyPrint) > 0 {
+		r.hooks = append(r.hooks, newBuiltinHook(PrettyPrintHook(r.prettyPrint...), BeforeSaveHook))
+	}
+
+	if len(r.bodyPolicies) > 0 {
+		r.hooks = append(r.hooks, newBuiltinHook(BodyPolicyHook(r.bodyPolicies), BeforeSaveHook))
+	}
+
 	// Configure the cassette based on the recorder configuration
-	var err error
-	r.cassette, err = r.getCassette()
+	r.cassette, err = r.defaultCassette(r.cassetteName, r.mode)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := r.loadReplayCassettes(); err != nil {
+		return nil, err
+	}
+
+	if err := r.startCassette(r.cassette, r.mode); err != nil {
+		return nil, err
+	}
+
+	r.watchSignals()
+
 	return r, nil
 }
 
+// wrapMatcher wraps the given matcher, so that requests to specific hosts
+// are matched using their matchers, and that it ignores the stripped query
+// parameters, range and conditional headers, and normalizes requests and
+// their line endings before matching.
+func (rec *Recorder) wrapMatcher(matcher cassette.RequestMatcher) cassette.RequestMatcher {
+	if len(rec.hostMatchers) > 0 {
+		matcher = &hostRoutingMatcher{matcher: matcher, hosts: rec.hostMatchers}
+	}
+
+	if len(rec.stripQueryParams) > 0 {
+		matcher = &queryStrippingMatcher{matcher: matcher, params: rec.stripQueryParams}
+	}
+
+	var ignoreHeaders []string
+	if rec.rangeRequests {
+		ignoreHeaders = append(ignoreHeaders, "Range", "If-Range")
+	}
+	if rec.conditionalRequests {
+		ignoreHeaders = append(ignoreHeaders, "If-None-Match", "If-Modified-Since")
+	}
+	if len(ignoreHeaders) > 0 {
+		matcher = &headerIgnoringMatcher{matcher: matcher, headers: ignoreHeaders}
+	}
+
+	if len(rec.normalizers) > 0 {
+		matcher = cassette.NewNormalizingMatcher(matcher, rec.normalizers...)
+	}
+
+	if rec.lineEndings != cassette.PreserveLineEndings {
+		matcher = cassette.NewLineEndingMatcher(matcher, rec.lineEndings)
+	}
+
+	return matcher
+}
+
+// SetMatcher replaces the [cassette.RequestMatcher] used for matching
+// requests against recorded interactions, and rebuilds the hash index of the
+// cassette. This allows adjusting the matching rules between subtests,
+// without creating a new recorder.
+//
+// Note, that the hashes persisted in the cassette reflect the matcher in use
+// when the cassette is saved.
+func (rec *Recorder) SetMatcher(matcher cassette.RequestMatcher) error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.matcher = rec.wrapMatcher(matcher)
+	rec.matcherVersion++
+	for _, c := range slices.Concat(rec.allCassettesLocked(), rec.replayCassettes.Cassettes()) {
+		if err := c.SetMatcher(rec.matcher); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetHasher is a convenience for [Recorder.SetMatcher], which uses the
+// provided [HasherFunc] for matching requests.
+func (rec *Recorder) SetHasher(hasher HasherFunc) error {
+	return rec.SetMatcher(&hasherAdapter{fn: hasher})
+}
+
 // getCassette creates a new [*cassette.Cassette], or loads an already existing
-// one depending on the mode of the recorder.
-func (rec *Recorder) getCassette() (*cassette.Cassette, error) {
-	if rec.cassetteName == "" {
+// one depending on the given mode of the recorder, which matches requests
+// using the given matcher.
+func (rec *Recorder) getCassette(name string, mode Mode, matcher cassette.RequestMatcher) (*cassette.Cassette, error) {
+	if name == "" {
 		return nil, ErrNoCassetteName
 	}
 
-	tape := cassette.New(rec.cassetteName)
+	if rec.cassetteDir != "" && !filepath.IsAbs(name) {
+		name = filepath.Join(rec.cassetteDir, name)
+	}
+
+	tape := cassette.New(name)
 
 	// Configure the cassette based on the recorder configuration
 	tape.ReplayableInteractions = rec.replayableInteractions
-	tape.Matcher = rec.matcher
+	tape.Matcher = matcher
 	tape.CompressionEnabled = rec.withCompression
-
-	_, statErr := os.Stat(tape.File())
+	tape.CompressionLevel = rec.compressionLevel
+	tape.Binary = rec.binaryFormat
+	tape.SecretScanner = rec.secretScanner
+	tape.ReadOnly = rec.readOnlyCassette
+	tape.Strict = rec.strictDecoding
+	tape.OnDuplicates = rec.onDuplicates
+	tape.MatchVary = rec.varyMatching
+	tape.IncludeTags = rec.replayTags
+	tape.ExcludeTags = rec.replayExcludeTags
+
+	file, statErr := tape.Locate()
 	if statErr != nil && !os.IsNotExist(statErr) {
 		// Another kind of error occurred (e.g., permissions)
-		return nil, fmt.Errorf("failed to access cassette file %s: %w", tape.File(), statErr)
+		return nil, fmt.Errorf("failed to access cassette file %s: %w", file, statErr)
 	}
 	cassetteExists := statErr == nil
 
 	loadTape := func() error {
 		if err := tape.Load(); err != nil {
-			return fmt.Errorf("failed to load cassette %s: %w", tape.File(), err)
+			return fmt.Errorf("failed to load cassette %s: %w", file, err)
+		}
+		return nil
+	}
+	checkEmpty := func() error {
+		if rec.failOnEmptyCassette && len(tape.Interactions) == 0 {
+			return fmt.Errorf("%w: %s", cassette.ErrEmptyCassette, file)
 		}
 		return nil
 	}
 
-	switch rec.mode {
+	switch mode {
 	case ModeRecordOnly, ModePassthrough:
 		// Always create a new cassette file.
 
-	case ModeRecordOnce, ModeReplayWithNewEpisodes:
+	case ModeRecordOnce, ModeReplayWithNewEpisodes, ModeRefresh:
 		if cassetteExists {
 			if err := loadTape(); err != nil {
 				return nil, err
 			}
+			if mode == ModeRecordOnce {
+				if err := checkEmpty(); err != nil {
+					return nil, err
+				}
+			}
 		}
 
 	case ModeReplayOnly:
@@ -386,6 +1406,9 @@ func (rec *Recorder) getCassette() (*cassette.Cassette, error) {
 		if err := loadTape(); err != nil {
 			return nil, err
 		}
+		if err := checkEmpty(); err != nil {
+			return nil, err
+		}
 
 	default:
 		return nil, ErrInvalidMode
@@ -396,29 +1419,49 @@ func (rec *Recorder) getCassette() (*cassette.Cassette, error) {
 
 // getRoundTripper returns the [http.RoundTripper] used by the recorder.
 func (rec *Recorder) getRoundTripper() http.RoundTripper {
+	if rec.offline {
+		return offlineRoundTripper{}
+	}
+	rt := rec.realTransport
 	if rec.blockUnsafeMethods {
-		return &blockUnsafeMethodsRoundTripper{
-			RoundTripper: rec.realTransport,
+		rt = &blockUnsafeMethodsRoundTripper{
+			RoundTripper: rt,
+			methods:      rec.unsafeMethods,
+			allowHosts:   rec.unsafeAllowHosts,
 		}
 	}
-	return rec.realTransport
+	if len(rec.blockHosts) > 0 {
+		rt = &blockHostsRoundTripper{
+			RoundTripper: rt,
+			hosts:        rec.blockHosts,
+		}
+	}
+	if len(rec.requestHooks) > 0 {
+		rt = &beforeRequestRoundTripper{
+			RoundTripper: rt,
+			hooks:        rec.requestHooks,
+		}
+	}
+	return rt
 }
 
-// requestHandler proxies requests to their original destination
+// requestHandler proxies requests to their original destination, and records
+// the interactions in, or replays them from the given cassette.
 // If serverResponse is provided, this is used for the recording instead of using RoundTrip
-func (rec *Recorder) requestHandler(r *http.Request, serverResponse *http.Response) (*cassette.Interaction, error) {
+func (rec *Recorder) requestHandler(c *cassette.Cassette, r *http.Request, serverResponse *http.Response) (*cassette.Interaction, error) {
 	if err := r.Context().Err(); err != nil {
 		return nil, err
 	}
 
+	mode := rec.Mode()
 	switch {
-	case rec.mode == ModeReplayOnly:
-		return rec.cassette.GetInteraction(r)
-	case rec.mode == ModeReplayWithNewEpisodes:
-		interaction, err := rec.cassette.GetInteraction(r)
+	case mode == ModeReplayOnly:
+		return c.GetInteraction(r)
+	case mode == ModeReplayWithNewEpisodes:
+		interaction, err := c.GetInteraction(r)
 		if err == nil {
 			// Interaction found, return it
-			return interaction, nil
+			return rec.replayOrRefresh(c, r, serverResponse, interaction)
 		} else if errors.Is(err, cassette.ErrInteractionNotFound) {
 			// Interaction not found, we have a new episode
 			break
@@ -426,20 +1469,36 @@ func (rec *Recorder) requestHandler(r *http.Request, serverResponse *http.Respon
 			// Any other error is an error
 			return nil, err
 		}
-	case rec.mode == ModeRecordOnce && !rec.cassette.IsNew:
+	case mode == ModeRecordOnce && !c.IsNew:
 		// We've got an existing cassette, return what we've got
-		return rec.cassette.GetInteraction(r)
-	case rec.mode == ModePassthrough:
+		interaction, err := c.GetInteraction(r)
+		if err != nil {
+			return nil, err
+		}
+		return rec.replayOrRefresh(c, r, serverResponse, interaction)
+	case mode == ModePassthrough:
 		// Passthrough requests always hit the original endpoint
 		break
-	case (rec.mode == ModeRecordOnly || rec.mode == ModeRecordOnce) && rec.cassette.ReplayableInteractions:
+	case mode == ModeRefresh:
+		interaction, err := c.GetInteraction(r)
+		if err == nil {
+			// Interaction found, overwrite it with a fresh one
+			return rec.refreshInteraction(c, r, serverResponse, interaction)
+		} else if errors.Is(err, cassette.ErrInteractionNotFound) {
+			// Interaction not found, we have to record it
+			break
+		} else {
+			// Any other error is an error
+			return nil, err
+		}
+	case (mode == ModeRecordOnly || mode == ModeRecordOnce) && c.ReplayableInteractions:
 		// When running with replayable interactions look for existing
 		// interaction first, so we avoid hitting multiple times the
 		// same endpoint.
-		interaction, err := rec.cassette.GetInteraction(r)
+		interaction, err := c.GetInteraction(r)
 		if err == nil {
 			// Interaction found, return it
-			return interaction, nil
+			return rec.replayOrRefresh(c, r, serverResponse, interaction)
 		} else if errors.Is(err, cassette.ErrInteractionNotFound) {
 			// Interaction not found, we have to record it
 			break
@@ -452,25 +1511,84 @@ func (rec *Recorder) requestHandler(r *http.Request, serverResponse *http.Respon
 		break
 	}
 
-	// Read and cache the request body for recording and form parsing.
-	var bodyBytes []byte
-	if r.Body != nil && r.Body != http.NoBody {
-		var err error
-		bodyBytes, err = io.ReadAll(r.Body)
-		if err != nil {
-			return nil, err
+	interaction, err := rec.captureInteraction(c, r, serverResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	c.AddInteraction(interaction)
+
+	// The recorded interaction may be replayed concurrently already, and
+	// must not be modified by the hooks applied to the response.
+	return interaction.Clone(), nil
+}
+
+// replayOrRefresh returns the given interaction for replay, unless it has
+// expired according to the configured cassette TTL, in which case it is
+// re-recorded. If the real endpoint cannot be reached, the expired
+// interaction is replayed instead.
+func (rec *Recorder) replayOrRefresh(c *cassette.Cassette, r *http.Request, serverResponse *http.Response, interaction *cassette.Interaction) (*cassette.Interaction, error) {
+	if rec.offline || rec.cassetteTTL <= 0 || interaction.RecordedAt.IsZero() || interaction.Age() <= rec.cassetteTTL {
+		return interaction, nil
+	}
+
+	fresh, err := rec.refreshInteraction(c, r, serverResponse, interaction)
+	if err != nil {
+		if ctxErr := r.Context().Err(); ctxErr != nil {
+			return nil, ctxErr
 		}
-		r.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+		slog.Warn("failed to re-record expired interaction, replaying it instead", "cassette", c.Name, "interaction_id", interaction.ID, "error", err)
+		return interaction, nil
+	}
+
+	return fresh, nil
+}
+
+// refreshInteraction re-records the given interaction and overwrites it in
+// place in the cassette.
+func (rec *Recorder) refreshInteraction(c *cassette.Cassette, r *http.Request, serverResponse *http.Response, interaction *cassette.Interaction) (*cassette.Interaction, error) {
+	fresh, err := rec.captureInteraction(c, r, serverResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.ReplaceInteraction(interaction.ID, fresh); err != nil {
+		return nil, err
+	}
+	rec.mu.Lock()
+	rec.refreshed[c] = true
+	rec.mu.Unlock()
+
+	return fresh.Clone(), nil
+}
+
+// captureInteraction performs the request to its original destination and
+// captures the request/response pair, after applying the after-capture
+// hooks. If serverResponse is provided, it is used for the recording instead
+// of performing the request.
+func (rec *Recorder) captureInteraction(c *cassette.Cassette, r *http.Request, serverResponse *http.Response) (*cassette.Interaction, error) {
+	// Read and cache the request body for recording and form parsing.
+	bodyBytes, err := cassette.RequestBody(r)
+	if err != nil {
+		return nil, err
 	}
 
 	// Parse form values directly from the original request.
 	// This is much cheaper than DumpRequestOut + ReadRequest.
-	if err := r.ParseForm(); err != nil {
+	// Large and multipart bodies were spooled, and are sent from their
+	// file.
+	spooled := spooledBodyFromContext(r.Context())
+	if spooled != nil {
+		if r.Body, err = spooled.open(); err != nil {
+			return nil, err
+		}
+		r.GetBody = spooled.open
+	} else if err := r.ParseForm(); err != nil {
 		return nil, err
 	}
 
 	// Restore body for RoundTrip (only needed when not using serverResponse)
-	if serverResponse == nil && len(bodyBytes) > 0 {
+	if serverResponse == nil && spooled == nil && len(bodyBytes) > 0 {
 		r.Body = io.NopCloser(bytes.NewReader(bodyBytes))
 	}
 
@@ -478,21 +1596,45 @@ func (rec *Recorder) requestHandler(r *http.Request, serverResponse *http.Respon
 	// If serverResponse is provided, use it instead
 	start := time.Now()
 	resp := serverResponse
+	var continued atomic.Bool
 	if resp == nil {
+		// Capture the 100 Continue handshake, while preserving any
+		// client trace of the caller.
+		ctx := httptrace.WithClientTrace(r.Context(), &httptrace.ClientTrace{
+			Got100Continue: func() { continued.Store(true) },
+		})
+
 		var err error
-		resp, err = rec.getRoundTripper().RoundTrip(r)
+		out := r.WithContext(ctx)
+		if spooled != nil && spooled.header != nil {
+			out.Header = spooled.header
+		}
+		resp, err = rec.getRoundTripper().RoundTrip(out)
 		if err != nil {
 			return nil, err
 		}
 		defer resp.Body.Close()
+	} else if expectsContinue(r) && len(bodyBytes) > 0 {
+		// The server sends 100 Continue, once the handler starts
+		// reading the request body.
+		continued.Store(true)
 	}
 	requestDuration := time.Since(start)
 
-	respBody, err := io.ReadAll(resp.Body)
+	var respBody, respDigest string
+	var respLength int64
+	if rec.isDigestOnly(r) {
+		respDigest, respLength, err = spoolResponseBody(r, resp.Body)
+	} else {
+		respBody, err = readResponseBody(resp)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	// Link the hops of a redirect chain followed by the client
+	parentID := redirectParentID(r)
+
 	// Add interaction to the cassette
 	interaction := &cassette.Interaction{
 		Request: cassette.Request{
@@ -521,19 +1663,35 @@ func (rec *Recorder) requestHandler(r *http.Request, serverResponse *http.Respon
 			Trailer:          resp.Trailer,
 			ContentLength:    resp.ContentLength,
 			Uncompressed:     resp.Uncompressed,
-			Body:             string(respBody),
+			Continue:         continued.Load(),
+			Body:             respBody,
+			BodyDigest:       respDigest,
+			BodyLength:       respLength,
 			Headers:          resp.Header,
 			Duration:         requestDuration,
 		},
+		ParentID:   parentID,
+		RecordedAt: start.UTC().Truncate(time.Second),
+		Metadata:   maps.Clone(cassette.MetadataFromContext(r.Context())),
+		Tags:       slices.Clone(cassette.TagsFromContext(r.Context())),
+	}
+	if spooled != nil {
+		if err := rec.recordSpooledBody(c, interaction, spooled); err != nil {
+			return nil, err
+		}
 	}
 
 	// Apply after-capture hooks before we add the interaction to
 	// the in-memory cassette.
-	if err := rec.applyHooks(interaction, AfterCaptureHook); err != nil {
+	if err := rec.applyHooks(r, interaction, AfterCaptureHook); err != nil {
 		return nil, err
 	}
 
-	rec.cassette.AddInteraction(interaction)
+	if rec.reportUnused {
+		rec.mu.Lock()
+		rec.captured[interaction] = true
+		rec.mu.Unlock()
+	}
 
 	return interaction, nil
 }
@@ -542,33 +1700,104 @@ func (rec *Recorder) requestHandler(r *http.Request, serverResponse *http.Respon
 // interactions if running in one of the recording modes. When
 // running in ModePassthrough no cassette will be saved on disk.
 func (rec *Recorder) Stop() error {
-	cassetteFile := rec.cassette.File()
-	_, err := os.Stat(cassetteFile)
+	// Restore the default transport, if the recorder was installed globally
+	_ = rec.UninstallGlobal()
+	rec.unwatchSignals()
+
+	rec.mu.Lock()
+	cassettes := rec.allCassettesLocked()
+	rec.mu.Unlock()
+
+	if rec.reportUnused {
+		for _, c := range cassettes {
+			rec.logUnused(c)
+		}
+	}
+
+	for _, c := range cassettes {
+		if err := rec.stopCassette(c); err != nil {
+			return err
+		}
+	}
+
+	rec.mu.Lock()
+	clear(rec.captured)
+	clear(rec.misses)
+	rec.mu.Unlock()
+
+	return nil
+}
+
+// logUnused logs the interactions of the cassette, which were loaded but
+// never replayed, and the requests, which did not match any interaction.
+func (rec *Recorder) logUnused(c *cassette.Cassette) {
+	rec.mu.RLock()
+	defer rec.mu.RUnlock()
+
+	c.Lock()
+	var unused []string
+	for _, i := range c.Interactions {
+		if !i.WasReplayed() && !rec.captured[i] {
+			unused = append(unused, fmt.Sprintf("%d: %s %s", i.ID, i.Request.Method, i.Request.URL))
+		}
+	}
+	c.Unlock()
+
+	if len(unused) > 0 {
+		slog.Warn("cassette has interactions, which were never replayed", "cassette", c.Name, "count", len(unused), "interactions", unused)
+	}
+	if misses := rec.misses[c]; len(misses) > 0 {
+		slog.Warn("requests did not match any interaction of cassette", "cassette", c.Name, "count", len(misses), "requests", misses)
+	}
+}
+
+// stopCassette saves the given cassette if needed, and applies the
+// on-recorder-stop hooks to its interactions.
+func (rec *Recorder) stopCassette(c *cassette.Cassette) error {
+	_, err := c.Locate()
 	cassetteExists := !os.IsNotExist(err)
 
-	// Only save if there are interactions to save
-	hasInteractions := len(rec.cassette.Interactions) > 0
+	// Only save if there are interactions to save, and leave loaded
+	// cassettes untouched, unless interactions were added or replaced.
+	hasInteractions := len(c.Interactions) > 0 && (c.IsNew || c.Modified())
+
+	rec.mu.RLock()
+	refreshed := rec.refreshed[c]
+	rec.mu.RUnlock()
 
 	// Nothing to do for ModeReplayOnly and ModePassthrough here
+	mode := rec.Mode()
 	switch {
-	case rec.mode == ModeRecordOnly || rec.mode == ModeReplayWithNewEpisodes:
+	case mode == ModeRecordOnly || mode == ModeReplayWithNewEpisodes || mode == ModeRefresh:
 		if hasInteractions {
-			if err := rec.persistCassette(); err != nil {
+			if err := rec.persistCassette(c); err != nil {
 				return err
 			}
 		}
 
-	case rec.mode == ModeRecordOnce && !cassetteExists:
+	case mode == ModeRecordOnce && (!cassetteExists || refreshed):
 		if hasInteractions {
-			if err := rec.persistCassette(); err != nil {
+			if err := rec.persistCassette(c); err != nil {
 				return err
 			}
 		}
 	}
 
 	// Apply on-recorder-stop hooks
-	for _, interaction := range rec.cassette.Interactions {
-		if err := rec.applyHooks(interaction, OnRecorderStopHook); err != nil {
+	for _, interaction := range c.Interactions {
+		if err := rec.applyHooks(nil, interaction, OnRecorderStopHook); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// startCassette applies the start hooks to the cassette, which the recorder
+// starts using in the given mode.
+func (rec *Recorder) startCassette(c *cassette.Cassette, mode Mode) error {
+	for _, hook := range rec.startHooks {
+		if err := hook(c, mode); err != nil {
 			return err
 		}
 	}
@@ -577,25 +1806,40 @@ func (rec *Recorder) Stop() error {
 }
 
 // persistCassette persists the cassette on disk for future re-use
-func (rec *Recorder) persistCassette() error {
+func (rec *Recorder) persistCassette(c *cassette.Cassette) error {
+	if c.ReadOnly {
+		return nil
+	}
+
 	// Apply any before-save hooks
-	for _, interaction := range rec.cassette.Interactions {
-		if err := rec.applyHooks(interaction, BeforeSaveHook); err != nil {
+	for _, interaction := range c.Interactions {
+		if err := rec.applyHooks(nil, interaction, BeforeSaveHook); err != nil {
 			return err
 		}
 	}
 
-	return rec.cassette.Save()
+	return c.Save()
 }
 
 // applyHooks applies the registered hooks of the given kind with the
-// specified interaction
-func (rec *Recorder) applyHooks(i *cassette.Interaction, kind HookKind) error {
-	for _, hook := range rec.hooks {
-		if hook.Kind == kind {
-			if err := hook.Handler(i); err != nil {
-				return err
-			}
+// specified interaction, and its originating request, if any.
+func (rec *Recorder) applyHooks(r *http.Request, i *cassette.Interaction, kind HookKind) error {
+	rec.mu.RLock()
+	hooks := rec.hooks
+	rec.mu.RUnlock()
+
+	for _, hook := range hooks {
+		if hook.Kind != kind {
+			continue
+		}
+		var err error
+		if hook.Handler != nil {
+			err = hook.Handler(i)
+		} else if hook.RequestHandler != nil {
+			err = hook.RequestHandler(r, i)
+		}
+		if err != nil {
+			return err
 		}
 	}
 
@@ -607,29 +1851,115 @@ func (rec *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
 	return rec.executeAndRecord(req, nil)
 }
 
+// replayOrRecord returns the stub or the replayed interaction matching the
+// request, or records the request/response pair.
+func (rec *Recorder) replayOrRecord(req *http.Request, serverResponse *http.Response) (*cassette.Interaction, error) {
+	// Stubs only make sense for requests sent to the real server
+	if serverResponse == nil {
+		stub, err := rec.matchStub(req)
+		if err != nil || stub != nil {
+			return stub, err
+		}
+	}
+
+	if i, err := rec.replayFromCassettes(req); err != nil || i != nil {
+		return i, err
+	}
+
+	c, err := rec.cassetteFor(req)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction, err := rec.requestHandler(c, req, serverResponse)
+	if err != nil {
+		if rec.reportUnused && errors.Is(err, cassette.ErrInteractionNotFound) {
+			rec.mu.Lock()
+			rec.misses[c] = append(rec.misses[c], req.Method+" "+req.URL.String())
+			rec.mu.Unlock()
+		}
+		return nil, err
+	}
+
+	// Replay the final response of a recorded redirect chain at once
+	if rec.collapseRedirects && interaction.WasReplayed() && isRedirect(interaction.Response.Code) {
+		final, err := c.FollowRedirects(interaction.ID)
+		if err != nil {
+			return nil, err
+		}
+		if final.ID != interaction.ID {
+			interaction = final.Clone()
+		}
+	}
+
+	return interaction, nil
+}
+
 // executeAndRecord is used internally by the HTTPMiddleware to allow recording a response on the server side
 func (rec *Recorder) executeAndRecord(req *http.Request, serverResponse *http.Response) (*http.Response, error) {
 	// Passthrough mode, use real transport
-	if rec.mode == ModePassthrough {
+	if rec.Mode() == ModePassthrough {
 		return rec.getRoundTripper().RoundTrip(req)
 	}
 
 	// Apply passthrough handler functions
-	for _, passthroughFunc := range rec.passthroughs {
-		if passthroughFunc(req) {
+	rec.mu.RLock()
+	passthroughs := rec.passthroughs
+	onlyHosts := rec.onlyHosts
+	rec.mu.RUnlock()
+	if len(onlyHosts) > 0 && !matchHost(req, onlyHosts) {
+		return rec.getRoundTripper().RoundTrip(req)
+	}
+	for _, p := range passthroughs {
+		if p.fn(req) {
 			return rec.getRoundTripper().RoundTrip(req)
 		}
 	}
 
-	interaction, err := rec.requestHandler(req, serverResponse)
+	// Stream large and multipart request bodies to temporary files, which
+	// are matched on their digest and parts.
+	if serverResponse == nil {
+		var cleanup func()
+		var err error
+		if req, cleanup, err = rec.spoolBody(req); err != nil {
+			return nil, err
+		}
+		defer cleanup()
+	}
+
+	// Responses, of which only the digest is recorded, are sent to the
+	// client from a temporary file while recording.
+	var digestOnly *digestOnlyBody
+	if serverResponse == nil && rec.isDigestOnly(req) {
+		digestOnly = &digestOnlyBody{}
+		req = req.WithContext(contextWithDigestOnlyBody(req.Context(), digestOnly))
+		defer digestOnly.remove()
+	}
+
+	// Read and hash the request only once, while it is matched against
+	// stubs and cassettes.
+	req = req.WithContext(cassette.ContextWithRequestCache(req.Context()))
+
+	interaction, err := rec.replayOrRecord(req, serverResponse)
 	if err != nil {
 		return nil, err
 	}
 
 	// Apply before-response-replay hooks
-	if err := rec.applyHooks(interaction, BeforeResponseReplayHook); err != nil {
+	if err := rec.applyHooks(req, interaction, BeforeResponseReplayHook); err != nil {
 		return nil, err
 	}
+	if rec.repairContentLength && interaction.WasReplayed() {
+		cassette.RepairContentLength(interaction)
+	}
+
+	// Replay the 100 Continue handshake to the client trace of the
+	// caller, which was already notified for real requests.
+	if interaction.WasReplayed() && interaction.Response.Continue && expectsContinue(req) {
+		if trace := httptrace.ContextClientTrace(req.Context()); trace != nil && trace.Got100Continue != nil {
+			trace.Got100Continue()
+		}
+	}
 
 	select {
 	case <-req.Context().Done():
@@ -640,15 +1970,176 @@ func (rec *Recorder) executeAndRecord(req *http.Request, serverResponse *http.Re
 			<-time.After(interaction.Response.Duration)
 		}
 
+		resp, err := rec.getHTTPResponse(req, interaction)
+		if err != nil {
+			return nil, err
+		}
+		if digestOnly != nil && digestOnly.path != "" {
+			if resp.Body, err = digestOnly.open(); err != nil {
+				return nil, err
+			}
+		}
+		if err := rec.applyUncompressedPolicy(resp); err != nil {
+			return nil, err
+		}
+		if rec.downgradeHTTP1 {
+			cassette.DowngradeToHTTP1(resp)
+		}
+		if rec.bufferChunked {
+			if err := bufferResponse(resp); err != nil {
+				return nil, err
+			}
+		}
+
+		// Tag the recorded redirect responses, so that the next hop of the
+		// chain can be linked to the interaction.
+		if !interaction.WasReplayed() && isRedirect(resp.StatusCode) && resp.Request != nil {
+			ctx := context.WithValue(resp.Request.Context(), redirectParentContextKey{}, interaction.ID)
+			resp.Request = resp.Request.WithContext(ctx)
+		}
+
+		return resp, nil
+	}
+}
+
+// getHTTPResponse converts the interaction to the response for the given
+// request. Responses to conditional and range requests are synthesized from
+// replayed interactions, if configured. Interactions recorded for the
+// request are returned as is.
+func (rec *Recorder) getHTTPResponse(req *http.Request, interaction *cassette.Interaction) (*http.Response, error) {
+	if !interaction.WasReplayed() {
 		return interaction.GetHTTPResponse()
 	}
+
+	// Conditions are evaluated before ranges, as per RFC 9110, section 13.2.2
+	if rec.conditionalRequests {
+		resp, err := interaction.GetHTTPConditionalResponse(req.Header.Get("If-None-Match"), req.Header.Get("If-Modified-Since"))
+		if err != nil || resp.StatusCode == http.StatusNotModified {
+			return resp, err
+		}
+	}
+
+	if rec.rangeRequests {
+		return interaction.GetHTTPRangeResponse(req.Header.Get("Range"), req.Header.Get("If-Range"))
+	}
+
+	return interaction.GetHTTPResponse()
+}
+
+// isRedirect returns true, if the status code is a redirect status code.
+func isRedirect(code int) bool {
+	return code >= 300 && code < 400 && code != http.StatusNotModified
+}
+
+// redirectParentContextKey is the context key for the id of the interaction
+// of a recorded redirect response, which is carried by the request of the
+// response, so that the response is not retained by the recorder.
+type redirectParentContextKey struct{}
+
+// redirectParentID returns the id of the interaction of the redirect
+// response, which the client followed to send the request, if any.
+func redirectParentID(r *http.Request) *int {
+	if r.Response == nil || r.Response.Request == nil {
+		return nil
+	}
+	if id, ok := r.Response.Request.Context().Value(redirectParentContextKey{}).(int); ok {
+		return &id
+	}
+	return nil
+}
+
+// expectsContinue returns true, if the request has an "Expect: 100-continue"
+// header.
+func expectsContinue(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Expect"), "100-continue")
+}
+
+// readResponseBody reads the body of the response into a pooled buffer,
+// which is copied once into the returned string.
+func readResponseBody(resp *http.Response) (string, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	// The declared length is only a hint, which is capped, and which is
+	// ignored for responses without a body.
+	noBody := resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified ||
+		(resp.Request != nil && resp.Request.Method == http.MethodHead)
+	if resp.ContentLength > 0 && !noBody {
+		buf.Grow(int(min(resp.ContentLength, maxPooledBufferSize)))
+	}
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// bufferResponse rewrites a chunked response without trailers to a response
+// with the length of its body.
+func bufferResponse(resp *http.Response) error {
+	if !slices.Contains(resp.TransferEncoding, "chunked") || len(resp.Trailer) > 0 {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	resp.TransferEncoding = nil
+	resp.ContentLength = int64(len(body))
+	resp.Header = resp.Header.Clone()
+	if resp.Header == nil {
+		resp.Header = make(http.Header)
+	}
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	return nil
 }
 
 // Mode returns recorder state
 func (rec *Recorder) Mode() Mode {
+	rec.mu.RLock()
+	defer rec.mu.RUnlock()
 	return rec.mode
 }
 
+// SetMode switches the recorder to the given mode at runtime, e.g. to toggle
+// a long-lived recorder between recording and replaying. The in-memory
+// cassette is kept as is, i.e. it is neither reloaded from, nor saved on
+// disk when switching modes.
+func (rec *Recorder) SetMode(mode Mode) error {
+	if _, ok := modeNames[mode]; !ok {
+		return ErrInvalidMode
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.mode = mode
+
+	return nil
+}
+
+// AddPassthrough registers the [PassthroughFunc] at runtime, so that
+// requests satisfying the predicate are passed through to the original
+// endpoint. The returned function removes the passthrough again.
+func (rec *Recorder) AddPassthrough(passfunc PassthroughFunc) (remove func()) {
+	p := &passthrough{fn: passfunc}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.passthroughs = append(slices.Clip(rec.passthroughs), p)
+
+	return func() {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		rec.passthroughs = slices.DeleteFunc(slices.Clone(rec.passthroughs), func(other *passthrough) bool {
+			return other == p
+		})
+	}
+}
+
 // GetDefaultClient returns an HTTP client with a pre-configured
 // transport
 func (rec *Recorder) GetDefaultClient() *http.Client {
@@ -658,11 +2149,55 @@ func (rec *Recorder) GetDefaultClient() *http.Client {
 	return client
 }
 
+// Cassette returns the in-memory cassette used by the recorder, which allows
+// inspecting what was recorded or replayed without stopping the recorder.
+// It returns nil, if the cassette was ejected.
+func (rec *Recorder) Cassette() *cassette.Cassette {
+	rec.mu.RLock()
+	defer rec.mu.RUnlock()
+	return rec.cassette
+}
+
+// Interactions returns a snapshot of the interactions in the cassette used
+// by the recorder, including the ones recorded so far.
+func (rec *Recorder) Interactions() []*cassette.Interaction {
+	c := rec.Cassette()
+	if c == nil {
+		return nil
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	return slices.Clone(c.Interactions)
+}
+
+// ReplayedCount returns the number of interactions in the cassette, which
+// were replayed so far.
+func (rec *Recorder) ReplayedCount() int {
+	c := rec.Cassette()
+	if c == nil {
+		return 0
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	count := 0
+	for _, i := range c.Interactions {
+		if i.WasReplayed() {
+			count++
+		}
+	}
+
+	return count
+}
+
 // IsNewCassette returns true, if the recorder was started with a
 // new/empty cassette. Returns false, if it was started using an
 // existing cassette, which was loaded.
 func (rec *Recorder) IsNewCassette() bool {
-	return rec.cassette.IsNew
+	c := rec.Cassette()
+	return c != nil && c.IsNew
 }
 
 // IsRecording returns true, if the recorder is recording
@@ -676,12 +2211,13 @@ func (rec *Recorder) IsNewCassette() bool {
 // not part of the cassette already. In these cases the recorder is
 // considered to be recording for these modes.
 func (rec *Recorder) IsRecording() bool {
+	mode := rec.Mode()
 	switch {
-	case rec.mode == ModeRecordOnly || rec.mode == ModeReplayWithNewEpisodes:
+	case mode == ModeRecordOnly || mode == ModeReplayWithNewEpisodes || mode == ModeRefresh:
 		return true
-	case rec.mode == ModeReplayOnly || rec.mode == ModePassthrough:
+	case mode == ModeReplayOnly || mode == ModePassthrough:
 		return false
-	case rec.mode == ModeRecordOnce && rec.IsNewCassette():
+	case mode == ModeRecordOnce && rec.IsNewCassette():
 		return true
 	default:
 		return false
@@ -27,17 +27,26 @@ package recorder_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/http/httptrace"
 	"net/url"
 	"os"
 	"path"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/goware/go-vcr/cassette"
 	"github.com/goware/go-vcr/recorder"
@@ -932,13 +941,14 @@ func TestBeforeResponseReplayHook(t *testing.T) {
 		t.Fatal("recorder should not be recording")
 	}
 
+	// The content length is fixed up to match the modified body
 	newTests := []testCase{
 		{
 			method:            http.MethodPost,
 			body:              "foo",
 			wantBody:          dummyBody,
 			wantStatus:        http.StatusOK,
-			wantContentLength: 15,
+			wantContentLength: len(dummyBody),
 			path:              "/api/v1/foo",
 		},
 		{
@@ -946,7 +956,7 @@ func TestBeforeResponseReplayHook(t *testing.T) {
 			body:              "bar",
 			wantBody:          dummyBody,
 			wantStatus:        http.StatusOK,
-			wantContentLength: 15,
+			wantContentLength: len(dummyBody),
 			path:              "/api/v1/bar",
 		},
 	}
@@ -959,6 +969,281 @@ func TestBeforeResponseReplayHook(t *testing.T) {
 	}
 }
 
+func TestBeforeRequestHook(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, "%s go-vcr", r.Method)
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cassPath, err := newCassettePath("test_before_request_hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Inject the credentials, and rewrite the host of the live requests
+	authHook := func(r *http.Request) error {
+		r.Header.Set("Authorization", "Bearer secret")
+		return nil
+	}
+	hostHook := func(r *http.Request) error {
+		r.URL.Host = serverURL.Host
+		r.Host = ""
+		return nil
+	}
+	rec, err := recorder.New(cassPath,
+		recorder.WithBeforeRequestHook(authHook),
+		recorder.WithBeforeRequestHook(hostHook),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test := testCase{
+		method:            http.MethodGet,
+		wantBody:          "GET go-vcr",
+		wantStatus:        http.StatusOK,
+		wantContentLength: 10,
+		path:              "/api/v1/foo",
+	}
+	ctx := context.Background()
+	if err := test.run(ctx, rec.GetDefaultClient(), "http://api.example.invalid"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The changes of the hooks are not recorded
+	c, err := cassette.Load(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Interactions[0].Request.URL; got != "http://api.example.invalid/api/v1/foo" {
+		t.Fatalf("got recorded URL %q", got)
+	}
+	if got := c.Interactions[0].Request.Headers.Get("Authorization"); got != "" {
+		t.Fatalf("got recorded Authorization header %q", got)
+	}
+
+	// Replay without the hooks
+	rec, err = recorder.New(cassPath, recorder.WithMode(recorder.ModeReplayOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+	if err := test.run(ctx, rec.GetDefaultClient(), "http://api.example.invalid"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hook errors abort the request
+	errHook := errors.New("no credentials")
+	rec, err = recorder.New(cassPath,
+		recorder.WithMode(recorder.ModePassthrough),
+		recorder.WithBeforeRequestHook(func(r *http.Request) error { return errHook }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+	if _, err := rec.GetDefaultClient().Get(server.URL); !errors.Is(err, errHook) {
+		t.Fatalf("got error %v, want %v", err, errHook)
+	}
+}
+
+func TestOnRecorderStartHook(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_on_recorder_start_hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type start struct {
+		name         string
+		mode         recorder.Mode
+		interactions int
+	}
+	var starts []start
+	startHook := func(c *cassette.Cassette, mode recorder.Mode) error {
+		starts = append(starts, start{name: c.Name, mode: mode, interactions: len(c.Interactions)})
+		return nil
+	}
+
+	rec, err := recorder.New(cassPath, recorder.WithOnRecorderStartHook(startHook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test := testCase{
+		method:            http.MethodGet,
+		wantBody:          "GET go-vcr\n",
+		wantStatus:        http.StatusOK,
+		wantContentLength: 11,
+		path:              "/api/v1/foo",
+	}
+	if err := test.run(context.Background(), rec.GetDefaultClient(), server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	// Selecting a cassette for requests starts it on first use
+	tenantPath, err := newCassettePath("test_on_recorder_start_hook_tenant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		ctx := recorder.ContextWithCassette(context.Background(), tenantPath)
+		if err := test.run(ctx, rec.GetDefaultClient(), server.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Inserting a cassette starts it as well
+	otherPath, err := newCassettePath("test_on_recorder_start_hook_other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.InsertCassette(otherPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err = recorder.New(cassPath,
+		recorder.WithMode(recorder.ModeReplayOnly),
+		recorder.WithOnRecorderStartHook(startHook),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Stop()
+
+	want := []start{
+		{name: cassPath, mode: recorder.ModeRecordOnce},
+		{name: tenantPath, mode: recorder.ModeRecordOnce},
+		{name: otherPath, mode: recorder.ModeRecordOnce},
+		{name: cassPath, mode: recorder.ModeReplayOnly, interactions: 1},
+	}
+	if !slices.Equal(starts, want) {
+		t.Fatalf("got starts %+v, want %+v", starts, want)
+	}
+
+	// A failing precondition prevents the recorder from starting
+	errPrecondition := errors.New("fixture is missing")
+	_, err = recorder.New(cassPath, recorder.WithOnRecorderStartHook(func(c *cassette.Cassette, mode recorder.Mode) error {
+		return errPrecondition
+	}))
+	if !errors.Is(err, errPrecondition) {
+		t.Fatalf("got error %v, want %v", err, errPrecondition)
+	}
+}
+
+// tenantKey is the context key of the tenant in TestRequestAwareHook.
+type tenantKey struct{}
+
+func TestRequestAwareHook(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_request_aware_hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Record the tenant of each request from its context
+	tenantHook := func(r *http.Request, i *cassette.Interaction) error {
+		if tenant, ok := r.Context().Value(tenantKey{}).(string); ok {
+			i.SetMetadata("tenant", tenant)
+		}
+		return nil
+	}
+	var saveRequests []*http.Request
+	saveHook := func(r *http.Request, i *cassette.Interaction) error {
+		saveRequests = append(saveRequests, r)
+		return nil
+	}
+	rec, err := recorder.New(cassPath,
+		recorder.WithRequestAwareHook(tenantHook, recorder.AfterCaptureHook),
+		recorder.WithRequestAwareHook(saveHook, recorder.BeforeSaveHook),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []testCase{
+		{
+			method:            http.MethodGet,
+			wantBody:          "GET go-vcr\n",
+			wantStatus:        http.StatusOK,
+			wantContentLength: 11,
+			path:              "/api/v1/foo",
+		},
+		{
+			method:            http.MethodGet,
+			wantBody:          "GET go-vcr\n",
+			wantStatus:        http.StatusOK,
+			wantContentLength: 11,
+			path:              "/api/v1/bar",
+		},
+	}
+	tenants := []string{"acme", "globex"}
+	for n, test := range tests {
+		ctx := context.WithValue(context.Background(), tenantKey{}, tenants[n])
+		if err := test.run(ctx, rec.GetDefaultClient(), server.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cassette.Load(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n, i := range c.Interactions {
+		if got := i.Metadata["tenant"]; got != tenants[n] {
+			t.Fatalf("got tenant %q of interaction %d, want %q", got, n, tenants[n])
+		}
+	}
+	if len(saveRequests) != 2 || saveRequests[0] != nil || saveRequests[1] != nil {
+		t.Fatalf("got before-save requests %v, want nil requests", saveRequests)
+	}
+
+	// Replay the responses depending on the tenant
+	replayHook := func(r *http.Request, i *cassette.Interaction) error {
+		if r.Context().Value(tenantKey{}) == "acme" {
+			i.Response.Body = "acme go-vcr"
+			i.Response.ContentLength = int64(len(i.Response.Body))
+		}
+		return nil
+	}
+	rec, err = recorder.New(cassPath,
+		recorder.WithMode(recorder.ModeReplayOnly),
+		recorder.WithRequestAwareHook(replayHook, recorder.BeforeResponseReplayHook),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	tests[0].wantBody, tests[0].wantContentLength = "acme go-vcr", 11
+	for n, test := range tests {
+		ctx := context.WithValue(context.Background(), tenantKey{}, tenants[n])
+		if err := test.run(ctx, rec.GetDefaultClient(), server.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
 func TestReplayableInteractions(t *testing.T) {
 	tc := testCase{
 		method:            http.MethodGet,
@@ -1181,6 +1466,76 @@ func TestBlockRealTransportUnsafeMethods(t *testing.T) {
 	}
 }
 
+func TestUnsafeMethods(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+
+	getCase := testCase{
+		method:            http.MethodGet,
+		wantBody:          "GET go-vcr\n",
+		wantStatus:        http.StatusOK,
+		wantContentLength: 11,
+		path:              "/api/v1/foo",
+	}
+	postCase := testCase{
+		method:            http.MethodPost,
+		body:              "foo",
+		wantBody:          "POST go-vcr\nfoo",
+		wantStatus:        http.StatusOK,
+		wantContentLength: 15,
+		path:              "/api/v1/bar",
+	}
+	blocked := func(tc testCase) testCase {
+		return testCase{method: tc.method, body: tc.body, path: tc.path, wantError: recorder.ErrUnsafeRequestMethod}
+	}
+
+	tests := []struct {
+		name  string
+		opts  []recorder.Option
+		cases []testCase
+	}{
+		{
+			name:  "custom methods",
+			opts:  []recorder.Option{recorder.WithUnsafeMethods(http.MethodGet)},
+			cases: []testCase{blocked(getCase), postCase},
+		},
+		{
+			name:  "allowed hosts",
+			opts:  []recorder.Option{recorder.WithAllowUnsafeHosts("127.0.0.1")},
+			cases: []testCase{getCase, postCase},
+		},
+		{
+			name:  "other hosts",
+			opts:  []recorder.Option{recorder.WithAllowUnsafeHosts("example.com")},
+			cases: []testCase{getCase, blocked(postCase)},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cassPath, err := newCassettePath("test_unsafe_methods")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			opts := append([]recorder.Option{
+				recorder.WithMode(recorder.ModeRecordOnly),
+				recorder.WithBlockUnsafeMethods(true),
+			}, test.opts...)
+			rec, err := recorder.New(cassPath, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rec.Stop()
+
+			for _, tc := range test.cases {
+				if err := tc.run(context.Background(), rec.GetDefaultClient(), server.URL); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
 func TestInvalidRecorderMode(t *testing.T) {
 	// Create recorder
 	opts := []recorder.Option{
@@ -1395,3 +1750,1898 @@ func TestLazyFileCreation(t *testing.T) {
 		}
 	})
 }
+
+func TestNormalizers(t *testing.T) {
+	server := newEchoHttpServer()
+	serverUrl := server.URL
+
+	cassPath, err := newCassettePath("test_normalizers")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := []recorder.Option{
+		recorder.WithNormalizers(cassette.DefaultNormalizers()...),
+	}
+	rec, err := recorder.New(cassPath, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recordTest := testCase{
+		method:            http.MethodPost,
+		body:              "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		wantBody:          "POST go-vcr\n6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		wantStatus:        http.StatusOK,
+		wantContentLength: 48,
+		path:              "/api/v1/users/1234",
+	}
+
+	ctx := context.Background()
+	if err := recordTest.run(ctx, rec.GetDefaultClient(), serverUrl); err != nil {
+		t.Fatal(err)
+	}
+
+	server.Close()
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cassette.Load(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := c.Interactions[0].Request.Body; got != cassette.UUIDPlaceholder {
+		t.Fatalf("expected request body to be normalized, got %q", got)
+	}
+
+	// Replay using different identifiers
+	rec, err = recorder.New(cassPath, append(opts, recorder.WithMode(recorder.ModeReplayOnly))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	replayTest := testCase{
+		method:            http.MethodPost,
+		body:              "6ba7b811-9dad-11d1-80b4-00c04fd430c8",
+		wantBody:          "POST go-vcr\n" + cassette.UUIDPlaceholder,
+		wantStatus:        http.StatusOK,
+		wantContentLength: 48,
+		path:              "/api/v1/users/5678",
+	}
+
+	if err := replayTest.run(ctx, rec.GetDefaultClient(), serverUrl); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStripQueryParams(t *testing.T) {
+	server := newEchoHttpServer()
+	serverUrl := server.URL
+
+	cassPath, err := newCassettePath("test_strip_query_params")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := []recorder.Option{
+		recorder.WithStripQueryParams("api_key", "ts"),
+	}
+	rec, err := recorder.New(cassPath, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test := testCase{
+		method:            http.MethodGet,
+		wantBody:          "GET go-vcr\n",
+		wantStatus:        http.StatusOK,
+		wantContentLength: 11,
+		path:              "/api/v1/foo?api_key=secret&page=1&ts=100",
+	}
+
+	ctx := context.Background()
+	if err := test.run(ctx, rec.GetDefaultClient(), serverUrl); err != nil {
+		t.Fatal(err)
+	}
+
+	server.Close()
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cassette.Load(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := c.Interactions[0].Request.URL, serverUrl+"/api/v1/foo?page=1"; got != want {
+		t.Fatalf("got recorded URL %q, want %q", got, want)
+	}
+
+	// Replay with different values for the stripped parameters
+	rec, err = recorder.New(cassPath, append(opts, recorder.WithMode(recorder.ModeReplayOnly))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	test.path = "/api/v1/foo?api_key=other&page=1&ts=200"
+	if err := test.run(ctx, rec.GetDefaultClient(), serverUrl); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInteractionMetadata(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_interaction_metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Hooks have access to the metadata provided via the request context
+	hook := func(i *cassette.Interaction) error {
+		i.SetMetadata("ticket", "VCR-42")
+		return nil
+	}
+	rec, err := recorder.New(cassPath, recorder.WithHook(hook, recorder.AfterCaptureHook))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test := testCase{
+		method:            http.MethodGet,
+		wantBody:          "GET go-vcr\n",
+		wantStatus:        http.StatusOK,
+		wantContentLength: 11,
+		path:              "/api/v1/foo",
+	}
+
+	ctx := cassette.ContextWithMetadata(context.Background(), map[string]string{"test": t.Name()})
+	if err := test.run(ctx, rec.GetDefaultClient(), server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cassette.Load(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"test": t.Name(), "ticket": "VCR-42"}
+	got := c.Interactions[0].Metadata
+	if len(got) != len(want) || got["test"] != want["test"] || got["ticket"] != want["ticket"] {
+		t.Fatalf("got metadata %v, want %v", got, want)
+	}
+
+	req, err := c.Interactions[0].GetHTTPRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if md := cassette.MetadataFromContext(req.Context()); md["test"] != t.Name() {
+		t.Fatalf("expected recorded request context to carry metadata, got %v", md)
+	}
+}
+
+func TestReplayTags(t *testing.T) {
+	server := newEchoHttpServer()
+	serverUrl := server.URL
+
+	cassPath, err := newCassettePath("test_replay_tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tag the interactions via the request context, and via a hook
+	hook := func(i *cassette.Interaction) error {
+		if strings.HasSuffix(i.Request.URL, "/admin") {
+			i.AddTag("admin")
+		}
+		return nil
+	}
+	rec, err := recorder.New(cassPath, recorder.WithMode(recorder.ModeRecordOnly), recorder.WithHook(hook, recorder.AfterCaptureHook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := []struct {
+		path string
+		tags []string
+	}{
+		{path: "/login", tags: []string{"auth"}},
+		{path: "/admin", tags: []string{"auth"}},
+		{path: "/orders"},
+	}
+	for _, r := range requests {
+		tc := testCase{method: http.MethodGet, wantBody: "GET go-vcr\n", wantStatus: http.StatusOK, wantContentLength: 11, path: r.path}
+		ctx := cassette.ContextWithTags(context.Background(), r.tags...)
+		if err := tc.run(ctx, rec.GetDefaultClient(), serverUrl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	c, err := cassette.Load(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTags := [][]string{{"auth"}, {"auth", "admin"}, nil}
+	for i, want := range wantTags {
+		if got := c.Interactions[i].Tags; !slices.Equal(got, want) {
+			t.Fatalf("got tags %v for interaction %d, want %v", got, i, want)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		opts     []recorder.Option
+		wantHits []string
+	}{
+		{name: "include", opts: []recorder.Option{recorder.WithReplayTags("auth")}, wantHits: []string{"/login", "/admin"}},
+		{name: "exclude", opts: []recorder.Option{recorder.WithReplayExcludeTags("admin")}, wantHits: []string{"/login", "/orders"}},
+		{name: "include and exclude", opts: []recorder.Option{recorder.WithReplayTags("auth"), recorder.WithReplayExcludeTags("admin")}, wantHits: []string{"/login"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := append([]recorder.Option{recorder.WithMode(recorder.ModeReplayOnly)}, test.opts...)
+			rec, err := recorder.New(cassPath, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rec.Stop()
+
+			for _, r := range requests {
+				tc := testCase{method: http.MethodGet, wantError: cassette.ErrInteractionNotFound, path: r.path}
+				if slices.Contains(test.wantHits, r.path) {
+					tc = testCase{method: http.MethodGet, wantBody: "GET go-vcr\n", wantStatus: http.StatusOK, wantContentLength: 11, path: r.path}
+				}
+				if err := tc.run(context.Background(), rec.GetDefaultClient(), serverUrl); err != nil {
+					t.Fatalf("%s: %s", r.path, err)
+				}
+			}
+		})
+	}
+}
+
+func TestCassetteTTL(t *testing.T) {
+	var version atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "version %d", version.Load())
+	}))
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_cassette_ttl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(rec *recorder.Recorder) string {
+		t.Helper()
+		resp, err := rec.GetDefaultClient().Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+
+	// Record the initial cassette and age it
+	rec, err := recorder.New(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get(rec)
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cassette.Load(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Interactions[0].RecordedAt = time.Now().Add(-48 * time.Hour)
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fresh interactions are replayed
+	version.Store(1)
+	rec, err = recorder.New(cassPath, recorder.WithCassetteTTL(72*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := get(rec); got != "version 0" {
+		t.Fatalf("expected recorded response, got %q", got)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Expired interactions are re-recorded
+	rec, err = recorder.New(cassPath, recorder.WithCassetteTTL(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := get(rec); got != "version 1" {
+		t.Fatalf("expected re-recorded response, got %q", got)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = cassette.Load(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Interactions) != 1 {
+		t.Fatalf("expected 1 interaction, got %d", len(c.Interactions))
+	}
+	if body := c.Interactions[0].Response.Body; body != "version 1" {
+		t.Fatalf("expected refreshed interaction to be saved, got %q", body)
+	}
+	if age := c.Interactions[0].Age(); age > time.Hour {
+		t.Fatalf("expected refreshed interaction to be fresh, got age %s", age)
+	}
+}
+
+func TestRefreshMode(t *testing.T) {
+	var version atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s version %d", r.URL.Path, version.Load())
+	}))
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_refresh_mode")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(client *http.Client, path string) {
+		t.Helper()
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	rec, err := recorder.New(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get(rec.GetDefaultClient(), "/a")
+	get(rec.GetDefaultClient(), "/b")
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	version.Store(1)
+	rec, err = recorder.New(cassPath, recorder.WithMode(recorder.ModeRefresh))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rec.IsRecording() {
+		t.Fatal("recorder is not recording")
+	}
+	get(rec.GetDefaultClient(), "/a")
+	get(rec.GetDefaultClient(), "/c")
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cassette.Load(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"/a version 1", "/b version 0", "/c version 1"}
+	if len(c.Interactions) != len(want) {
+		t.Fatalf("expected %d interactions, got %d", len(want), len(c.Interactions))
+	}
+	for i, body := range want {
+		if got := c.Interactions[i].Response.Body; got != body {
+			t.Fatalf("interaction %d: got body %q, want %q", i, got, body)
+		}
+	}
+}
+
+func TestSetMatcher(t *testing.T) {
+	server := newEchoHttpServer()
+	serverUrl := server.URL
+
+	cassPath, err := newCassettePath("test_set_matcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(rec *recorder.Recorder, run string) error {
+		req, err := http.NewRequest(http.MethodGet, serverUrl, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("X-Run", run)
+		resp, err := rec.GetDefaultClient().Do(req)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	rec, err := recorder.New(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := get(rec, "1"); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err = recorder.New(cassPath, recorder.WithMode(recorder.ModeReplayOnly), recorder.WithReplayableInteractions(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	if err := get(rec, "2"); !errors.Is(err, cassette.ErrInteractionNotFound) {
+		t.Fatalf("expected ErrInteractionNotFound, got %v", err)
+	}
+
+	if err := rec.SetMatcher(cassette.NewMatcher(cassette.WithIgnoreHeaders("X-Run"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := get(rec, "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rec.SetMatcher(cassette.DefaultMatcher); err != nil {
+		t.Fatal(err)
+	}
+	if err := get(rec, "2"); !errors.Is(err, cassette.ErrInteractionNotFound) {
+		t.Fatalf("expected ErrInteractionNotFound, got %v", err)
+	}
+}
+
+func TestSetModeAndAddPassthrough(t *testing.T) {
+	server := newEchoHttpServer()
+	serverUrl := server.URL
+
+	cassPath, err := newCassettePath("test_set_mode")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := recorder.New(cassPath, recorder.WithMode(recorder.ModeRecordOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	foo := testCase{
+		method:            http.MethodGet,
+		wantBody:          "GET go-vcr\n",
+		wantStatus:        http.StatusOK,
+		wantContentLength: 11,
+		path:              "/api/v1/foo",
+	}
+	bar := foo
+	bar.path = "/api/v1/bar"
+
+	ctx := context.Background()
+	client := rec.GetDefaultClient()
+
+	// Passthrough requests are not recorded
+	remove := rec.AddPassthrough(func(r *http.Request) bool {
+		return r.URL.Path == "/api/v1/bar"
+	})
+	for _, test := range []testCase{foo, bar} {
+		if err := test.run(ctx, client, serverUrl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := rec.SetMode(recorder.Mode(-42)); !errors.Is(err, recorder.ErrInvalidMode) {
+		t.Fatalf("expected ErrInvalidMode, got %v", err)
+	}
+
+	// Replay the interaction recorded in-memory
+	if err := rec.SetMode(recorder.ModeReplayOnly); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Mode() != recorder.ModeReplayOnly || rec.IsRecording() {
+		t.Fatal("recorder is not in the correct mode")
+	}
+
+	server.Close()
+	if err := foo.run(ctx, client, serverUrl); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the passthrough, the unrecorded request is not found
+	remove()
+	bar.wantError = cassette.ErrInteractionNotFound
+	if err := bar.run(ctx, client, serverUrl); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInspectCassette(t *testing.T) {
+	server := newEchoHttpServer()
+	serverUrl := server.URL
+
+	cassPath, err := newCassettePath("test_inspect_cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []testCase{
+		{
+			method:            http.MethodGet,
+			wantBody:          "GET go-vcr\n",
+			wantStatus:        http.StatusOK,
+			wantContentLength: 11,
+			path:              "/api/v1/foo",
+		},
+		{
+			method:            http.MethodPost,
+			body:              "foo",
+			wantBody:          "POST go-vcr\nfoo",
+			wantStatus:        http.StatusOK,
+			wantContentLength: 15,
+			path:              "/api/v1/bar",
+		},
+	}
+
+	rec, err := recorder.New(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for _, test := range tests {
+		if err := test.run(ctx, rec.GetDefaultClient(), serverUrl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Inspect the recorded interactions before stopping the recorder
+	interactions := rec.Interactions()
+	if len(interactions) != len(tests) {
+		t.Fatalf("expected %d interactions, got %d", len(tests), len(interactions))
+	}
+	if interactions[1].Request.Method != http.MethodPost {
+		t.Fatalf("unexpected method %q", interactions[1].Request.Method)
+	}
+	if rec.Cassette().Name != cassPath {
+		t.Fatalf("unexpected cassette name %q", rec.Cassette().Name)
+	}
+
+	server.Close()
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err = recorder.New(cassPath, recorder.WithMode(recorder.ModeReplayOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	if err := tests[0].run(ctx, rec.GetDefaultClient(), serverUrl); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := rec.ReplayedCount(); n != 1 {
+		t.Fatalf("expected 1 replayed interaction, got %d", n)
+	}
+}
+
+func TestOffline(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		fmt.Fprintf(w, "%s go-vcr\n", r.Method)
+	}))
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_offline")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorded := testCase{
+		method:            http.MethodGet,
+		wantBody:          "GET go-vcr\n",
+		wantStatus:        http.StatusOK,
+		wantContentLength: 11,
+		path:              "/api/v1/foo",
+	}
+
+	rec, err := recorder.New(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := recorded.run(context.Background(), rec.GetDefaultClient(), server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	hits.Store(0)
+
+	rec, err = recorder.New(
+		cassPath,
+		recorder.WithMode(recorder.ModeReplayWithNewEpisodes),
+		recorder.WithOffline(true),
+		recorder.WithReplayableInteractions(true),
+		recorder.WithPassthrough(func(r *http.Request) bool {
+			return r.URL.Path == "/api/v1/passthrough"
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	// Recorded interactions are replayed
+	if err := recorded.run(context.Background(), rec.GetDefaultClient(), server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	// Missing interactions and passthrough requests are blocked
+	for _, path := range []string{"/api/v1/bar", "/api/v1/passthrough"} {
+		_, err := rec.GetDefaultClient().Get(server.URL + path)
+		if !errors.Is(err, recorder.ErrRealNetworkBlocked) {
+			t.Fatalf("%s: expected ErrRealNetworkBlocked, got %v", path, err)
+		}
+	}
+
+	// Passthrough mode is blocked as well
+	if err := rec.SetMode(recorder.ModePassthrough); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rec.GetDefaultClient().Get(server.URL + recorded.path); !errors.Is(err, recorder.ErrRealNetworkBlocked) {
+		t.Fatalf("expected ErrRealNetworkBlocked, got %v", err)
+	}
+
+	if n := hits.Load(); n != 0 {
+		t.Fatalf("expected no requests to reach the server, got %d", n)
+	}
+}
+
+func TestHTTP2Replay(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", r.Method, r.Proto)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_http2_replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(rec *recorder.Recorder) *http.Response {
+		t.Helper()
+		resp, err := rec.GetDefaultClient().Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != "GET HTTP/2.0" {
+			t.Fatalf("unexpected body %q", body)
+		}
+		return resp
+	}
+
+	rec, err := recorder.New(cassPath, recorder.WithRealTransport(server.Client().Transport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := get(rec); resp.ProtoMajor != 2 {
+		t.Fatalf("expected HTTP/2 response, got %s", resp.Proto)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, downgrade := range []bool{false, true} {
+		rec, err := recorder.New(
+			cassPath,
+			recorder.WithMode(recorder.ModeReplayOnly),
+			recorder.WithHTTP1Downgrade(downgrade),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp := get(rec)
+		wantMajor := 2
+		if downgrade {
+			wantMajor = 1
+		}
+		if resp.ProtoMajor != wantMajor {
+			t.Fatalf("downgrade %v: got protocol %s", downgrade, resp.Proto)
+		}
+		if resp.TransferEncoding != nil {
+			t.Fatalf("unexpected transfer encoding %v", resp.TransferEncoding)
+		}
+
+		if err := rec.Stop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTrailerReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		fmt.Fprint(w, "GET go-vcr")
+		w.Header().Set("X-Checksum", "abc123")
+	}))
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_trailer_replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(rec *recorder.Recorder) {
+		t.Helper()
+		resp, err := rec.GetDefaultClient().Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if !slices.Contains(resp.TransferEncoding, "chunked") {
+			t.Fatalf("expected chunked response, got transfer encoding %v", resp.TransferEncoding)
+		}
+		if v, ok := resp.Trailer["X-Checksum"]; !ok || len(v) > 0 {
+			t.Fatalf("expected trailer to be announced and empty before the body is read, got %v", resp.Trailer)
+		}
+		if _, err := io.ReadAll(resp.Body); err != nil {
+			t.Fatal(err)
+		}
+		if got := resp.Trailer.Get("X-Checksum"); got != "abc123" {
+			t.Fatalf("unexpected trailer value %q", got)
+		}
+	}
+
+	rec, err := recorder.New(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get(rec)
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Responses with trailers are never buffered
+	for _, opts := range [][]recorder.Option{nil, {recorder.WithPreserveChunked(false)}} {
+		rec, err := recorder.New(cassPath, append(opts, recorder.WithMode(recorder.ModeReplayOnly))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		get(rec)
+		if err := rec.Stop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestChunkedReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "part1")
+		w.(http.Flusher).Flush()
+		fmt.Fprint(w, "part2")
+	}))
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_chunked_replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(rec *recorder.Recorder) *http.Response {
+		t.Helper()
+		resp, err := rec.GetDefaultClient().Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != "part1part2" {
+			t.Fatalf("unexpected body %q", body)
+		}
+		return resp
+	}
+
+	rec, err := recorder.New(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get(rec)
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name              string
+		opts              []recorder.Option
+		wantChunked       bool
+		wantContentLength int64
+	}{
+		{name: "default", wantChunked: true, wantContentLength: -1},
+		{name: "preserve", opts: []recorder.Option{recorder.WithPreserveChunked(true)}, wantChunked: true, wantContentLength: -1},
+		{name: "buffer", opts: []recorder.Option{recorder.WithPreserveChunked(false)}, wantChunked: false, wantContentLength: 10},
+	}
+
+	for _, test := range tests {
+		rec, err := recorder.New(cassPath, append(test.opts, recorder.WithMode(recorder.ModeReplayOnly))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp := get(rec)
+		if chunked := slices.Contains(resp.TransferEncoding, "chunked"); chunked != test.wantChunked {
+			t.Fatalf("%s: got transfer encoding %v", test.name, resp.TransferEncoding)
+		}
+		if resp.ContentLength != test.wantContentLength {
+			t.Fatalf("%s: got content length %d, want %d", test.name, resp.ContentLength, test.wantContentLength)
+		}
+
+		if err := rec.Stop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExpectContinue(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/reject" {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "received %d bytes", len(body))
+	}))
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_expect_continue")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upload := func(rec *recorder.Recorder, path string, wantStatus int, wantContinue bool) {
+		t.Helper()
+		var continued atomic.Int32
+		ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+			Got100Continue: func() { continued.Add(1) },
+		})
+
+		body := bytes.Repeat([]byte("a"), 1<<16)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+path, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Expect", "100-continue")
+
+		resp, err := rec.GetDefaultClient().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != wantStatus {
+			t.Fatalf("%s: got status %d, want %d", path, resp.StatusCode, wantStatus)
+		}
+		if got := continued.Load() == 1; got != wantContinue {
+			t.Fatalf("%s: got 100 Continue %d times", path, continued.Load())
+		}
+	}
+
+	rec, err := recorder.New(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload(rec, "/upload", http.StatusOK, true)
+	upload(rec, "/reject", http.StatusRequestEntityTooLarge, false)
+
+	interactions := rec.Interactions()
+	if !interactions[0].Response.Continue || interactions[1].Response.Continue {
+		t.Fatal("unexpected 100 Continue handshake recorded")
+	}
+	if len(interactions[0].Request.Body) != 1<<16 {
+		t.Fatalf("unexpected recorded body length %d", len(interactions[0].Request.Body))
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err = recorder.New(cassPath, recorder.WithMode(recorder.ModeReplayOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+	upload(rec, "/upload", http.StatusOK, true)
+	upload(rec, "/reject", http.StatusRequestEntityTooLarge, false)
+}
+
+func TestRangeRequests(t *testing.T) {
+	content := strings.NewReader("0123456789")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file.txt", time.Time{}, content)
+	}))
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_range_requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(rec *recorder.Recorder, rangeHeader string) (*http.Response, string) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		resp, err := rec.GetDefaultClient().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, string(body)
+	}
+
+	rec, err := recorder.New(cassPath, recorder.WithRangeRequests(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, body := get(rec, ""); body != "0123456789" {
+		t.Fatalf("unexpected body %q", body)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	server.Close()
+	rec, err = recorder.New(
+		cassPath,
+		recorder.WithMode(recorder.ModeReplayOnly),
+		recorder.WithRangeRequests(true),
+		recorder.WithReplayableInteractions(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	resp, body := get(rec, "bytes=2-5")
+	if resp.StatusCode != http.StatusPartialContent || body != "2345" {
+		t.Fatalf("unexpected response %d %q", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get("Content-Range"); got != "bytes 2-5/10" {
+		t.Fatalf("unexpected Content-Range %q", got)
+	}
+
+	resp, body = get(rec, "bytes=7-")
+	if resp.StatusCode != http.StatusPartialContent || body != "789" {
+		t.Fatalf("unexpected response %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, "GET go-vcr")
+	}))
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_conditional_requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(rec *recorder.Recorder, ifNoneMatch string) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		resp, err := rec.GetDefaultClient().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	rec, err := recorder.New(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := get(rec, ""); code != http.StatusOK {
+		t.Fatalf("unexpected status %d", code)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err = recorder.New(
+		cassPath,
+		recorder.WithMode(recorder.ModeReplayOnly),
+		recorder.WithConditionalRequests(true),
+		recorder.WithReplayableInteractions(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	tests := []struct {
+		ifNoneMatch string
+		wantCode    int
+	}{
+		{ifNoneMatch: "", wantCode: http.StatusOK},
+		{ifNoneMatch: `"v1"`, wantCode: http.StatusNotModified},
+		{ifNoneMatch: `"v0"`, wantCode: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		if code := get(rec, test.ifNoneMatch); code != test.wantCode {
+			t.Fatalf("If-None-Match %q: got status %d, want %d", test.ifNoneMatch, code, test.wantCode)
+		}
+	}
+}
+
+func TestDecodeContent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		fmt.Fprint(gz, "GET go-vcr")
+		gz.Close()
+	}))
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_decode_content")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(rec *recorder.Recorder) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Disable transparent decompression by the transport
+		req.Header.Set("Accept-Encoding", "gzip")
+
+		resp, err := rec.GetDefaultClient().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.Header.Get("Content-Encoding") != "gzip" {
+			t.Fatalf("unexpected Content-Encoding %q", resp.Header.Get("Content-Encoding"))
+		}
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != "GET go-vcr" {
+			t.Fatalf("unexpected body %q", body)
+		}
+	}
+
+	rec, err := recorder.New(cassPath, recorder.WithDecodeContent(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	get(rec)
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cassette.Load(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := c.Interactions[0].Response; r.Body != "GET go-vcr" || r.DecodedContentEncoding != "gzip" {
+		t.Fatalf("expected decoded body in cassette, got %q (%s)", r.Body, r.DecodedContentEncoding)
+	}
+
+	// Responses with trailers are never buffered
+	for _, opts := range [][]recorder.Option{nil, {recorder.WithPreserveChunked(false)}} {
+		rec, err := recorder.New(cassPath, append(opts, recorder.WithMode(recorder.ModeReplayOnly))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		get(rec)
+		if err := rec.Stop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRedirectChain(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusFound)
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusMovedPermanently)
+		default:
+			fmt.Fprint(w, "final")
+		}
+	}))
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_redirect_chain")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(client *http.Client, wantStatus int, wantBody string) {
+		t.Helper()
+		resp, err := client.Get(server.URL + "/a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != wantStatus || string(body) != wantBody {
+			t.Fatalf("unexpected response %d %q", resp.StatusCode, body)
+		}
+	}
+
+	rec, err := recorder.New(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get(rec.GetDefaultClient(), http.StatusOK, "final")
+
+	interactions := rec.Interactions()
+	if len(interactions) != 3 {
+		t.Fatalf("expected 3 interactions, got %d", len(interactions))
+	}
+	if interactions[0].ParentID != nil {
+		t.Fatalf("unexpected parent of first hop %d", *interactions[0].ParentID)
+	}
+	for idx, i := range interactions[1:] {
+		if i.ParentID == nil || *i.ParentID != interactions[idx].ID {
+			t.Fatalf("interaction %d is not linked to interaction %d", i.ID, interactions[idx].ID)
+		}
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	server.Close()
+
+	// Replay the full chain
+	rec, err = recorder.New(cassPath, recorder.WithMode(recorder.ModeReplayOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	get(rec.GetDefaultClient(), http.StatusOK, "final")
+	if n := rec.ReplayedCount(); n != 3 {
+		t.Fatalf("expected 3 replayed interactions, got %d", n)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replay the collapsed chain to a client, which does not follow redirects
+	rec, err = recorder.New(
+		cassPath,
+		recorder.WithMode(recorder.ModeReplayOnly),
+		recorder.WithCollapseRedirects(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	client := rec.GetDefaultClient()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	get(client, http.StatusOK, "final")
+}
+
+func TestUnusedReport(t *testing.T) {
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	defer slog.SetDefault(defaultLogger)
+
+	server := newEchoHttpServer()
+	serverUrl := server.URL
+
+	cassPath, err := newCassettePath("test_unused_report")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Record the interactions, none of which are unused
+	rec, err := recorder.New(cassPath, recorder.WithMode(recorder.ModeRecordOnly), recorder.WithUnusedReport(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, p := range []string{"/used", "/unused"} {
+		tc := testCase{method: http.MethodGet, wantBody: "GET go-vcr\n", wantStatus: http.StatusOK, wantContentLength: 11, path: p}
+		if err := tc.run(ctx, rec.GetDefaultClient(), serverUrl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+	if strings.Contains(logs.String(), "never replayed") {
+		t.Fatalf("unexpected report of recorded interactions:\n%s", logs.String())
+	}
+
+	// Replay one of the interactions, and miss the cassette once
+	rec, err = recorder.New(cassPath, recorder.WithMode(recorder.ModeReplayOnly), recorder.WithUnusedReport(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []testCase{
+		{method: http.MethodGet, wantBody: "GET go-vcr\n", wantStatus: http.StatusOK, wantContentLength: 11, path: "/used"},
+		{method: http.MethodGet, wantError: cassette.ErrInteractionNotFound, path: "/missing"},
+	}
+	for _, tc := range tests {
+		if err := tc.run(ctx, rec.GetDefaultClient(), serverUrl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logs.Reset()
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	var unused, misses string
+	for _, line := range strings.Split(logs.String(), "\n") {
+		switch {
+		case strings.Contains(line, "never replayed"):
+			unused = line
+		case strings.Contains(line, "did not match"):
+			misses = line
+		}
+	}
+	if !strings.Contains(unused, "count=1") || !strings.Contains(unused, "/unused") || strings.Contains(unused, "/used") {
+		t.Fatalf("unexpected report of unused interactions:\n%s", logs.String())
+	}
+	if !strings.Contains(misses, "count=1") || !strings.Contains(misses, "GET "+serverUrl+"/missing") {
+		t.Fatalf("unexpected report of missed requests:\n%s", logs.String())
+	}
+}
+
+func TestSplitByHost(t *testing.T) {
+	server := newEchoHttpServer()
+	serverUrl := server.URL
+
+	cassPath, err := newCassettePath("test_split_by_host")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Use distinct host names for the same server
+	_, port, err := net.SplitHostPort(strings.TrimPrefix(serverUrl, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := []string{"127.0.0.1", "localhost"}
+
+	rec, err := recorder.New(cassPath, recorder.WithMode(recorder.ModeRecordOnly), recorder.WithSplitByHost(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, host := range hosts {
+		tc := testCase{method: http.MethodGet, wantBody: "GET go-vcr\n", wantStatus: http.StatusOK, wantContentLength: 11, path: "/api"}
+		if err := tc.run(ctx, rec.GetDefaultClient(), "http://"+net.JoinHostPort(host, port)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	if _, err := os.Stat(cassPath + ".yaml"); !os.IsNotExist(err) {
+		t.Fatalf("expected default cassette not to be written, got %v", err)
+	}
+	for _, host := range hosts {
+		c, err := cassette.Load(cassPath + "." + host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(c.Interactions) != 1 || !strings.Contains(c.Interactions[0].Request.URL, host) {
+			t.Fatalf("unexpected interactions in cassette of host %s", host)
+		}
+	}
+
+	// Replay from the per-host cassettes
+	rec, err = recorder.New(cassPath, recorder.WithMode(recorder.ModeReplayOnly), recorder.WithSplitByHost(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+	for _, host := range hosts {
+		tc := testCase{method: http.MethodGet, wantBody: "GET go-vcr\n", wantStatus: http.StatusOK, wantContentLength: 11, path: "/api"}
+		if err := tc.run(ctx, rec.GetDefaultClient(), "http://"+net.JoinHostPort(host, port)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tc := testCase{method: http.MethodGet, wantError: cassette.ErrCassetteNotFound, path: "/api"}
+	if err := tc.run(ctx, rec.GetDefaultClient(), "http://"+net.JoinHostPort("127.0.0.2", port)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShards(t *testing.T) {
+	server := newEchoHttpServer()
+	serverUrl := server.URL
+
+	cassPath, err := newCassettePath("test_shards")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const shards, requests = 4, 64
+	run := func(rec *recorder.Recorder) {
+		t.Helper()
+		var wg sync.WaitGroup
+		errs := make(chan error, requests)
+		for n := range requests {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				tc := testCase{method: http.MethodGet, wantBody: "GET go-vcr\n", wantStatus: http.StatusOK, wantContentLength: 11, path: fmt.Sprintf("/api/%d", n)}
+				errs <- tc.run(context.Background(), rec.GetDefaultClient(), serverUrl)
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	rec, err := recorder.New(cassPath, recorder.WithMode(recorder.ModeRecordOnly), recorder.WithShards(shards))
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(rec)
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	for shard := range shards {
+		if _, err := os.Stat(cassette.ShardName(cassPath, shard) + ".yaml"); err != nil {
+			t.Fatalf("expected shard %d to be written: %s", shard, err)
+		}
+	}
+	c, err := cassette.Load(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Interactions) != requests {
+		t.Fatalf("got %d merged interactions, want %d", len(c.Interactions), requests)
+	}
+
+	// Replay from the shards
+	rec, err = recorder.New(cassPath, recorder.WithMode(recorder.ModeReplayOnly), recorder.WithShards(shards))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+	run(rec)
+}
+
+func TestPrettyPrint(t *testing.T) {
+	body := `{"id":1,"name":"go-vcr"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}))
+	serverUrl := server.URL
+
+	cassPath, err := newCassettePath("test_pretty_print")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc := testCase{method: http.MethodGet, wantBody: body, wantStatus: http.StatusOK, wantContentLength: len(body), path: "/api"}
+	rec, err := recorder.New(cassPath, recorder.WithMode(recorder.ModeRecordOnly), recorder.WithPrettyPrint(cassette.FormatJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tc.run(context.Background(), rec.GetDefaultClient(), serverUrl); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	c, err := cassette.Load(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\n  \"id\": 1,\n  \"name\": \"go-vcr\"\n}\n"; c.Interactions[0].Response.Body != want {
+		t.Fatalf("got recorded body %q, want %q", c.Interactions[0].Response.Body, want)
+	}
+
+	// The compact body is replayed
+	rec, err = recorder.New(cassPath, recorder.WithMode(recorder.ModeReplayOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+	if err := tc.run(context.Background(), rec.GetDefaultClient(), serverUrl); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCanonicalHeaders(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_canonical_headers")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(rec *recorder.Recorder) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/api", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header["x-trace"] = []string{"b", "a"}
+		resp, err := rec.GetDefaultClient().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	rec, err := recorder.New(cassPath, recorder.WithMode(recorder.ModeRecordOnly), recorder.WithCanonicalHeaders(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	send(rec)
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cassette.Load(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Interactions[0].Request.Headers["X-Trace"]; !slices.Equal(got, []string{"a", "b"}) {
+		t.Fatalf("got headers %v, want X-Trace canonicalized and sorted", c.Interactions[0].Request.Headers)
+	}
+
+	// The request is replayed using the hash of the original request
+	rec, err = recorder.New(cassPath, recorder.WithMode(recorder.ModeReplayOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+	send(rec)
+}
+
+func TestLineEndings(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_line_endings")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(rec *recorder.Recorder, body string) error {
+		t.Helper()
+		resp, err := rec.GetDefaultClient().Post(server.URL+"/api", "text/plain", strings.NewReader(body))
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	rec, err := recorder.New(cassPath, recorder.WithMode(recorder.ModeRecordOnly), recorder.WithLineEndings(cassette.LFLineEndings))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := send(rec, "a\r\nb\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cassette.Load(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Interactions[0].Request.Body; got != "a\nb\n" {
+		t.Fatalf("got request body %q, want LF line endings", got)
+	}
+
+	// Requests with either line endings match the recorded interaction
+	rec, err = recorder.New(cassPath, recorder.WithMode(recorder.ModeReplayOnly), recorder.WithLineEndings(cassette.LFLineEndings), recorder.WithReplayableInteractions(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+	for _, body := range []string{"a\r\nb\r\n", "a\nb\n"} {
+		if err := send(rec, body); err != nil {
+			t.Fatalf("body %q: %v", body, err)
+		}
+	}
+}
+
+func TestBinaryFormat(t *testing.T) {
+	server := newEchoHttpServer()
+	serverUrl := server.URL
+
+	cassPath, err := newCassettePath("test_binary_format")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc := testCase{method: http.MethodGet, wantBody: "GET go-vcr\n", wantStatus: http.StatusOK, wantContentLength: 11, path: "/api"}
+	rec, err := recorder.New(cassPath, recorder.WithMode(recorder.ModeRecordOnly), recorder.WithBinaryFormat(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tc.run(context.Background(), rec.GetDefaultClient(), serverUrl); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	if _, err := os.Stat(cassPath + ".cbor"); err != nil {
+		t.Fatalf("expected binary cassette: %s", err)
+	}
+
+	rec, err = recorder.New(cassPath, recorder.WithMode(recorder.ModeReplayOnly), recorder.WithBinaryFormat(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+	if err := tc.run(context.Background(), rec.GetDefaultClient(), serverUrl); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadOnlyCassette(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+	serverUrl := server.URL
+
+	cassPath, err := newCassettePath("test_read_only_cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorded := testCase{method: http.MethodGet, wantBody: "GET go-vcr\n", wantStatus: http.StatusOK, wantContentLength: 11, path: "/api/v1/foo"}
+	rec, err := recorder.New(cassPath, recorder.WithMode(recorder.ModeRecordOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := recorded.run(context.Background(), rec.GetDefaultClient(), serverUrl); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := os.ReadFile(cassPath + ".yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newEpisode := testCase{method: http.MethodPost, body: "foo", wantBody: "POST go-vcr\nfoo", wantStatus: http.StatusOK, wantContentLength: 15, path: "/api/v1/bar"}
+	for _, mode := range []recorder.Mode{recorder.ModeReplayWithNewEpisodes, recorder.ModeRecordOnly, recorder.ModeRefresh} {
+		rec, err := recorder.New(cassPath, recorder.WithMode(mode), recorder.WithReadOnlyCassette(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tc := range []testCase{recorded, newEpisode} {
+			if err := tc.run(context.Background(), rec.GetDefaultClient(), serverUrl); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := rec.Cassette().Save(); !errors.Is(err, cassette.ErrReadOnlyCassette) {
+			t.Fatalf("got error %v, want %v", err, cassette.ErrReadOnlyCassette)
+		}
+		if err := rec.Stop(); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := os.ReadFile(cassPath + ".yaml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("cassette was rewritten in mode %s", mode)
+		}
+	}
+}
+
+// newBenchmarkCassette records a cassette with n interactions of distinct
+// requests, whose responses vary on the Accept header, if vary is true.
+// It returns the path of the cassette and the URL of the recorded server.
+func newBenchmarkCassette(b *testing.B, n int, vary bool) (string, string) {
+	b.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if vary {
+			w.Header().Set("Vary", "Accept")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"path": %q}`, r.URL.Path)
+	}))
+	defer server.Close()
+
+	cassPath, err := newCassettePath("benchmark")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	rec, err := recorder.New(cassPath, recorder.WithMode(recorder.ModeRecordOnly), recorder.WithSkipRequestLatency(true))
+	if err != nil {
+		b.Fatal(err)
+	}
+	for k := range n {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/items/%d", server.URL, k), nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		req.Header.Set("Accept", "application/json")
+		resp, err := rec.RoundTrip(req)
+		if err != nil {
+			b.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if err := rec.Stop(); err != nil {
+		b.Fatal(err)
+	}
+
+	return cassPath, server.URL
+}
+
+// benchmarkReplay replays the requests of a cassette recorded using
+// newBenchmarkCassette with the given options.
+func benchmarkReplay(b *testing.B, vary bool, opts ...recorder.Option) {
+	const n = 1000
+	cassPath, serverURL := newBenchmarkCassette(b, n, vary)
+
+	opts = append([]recorder.Option{
+		recorder.WithMode(recorder.ModeReplayOnly),
+		recorder.WithReplayableInteractions(true),
+		recorder.WithSkipRequestLatency(true),
+	}, opts...)
+	rec, err := recorder.New(cassPath, opts...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer rec.Stop()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for k := range b.N {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/items/%d", serverURL, k%n), nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		req.Header.Set("Accept", "application/json")
+		resp, err := rec.RoundTrip(req)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+			b.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+}
+
+func TestRecordDeclaredContentLength(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "800000000")
+	}))
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_record_declared_content_length")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := recorder.New(cassPath, recorder.WithMode(recorder.ModeRecordOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	// The body buffer is not sized from the declared length of responses
+	// without a body
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	resp, err := rec.GetDefaultClient().Head(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	runtime.ReadMemStats(&after)
+
+	if n := after.TotalAlloc - before.TotalAlloc; n > 64<<20 {
+		t.Fatalf("recording allocated %d bytes", n)
+	}
+}
+
+func BenchmarkReplayHashMatch(b *testing.B) {
+	benchmarkReplay(b, false)
+}
+
+// BenchmarkReplayVaryMatch replays interactions, which vary on a request
+// header, and are thus matched using the vary index of the cassette.
+func BenchmarkReplayVaryMatch(b *testing.B) {
+	benchmarkReplay(b, true, recorder.WithVaryMatching(true))
+}
+
+func TestFailOnEmptyCassette(t *testing.T) {
+	cassPath, err := newCassettePath("test_fail_on_empty_cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cassette.New(cassPath).Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mode := range []recorder.Mode{recorder.ModeReplayOnly, recorder.ModeRecordOnce} {
+		t.Run(mode.String(), func(t *testing.T) {
+			_, err := recorder.New(cassPath, recorder.WithMode(mode), recorder.WithFailOnEmptyCassette(true))
+			if !errors.Is(err, cassette.ErrEmptyCassette) {
+				t.Fatalf("got error %v, want %v", err, cassette.ErrEmptyCassette)
+			}
+
+			// Empty cassettes are loaded by default
+			rec, err := recorder.New(cassPath, recorder.WithMode(mode))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rec.Stop()
+		})
+	}
+}
+
+func TestStopUnmodifiedCassette(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_stop_unmodified_cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(rec *recorder.Recorder, path string) {
+		t.Helper()
+		resp, err := rec.GetDefaultClient().Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	rec, err := recorder.New(cassPath, recorder.WithMode(recorder.ModeRecordOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	get(rec, "/api")
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Annotate the cassette by hand, which is lost, when it is rewritten
+	file := cassPath + ".yaml"
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	annotated := append([]byte("# hand-edited\n"), data...)
+	if err := os.WriteFile(file, annotated, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(file, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err = recorder.New(cassPath, recorder.WithMode(recorder.ModeReplayWithNewEpisodes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	get(rec, "/api")
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(file); err != nil || !bytes.Equal(data, annotated) {
+		t.Fatalf("expected the unmodified cassette not to be rewritten, got %v", err)
+	}
+	if info, err := os.Stat(file); err != nil || !info.ModTime().Equal(past) {
+		t.Fatalf("expected the modification time of the unmodified cassette to be kept, got %v", err)
+	}
+
+	// New episodes are saved
+	rec, err = recorder.New(cassPath, recorder.WithMode(recorder.ModeReplayWithNewEpisodes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	get(rec, "/api/new")
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	c, err := cassette.Load(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Interactions) != 2 {
+		t.Fatalf("got %d interactions, want 2", len(c.Interactions))
+	}
+}
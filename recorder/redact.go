@@ -0,0 +1,339 @@
+package recorder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goware/go-vcr/cassette"
+)
+
+// RedactedValue is the value used by the built-in redaction hooks to replace
+// sensitive data.
+const RedactedValue = "[REDACTED]"
+
+// RedactHeaders returns a [HookFunc], which replaces the values of the given
+// headers with [RedactedValue]. Both request and response headers and
+// trailers are redacted.
+//
+// The hook is best registered as a [BeforeSaveHook], so that the code under
+// test keeps seeing the real values, and the recorded request hashes are
+// computed from the original request.
+func RedactHeaders(names ...string) HookFunc {
+	return func(i *cassette.Interaction) error {
+		for _, h := range interactionHeaders(i) {
+			for _, name := range names {
+				redactHeader(h, name)
+			}
+		}
+		return nil
+	}
+}
+
+// RedactSetCookie returns a [HookFunc], which redacts the cookie values from
+// the Set-Cookie response headers and the Cookie request headers. Cookie names
+// and attributes are preserved.
+func RedactSetCookie() HookFunc {
+	return func(i *cassette.Interaction) error {
+		for _, h := range []http.Header{i.Response.Headers, i.Response.Trailer} {
+			for k, values := range h {
+				if http.CanonicalHeaderKey(k) != "Set-Cookie" {
+					continue
+				}
+				for idx, v := range values {
+					values[idx] = redactSetCookie(v)
+				}
+			}
+		}
+
+		for k, values := range i.Request.Headers {
+			if http.CanonicalHeaderKey(k) != "Cookie" {
+				continue
+			}
+			for idx, v := range values {
+				values[idx] = redactCookie(v)
+			}
+		}
+
+		return nil
+	}
+}
+
+// awsSignaturePattern matches the sensitive parts of an AWS Signature Version
+// 4 Authorization header.
+var awsSignaturePattern = regexp.MustCompile(`(Credential|Signature)=[^,\s]+`)
+
+// awsQueryParams are the query parameters of pre-signed AWS URLs, which
+// contain sensitive data.
+var awsQueryParams = []string{"X-Amz-Credential", "X-Amz-Signature", "X-Amz-Security-Token"}
+
+// RedactAWSSignatures returns a [HookFunc], which redacts AWS Signature
+// Version 4 credentials. The credential and signature components of the
+// Authorization header, the X-Amz-Security-Token header and the signing
+// parameters of pre-signed URLs are replaced with [RedactedValue].
+func RedactAWSSignatures() HookFunc {
+	return func(i *cassette.Interaction) error {
+		for k, values := range i.Request.Headers {
+			switch http.CanonicalHeaderKey(k) {
+			case "Authorization":
+				for idx, v := range values {
+					if strings.HasPrefix(v, "AWS4-") {
+						values[idx] = awsSignaturePattern.ReplaceAllString(v, "$1="+RedactedValue)
+					}
+				}
+			case "X-Amz-Security-Token":
+				for idx := range values {
+					values[idx] = RedactedValue
+				}
+			}
+		}
+
+		u, err := url.Parse(i.Request.URL)
+		if err != nil {
+			return err
+		}
+
+		query := u.Query()
+		redacted := false
+		for _, param := range awsQueryParams {
+			if query.Has(param) {
+				query.Set(param, RedactedValue)
+				redacted = true
+			}
+		}
+
+		if redacted {
+			u.RawQuery = query.Encode()
+			i.Request.URL = u.String()
+		}
+
+		return nil
+	}
+}
+
+// RedactFormFields returns a [HookFunc], which replaces the values of the
+// given fields of URL-encoded and multipart/form-data request bodies with
+// [RedactedValue], e.g. "password" or "client_secret". The bodies are parsed
+// and encoded again, so that they stay valid, with the other fields left as
+// is, and the recorded form values and the Content-Length are updated
+// accordingly. Multipart parts recorded separately, see
+// [WithMultipartParts], are redacted as well, except for the content of
+// file parts.
+//
+// The hook is best registered as a [BeforeSaveHook], as described in
+// [RedactHeaders].
+func RedactFormFields(names ...string) HookFunc {
+	return func(i *cassette.Interaction) error {
+		for _, name := range names {
+			if i.Request.Form.Has(name) {
+				values := i.Request.Form[name]
+				for idx := range values {
+					values[idx] = RedactedValue
+				}
+			}
+		}
+		for idx, p := range i.Request.Parts {
+			if p.Filename == "" && slices.Contains(names, p.Name) {
+				i.Request.Parts[idx].Value = RedactedValue
+			}
+		}
+
+		if i.Request.Body == "" || i.Request.BodyDigest != "" {
+			return nil
+		}
+
+		mediaType, params, err := mime.ParseMediaType(i.Request.Headers.Get("Content-Type"))
+		if err != nil {
+			return nil
+		}
+
+		var body string
+		switch {
+		case mediaType == "application/x-www-form-urlencoded":
+			body = redactURLEncoded(i.Request.Body, names)
+		case mediaType == "multipart/form-data" && params["boundary"] != "":
+			body, err = redactMultipart(i.Request.Body, params["boundary"], names)
+			if err != nil {
+				return fmt.Errorf("failed to redact multipart body: %w", err)
+			}
+		default:
+			return nil
+		}
+
+		setRequestBody(i, body)
+
+		return nil
+	}
+}
+
+// redactURLEncoded redacts the values of the named fields of the
+// URL-encoded body. The other fields are kept as is, including their order
+// and encoding.
+func redactURLEncoded(body string, names []string) string {
+	pairs := strings.Split(body, "&")
+	for idx, pair := range pairs {
+		key, _, _ := strings.Cut(pair, "=")
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+		if slices.Contains(names, name) {
+			pairs[idx] = key + "=" + url.QueryEscape(RedactedValue)
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// redactMultipart redacts the content of the named parts of the multipart
+// body. The other parts are copied as is, using the same boundary. Bodies
+// without any of the parts are returned as is.
+func redactMultipart(body, boundary string, names []string) (string, error) {
+	redacted := false
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return "", err
+	}
+
+	mr := multipart.NewReader(strings.NewReader(body), boundary)
+	for {
+		part, err := mr.NextRawPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		w, err := mw.CreatePart(part.Header)
+		if err != nil {
+			return "", err
+		}
+		if slices.Contains(names, part.FormName()) {
+			redacted = true
+			_, err = io.WriteString(w, RedactedValue)
+		} else {
+			_, err = io.Copy(w, part)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if !redacted {
+		return body, nil
+	}
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// setRequestBody replaces the recorded request body, and fixes up its
+// content length, if it was the length of the body.
+func setRequestBody(i *cassette.Interaction, body string) {
+	if i.Request.ContentLength == int64(len(i.Request.Body)) {
+		i.Request.ContentLength = int64(len(body))
+		if i.Request.Headers.Get("Content-Length") != "" {
+			i.Request.Headers.Set("Content-Length", strconv.Itoa(len(body)))
+		}
+	}
+	i.Request.Body = body
+}
+
+// interactionHeaders returns all headers and trailers of the interaction.
+func interactionHeaders(i *cassette.Interaction) []http.Header {
+	return []http.Header{
+		i.Request.Headers,
+		i.Request.Trailer,
+		i.Response.Headers,
+		i.Response.Trailer,
+	}
+}
+
+// redactHeader replaces the values of the named header with [RedactedValue].
+// Header keys are compared in their canonical form, since recorded headers
+// are not guaranteed to be canonicalized.
+func redactHeader(h http.Header, name string) {
+	name = http.CanonicalHeaderKey(name)
+	for k, values := range h {
+		if http.CanonicalHeaderKey(k) != name {
+			continue
+		}
+		for idx := range values {
+			values[idx] = RedactedValue
+		}
+	}
+}
+
+// redactSetCookie redacts the value of a Set-Cookie header, e.g.
+// "session=abc; Path=/" becomes "session=[REDACTED]; Path=/".
+func redactSetCookie(v string) string {
+	pair, attrs, hasAttrs := strings.Cut(v, ";")
+	name, _, _ := strings.Cut(pair, "=")
+	redacted := strings.TrimSpace(name) + "=" + RedactedValue
+	if hasAttrs {
+		redacted += ";" + attrs
+	}
+	return redacted
+}
+
+// redactCookie redacts all cookie values of a Cookie header.
+func redactCookie(v string) string {
+	pairs := strings.Split(v, ";")
+	for idx, pair := range pairs {
+		name, _, _ := strings.Cut(pair, "=")
+		pairs[idx] = strings.TrimSpace(name) + "=" + RedactedValue
+	}
+	return strings.Join(pairs, "; ")
+}
+
+// AnonymizePII returns a [HookFunc], which replaces IP addresses, including
+// the remote address, and email addresses in the interaction with
+// deterministic fake values. See [cassette.AnonymizeInteraction] for details.
+func AnonymizePII() HookFunc {
+	return func(i *cassette.Interaction) error {
+		cassette.AnonymizeInteraction(i)
+		return nil
+	}
+}
+
+// volatileHeaders are the headers, which change whenever a request is sent,
+// and which are removed by [StripVolatileFields].
+var volatileHeaders = []string{"Date", "X-Request-Id"}
+
+// StripVolatileFields returns a [HookFunc], which removes the fields of the
+// interaction changing whenever the test runs, so that re-recording a
+// cassette does not produce spurious diffs. It removes the Date and
+// X-Request-Id headers, the host and remote address of the request, and
+// zeroes the response duration and the recording time.
+//
+// Interactions without a recording time never expire, see
+// [WithCassetteTTL], and their cookies are not shifted, see
+// [WithShiftCookieExpiry].
+func StripVolatileFields() HookFunc {
+	return func(i *cassette.Interaction) error {
+		for _, h := range interactionHeaders(i) {
+			for k := range h {
+				if slices.Contains(volatileHeaders, http.CanonicalHeaderKey(k)) {
+					delete(h, k)
+				}
+			}
+		}
+		i.Request.Host = ""
+		i.Request.RemoteAddr = ""
+		i.Response.Duration = 0
+		i.RecordedAt = time.Time{}
+		return nil
+	}
+}
@@ -0,0 +1,184 @@
+package recorder
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/goware/go-vcr/cassette"
+)
+
+// Stub describes a synthetic interaction, which is replayed by the
+// [Recorder] alongside the recorded interactions, e.g. for edge cases which
+// are hard to record. Create it using [Recorder.Stub], and register it using
+// [Stub.Reply] or [Stub.ReplyJSON]:
+//
+//	err := rec.Stub().Get("/v1/users").ReplyJSON(users)
+//
+// Requests are matched against stubs using the matcher of the recorder, the
+// same way as against recorded interactions, i.e. the stub must describe the
+// headers and body of the request, unless the matcher ignores them. Stubs
+// without a host match requests to any host. Matching stubs take precedence
+// over the recorded interactions in all modes but [ModePassthrough], and they
+// are neither recorded nor consumed, when replayed.
+type Stub struct {
+	rec     *Recorder
+	builder *cassette.InteractionBuilder
+}
+
+// Stub returns a new [Stub] of a GET request to the root path, which
+// replies with 200 OK, until configured otherwise.
+func (rec *Recorder) Stub() *Stub {
+	return &Stub{
+		rec:     rec,
+		builder: cassette.NewInteraction().Request(http.MethodGet, "/"),
+	}
+}
+
+// Request sets the method and URL of the request. The URL may be a path
+// only, e.g. "/v1/users", to match requests to any host.
+func (s *Stub) Request(method, url string) *Stub {
+	s.builder.Request(method, url)
+	return s
+}
+
+// Get sets the request to a GET request of the URL, see [Stub.Request].
+func (s *Stub) Get(url string) *Stub {
+	return s.Request(http.MethodGet, url)
+}
+
+// Post sets the request to a POST request of the URL, see [Stub.Request].
+func (s *Stub) Post(url string) *Stub {
+	return s.Request(http.MethodPost, url)
+}
+
+// Put sets the request to a PUT request of the URL, see [Stub.Request].
+func (s *Stub) Put(url string) *Stub {
+	return s.Request(http.MethodPut, url)
+}
+
+// Patch sets the request to a PATCH request of the URL, see [Stub.Request].
+func (s *Stub) Patch(url string) *Stub {
+	return s.Request(http.MethodPatch, url)
+}
+
+// Delete sets the request to a DELETE request of the URL, see
+// [Stub.Request].
+func (s *Stub) Delete(url string) *Stub {
+	return s.Request(http.MethodDelete, url)
+}
+
+// Header adds the value to the request header.
+func (s *Stub) Header(key, value string) *Stub {
+	s.builder.ReqHeader(key, value)
+	return s
+}
+
+// Body sets the body of the request.
+func (s *Stub) Body(body string) *Stub {
+	s.builder.ReqBody(body)
+	return s
+}
+
+// BodyJSON sets the body of the request to the JSON encoding of v, along
+// with an application/json Content-Type header.
+func (s *Stub) BodyJSON(v any) *Stub {
+	s.builder.ReqBodyJSON(v)
+	return s
+}
+
+// Status sets the status code of the response.
+func (s *Stub) Status(code int) *Stub {
+	s.builder.RespStatus(code)
+	return s
+}
+
+// ReplyHeader adds the value to the response header.
+func (s *Stub) ReplyHeader(key, value string) *Stub {
+	s.builder.RespHeader(key, value)
+	return s
+}
+
+// Reply registers the stub, which replies with the given body.
+func (s *Stub) Reply(body string) error {
+	s.builder.RespBody(body)
+	return s.register()
+}
+
+// ReplyJSON registers the stub, which replies with the JSON encoding of v,
+// along with an application/json Content-Type header.
+func (s *Stub) ReplyJSON(v any) error {
+	s.builder.RespBodyJSON(v)
+	return s.register()
+}
+
+// register adds the stub to the recorder.
+func (s *Stub) register() error {
+	i, err := s.builder.Build()
+	if err != nil {
+		return fmt.Errorf("invalid stub: %w", err)
+	}
+
+	s.rec.mu.Lock()
+	defer s.rec.mu.Unlock()
+	s.rec.stubs = append(s.rec.stubs, i)
+
+	return nil
+}
+
+// ResetStubs removes the stubs registered using [Recorder.Stub].
+func (rec *Recorder) ResetStubs() {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.stubs = nil
+}
+
+// matchStub returns a copy of the most recently registered stub matching
+// the request, or nil, if no stub matches.
+func (rec *Recorder) matchStub(r *http.Request) (*cassette.Interaction, error) {
+	rec.mu.RLock()
+	stubs, matcher := rec.stubs, rec.matcher
+	rec.mu.RUnlock()
+
+	if len(stubs) == 0 {
+		return nil, nil
+	}
+
+	body, err := cassette.RequestBody(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body for matching: %w", err)
+	}
+	defer func() {
+		if body != nil {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+	}()
+
+	hash, err := cassette.HashRequest(matcher, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash request: %w", err)
+	}
+
+	for idx := len(stubs) - 1; idx >= 0; idx-- {
+		stubReq, err := stubs[idx].GetHTTPRequest()
+		if err != nil {
+			return nil, err
+		}
+		if stubReq.URL.Host == "" {
+			stubReq.URL.Scheme, stubReq.URL.Host = r.URL.Scheme, r.URL.Host
+			stubReq.Host = r.Host
+		}
+
+		stubHash, err := matcher.Hash(stubReq)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash stub request: %w", err)
+		}
+		if stubHash == hash {
+			return stubs[idx].Clone(), nil
+		}
+	}
+
+	return nil, nil
+}
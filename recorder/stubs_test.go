@@ -0,0 +1,104 @@
+package recorder_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/goware/go-vcr/cassette"
+	"github.com/goware/go-vcr/recorder"
+)
+
+func TestStubs(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_stubs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := []map[string]string{{"name": "go-vcr"}}
+	stub := func(rec *recorder.Recorder) {
+		t.Helper()
+		if err := rec.Stub().Get("/v1/users").ReplyJSON(users); err != nil {
+			t.Fatal(err)
+		}
+		if err := rec.Stub().Post("https://api.example.com/v1/users").Body("name=go-vcr").Status(http.StatusConflict).Reply("exists"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stubbed := testCase{
+		method:            http.MethodGet,
+		wantBody:          `[{"name":"go-vcr"}]`,
+		wantStatus:        http.StatusOK,
+		wantContentLength: 19,
+		path:              "/v1/users",
+	}
+	conflict := testCase{
+		method:            http.MethodPost,
+		body:              "name=go-vcr",
+		wantBody:          "exists",
+		wantStatus:        http.StatusConflict,
+		wantContentLength: 6,
+		path:              "/v1/users",
+	}
+	recorded := testCase{
+		method:            http.MethodGet,
+		wantBody:          "GET go-vcr\n",
+		wantStatus:        http.StatusOK,
+		wantContentLength: 11,
+		path:              "/api/v1/foo",
+	}
+
+	rec, err := recorder.New(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub(rec)
+
+	ctx := context.Background()
+	client := rec.GetDefaultClient()
+	for _, test := range []testCase{stubbed, stubbed, recorded} {
+		if err := test.run(ctx, client, server.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := conflict.run(ctx, client, "https://api.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stubs are not recorded
+	c, err := cassette.Load(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Interactions) != 1 {
+		t.Fatalf("got %d recorded interactions, want 1", len(c.Interactions))
+	}
+
+	// Stubs are replayed alongside the recorded interactions
+	rec, err = recorder.New(cassPath, recorder.WithMode(recorder.ModeReplayOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+	stub(rec)
+
+	client = rec.GetDefaultClient()
+	for _, test := range []testCase{stubbed, recorded} {
+		if err := test.run(ctx, client, server.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec.ResetStubs()
+	if _, err := client.Get(server.URL + "/v1/users"); !errors.Is(err, cassette.ErrInteractionNotFound) {
+		t.Fatalf("got error %v, want %v", err, cassette.ErrInteractionNotFound)
+	}
+}
@@ -0,0 +1,237 @@
+package recorder
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestdataDir is the directory, in which cassettes created by [NewWithT]
+// are stored.
+const TestdataDir = "testdata"
+
+// UpdateFlagName is the name of the boolean command-line flag, which is
+// consulted by [UpdateFlagMode].
+var UpdateFlagName = "update"
+
+// UpdateFlagMode returns an [Option], which configures the [Recorder] to run
+// in [ModeRecordOnly] when the -update command-line flag is set, following
+// the convention used for updating golden files, e.g.
+//
+//	go test ./... -update
+//
+// The flag is not registered by this package, so that it can be shared with
+// golden file helpers. Register it in the test package, e.g.
+//
+//	var _ = flag.Bool("update", false, "update golden files and cassettes")
+//
+// When the flag is not set, or not registered, the option has no effect. The
+// option should be provided after [WithMode], so that it takes precedence.
+func UpdateFlagMode() Option {
+	return func(r *Recorder) {
+		if isUpdateFlagSet() {
+			r.mode = ModeRecordOnly
+		}
+	}
+}
+
+// isUpdateFlagSet returns true, if the update command-line flag is
+// registered and set.
+func isUpdateFlagSet() bool {
+	f := flag.Lookup(UpdateFlagName)
+	if f == nil {
+		return false
+	}
+
+	val, err := strconv.ParseBool(f.Value.String())
+	return err == nil && val
+}
+
+// NewWithT creates a new [Recorder] for the given test, and configures it
+// using the provided options. The cassette name is derived from the test
+// name using [CassetteNameForTest]. The recorder is stopped automatically
+// when the test and all its subtests complete, and the test is marked as
+// failed if stopping the recorder fails.
+func NewWithT(tb testing.TB, opts ...Option) *Recorder {
+	tb.Helper()
+
+	rec, err := New(CassetteNameForTest(tb), opts...)
+	if err != nil {
+		tb.Fatalf("failed to create recorder: %v", err)
+	}
+
+	tb.Cleanup(func() {
+		if err := rec.Stop(); err != nil {
+			tb.Errorf("failed to stop recorder: %v", err)
+		}
+	})
+
+	return rec
+}
+
+// Run runs f as a subtest of t called name, as [testing.T.Run] does, and
+// passes it a child recorder, which is created with the options of rec. The
+// cassette of the child recorder is named after the cassette of rec and the
+// name of the subtest, e.g. the subtest get_user of a recorder using the
+// cassette testdata/TestAPI uses the cassette testdata/TestAPI/get_user.
+// Nested calls of Run on the child recorder mirror the subtest hierarchy
+// accordingly. This saves table-driven tests deriving a cassette name for
+// every case.
+//
+// The child recorder is stopped automatically when the subtest completes,
+// and the subtest is marked as failed if stopping it fails.
+func (rec *Recorder) Run(t *testing.T, name string, f func(t *testing.T, rec *Recorder)) bool {
+	t.Helper()
+
+	parent := t.Name()
+	rec.mu.RLock()
+	cassetteName := rec.cassetteName
+	rec.mu.RUnlock()
+
+	return t.Run(name, func(t *testing.T) {
+		t.Helper()
+
+		child, err := New(cassettePathForTest(cassetteName, strings.TrimPrefix(t.Name(), parent+"/")), rec.opts...)
+		if err != nil {
+			t.Fatalf("failed to create recorder: %v", err)
+		}
+		t.Cleanup(func() {
+			if err := child.Stop(); err != nil {
+				t.Errorf("failed to stop recorder: %v", err)
+			}
+		})
+
+		f(t, child)
+	})
+}
+
+// NewShared creates a new [Recorder], which is shared between the tests of
+// a package, and configures it using the provided options. This saves
+// creating and stopping a recorder for every test of large test suites.
+// The tests keep their interactions in separate cassettes within the named
+// one using [Recorder.ClientForTest] or [Recorder.ContextForTest], so that
+// the cassette itself need not exist, when replaying. The recorder is
+// usually created in TestMain, and stopped by [RunMain], e.g.
+//
+//	var rec *recorder.Recorder
+//
+//	func TestMain(m *testing.M) {
+//		var err error
+//		rec, err = recorder.NewShared("testdata/shared")
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//		os.Exit(recorder.RunMain(m, rec))
+//	}
+func NewShared(name string, opts ...Option) (*Recorder, error) {
+	return New(name, append(opts, func(r *Recorder) {
+		r.shared = true
+	})...)
+}
+
+// RunMain runs the tests of the package using m, as [testing.M.Run] does,
+// and stops the recorder rec, which is shared between the tests, once all
+// of them completed, see [NewShared]. The returned exit code indicates a
+// failure, if stopping the recorder fails.
+func RunMain(m *testing.M, rec *Recorder) int {
+	code := m.Run()
+
+	if err := rec.Stop(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to stop recorder: %v\n", err)
+		if code == 0 {
+			code = 1
+		}
+	}
+
+	return code
+}
+
+// ClientForTest returns an [http.Client], which records the requests of the
+// given test into, and replays them from the cassette of the test, see
+// [Recorder.ContextForTest].
+func (rec *Recorder) ClientForTest(tb testing.TB) *http.Client {
+	return &http.Client{
+		Transport: &testRoundTripper{
+			rec:  rec,
+			name: rec.testCassetteName(tb),
+		},
+	}
+}
+
+// ContextForTest returns a copy of the parent context, which selects the
+// cassette of the given test for requests using it, see
+// [ContextWithCassette]. The cassette is named after the cassette of rec and
+// the name of the test, e.g. the test TestAPI/get_user of a recorder using
+// the cassette testdata/shared uses the cassette
+// testdata/shared/TestAPI/get_user. The cassettes of all tests are saved
+// once, when the shared recorder is stopped, see [NewShared].
+func (rec *Recorder) ContextForTest(parent context.Context, tb testing.TB) context.Context {
+	return ContextWithCassette(parent, rec.testCassetteName(tb))
+}
+
+// testCassetteName returns the name of the cassette of the test within the
+// cassette of the recorder.
+func (rec *Recorder) testCassetteName(tb testing.TB) string {
+	rec.mu.RLock()
+	cassetteName := rec.cassetteName
+	rec.mu.RUnlock()
+
+	return cassettePathForTest(cassetteName, tb.Name())
+}
+
+// testRoundTripper selects the cassette of a test for the requests sent by
+// the client returned by [Recorder.ClientForTest].
+type testRoundTripper struct {
+	rec  *Recorder
+	name string
+}
+
+func (t *testRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	return t.rec.RoundTrip(r.WithContext(ContextWithCassette(r.Context(), t.name)))
+}
+
+// CassetteNameForTest returns a cassette name derived from the name of the
+// given test, which resides in the [TestdataDir] directory. Subtests are
+// mapped to nested directories, e.g. the cassette for TestAPI/get_user is
+// testdata/TestAPI/get_user. Characters which are not valid in file names
+// on common file systems are replaced with underscores.
+func CassetteNameForTest(tb testing.TB) string {
+	return cassettePathForTest(TestdataDir, tb.Name())
+}
+
+// cassettePathForTest returns the path of the cassette of the named test
+// within root, mapping subtests to nested directories.
+func cassettePathForTest(root, name string) string {
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		segments[i] = sanitizeFileName(segment)
+	}
+
+	return filepath.Join(append([]string{root}, segments...)...)
+}
+
+// sanitizeFileName replaces characters, which are not valid in file names.
+func sanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20, strings.ContainsRune(`<>:"/\|?*`, r):
+			return '_'
+		default:
+			return r
+		}
+	}, name)
+
+	// Avoid special path elements and names, which are invalid on Windows.
+	name = strings.TrimRight(name, ". ")
+	if name == "" {
+		return "_"
+	}
+
+	return name
+}
@@ -0,0 +1,166 @@
+package recorder_test
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/goware/go-vcr/cassette"
+	"github.com/goware/go-vcr/recorder"
+)
+
+func TestCassetteNameForTest(t *testing.T) {
+	want := filepath.Join("testdata", "TestCassetteNameForTest")
+	if got := recorder.CassetteNameForTest(t); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	t.Run(`get "user" <1>?`, func(t *testing.T) {
+		want := filepath.Join("testdata", "TestCassetteNameForTest", `get__user___1__`)
+		if got := recorder.CassetteNameForTest(t); got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	})
+}
+
+func TestNewWithT(t *testing.T) {
+	rec := recorder.NewWithT(t, recorder.WithMode(recorder.ModePassthrough))
+
+	if rec.Mode() != recorder.ModePassthrough {
+		t.Fatal("recorder is not in the correct mode")
+	}
+}
+
+var _ = flag.Bool("update", false, "update cassettes")
+
+func TestUpdateFlagMode(t *testing.T) {
+	t.Setenv(recorder.EnvMode, "")
+
+	opts := []recorder.Option{
+		recorder.WithMode(recorder.ModeReplayOnly),
+		recorder.UpdateFlagMode(),
+	}
+
+	rec := recorder.NewWithT(t, append(opts, recorder.WithMode(recorder.ModePassthrough))...)
+	if rec.Mode() != recorder.ModePassthrough {
+		t.Fatalf("unexpected mode %s", rec.Mode())
+	}
+
+	if err := flag.Set("update", "true"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { flag.Set("update", "false") })
+
+	rec = recorder.NewWithT(t, opts...)
+	if rec.Mode() != recorder.ModeRecordOnly {
+		t.Fatalf("expected ModeRecordOnly, got %s", rec.Mode())
+	}
+}
+
+func TestRun(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+
+	dir := t.TempDir()
+	t.Setenv(recorder.EnvMode, "")
+	t.Setenv(recorder.EnvCassetteDir, dir)
+
+	rec := recorder.NewWithT(t, recorder.WithMode(recorder.ModeRecordOnly), recorder.WithSkipRequestLatency(true))
+
+	tests := []string{"get user", "list/users"}
+	for _, name := range tests {
+		rec.Run(t, name, func(t *testing.T, rec *recorder.Recorder) {
+			if rec.Mode() != recorder.ModeRecordOnly {
+				t.Fatalf("got mode %s, want options of the parent", rec.Mode())
+			}
+			rec.Run(t, "nested", func(t *testing.T, rec *recorder.Recorder) {
+				tc := testCase{method: http.MethodGet, wantBody: "GET go-vcr\n", wantStatus: http.StatusOK, wantContentLength: 11, path: "/api"}
+				if err := tc.run(context.Background(), rec.GetDefaultClient(), server.URL); err != nil {
+					t.Fatal(err)
+				}
+			})
+		})
+	}
+
+	for _, name := range []string{
+		filepath.Join(dir, "testdata", "TestRun", "get_user", "nested"),
+		filepath.Join(dir, "testdata", "TestRun", "list", "users", "nested"),
+	} {
+		c, err := cassette.Load(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(c.Interactions) != 1 {
+			t.Fatalf("got %d interactions in cassette %s, want 1", len(c.Interactions), name)
+		}
+	}
+}
+
+func TestClientForTest(t *testing.T) {
+	server := newEchoHttpServer()
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]testCase{
+		"get":  {method: http.MethodGet, wantBody: "GET go-vcr\n", wantStatus: http.StatusOK, wantContentLength: 11, path: "/api/v1/foo"},
+		"post": {method: http.MethodPost, body: "foo", wantBody: "POST go-vcr\nfoo", wantStatus: http.StatusOK, wantContentLength: 15, path: "/api/v1/bar"},
+	}
+
+	rec, err := recorder.NewShared(cassPath, recorder.WithMode(recorder.ModeRecordOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if err := tc.run(context.Background(), rec.ClientForTest(t), server.URL); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each test has its own cassette, while the shared one is not saved
+	for name, tc := range cases {
+		c, err := cassette.Load(filepath.Join(cassPath, "TestClientForTest", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(c.Interactions) != 1 || c.Interactions[0].Request.Method != tc.method {
+			t.Fatalf("got interactions %v in cassette of %s", c.Interactions, name)
+		}
+	}
+
+	rec, err = recorder.NewShared(cassPath, recorder.WithMode(recorder.ModeReplayOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	// Requests of a test without a cassette are not replayed
+	tc := cases["get"]
+	tc.wantError = cassette.ErrCassetteNotFound
+	if err := tc.run(context.Background(), rec.ClientForTest(t), server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := rec.ContextForTest(context.Background(), t)
+	if got, want := recorder.CassetteFromContext(ctx), filepath.Join(cassPath, "TestClientForTest"); got != want {
+		t.Fatalf("got cassette %q, want %q", got, want)
+	}
+
+	// The cassettes of the tests are replayed
+	for name, tc := range cases {
+		ctx := recorder.ContextWithCassette(context.Background(), filepath.Join(cassPath, "TestClientForTest", name))
+		if err := tc.run(ctx, rec.GetDefaultClient(), server.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package recorder
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// TransportOptions configures the HTTP transport, which the [Recorder] uses
+// to perform real requests. See [WithTransportOptions].
+type TransportOptions struct {
+	// Proxy returns the proxy to use for a given request. When nil,
+	// [http.ProxyFromEnvironment] is used, which honors the HTTP_PROXY,
+	// HTTPS_PROXY and NO_PROXY environment variables.
+	Proxy func(*http.Request) (*url.URL, error)
+
+	// DialContext specifies the dial function for creating unencrypted
+	// TCP connections. When nil, a [net.Dialer] with the same settings as
+	// [http.DefaultTransport] is used.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// TLSClientConfig specifies the TLS configuration to use, e.g. custom
+	// root CAs or client certificates.
+	TLSClientConfig *tls.Config
+}
+
+// WithTransportOptions is an [Option], which configures the [Recorder] to
+// perform real requests using a new [http.Transport] configured with the
+// given options. All other transport settings match the ones of
+// [http.DefaultTransport].
+func WithTransportOptions(opts TransportOptions) Option {
+	return func(r *Recorder) {
+		r.realTransport = newTransport(opts)
+	}
+}
+
+// newTransport creates a new [http.Transport] using the given options.
+func newTransport(opts TransportOptions) *http.Transport {
+	var t *http.Transport
+	if dt, ok := defaultRealTransport().(*http.Transport); ok {
+		t = dt.Clone()
+	} else {
+		// Mirror the settings of http.DefaultTransport
+		t = &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		}
+	}
+
+	t.Proxy = http.ProxyFromEnvironment
+	if opts.Proxy != nil {
+		t.Proxy = opts.Proxy
+	}
+
+	if opts.DialContext != nil {
+		t.DialContext = opts.DialContext
+	}
+
+	if opts.TLSClientConfig != nil {
+		t.TLSClientConfig = opts.TLSClientConfig.Clone()
+	}
+
+	return t
+}
@@ -0,0 +1,55 @@
+package recorder_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/goware/go-vcr/recorder"
+)
+
+func TestTransportOptions(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_transport_options")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	var dials atomic.Int32
+	dialer := &net.Dialer{}
+	opts := recorder.TransportOptions{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dials.Add(1)
+			return dialer.DialContext(ctx, network, addr)
+		},
+		TLSClientConfig: &tls.Config{RootCAs: roots},
+	}
+
+	rec, err := recorder.New(cassPath, recorder.WithTransportOptions(opts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop()
+
+	resp, err := rec.GetDefaultClient().Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if dials.Load() == 0 {
+		t.Fatal("expected custom dialer to be used")
+	}
+}
@@ -0,0 +1,52 @@
+package recorder
+
+import (
+	"net/http"
+
+	"github.com/goware/go-vcr/cassette"
+)
+
+// UncompressedPolicy specifies how responses, which were transparently
+// decompressed by the [http.Transport] when recording, are presented to the
+// client, see [WithUncompressedPolicy].
+type UncompressedPolicy int
+
+const (
+	// UncompressedAsRecorded presents such responses as recorded, which is
+	// the default.
+	UncompressedAsRecorded UncompressedPolicy = iota
+
+	// UncompressedRecompress presents such responses gzip-compressed, with
+	// their Content-Encoding header restored, see
+	// [cassette.RecompressResponse].
+	UncompressedRecompress
+
+	// UncompressedTransparent presents such responses decompressed, and
+	// without Content-Encoding and Content-Length headers, as the
+	// [http.Transport] does, see [cassette.UncompressResponse].
+	UncompressedTransparent
+)
+
+// WithUncompressedPolicy is an [Option], which configures how the
+// [Recorder] presents responses, which were recorded with the Uncompressed
+// flag set, because the [http.Transport] requested and transparently
+// decompressed a gzip-compressed body. The policy is applied both when
+// recording and when replaying, so that the client sees the same response
+// either way.
+func WithUncompressedPolicy(policy UncompressedPolicy) Option {
+	return func(r *Recorder) {
+		r.uncompressedPolicy = policy
+	}
+}
+
+// applyUncompressedPolicy applies the policy to the response, see
+// [WithUncompressedPolicy].
+func (rec *Recorder) applyUncompressedPolicy(resp *http.Response) error {
+	switch rec.uncompressedPolicy {
+	case UncompressedRecompress:
+		return cassette.RecompressResponse(resp)
+	case UncompressedTransparent:
+		return cassette.UncompressResponse(resp)
+	}
+	return nil
+}
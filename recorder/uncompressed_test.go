@@ -0,0 +1,97 @@
+package recorder_test
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/goware/go-vcr/cassette"
+	"github.com/goware/go-vcr/recorder"
+)
+
+func TestUncompressedPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			t.Errorf("got Accept-Encoding %q, want gzip", r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		io.WriteString(zw, "hello")
+		zw.Close()
+	}))
+	defer server.Close()
+
+	cassPath, err := newCassettePath("test_uncompressed_policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(rec *recorder.Recorder) (*http.Response, string) {
+		t.Helper()
+		resp, err := rec.GetDefaultClient().Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body := io.Reader(resp.Body)
+		if resp.Header.Get("Content-Encoding") == "gzip" {
+			if body, err = gzip.NewReader(resp.Body); err != nil {
+				t.Fatal(err)
+			}
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, string(data)
+	}
+
+	// The transport requests and decompresses the gzip-compressed body
+	rec, err := recorder.New(cassPath, recorder.WithMode(recorder.ModeRecordOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, body := get(rec); !resp.Uncompressed || body != "hello" {
+		t.Fatalf("got uncompressed %t and body %q, want decompressed body", resp.Uncompressed, body)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cassette.Load(cassPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Interactions[0].Response.Uncompressed {
+		t.Fatal("expected the response to be recorded as uncompressed")
+	}
+
+	tests := []struct {
+		name   string
+		policy recorder.UncompressedPolicy
+		want   string
+	}{
+		{name: "recompress", policy: recorder.UncompressedRecompress, want: "gzip"},
+		{name: "transparent", policy: recorder.UncompressedTransparent, want: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec, err := recorder.New(cassPath, recorder.WithMode(recorder.ModeReplayOnly), recorder.WithUncompressedPolicy(test.policy))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rec.Stop()
+
+			resp, body := get(rec)
+			if got := resp.Header.Get("Content-Encoding"); got != test.want || body != "hello" {
+				t.Fatalf("got Content-Encoding %q and body %q, want %q and %q", got, body, test.want, "hello")
+			}
+			if resp.Uncompressed != (test.policy == recorder.UncompressedTransparent) {
+				t.Fatalf("got uncompressed %t", resp.Uncompressed)
+			}
+		})
+	}
+}
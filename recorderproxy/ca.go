@@ -0,0 +1,139 @@
+package recorderproxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+)
+
+// NewCA generates a new self-signed certificate authority, which is used by
+// the [Proxy] to issue certificates for the intercepted hosts. Clients must
+// trust the certificate of the CA, see [Proxy.CACertificatePEM].
+func NewCA() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate CA key: %w", err)
+	}
+
+	serial, err := newSerialNumber()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"go-vcr"}, CommonName: "go-vcr recorder proxy CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, nil
+}
+
+// certIssuer issues and caches leaf certificates for the intercepted hosts.
+type certIssuer struct {
+	mu    sync.Mutex
+	ca    tls.Certificate
+	certs map[string]*tls.Certificate
+}
+
+func newCertIssuer(ca tls.Certificate) (*certIssuer, error) {
+	if ca.Leaf == nil {
+		if len(ca.Certificate) == 0 {
+			return nil, fmt.Errorf("CA has no certificate")
+		}
+		leaf, err := x509.ParseCertificate(ca.Certificate[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+		}
+		ca.Leaf = leaf
+	}
+
+	return &certIssuer{ca: ca, certs: make(map[string]*tls.Certificate)}, nil
+}
+
+// certificate returns the certificate for the given host, issuing it when
+// needed.
+func (ci *certIssuer) certificate(host string) (*tls.Certificate, error) {
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+
+	if cert, ok := ci.certs[host]; ok {
+		return cert, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key for %s: %w", host, err)
+	}
+
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{Organization: []string{"go-vcr"}, CommonName: host},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.AddDate(0, 1, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ci.ca.Leaf, &key.PublicKey, ci.ca.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate for %s: %w", host, err)
+	}
+
+	cert := &tls.Certificate{
+		Certificate: [][]byte{der, ci.ca.Certificate[0]},
+		PrivateKey:  key,
+	}
+	ci.certs[host] = cert
+
+	return cert, nil
+}
+
+// certificatePEM returns the PEM encoded certificate of the CA.
+func (ci *certIssuer) certificatePEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ci.ca.Certificate[0]})
+}
+
+func newSerialNumber() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	return serial, nil
+}
@@ -0,0 +1,254 @@
+// Package recorderproxy runs a [recorder.Recorder] as an HTTP(S) forward
+// proxy, so that traffic of processes, which are not written in Go, e.g.
+// CLIs or containers under test, can be recorded into and replayed from
+// cassettes.
+//
+// HTTPS traffic is intercepted by terminating the TLS connections at the
+// proxy, using certificates issued on the fly by a certificate authority,
+// which the clients have to trust. See [Proxy.CACertificatePEM].
+package recorderproxy
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/goware/go-vcr/cassette"
+	"github.com/goware/go-vcr/recorder"
+)
+
+// hopByHopHeaders are the headers, which apply to a single connection, and
+// must not be forwarded by proxies, as per RFC 9110, section 7.6.1.
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// Proxy is an HTTP(S) forward proxy, which sends the proxied requests
+// through a [recorder.Recorder].
+type Proxy struct {
+	// recorder records and replays the proxied requests
+	recorder *recorder.Recorder
+
+	// ca is the certificate authority used to intercept TLS connections
+	ca *tls.Certificate
+
+	// issuer issues the certificates for the intercepted hosts
+	issuer *certIssuer
+}
+
+// Option is a function which configures the [Proxy].
+type Option func(p *Proxy)
+
+// WithCA is an [Option], which configures the [Proxy] to issue certificates
+// for the intercepted hosts using the given certificate authority, instead
+// of generating a new one. This allows clients to trust the CA once, e.g.
+// by baking it into a container image.
+func WithCA(ca tls.Certificate) Option {
+	return func(p *Proxy) {
+		p.ca = &ca
+	}
+}
+
+// New creates a new [Proxy], which sends the proxied requests through the
+// given recorder.
+func New(rec *recorder.Recorder, opts ...Option) (*Proxy, error) {
+	p := &Proxy{
+		recorder: rec,
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	if p.ca == nil {
+		ca, err := NewCA()
+		if err != nil {
+			return nil, err
+		}
+		p.ca = &ca
+	}
+
+	var err error
+	p.issuer, err = newCertIssuer(*p.ca)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// CACertificatePEM returns the PEM encoded certificate of the certificate
+// authority, which clients must trust in order to proxy HTTPS requests.
+func (p *Proxy) CACertificatePEM() []byte {
+	return p.issuer.certificatePEM()
+}
+
+// ServeHTTP implements the [http.Handler] interface.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.serveConnect(w, r)
+		return
+	}
+
+	if !r.URL.IsAbs() {
+		http.Error(w, "recorderproxy: not a proxy request", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := p.roundTrip(r, "http")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("recorderproxy: %s", err), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for k, values := range resp.Header {
+		w.Header()[k] = values
+	}
+	removeHopByHopHeaders(w.Header())
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+// serveConnect intercepts the TLS connection tunneled using the CONNECT
+// method, and serves the HTTP requests sent over it.
+func (p *Proxy) serveConnect(w http.ResponseWriter, r *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "recorderproxy: connection cannot be hijacked", http.StatusInternalServerError)
+		return
+	}
+
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("recorderproxy: %s", err), http.StatusInternalServerError)
+		return
+	}
+	defer conn.Close()
+
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		return
+	}
+
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+
+	tlsConn := tls.Server(&bufferedConn{Conn: conn, r: buf.Reader}, &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if hello.ServerName != "" {
+				return p.issuer.certificate(hello.ServerName)
+			}
+			return p.issuer.certificate(host)
+		},
+		// The requests are read using the HTTP/1.1 parser
+		NextProtos: []string{"http/1.1"},
+	})
+	if err := tlsConn.HandshakeContext(r.Context()); err != nil {
+		slog.Warn("recorderproxy: TLS handshake failed", "host", r.Host, "error", err)
+		return
+	}
+
+	reader := bufio.NewReader(tlsConn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				slog.Warn("recorderproxy: failed to read request", "host", r.Host, "error", err)
+			}
+			return
+		}
+		req.URL.Scheme = "https"
+		req.URL.Host = r.Host
+
+		resp, err := p.roundTrip(req.WithContext(r.Context()), "https")
+		if err != nil {
+			body := fmt.Sprintf("recorderproxy: %s\n", err)
+			resp = &http.Response{
+				StatusCode:    http.StatusBadGateway,
+				ProtoMajor:    1,
+				ProtoMinor:    1,
+				Header:        http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+				ContentLength: int64(len(body)),
+				Body:          io.NopCloser(strings.NewReader(body)),
+			}
+		}
+
+		// The response is written over an HTTP/1.1 connection, which is
+		// kept alive, so bodies of unknown length must be chunked.
+		cassette.DowngradeToHTTP1(resp)
+		removeHopByHopHeaders(resp.Header)
+		resp.Close = req.Close
+		if resp.ContentLength < 0 {
+			resp.TransferEncoding = []string{"chunked"}
+		}
+		err = resp.Write(tlsConn)
+		resp.Body.Close()
+		if err != nil || req.Close {
+			return
+		}
+	}
+}
+
+// roundTrip sends the proxied request through the recorder.
+func (p *Proxy) roundTrip(r *http.Request, scheme string) (*http.Response, error) {
+	// Turn the server request into a client request, which matches the
+	// requests sent directly by Go clients.
+	r.RequestURI = ""
+	r.RemoteAddr = ""
+	r.URL.Host = stripDefaultPort(r.URL.Host, scheme)
+	r.Host = stripDefaultPort(r.Host, scheme)
+	removeHopByHopHeaders(r.Header)
+
+	return p.recorder.RoundTrip(r)
+}
+
+// stripDefaultPort removes the default port of the scheme from the host.
+func stripDefaultPort(host, scheme string) string {
+	switch {
+	case scheme == "https" && strings.HasSuffix(host, ":443"):
+		return strings.TrimSuffix(host, ":443")
+	case scheme == "http" && strings.HasSuffix(host, ":80"):
+		return strings.TrimSuffix(host, ":80")
+	}
+	return host
+}
+
+// removeHopByHopHeaders removes the hop-by-hop headers, including the ones
+// listed in the Connection header.
+func removeHopByHopHeaders(h http.Header) {
+	for _, v := range h.Values("Connection") {
+		for _, name := range strings.Split(v, ",") {
+			h.Del(strings.TrimSpace(name))
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		h.Del(name)
+	}
+}
+
+// bufferedConn is a [net.Conn], which reads the data already buffered when
+// hijacking the connection first.
+type bufferedConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
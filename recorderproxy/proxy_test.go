@@ -0,0 +1,151 @@
+package recorderproxy_test
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/goware/go-vcr/recorder"
+	"github.com/goware/go-vcr/recorderproxy"
+)
+
+func newCassettePath(t *testing.T, name string) string {
+	t.Helper()
+
+	dir, err := os.MkdirTemp(os.TempDir(), "go-vcr-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return path.Join(dir, name)
+}
+
+// newProxyClient starts a proxy for the recorder, and returns a client
+// sending its requests through the proxy.
+func newProxyClient(t *testing.T, rec *recorder.Recorder) *http.Client {
+	t.Helper()
+
+	proxy, err := recorderproxy.New(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(proxy)
+	t.Cleanup(server.Close)
+
+	proxyURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(proxy.CACertificatePEM()) {
+		t.Fatal("failed to add CA certificate")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxyURL)
+	transport.TLSClientConfig.RootCAs = roots
+	t.Cleanup(transport.CloseIdleConnections)
+
+	return &http.Client{Transport: transport}
+}
+
+func get(client *http.Client, url string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("got status %d: %s", resp.StatusCode, body)
+	}
+
+	return string(body), nil
+}
+
+func TestProxy(t *testing.T) {
+	tests := []struct {
+		name      string
+		newServer func(handler http.Handler) *httptest.Server
+	}{
+		{name: "http", newServer: httptest.NewServer},
+		{name: "https", newServer: httptest.NewTLSServer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			server := tt.newServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				fmt.Fprintf(w, "%s %s go-vcr", r.Method, r.URL.Path)
+			}))
+			serverURL := server.URL
+			cassPath := newCassettePath(t, "test_proxy_"+tt.name)
+
+			// Record
+			rec, err := recorder.New(
+				cassPath,
+				recorder.WithMode(recorder.ModeRecordOnly),
+				recorder.WithRealTransport(server.Client().Transport),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			client := newProxyClient(t, rec)
+			for _, p := range []string{"/foo", "/bar"} {
+				body, err := get(client, serverURL+p)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := "GET " + p + " go-vcr"; body != want {
+					t.Fatalf("got body %q, want %q", body, want)
+				}
+			}
+
+			if err := rec.Stop(); err != nil {
+				t.Fatal(err)
+			}
+			server.Close()
+
+			// Replay with the server gone
+			rec, err = recorder.New(cassPath, recorder.WithMode(recorder.ModeReplayOnly))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rec.Stop()
+
+			client = newProxyClient(t, rec)
+			for _, p := range []string{"/foo", "/bar"} {
+				body, err := get(client, serverURL+p)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := "GET " + p + " go-vcr"; body != want {
+					t.Fatalf("got body %q, want %q", body, want)
+				}
+			}
+
+			if requests != 2 {
+				t.Fatalf("got %d server requests, want 2", requests)
+			}
+
+			// Requests missing from the cassette are reported as bad gateway
+			if _, err := get(client, serverURL+"/baz"); err == nil {
+				t.Fatal("expected error for unrecorded request")
+			}
+		})
+	}
+}
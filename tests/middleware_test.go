@@ -53,14 +53,9 @@ func TestMiddleware(t *testing.T) {
 			recorder.WithMode(recorder.ModeRecordOnly),
 			// Use basicRequestHasher for stable hashes across test runs with random ports
 			recorder.WithHasher(basicRequestHasher),
-			// Use a BeforeSaveHook to remove host, remote_addr, and duration
-			// since they change whenever the test runs
-			recorder.WithHook(func(i *cassette.Interaction) error {
-				i.Request.Host = ""
-				i.Request.RemoteAddr = ""
-				i.Response.Duration = 0
-				return nil
-			}, recorder.BeforeSaveHook),
+			// Remove host, remote_addr, duration and recorded_at since they
+			// change whenever the test runs
+			recorder.WithStripVolatileFields(),
 		)
 		if err != nil {
 			t.Errorf("error creating recorder: %v", err)